@@ -0,0 +1,248 @@
+//go:build windows
+// +build windows
+
+package taskmaster
+
+import (
+	"errors"
+	"fmt"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// archiveSecurityInformation are the parts of the security descriptors of
+// folders and tasks that are stored in a FolderArchive. The SACL isn't included,
+// as reading it requires the SeSecurityPrivilege.
+const archiveSecurityInformation = OWNER_SECURITY_INFORMATION | GROUP_SECURITY_INFORMATION | DACL_SECURITY_INFORMATION
+
+// ExportFolder exports the task folder at path, all of its subfolders and all of
+// their tasks, including hidden tasks, to a FolderArchive. Tasks are stored as
+// XML along with their logon type and security descriptor, and folders are
+// stored with their security descriptor.
+func (t *TaskService) ExportFolder(path string) (FolderArchive, error) {
+	if path[0] != '\\' {
+		return FolderArchive{}, ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return FolderArchive{}, err
+	}
+	defer folderObj.Release()
+
+	archive := FolderArchive{Path: path}
+	if err = t.exportFolder(folderObj, path, "", &archive); err != nil {
+		return FolderArchive{}, err
+	}
+
+	return archive, nil
+}
+
+// exportFolder adds the folder at path, whose path relative to the exported
+// folder is relPath, to archive, and then its tasks and subfolders.
+func (t *TaskService) exportFolder(folderObj *ole.IDispatch, path, relPath string, archive *FolderArchive) error {
+	sddl, err := t.callMethod(folderObj, "GetSecurityDescriptor", int(archiveSecurityInformation))
+	if err != nil {
+		return fmt.Errorf("error getting security descriptor of folder %s: %w", path, getTaskSchedulerPathError(err, "GetSecurityDescriptor", path))
+	}
+	archive.Folders = append(archive.Folders, ArchivedFolder{
+		Path: relPath,
+		SDDL: sddl.ToString(),
+	})
+
+	err = t.forEachTaskInFolder(folderObj, TASK_ENUM_HIDDEN, func(task *ole.IDispatch) error {
+		defer task.Release()
+
+		archivedTask, err := t.exportTask(task, relPath)
+		if err != nil {
+			return err
+		}
+		archive.Tasks = append(archive.Tasks, archivedTask)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := t.callMethod(folderObj, "GetFolders", 0)
+	if err != nil {
+		return fmt.Errorf("error getting subfolders of folder %s: %w", path, getTaskSchedulerPathError(err, "GetFolders", path))
+	}
+	taskFolderList := res.ToIDispatch()
+	defer taskFolderList.Release()
+
+	return t.forEach(taskFolderList, func(v *ole.VARIANT) error {
+		taskFolder := v.ToIDispatch()
+		defer taskFolder.Release()
+
+		name, err := oleutil.GetProperty(taskFolder, "Name")
+		if err != nil {
+			return fmt.Errorf("error getting name of folder: %w", getTaskSchedulerError(err))
+		}
+
+		return t.exportFolder(taskFolder, joinTaskPath(path, name.ToString()), joinArchivePath(relPath, name.ToString()), archive)
+	})
+}
+
+func (t *TaskService) exportTask(task *ole.IDispatch, folderRelPath string) (ArchivedTask, error) {
+	pathVar, err := oleutil.GetProperty(task, "Path")
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting path of registered task: %w", getTaskSchedulerError(err))
+	}
+	path := pathVar.ToString()
+
+	name, err := oleutil.GetProperty(task, "Name")
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting name of registered task %s: %w", path, getTaskSchedulerPathError(err, "Name", path))
+	}
+	xml, err := oleutil.GetProperty(task, "Xml")
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting XML of registered task %s: %w", path, getTaskSchedulerPathError(err, "Xml", path))
+	}
+	sddl, err := t.callMethod(task, "GetSecurityDescriptor", int(archiveSecurityInformation))
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting security descriptor of registered task %s: %w", path, getTaskSchedulerPathError(err, "GetSecurityDescriptor", path))
+	}
+
+	definition, err := oleutil.GetProperty(task, "Definition")
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting definition of registered task %s: %w", path, getTaskSchedulerPathError(err, "Definition", path))
+	}
+	definitionObj := definition.ToIDispatch()
+	defer definitionObj.Release()
+	principal, err := oleutil.GetProperty(definitionObj, "Principal")
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting principal of registered task %s: %w", path, getTaskSchedulerPathError(err, "Principal", path))
+	}
+	principalObj := principal.ToIDispatch()
+	defer principalObj.Release()
+	logonType, err := oleutil.GetProperty(principalObj, "LogonType")
+	if err != nil {
+		return ArchivedTask{}, fmt.Errorf("error getting logon type of registered task %s: %w", path, getTaskSchedulerPathError(err, "LogonType", path))
+	}
+
+	return ArchivedTask{
+		Path:      joinArchivePath(folderRelPath, name.ToString()),
+		XML:       xml.ToString(),
+		LogonType: TaskLogonType(logonType.Val),
+		SDDL:      sddl.ToString(),
+	}, nil
+}
+
+// ImportFolder recreates the folders and tasks of archive, and returns the paths
+// of the tasks that were registered. Existing folders are reused, and tasks that
+// already exist are handled according to opts.Conflict. If some folders or tasks
+// could not be imported, the remaining ones are still imported and an error
+// combining every failure is returned along with the paths of the tasks that
+// were registered. Tasks that use TASK_LOGON_PASSWORD cannot be imported, as
+// their password isn't stored in the archive.
+func (t *TaskService) ImportFolder(archive FolderArchive, opts ImportFolderOptions) ([]string, error) {
+	path := opts.Path
+	if path == "" {
+		path = archive.Path
+	}
+	if path == "" || path[0] != '\\' {
+		return nil, ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	for _, folder := range archive.Folders {
+		if err := t.importFolder(joinTaskPath(path, folder.Path), folder.SDDL, opts.RestoreSecurity); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var imported []string
+	for _, task := range archive.Tasks {
+		taskPath := joinTaskPath(path, task.Path)
+		flags := TASK_CREATE
+		if t.registeredTaskExist(taskPath) {
+			switch opts.Conflict {
+			case IMPORT_CONFLICT_OVERWRITE:
+				flags = TASK_CREATE_OR_UPDATE
+			case IMPORT_CONFLICT_RENAME:
+				taskPath = t.unusedTaskPath(taskPath)
+			default:
+				continue
+			}
+		}
+
+		var sddl string
+		if opts.RestoreSecurity {
+			sddl = task.SDDL
+		}
+		res, err := t.callMethod(t.rootFolderObj, "RegisterTask", taskPath, task.XML, int(flags), "", "", int(task.LogonType), sddl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error registering task %s: %w", taskPath, getTaskSchedulerPathError(err, "RegisterTask", taskPath)))
+			continue
+		}
+		res.ToIDispatch().Release()
+		imported = append(imported, taskPath)
+	}
+
+	return imported, errors.Join(errs...)
+}
+
+// importFolder creates the folder at path if it doesn't exist. If restoreSecurity
+// is true, the security descriptor of the folder is set to sddl.
+func (t *TaskService) importFolder(path, sddl string, restoreSecurity bool) error {
+	if !restoreSecurity {
+		sddl = ""
+	}
+
+	folderObj, err := t.getFolderObj(path)
+	if errors.Is(err, ErrFolderNotFound) {
+		return t.createFolder(path, sddl)
+	} else if err != nil {
+		return err
+	}
+	defer folderObj.Release()
+
+	if sddl != "" {
+		_, err = t.callMethod(folderObj, "SetSecurityDescriptor", sddl, 0)
+		if err != nil {
+			return fmt.Errorf("error setting security descriptor of folder %s: %w", path, getTaskSchedulerPathError(err, "SetSecurityDescriptor", path))
+		}
+	}
+
+	return nil
+}
+
+// unusedTaskPath returns path with the lowest number suffix, starting at 2, that
+// no registered task exists at.
+func (t *TaskService) unusedTaskPath(path string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", path, i)
+		if !t.registeredTaskExist(candidate) {
+			return candidate
+		}
+	}
+}
+
+// joinTaskPath joins the path of a folder with a path relative to it.
+func joinTaskPath(folderPath, relPath string) string {
+	if relPath == "" {
+		return folderPath
+	} else if folderPath == `\` {
+		return `\` + relPath
+	}
+
+	return folderPath + `\` + relPath
+}
+
+// joinArchivePath joins two relative paths of a FolderArchive.
+func joinArchivePath(relPath, name string) string {
+	if relPath == "" {
+		return name
+	}
+
+	return relPath + `\` + name
+}
@@ -0,0 +1,359 @@
+package taskmaster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+// Accounts that a task can run as with TaskBuilder.RunAs without a password.
+const (
+	SYSTEM          = "SYSTEM"
+	LOCAL_SERVICE   = "LOCAL SERVICE"
+	NETWORK_SERVICE = "NETWORK SERVICE"
+)
+
+// NewServiceAccountPrincipal returns a principal that runs a task as account,
+// which must be SYSTEM, LOCAL_SERVICE or NETWORK_SERVICE, optionally prefixed
+// with the NT AUTHORITY domain. The task runs whether or not a user is logged on,
+// and no password is needed to register it.
+func NewServiceAccountPrincipal(account string) (Principal, error) {
+	if !isServiceAccount(account) {
+		return Principal{}, fmt.Errorf("%q is not a service account", account)
+	}
+
+	return Principal{
+		LogonType:           TASK_LOGON_SERVICE_ACCOUNT,
+		ProcessTokenSidType: TASK_PROCESSTOKENSID_DEFAULT,
+		UserID:              account,
+	}, nil
+}
+
+// NewManagedServiceAccountPrincipal returns a principal that runs a task as
+// account, a group managed service account (gMSA) such as `DOMAIN\account$`. The
+// task runs whether or not a user is logged on. gMSAs use TASK_LOGON_PASSWORD,
+// but the task must be registered without a password, as the password of the
+// account is managed by Active Directory.
+func NewManagedServiceAccountPrincipal(account string) (Principal, error) {
+	if !isManagedServiceAccount(account) {
+		return Principal{}, fmt.Errorf("%q is not a managed service account: the account name must end with $", account)
+	}
+
+	return Principal{
+		LogonType:           TASK_LOGON_PASSWORD,
+		ProcessTokenSidType: TASK_PROCESSTOKENSID_DEFAULT,
+		UserID:              account,
+	}, nil
+}
+
+func isServiceAccount(user string) bool {
+	switch strings.TrimPrefix(strings.ToUpper(user), `NT AUTHORITY\`) {
+	case SYSTEM, LOCAL_SERVICE, NETWORK_SERVICE:
+		return true
+	default:
+		return false
+	}
+}
+
+func isManagedServiceAccount(user string) bool {
+	return len(user) > 1 && strings.HasSuffix(user, "$")
+}
+
+// TaskBuilder builds a Definition step by step, starting from the Task Scheduler
+// default values. Errors are deferred until Build is called, so calls can be
+// chained:
+//
+//	def, err := taskmaster.NewTaskBuilder().
+//		Exec("cmd.exe", "/c", "backup.bat").
+//		DailyAt(3, 0).
+//		RunAs(taskmaster.SYSTEM).
+//		Build()
+type TaskBuilder struct {
+	def Definition
+	err error
+}
+
+// NewTaskBuilder returns a TaskBuilder for a definition with the same default
+// values as one returned by TaskService.NewTaskDefinition.
+func NewTaskBuilder() *TaskBuilder {
+	return &TaskBuilder{
+		def: defaultDefinition(),
+	}
+}
+
+// NewDefinition returns a definition with the Task Scheduler default values.
+// Unlike TaskService.NewTaskDefinition, the author of the definition isn't set.
+func NewDefinition() Definition {
+	return defaultDefinition()
+}
+
+// defaultDefinition returns a definition with the Task Scheduler default values.
+func defaultDefinition() Definition {
+	var newDef Definition
+
+	newDef.Principal.LogonType = TASK_LOGON_INTERACTIVE_TOKEN
+	newDef.Principal.ProcessTokenSidType = TASK_PROCESSTOKENSID_DEFAULT
+	newDef.Principal.RunLevel = TASK_RUNLEVEL_LUA
+
+	newDef.RegistrationInfo.Date = time.Now()
+
+	newDef.Settings.AllowDemandStart = true
+	newDef.Settings.AllowHardTerminate = true
+	newDef.Settings.Compatibility = TASK_COMPATIBILITY_V2
+	newDef.Settings.DontStartOnBatteries = true
+	newDef.Settings.Enabled = true
+	newDef.Settings.Hidden = false
+	newDef.Settings.IdleSettings.IdleDuration = period.NewHMS(0, 10, 0) // PT10M
+	newDef.Settings.IdleSettings.WaitTimeout = period.NewHMS(1, 0, 0)   // PT1H
+	newDef.Settings.MaintenanceSettings = nil                           // automatic maintenance is disabled
+	newDef.Settings.MultipleInstances = TASK_INSTANCES_IGNORE_NEW
+	newDef.Settings.Priority = 7
+	newDef.Settings.RestartCount = 0
+	newDef.Settings.RestartOnIdle = false
+	newDef.Settings.RunOnlyIfIdle = false
+	newDef.Settings.RunOnlyIfNetworkAvailable = false
+	newDef.Settings.StartWhenAvailable = false
+	newDef.Settings.StopIfGoingOnBatteries = true
+	newDef.Settings.StopOnIdleEnd = true
+	newDef.Settings.TimeLimit = period.NewHMS(72, 0, 0) // PT72H
+	newDef.Settings.WakeToRun = false
+
+	return newDef
+}
+
+// Exec adds an ExecAction that runs path with args. Arguments that contain
+// spaces or quotes are quoted so they are passed to the program unchanged.
+func (b *TaskBuilder) Exec(path string, args ...string) *TaskBuilder {
+	quotedArgs := make([]string, len(args))
+	for i, arg := range args {
+		quotedArgs[i] = quoteArg(arg)
+	}
+
+	b.def.AddAction(ExecAction{
+		Path: path,
+		Args: strings.Join(quotedArgs, " "),
+	})
+
+	return b
+}
+
+// ExecIn is like Exec, but the program is started in workingDir.
+func (b *TaskBuilder) ExecIn(workingDir, path string, args ...string) *TaskBuilder {
+	b.Exec(path, args...)
+	action := b.def.Actions[len(b.def.Actions)-1].(ExecAction)
+	action.WorkingDir = workingDir
+	b.def.Actions[len(b.def.Actions)-1] = action
+
+	return b
+}
+
+// ComHandler adds a ComHandlerAction that starts the COM handler classID with data.
+func (b *TaskBuilder) ComHandler(classID, data string) *TaskBuilder {
+	b.def.AddAction(ComHandlerAction{
+		ClassID: classID,
+		Data:    data,
+	})
+
+	return b
+}
+
+// At adds a TimeTrigger that runs the task once at start.
+func (b *TaskBuilder) At(start time.Time) *TaskBuilder {
+	trigger, err := NewTimeTrigger(start)
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+
+	b.def.AddTrigger(trigger)
+	return b
+}
+
+// AtBoot adds a BootTrigger.
+func (b *TaskBuilder) AtBoot() *TaskBuilder {
+	b.def.AddTrigger(BootTrigger{TaskTrigger: newTaskTrigger(time.Time{}, nil)})
+	return b
+}
+
+// AtLogon adds a LogonTrigger that fires when userID logs on, or when any user
+// logs on if userID is empty.
+func (b *TaskBuilder) AtLogon(userID string) *TaskBuilder {
+	b.def.AddTrigger(NewLogonTrigger(userID))
+	return b
+}
+
+// DailyAt adds a DailyTrigger that runs the task every day at hour:minute,
+// local time, starting today.
+func (b *TaskBuilder) DailyAt(hour, minute int) *TaskBuilder {
+	start, err := timeOfDayToday(hour, minute)
+	if err != nil {
+		b.setErr(fmt.Errorf("invalid DailyAt: %v", err))
+		return b
+	}
+
+	b.def.AddTrigger(DailyTrigger{TaskTrigger: newTaskTrigger(start, nil), DayInterval: EveryDay})
+	return b
+}
+
+// WeeklyAt adds a WeeklyTrigger that runs the task every week on daysOfWeek at
+// hour:minute, local time, starting today.
+func (b *TaskBuilder) WeeklyAt(daysOfWeek DayOfWeek, hour, minute int) *TaskBuilder {
+	start, err := timeOfDayToday(hour, minute)
+	if err != nil {
+		b.setErr(fmt.Errorf("invalid WeeklyAt: %v", err))
+		return b
+	}
+
+	trigger, err := NewWeeklyTrigger(start, EveryWeek, daysOfWeek)
+	if err != nil {
+		b.setErr(fmt.Errorf("invalid WeeklyAt: %v", err))
+		return b
+	}
+
+	b.def.AddTrigger(trigger)
+	return b
+}
+
+// Trigger adds trigger, for schedules that the other methods of TaskBuilder
+// don't cover.
+func (b *TaskBuilder) Trigger(trigger Trigger) *TaskBuilder {
+	b.def.AddTrigger(trigger)
+	return b
+}
+
+// RunAs makes the task run as user. If user is SYSTEM, LOCAL_SERVICE or
+// NETWORK_SERVICE, or a group managed service account whose name ends with $,
+// the task runs whether or not a user is logged on, and is registered without a
+// password. Otherwise the task only runs while user is logged on, unless a
+// password is passed when the task is registered.
+func (b *TaskBuilder) RunAs(user string) *TaskBuilder {
+	b.def.Principal.UserID = user
+	b.def.Principal.GroupID = ""
+	switch {
+	case isServiceAccount(user):
+		b.def.Principal.LogonType = TASK_LOGON_SERVICE_ACCOUNT
+	case isManagedServiceAccount(user):
+		b.def.Principal.LogonType = TASK_LOGON_PASSWORD
+	default:
+		b.def.Principal.LogonType = TASK_LOGON_INTERACTIVE_TOKEN
+	}
+
+	return b
+}
+
+// RunAsGroup makes the task run as any logged on member of group.
+func (b *TaskBuilder) RunAsGroup(group string) *TaskBuilder {
+	b.def.Principal.GroupID = group
+	b.def.Principal.UserID = ""
+	b.def.Principal.LogonType = TASK_LOGON_GROUP
+
+	return b
+}
+
+// HighestPrivileges makes the task run with the highest privileges of its user.
+func (b *TaskBuilder) HighestPrivileges() *TaskBuilder {
+	b.def.Principal.RunLevel = TASK_RUNLEVEL_HIGHEST
+	return b
+}
+
+// RequireNetwork makes the task run only when the network profile identified by
+// network is available. If network is the zero value, any network will do.
+func (b *TaskBuilder) RequireNetwork(network NetworkSettings) *TaskBuilder {
+	b.def.Settings.RunOnlyIfNetworkAvailable = true
+	b.def.Settings.NetworkSettings = network
+	return b
+}
+
+// Author sets the author of the task.
+func (b *TaskBuilder) Author(author string) *TaskBuilder {
+	b.def.RegistrationInfo.Author = author
+	return b
+}
+
+// Description sets the description of the task.
+func (b *TaskBuilder) Description(description string) *TaskBuilder {
+	b.def.RegistrationInfo.Description = description
+	return b
+}
+
+// TimeLimit sets how long the task may run before it's stopped. A zero period
+// lets the task run indefinitely.
+func (b *TaskBuilder) TimeLimit(timeLimit period.Period) *TaskBuilder {
+	b.def.Settings.TimeLimit = timeLimit
+	return b
+}
+
+// Hidden hides the task in the Task Scheduler UI.
+func (b *TaskBuilder) Hidden() *TaskBuilder {
+	b.def.Settings.Hidden = true
+	return b
+}
+
+// Disabled registers the task disabled, so that its triggers don't fire.
+func (b *TaskBuilder) Disabled() *TaskBuilder {
+	b.def.Settings.Enabled = false
+	return b
+}
+
+// Build returns the definition, or the first error encountered while building
+// it. The definition is validated before being returned.
+func (b *TaskBuilder) Build() (Definition, error) {
+	if b.err != nil {
+		return Definition{}, b.err
+	}
+	if err := validateDefinition(b.def); err != nil {
+		return Definition{}, err
+	}
+
+	return b.def, nil
+}
+
+func (b *TaskBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func timeOfDayToday(hour, minute int) (time.Time, error) {
+	if hour < 0 || hour > 23 {
+		return time.Time{}, fmt.Errorf("hour %d is not between 0 and 23", hour)
+	}
+	if minute < 0 || minute > 59 {
+		return time.Time{}, fmt.Errorf("minute %d is not between 0 and 59", minute)
+	}
+
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.Local), nil
+}
+
+// quoteArg quotes arg the way the Microsoft C runtime expects command line
+// arguments to be quoted.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+
+	var buf strings.Builder
+	buf.WriteByte('"')
+	backslashes := 0
+	for _, c := range arg {
+		switch c {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// backslashes before a quote have to be escaped, as well as the quote
+			backslashes = backslashes*2 + 1
+		}
+		buf.WriteString(strings.Repeat(`\`, backslashes))
+		backslashes = 0
+		buf.WriteRune(c)
+	}
+	// backslashes before the closing quote have to be escaped
+	buf.WriteString(strings.Repeat(`\`, backslashes*2))
+	buf.WriteByte('"')
+
+	return buf.String()
+}
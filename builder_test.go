@@ -0,0 +1,104 @@
+package taskmaster
+
+import (
+	"testing"
+)
+
+func TestTaskBuilder(t *testing.T) {
+	def, err := NewTaskBuilder().
+		Exec("cmd.exe", "/c", `C:\Program Files\backup.bat`).
+		DailyAt(3, 0).
+		RunAs(SYSTEM).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(def.Actions) != 1 || def.Actions[0].(ExecAction).Args != `/c "C:\Program Files\backup.bat"` {
+		t.Fatalf("unexpected actions: %+v", def.Actions)
+	}
+	if len(def.Triggers) != 1 {
+		t.Fatalf("unexpected triggers: %+v", def.Triggers)
+	}
+	trigger := def.Triggers[0].(DailyTrigger)
+	if trigger.DayInterval != EveryDay || trigger.StartBoundary.Hour() != 3 || trigger.StartBoundary.Minute() != 0 {
+		t.Fatalf("unexpected trigger: %+v", trigger)
+	}
+	if def.Principal.UserID != SYSTEM || def.Principal.LogonType != TASK_LOGON_SERVICE_ACCOUNT {
+		t.Fatalf("unexpected principal: %+v", def.Principal)
+	}
+	if !def.Settings.Enabled || def.Settings.Priority != 7 {
+		t.Fatalf("default settings weren't set: %+v", def.Settings)
+	}
+
+	if _, err = NewTaskBuilder().Exec("cmd.exe").DailyAt(24, 0).Build(); err == nil {
+		t.Fatal("building with an invalid hour should fail")
+	}
+	if _, err = NewTaskBuilder().AtBoot().Build(); err != ErrNoActions {
+		t.Fatalf("expected ErrNoActions, got %v", err)
+	}
+
+	network := NetworkSettings{ID: "{6C9A8E1F-35B2-4C3D-9F41-0A6B2D7E5C18}", Name: "Office"}
+	def, err = NewTaskBuilder().Exec("cmd.exe").RequireNetwork(network).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !def.Settings.RunOnlyIfNetworkAvailable || def.Settings.NetworkSettings != network {
+		t.Fatalf("network settings weren't set: %+v", def.Settings)
+	}
+	if _, err = NewTaskBuilder().Exec("cmd.exe").RequireNetwork(NetworkSettings{ID: "Office"}).Build(); err == nil {
+		t.Fatal("building with an invalid network profile ID should fail")
+	}
+}
+
+func TestServiceAccountPrincipals(t *testing.T) {
+	principal, err := NewServiceAccountPrincipal(`NT AUTHORITY\NETWORK SERVICE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.LogonType != TASK_LOGON_SERVICE_ACCOUNT || principal.UserID != `NT AUTHORITY\NETWORK SERVICE` {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+	if _, err = NewServiceAccountPrincipal("Administrator"); err == nil {
+		t.Fatal("creating a service account principal for a user should fail")
+	}
+
+	principal, err = NewManagedServiceAccountPrincipal(`CONTOSO\backup$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.LogonType != TASK_LOGON_PASSWORD || principal.UserID != `CONTOSO\backup$` {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+	if _, err = NewManagedServiceAccountPrincipal(`CONTOSO\backup`); err == nil {
+		t.Fatal("creating a managed service account principal without $ should fail")
+	}
+
+	def, err := NewTaskBuilder().Exec("cmd.exe").RunAs(`CONTOSO\backup$`).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Principal.LogonType != TASK_LOGON_PASSWORD {
+		t.Fatalf("expected TASK_LOGON_PASSWORD for a managed service account, got %s", def.Principal.LogonType)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		arg    string
+		quoted string
+	}{
+		{`plain`, `plain`},
+		{``, `""`},
+		{`with space`, `"with space"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir\`, `C:\dir\`},
+		{`C:\my dir\`, `"C:\my dir\\"`},
+	}
+
+	for _, test := range tests {
+		if quoted := quoteArg(test.arg); quoted != test.quoted {
+			t.Errorf("quoteArg(%q) = %q, want %q", test.arg, quoted, test.quoted)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/rickb777/date/period"
+	"github.com/stratg5/taskmaster"
+)
+
+// the Task Scheduler service reports 1999-11-30 as the last run time of tasks
+// that have never run, and a zero date as the next run time of tasks that aren't
+// scheduled to run, which schtasks displays as N/A
+var neverRun = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func runCreate(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "create")
+	var schedule scheduleFlags
+	var taskName, taskRun, runAsUser, runAsPassword, runLevel, xmlFile string
+	var force, noPassword bool
+	fs.StringVar(&taskName, "tn", "", `the path of the task, such as \Folder\Task`)
+	fs.StringVar(&taskRun, "tr", "", "the program the task runs, followed by its arguments")
+	fs.StringVar(&runAsUser, "ru", "", `the user the task runs as, such as SYSTEM or domain\user. Defaults to the current user`)
+	fs.StringVar(&runAsPassword, "rp", "", "the password of the user the task runs as, so that it runs whether or not the user is logged on")
+	fs.StringVar(&runLevel, "rl", "", "the run level of the task, LIMITED or HIGHEST. Defaults to LIMITED, or to the run level of the -xml definition")
+	fs.StringVar(&xmlFile, "xml", "", "creates the task from the task XML in the file, or - for standard input")
+	fs.BoolVar(&force, "f", false, "overwrites the task if it already exists")
+	fs.BoolVar(&noPassword, "np", false, "runs the task as -ru whether or not the user is logged on, without access to network resources")
+	fs.StringVar(&schedule.schedule, "sc", "", "the schedule: MINUTE, HOURLY, DAILY, WEEKLY, MONTHLY, ONCE, ONSTART, ONLOGON, ONIDLE or ONEVENT")
+	fs.StringVar(&schedule.modifier, "mo", "", "how often the schedule repeats, FIRST, SECOND, THIRD, FOURTH, LAST or LASTDAY for MONTHLY, or the XPath event query for ONEVENT")
+	fs.StringVar(&schedule.days, "d", "", "the days of the week, such as MON,FRI, or of the month, such as 1,15, that the task runs on. * means every day")
+	fs.StringVar(&schedule.months, "m", "", "the months the task runs in, such as JAN,JUL. * means every month")
+	fs.IntVar(&schedule.idleTime, "i", 0, "how many minutes the computer must be idle before the task starts, for ONIDLE")
+	fs.StringVar(&schedule.startTime, "st", "", "the time of day the task starts at, as HH:mm. Defaults to now")
+	fs.StringVar(&schedule.startDate, "sd", "", "the first day the task runs, as yyyy-mm-dd. Defaults to today")
+	fs.StringVar(&schedule.endTime, "et", "", "the time of day on -ed after which the task no longer starts, as HH:mm")
+	fs.StringVar(&schedule.endDate, "ed", "", "the last day the task runs, as yyyy-mm-dd")
+	fs.StringVar(&schedule.duration, "du", "", "how long the task is repeated for, as HHHH:mm")
+	fs.IntVar(&schedule.interval, "ri", 0, "how many minutes pass between repetitions of the task")
+	fs.StringVar(&schedule.delay, "delay", "", "how long to wait before starting the task after ONSTART, ONLOGON or ONEVENT, as mmmm:ss")
+	fs.StringVar(&schedule.channel, "ec", "", "the event log channel of ONEVENT, such as System")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireTaskName(taskName); err != nil {
+		return err
+	}
+	path := taskPath(taskName)
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	var def taskmaster.Definition
+	if xmlFile != "" {
+		if def, err = readXMLDefinition(env, xmlFile); err != nil {
+			return err
+		}
+	} else {
+		if taskRun == "" {
+			return errors.New("the -tr flag is required")
+		}
+		if def, err = newDefinition(taskService, taskRun, schedule, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	switch strings.ToUpper(runLevel) {
+	case "":
+	case "LIMITED":
+		def.Principal.RunLevel = taskmaster.TASK_RUNLEVEL_LUA
+	case "HIGHEST":
+		def.Principal.RunLevel = taskmaster.TASK_RUNLEVEL_HIGHEST
+	default:
+		return fmt.Errorf("invalid run level %q, expected LIMITED or HIGHEST", runLevel)
+	}
+
+	if err = createTask(taskService, path, def, runAsUser, runAsPassword, noPassword, force); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "SUCCESS: The scheduled task \"%s\" has successfully been created.\n", path)
+
+	return nil
+}
+
+// newDefinition returns the definition of a task that runs the command line
+// taskRun on schedule.
+func newDefinition(taskService taskmaster.TaskService, taskRun string, schedule scheduleFlags, now time.Time) (taskmaster.Definition, error) {
+	trigger, err := schedule.trigger(now)
+	if err != nil {
+		return taskmaster.Definition{}, err
+	}
+
+	def := taskService.NewTaskDefinition()
+	program, args := splitCommandLine(taskRun)
+	def.AddAction(taskmaster.ExecAction{Path: program, Args: args})
+	def.AddTrigger(trigger)
+	if schedule.idleTime > 0 {
+		def.Settings.IdleDuration = period.NewHMS(0, schedule.idleTime, 0)
+	}
+
+	return def, nil
+}
+
+// createTask registers def at path, running as user if it isn't empty. An
+// existing task is only replaced if overwrite is true.
+func createTask(taskService taskmaster.TaskService, path string, def taskmaster.Definition, user, password string, noPassword, overwrite bool) error {
+	logonType := def.Principal.LogonType
+	switch {
+	case user == "" && password != "":
+		return errors.New("-rp can only be used with -ru")
+	case user == "":
+	case noPassword:
+		if password != "" {
+			return errors.New("-np can't be used with -rp")
+		}
+		def.Principal.UserID = user
+		logonType = taskmaster.TASK_LOGON_S4U
+	default:
+		if principal, err := taskmaster.NewServiceAccountPrincipal(user); err == nil {
+			principal.RunLevel = def.Principal.RunLevel
+			def.Principal = principal
+			logonType = principal.LogonType
+			break
+		}
+		def.Principal.UserID = user
+		def.Principal.GroupID = ""
+		logonType = taskmaster.TASK_LOGON_INTERACTIVE_TOKEN
+		if password != "" {
+			logonType = taskmaster.TASK_LOGON_PASSWORD
+		}
+	}
+	def.Principal.LogonType = logonType
+
+	task, created, err := taskService.CreateTaskEx(path, def, user, password, logonType, overwrite)
+	if err != nil {
+		return err
+	}
+	task.Release()
+	if !created {
+		return fmt.Errorf("the task %s already exists, use -f to overwrite it", path)
+	}
+
+	return nil
+}
+
+// splitCommandLine splits a command line into the program it runs and its
+// arguments. The program may be quoted if its path contains spaces.
+func splitCommandLine(commandLine string) (string, string) {
+	commandLine = strings.TrimSpace(commandLine)
+	if strings.HasPrefix(commandLine, `"`) {
+		if end := strings.Index(commandLine[1:], `"`); end != -1 {
+			return commandLine[1 : end+1], strings.TrimSpace(commandLine[end+2:])
+		}
+	}
+
+	program, args, _ := strings.Cut(commandLine, " ")
+	return program, strings.TrimSpace(args)
+}
+
+func runDelete(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "delete")
+	var taskName string
+	var force bool
+	fs.StringVar(&taskName, "tn", "", "the path of the task to delete")
+	fs.BoolVar(&force, "f", false, "deletes the task without asking for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireTaskName(taskName); err != nil {
+		return err
+	}
+	path := taskPath(taskName)
+
+	if !force {
+		fmt.Fprintf(env.stdout, "WARNING: Are you sure you want to remove the task \"%s\" (Y/N)? ", path)
+		answer, _ := bufio.NewReader(env.stdin).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Fprintf(env.stdout, "INFO: The scheduled task \"%s\" was not deleted.\n", path)
+			return nil
+		}
+	}
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	if err = taskService.DeleteTask(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "SUCCESS: The scheduled task \"%s\" was successfully deleted.\n", path)
+
+	return nil
+}
+
+func runQuery(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "query")
+	var taskName, format string
+	var noHeader, verbose, asXML bool
+	fs.StringVar(&taskName, "tn", "", `the path of the task, or of a folder ending with \, to display. Defaults to every task`)
+	fs.StringVar(&format, "fo", "TABLE", "the output format, TABLE, LIST or CSV")
+	fs.BoolVar(&noHeader, "nh", false, "omits the column headers of the TABLE and CSV formats")
+	fs.BoolVar(&verbose, "v", false, "displays every property of the tasks")
+	fs.BoolVar(&asXML, "xml", false, "displays the XML of the tasks")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	var tasks taskmaster.RegisteredTaskCollection
+	switch {
+	case taskName == "":
+		tasks, err = taskService.GetRegisteredTasks()
+	case strings.HasSuffix(taskName, `\`):
+		tasks, err = taskService.GetTasksInFolder(taskPath(taskName))
+	default:
+		var task taskmaster.RegisteredTask
+		task, err = taskService.GetRegisteredTask(taskPath(taskName))
+		tasks = taskmaster.RegisteredTaskCollection{task}
+	}
+	if err != nil {
+		return err
+	}
+	defer tasks.Release()
+
+	if asXML {
+		return writeTasksXML(env.stdout, tasks)
+	}
+
+	header := []string{"TaskName", "Next Run Time", "Status"}
+	if verbose {
+		header = append(header, "Last Run Time", "Last Result", "Author", "Task To Run", "Run As User", "Scheduled Task State")
+	}
+	rows := make([][]string, len(tasks))
+	for i, task := range tasks {
+		rows[i] = taskRow(task, verbose)
+	}
+
+	switch strings.ToUpper(format) {
+	case "TABLE":
+		return writeTable(env.stdout, header, rows, noHeader)
+	case "LIST":
+		return writeList(env.stdout, header, rows)
+	case "CSV":
+		return writeCSV(env.stdout, header, rows, noHeader)
+	default:
+		return fmt.Errorf("invalid format %q, expected TABLE, LIST or CSV", format)
+	}
+}
+
+func taskRow(task taskmaster.RegisteredTask, verbose bool) []string {
+	row := []string{task.Path, formatRunTime(task.NextRunTime), task.State.String()}
+	if !verbose {
+		return row
+	}
+
+	def := task.Definition
+	var actions []string
+	for _, action := range def.Actions {
+		if execAction, ok := action.(taskmaster.ExecAction); ok {
+			actions = append(actions, strings.TrimSpace(execAction.Path+" "+execAction.Args))
+		} else {
+			actions = append(actions, action.GetType().String())
+		}
+	}
+	user := def.Principal.UserID
+	if user == "" {
+		user = def.Principal.GroupID
+	}
+	state := "Enabled"
+	if !task.Enabled {
+		state = "Disabled"
+	}
+
+	lastResult := fmt.Sprintf("%d", uint32(task.LastTaskResult))
+	if task.LastRunTime.Before(neverRun) {
+		lastResult = "N/A"
+	}
+
+	return append(row,
+		formatRunTime(task.LastRunTime),
+		lastResult,
+		valueOrNA(def.RegistrationInfo.Author),
+		valueOrNA(strings.Join(actions, "; ")),
+		valueOrNA(user),
+		state,
+	)
+}
+
+func formatRunTime(t time.Time) string {
+	if t.Before(neverRun) {
+		return "N/A"
+	}
+
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func valueOrNA(s string) string {
+	if s == "" {
+		return "N/A"
+	}
+
+	return s
+}
+
+func writeTable(w io.Writer, header []string, rows [][]string, noHeader bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if !noHeader {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		underlines := make([]string, len(header))
+		for i, column := range header {
+			underlines[i] = strings.Repeat("=", len(column))
+		}
+		fmt.Fprintln(tw, strings.Join(underlines, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func writeList(w io.Writer, header []string, rows [][]string) error {
+	width := 0
+	for _, column := range header {
+		if len(column) > width {
+			width = len(column)
+		}
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if _, err := fmt.Fprintf(w, "%-*s %s\n", width+1, header[i]+":", value); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string, noHeader bool) error {
+	cw := csv.NewWriter(w)
+	if !noHeader {
+		cw.Write(header)
+	}
+	cw.WriteAll(rows)
+
+	return cw.Error()
+}
+
+func writeTasksXML(w io.Writer, tasks taskmaster.RegisteredTaskCollection) error {
+	for _, task := range tasks {
+		taskXML, err := task.ExportXML()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "<!-- %s -->\n%s\n", task.Path, taskXML)
+	}
+
+	return nil
+}
+
+func runRun(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "run")
+	var taskName string
+	var ignoreConstraints bool
+	fs.StringVar(&taskName, "tn", "", "the path of the task to run. Arguments after the flags are passed to the task")
+	fs.BoolVar(&ignoreConstraints, "i", false, "runs the task even if its conditions, such as running only when idle, aren't met")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireTaskName(taskName); err != nil {
+		return err
+	}
+	path := taskPath(taskName)
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	task, err := taskService.GetRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
+
+	flags := taskmaster.TASK_RUN_NO_FLAGS
+	if ignoreConstraints {
+		flags = taskmaster.TASK_RUN_IGNORE_CONSTRAINTS
+	}
+	runningTask, err := task.RunEx(fs.Args(), flags, 0, "")
+	if err != nil {
+		return err
+	}
+	runningTask.Release()
+	fmt.Fprintf(env.stdout, "SUCCESS: Attempted to run the scheduled task \"%s\".\n", path)
+
+	return nil
+}
+
+func runEnd(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "end")
+	var taskName string
+	fs.StringVar(&taskName, "tn", "", "the path of the task to stop")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireTaskName(taskName); err != nil {
+		return err
+	}
+	path := taskPath(taskName)
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	task, err := taskService.GetRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
+
+	if err = task.Stop(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "SUCCESS: The scheduled task \"%s\" has been terminated successfully.\n", path)
+
+	return nil
+}
+
+func runExport(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "export")
+	var taskName, format, output string
+	fs.StringVar(&taskName, "tn", "", "the path of the task to export")
+	fs.StringVar(&format, "fo", "XML", "the output format: XML, JSON for the taskmaster JSON encoding, or POWERSHELL for the encoding of Get-ScheduledTask | ConvertTo-Json")
+	fs.StringVar(&output, "o", "-", "the file to write the definition to, or - for standard output")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireTaskName(taskName); err != nil {
+		return err
+	}
+	path := taskPath(taskName)
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	task, err := taskService.GetRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
+
+	var data []byte
+	switch strings.ToUpper(format) {
+	case "XML":
+		var taskXML string
+		if taskXML, err = task.ExportXML(); err == nil {
+			data = []byte(taskXML)
+		}
+	case "JSON":
+		data, err = json.MarshalIndent(task.Definition, "", "  ")
+	case "POWERSHELL":
+		data, err = json.MarshalIndent(taskmaster.PowerShellTask{Path: task.Path, State: task.State, Definition: task.Definition}, "", "  ")
+	default:
+		return fmt.Errorf("invalid format %q, expected XML, JSON or POWERSHELL", format)
+	}
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if output == "-" {
+		_, err = env.stdout.Write(data)
+		return err
+	}
+
+	return os.WriteFile(output, data, 0o644)
+}
+
+func runImport(env *environment, args []string) error {
+	fs, conn := newFlagSet(env, "import")
+	var taskName, format, input, runAsUser, runAsPassword string
+	var force, noPassword bool
+	fs.StringVar(&taskName, "tn", "", "the path to create the task at. Defaults to the path stored in POWERSHELL input")
+	fs.StringVar(&format, "fo", "", "the input format, XML, JSON or POWERSHELL. Defaults to XML if the input starts with <, and JSON otherwise")
+	fs.StringVar(&input, "xml", "-", "the file to read the definition from, or - for standard input")
+	fs.StringVar(&runAsUser, "ru", "", "the user the task runs as. Defaults to the user of the definition")
+	fs.StringVar(&runAsPassword, "rp", "", "the password of the user the task runs as")
+	fs.BoolVar(&force, "f", false, "overwrites the task if it already exists")
+	fs.BoolVar(&noPassword, "np", false, "runs the task as -ru whether or not the user is logged on, without access to network resources")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	data, err := readInput(env, input)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = "JSON"
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+			format = "XML"
+		}
+	}
+
+	var tasks []taskmaster.PowerShellTask
+	switch strings.ToUpper(format) {
+	case "XML":
+		var def taskmaster.Definition
+		if def, err = unmarshalXMLDefinition(data); err != nil {
+			return err
+		}
+		tasks = []taskmaster.PowerShellTask{{Definition: def}}
+	case "JSON":
+		var def taskmaster.Definition
+		if err = json.Unmarshal(data, &def); err != nil {
+			return fmt.Errorf("error decoding %s: %v", input, err)
+		}
+		tasks = []taskmaster.PowerShellTask{{Definition: def}}
+	case "POWERSHELL":
+		if tasks, err = taskmaster.ParsePowerShellJSON(data); err != nil {
+			return fmt.Errorf("error decoding %s: %v", input, err)
+		}
+	default:
+		return fmt.Errorf("invalid format %q, expected XML, JSON or POWERSHELL", format)
+	}
+	if taskName != "" {
+		if len(tasks) != 1 {
+			return fmt.Errorf("-tn can't be used to import %d tasks", len(tasks))
+		}
+		tasks[0].Path = taskPath(taskName)
+	}
+
+	taskService, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer taskService.Disconnect()
+
+	for _, task := range tasks {
+		if err = requireTaskName(task.Path); err != nil {
+			return err
+		}
+		if err = createTask(taskService, task.Path, task.Definition, runAsUser, runAsPassword, noPassword, force); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.stdout, "SUCCESS: The scheduled task \"%s\" has successfully been created.\n", task.Path)
+	}
+
+	return nil
+}
+
+// readXMLDefinition decodes the task XML in the file name.
+func readXMLDefinition(env *environment, name string) (taskmaster.Definition, error) {
+	data, err := readInput(env, name)
+	if err != nil {
+		return taskmaster.Definition{}, err
+	}
+
+	return unmarshalXMLDefinition(data)
+}
+
+// unmarshalXMLDefinition decodes task XML, which schtasks and the Task Scheduler
+// UI export as UTF-16 and taskmaster as UTF-8.
+func unmarshalXMLDefinition(data []byte) (taskmaster.Definition, error) {
+	var def taskmaster.Definition
+	dec := xml.NewDecoder(bytes.NewReader(decodeUTF16(data)))
+	// the XML was converted to UTF-8, so its encoding declaration can be ignored
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := dec.Decode(&def); err != nil {
+		return taskmaster.Definition{}, fmt.Errorf("error decoding task XML: %v", err)
+	}
+
+	return def, nil
+}
+
+// decodeUTF16 converts text that starts with a UTF-16 byte order mark to UTF-8.
+// Other text is returned unchanged, except for a UTF-8 byte order mark.
+func decodeUTF16(data []byte) []byte {
+	var bigEndian bool
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		bigEndian = true
+	default:
+		return bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+
+	decoded := make([]byte, 0, len(units))
+	for _, r := range utf16.Decode(units) {
+		decoded = utf8.AppendRune(decoded, r)
+	}
+
+	return decoded
+}
+
+func readInput(env *environment, name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(env.stdin)
+	}
+
+	return os.ReadFile(name)
+}
@@ -0,0 +1,190 @@
+// Command taskmaster manages the scheduled tasks of local and remote computers
+// from the command line. Its commands and flags mirror those of schtasks, so it
+// can be used as a drop-in replacement in scripts:
+//
+//	taskmaster /create /tn \Backup /tr "C:\scripts\backup.bat" /sc daily /st 03:00
+//	taskmaster query -tn \Backup -fo list -v
+//
+// The commands are create, delete, query, run, end, export and import. Every
+// command accepts -s, -u and -p to manage the tasks of a remote computer as
+// another user. Flags can be written either as -name or as /name, and are case
+// insensitive when written the schtasks way.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/stratg5/taskmaster"
+)
+
+type command struct {
+	usage string
+	run   func(env *environment, args []string) error
+}
+
+var commands = map[string]command{
+	"create": {"creates a scheduled task", runCreate},
+	"delete": {"deletes a scheduled task", runDelete},
+	"query":  {"displays scheduled tasks", runQuery},
+	"run":    {"runs a scheduled task on demand", runRun},
+	"end":    {"stops the running instances of a scheduled task", runEnd},
+	"export": {"writes the definition of a scheduled task as XML or JSON", runExport},
+	"import": {"creates a scheduled task from an XML or JSON definition", runImport},
+}
+
+// environment holds where a command reads its input and writes its output.
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	env := &environment{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	if err := run(env, os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(env.stderr, "ERROR: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(env *environment, args []string) error {
+	if len(args) == 0 {
+		printUsage(env.stderr)
+		return flag.ErrHelp
+	}
+
+	name := strings.ToLower(strings.TrimLeft(args[0], "-/"))
+	if name == "?" || name == "h" || name == "help" {
+		printUsage(env.stdout)
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, run taskmaster -help for usage", args[0])
+	}
+
+	return cmd.run(env, args[1:])
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: taskmaster <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run taskmaster <command> -? for the flags of a command")
+}
+
+// newFlagSet returns a flag set for the command name with the connection flags
+// that every command accepts.
+func newFlagSet(env *environment, name string) (*flag.FlagSet, *connectFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	var conn connectFlags
+	fs.StringVar(&conn.server, "s", "", "the remote computer to connect to. Defaults to the local computer")
+	fs.StringVar(&conn.user, "u", "", `the user to connect as, in the form [domain\]user. Defaults to the current user`)
+	fs.StringVar(&conn.password, "p", "", "the password of the user to connect as")
+
+	return fs, &conn
+}
+
+// parseFlags parses args with fs, accepting schtasks-style flags such as /TN in
+// addition to -tn.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	return fs.Parse(normalizeArgs(fs, args))
+}
+
+// normalizeArgs rewrites arguments of the form /NAME, where name is a flag of
+// fs ignoring case, to -name. Other arguments starting with a slash, such as the
+// /c of `/tr "cmd /c backup.bat"` when it isn't quoted, are left unchanged.
+func normalizeArgs(fs *flag.FlagSet, args []string) []string {
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			copy(normalized[i:], args[i:])
+			break
+		}
+		normalized[i] = arg
+		if arg == "/?" || arg == "-?" {
+			normalized[i] = "-help"
+			continue
+		}
+		if !strings.HasPrefix(arg, "/") && !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-/"), "=")
+		if fs.Lookup(strings.ToLower(name)) == nil {
+			continue
+		}
+		normalized[i] = "-" + strings.ToLower(name)
+		if hasValue {
+			normalized[i] += "=" + value
+		}
+	}
+
+	return normalized
+}
+
+type connectFlags struct {
+	server   string
+	user     string
+	password string
+}
+
+// connect connects to the Task Scheduler service selected by the connection flags.
+func (c connectFlags) connect() (taskmaster.TaskService, error) {
+	if c.password != "" && c.user == "" {
+		return taskmaster.TaskService{}, errors.New("-p can only be used with -u")
+	}
+
+	domain, user := splitUser(c.user)
+	return taskmaster.Connect(
+		taskmaster.WithServer(strings.TrimPrefix(c.server, `\\`)),
+		taskmaster.WithCredentials(domain, user, c.password),
+	)
+}
+
+// splitUser splits a user in the form domain\user into its domain and name.
+func splitUser(user string) (string, string) {
+	if domain, name, ok := strings.Cut(user, `\`); ok {
+		return domain, name
+	}
+
+	return "", user
+}
+
+// requireTaskName returns an error if the -tn flag of a command wasn't set.
+func requireTaskName(taskName string) error {
+	if taskName == "" {
+		return errors.New("the -tn flag is required")
+	}
+
+	return nil
+}
+
+// taskPath returns the path of the task taskName, which is relative to the root
+// folder if it doesn't start with a backslash, the way schtasks accepts it.
+func taskPath(taskName string) string {
+	if strings.HasPrefix(taskName, `\`) {
+		return taskName
+	}
+
+	return `\` + taskName
+}
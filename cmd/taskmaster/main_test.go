@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/rickb777/date/period"
+	"github.com/stratg5/taskmaster"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.String("tn", "", "")
+	fs.String("tr", "", "")
+	fs.Bool("f", false, "")
+
+	args := []string{"/TN", `\Backup`, "-TR=cmd.exe", "/c", "/F", "/?", "--", "/tn"}
+	expected := []string{"-tn", `\Backup`, "-tr=cmd.exe", "/c", "-f", "-help", "--", "/tn"}
+	if normalized := normalizeArgs(fs, args); !reflect.DeepEqual(normalized, expected) {
+		t.Fatalf("expected %q, got %q", expected, normalized)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		commandLine string
+		program     string
+		args        string
+	}{
+		{"backup.bat", "backup.bat", ""},
+		{"cmd.exe /c exit 1", "cmd.exe", "/c exit 1"},
+		{`"C:\Program Files\app.exe" -v "a b"`, `C:\Program Files\app.exe`, `-v "a b"`},
+		{`"unterminated quote`, `"unterminated`, "quote"},
+	}
+
+	for _, test := range tests {
+		program, args := splitCommandLine(test.commandLine)
+		if program != test.program || args != test.args {
+			t.Errorf("%s: expected %q %q, got %q %q", test.commandLine, test.program, test.args, program, args)
+		}
+	}
+}
+
+func TestScheduleTrigger(t *testing.T) {
+	// 2021-01-01 is a Friday
+	now := time.Date(2021, time.January, 1, 12, 30, 0, 0, time.Local)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, time.January, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name     string
+		flags    scheduleFlags
+		expected taskmaster.Trigger
+	}{
+		{
+			"MINUTE",
+			scheduleFlags{schedule: "minute", modifier: "15", duration: "2:00"},
+			mustTrigger(taskmaster.NewTimeTrigger(now, taskmaster.RepetitionPattern{RepetitionInterval: period.NewHMS(0, 15, 0), RepetitionDuration: period.NewHMS(2, 0, 0)})),
+		},
+		{
+			"DAILY",
+			scheduleFlags{schedule: "DAILY", modifier: "2", startTime: "03:00"},
+			mustTrigger(taskmaster.NewDailyTrigger(at(3, 0), taskmaster.EveryOtherDay)),
+		},
+		{
+			"WEEKLY defaults to the day of the start date",
+			scheduleFlags{schedule: "WEEKLY", startTime: "03:00"},
+			mustTrigger(taskmaster.NewWeeklyTrigger(at(3, 0), taskmaster.EveryWeek, taskmaster.Friday)),
+		},
+		{
+			"WEEKLY",
+			scheduleFlags{schedule: "WEEKLY", days: "mon,WED", startTime: "03:00", startDate: "2021/01/04"},
+			mustTrigger(taskmaster.NewWeeklyTrigger(time.Date(2021, time.January, 4, 3, 0, 0, 0, time.Local), taskmaster.EveryWeek, taskmaster.Monday|taskmaster.Wednesday)),
+		},
+		{
+			"MONTHLY every other month",
+			scheduleFlags{schedule: "MONTHLY", modifier: "6", days: "1,15", startTime: "03:00", startDate: "2021-03-01"},
+			mustTrigger(taskmaster.NewMonthlyTrigger(time.Date(2021, time.March, 1, 3, 0, 0, 0, time.Local), taskmaster.One|taskmaster.Fifteen, taskmaster.March|taskmaster.September)),
+		},
+		{
+			"MONTHLY LASTDAY",
+			scheduleFlags{schedule: "MONTHLY", modifier: "LASTDAY", months: "JAN,JUL", startTime: "03:00"},
+			mustTrigger(taskmaster.NewMonthlyTrigger(at(3, 0), taskmaster.LastDayOfMonth, taskmaster.January|taskmaster.July)),
+		},
+		{
+			"MONTHLY on the second Tuesday",
+			scheduleFlags{schedule: "MONTHLY", modifier: "SECOND", days: "TUE", startTime: "03:00"},
+			mustTrigger(taskmaster.NewMonthlyDOWTrigger(at(3, 0), taskmaster.Second, taskmaster.Tuesday, taskmaster.AllMonths)),
+		},
+		{
+			"ONSTART",
+			scheduleFlags{schedule: "ONSTART", delay: "1:30"},
+			taskmaster.BootTrigger{TaskTrigger: taskmaster.TaskTrigger{Enabled: true}, Delay: period.NewHMS(0, 1, 30)},
+		},
+		{
+			"ONCE with an end date",
+			scheduleFlags{schedule: "ONCE", startTime: "18:00", endDate: "2021-01-02", interval: 30},
+			taskmaster.TimeTrigger{TaskTrigger: taskmaster.TaskTrigger{
+				Enabled:           true,
+				StartBoundary:     at(18, 0),
+				EndBoundary:       time.Date(2021, time.January, 2, 23, 59, 0, 0, time.Local),
+				RepetitionPattern: taskmaster.RepetitionPattern{RepetitionInterval: period.NewHMS(0, 30, 0)},
+			}},
+		},
+		{
+			"ONEVENT",
+			scheduleFlags{schedule: "ONEVENT", channel: "System", modifier: "*[System[EventID=41]]"},
+			taskmaster.EventTrigger{
+				TaskTrigger:  taskmaster.TaskTrigger{Enabled: true},
+				Subscription: `<QueryList><Query Id="0" Path="System"><Select Path="System">*[System[EventID=41]]</Select></Query></QueryList>`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		trigger, err := test.flags.trigger(now)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(trigger, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, trigger)
+		}
+	}
+
+	invalid := []scheduleFlags{
+		{},
+		{schedule: "YEARLY"},
+		{schedule: "DAILY", modifier: "366"},
+		{schedule: "WEEKLY", days: "MONDAY"},
+		{schedule: "MONTHLY", modifier: "FIRST"},
+		{schedule: "MONTHLY", modifier: "2", months: "JAN"},
+		{schedule: "ONCE"},
+		{schedule: "ONIDLE"},
+		{schedule: "ONEVENT"},
+		{schedule: "HOURLY", interval: 5},
+		{schedule: "DAILY", duration: "1:00"},
+		{schedule: "DAILY", startTime: "25:00"},
+	}
+	for _, flags := range invalid {
+		if _, err := flags.trigger(now); err == nil {
+			t.Errorf("expected an error for %+v", flags)
+		}
+	}
+}
+
+func TestUnmarshalXMLDefinition(t *testing.T) {
+	taskXML := `<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <Actions Context="Author">
+    <Exec>
+      <Command>cmd.exe</Command>
+      <Arguments>/c exit 0</Arguments>
+    </Exec>
+  </Actions>
+</Task>`
+
+	// schtasks exports task XML as little-endian UTF-16 with a byte order mark
+	data := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(taskXML)) {
+		data = append(data, byte(unit), byte(unit>>8))
+	}
+
+	for _, input := range [][]byte{data, []byte(taskXML)} {
+		def, err := unmarshalXMLDefinition(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []taskmaster.Action{taskmaster.ExecAction{Path: "cmd.exe", Args: "/c exit 0"}}
+		if !reflect.DeepEqual(def.Actions, expected) {
+			t.Fatalf("expected actions %+v, got %+v", expected, def.Actions)
+		}
+	}
+}
+
+func mustTrigger[T taskmaster.Trigger](trigger T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return trigger
+}
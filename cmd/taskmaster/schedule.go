@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+	"github.com/stratg5/taskmaster"
+)
+
+// scheduleFlags are the flags of the create command that describe when a task
+// runs, named after the flags of schtasks /create.
+type scheduleFlags struct {
+	schedule  string // -sc: MINUTE, HOURLY, DAILY, WEEKLY, MONTHLY, ONCE, ONSTART, ONLOGON, ONIDLE or ONEVENT
+	modifier  string // -mo: how often the schedule repeats, or the XPath event query of ONEVENT
+	days      string // -d: the days of the week or month the task runs on
+	months    string // -m: the months the task runs in
+	idleTime  int    // -i: how many minutes the computer must be idle for ONIDLE
+	startTime string // -st: the time of day the task starts, as HH:mm
+	startDate string // -sd: the first day the task runs, as yyyy-mm-dd
+	endTime   string // -et: the time of day the repetition of the task ends, as HH:mm
+	endDate   string // -ed: the last day the task runs, as yyyy-mm-dd
+	duration  string // -du: how long the task is repeated for, as HHHH:mm
+	interval  int    // -ri: how many minutes pass between repetitions of the task
+	delay     string // -delay: how long to wait after ONSTART, ONLOGON or ONEVENT, as mmmm:ss
+	channel   string // -ec: the event log channel of ONEVENT
+}
+
+var weekdayNames = map[string]taskmaster.DayOfWeek{
+	"SUN": taskmaster.Sunday,
+	"MON": taskmaster.Monday,
+	"TUE": taskmaster.Tuesday,
+	"WED": taskmaster.Wednesday,
+	"THU": taskmaster.Thursday,
+	"FRI": taskmaster.Friday,
+	"SAT": taskmaster.Saturday,
+}
+
+var monthNames = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+
+var weekNames = map[string]taskmaster.Week{
+	"FIRST":  taskmaster.First,
+	"SECOND": taskmaster.Second,
+	"THIRD":  taskmaster.Third,
+	"FOURTH": taskmaster.Fourth,
+	"LAST":   taskmaster.LastWeek,
+}
+
+// trigger returns the trigger described by the schedule flags. Dates and times
+// that aren't set default to now.
+func (f scheduleFlags) trigger(now time.Time) (taskmaster.Trigger, error) {
+	start, err := parseDateTime(f.startDate, f.startTime, now)
+	if err != nil {
+		return nil, err
+	}
+	repetition, err := f.repetition()
+	if err != nil {
+		return nil, err
+	}
+	schedule := strings.ToUpper(f.schedule)
+	if f.interval > 0 && (schedule == "MINUTE" || schedule == "HOURLY") {
+		return nil, fmt.Errorf("-ri can't be used with the %s schedule, use -mo instead", schedule)
+	}
+	if f.duration != "" && f.interval == 0 && schedule != "MINUTE" && schedule != "HOURLY" {
+		return nil, errors.New("-du can only be used with -ri")
+	}
+
+	var trigger taskmaster.Trigger
+	switch schedule {
+	case "MINUTE":
+		modifier, err := f.intModifier(1, 1439)
+		if err != nil {
+			return nil, err
+		}
+		repetition.RepetitionInterval = period.NewHMS(0, modifier, 0)
+		trigger, err = taskmaster.NewTimeTrigger(start, repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "HOURLY":
+		modifier, err := f.intModifier(1, 23)
+		if err != nil {
+			return nil, err
+		}
+		repetition.RepetitionInterval = period.NewHMS(modifier, 0, 0)
+		trigger, err = taskmaster.NewTimeTrigger(start, repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "DAILY":
+		modifier, err := f.intModifier(1, 365)
+		if err != nil {
+			return nil, err
+		}
+		trigger, err = taskmaster.NewDailyTrigger(start, taskmaster.DayInterval(modifier), repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "WEEKLY":
+		modifier, err := f.intModifier(1, 52)
+		if err != nil {
+			return nil, err
+		}
+		daysOfWeek, err := parseDaysOfWeek(f.days, start.Weekday())
+		if err != nil {
+			return nil, err
+		}
+		trigger, err = taskmaster.NewWeeklyTrigger(start, taskmaster.WeekInterval(modifier), daysOfWeek, repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "MONTHLY":
+		trigger, err = f.monthlyTrigger(start, repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "ONCE":
+		if f.startTime == "" {
+			return nil, errors.New("-st is required for the ONCE schedule")
+		}
+		trigger, err = taskmaster.NewTimeTrigger(start, repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "ONSTART":
+		delay, err := parseDelay(f.delay)
+		if err != nil {
+			return nil, err
+		}
+		trigger, err = taskmaster.NewBootTrigger(delay, repetition)
+		if err != nil {
+			return nil, err
+		}
+	case "ONLOGON":
+		delay, err := parseDelay(f.delay)
+		if err != nil {
+			return nil, err
+		}
+		trigger = taskmaster.LogonTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{Enabled: true, RepetitionPattern: repetition},
+			Delay:       delay,
+		}
+	case "ONIDLE":
+		if f.idleTime < 1 || f.idleTime > 999 {
+			return nil, errors.New("-i must be between 1 and 999 minutes for the ONIDLE schedule")
+		}
+		trigger = taskmaster.IdleTrigger{TaskTrigger: taskmaster.TaskTrigger{Enabled: true, RepetitionPattern: repetition}}
+	case "ONEVENT":
+		if f.channel == "" {
+			return nil, errors.New("-ec is required for the ONEVENT schedule")
+		}
+		delay, err := parseDelay(f.delay)
+		if err != nil {
+			return nil, err
+		}
+		query := f.modifier
+		if query == "" {
+			query = "*"
+		}
+		trigger = taskmaster.EventTrigger{
+			TaskTrigger:  taskmaster.TaskTrigger{Enabled: true, RepetitionPattern: repetition},
+			Delay:        delay,
+			Subscription: eventSubscription(f.channel, query),
+		}
+	case "":
+		return nil, errors.New("the -sc flag is required")
+	default:
+		return nil, fmt.Errorf("unknown schedule %q", f.schedule)
+	}
+
+	if f.endDate != "" {
+		endTime := f.endTime
+		if endTime == "" {
+			endTime = "23:59"
+		}
+		end, err := parseDateTime(f.endDate, endTime, now)
+		if err != nil {
+			return nil, err
+		}
+		trigger = setEndBoundary(trigger, end)
+	}
+
+	return trigger, nil
+}
+
+func (f scheduleFlags) monthlyTrigger(start time.Time, repetition taskmaster.RepetitionPattern) (taskmaster.Trigger, error) {
+	months, err := parseMonths(f.months)
+	if err != nil {
+		return nil, err
+	}
+
+	modifier := strings.ToUpper(f.modifier)
+	if week, ok := weekNames[modifier]; ok {
+		if f.days == "" || f.days == "*" {
+			return nil, fmt.Errorf("-d must be a day of the week for the %s modifier", modifier)
+		}
+		daysOfWeek, err := parseDaysOfWeek(f.days, start.Weekday())
+		if err != nil {
+			return nil, err
+		}
+		return taskmaster.NewMonthlyDOWTrigger(start, week, daysOfWeek, months, repetition)
+	}
+	if modifier == "LASTDAY" {
+		return taskmaster.NewMonthlyTrigger(start, taskmaster.LastDayOfMonth, months, repetition)
+	}
+
+	// a numeric modifier runs the task every modifier months, starting with the
+	// month of the start date. Monthly triggers can only select months of the
+	// year, so the months wrap around at the end of the year
+	interval, err := f.intModifier(1, 12)
+	if err != nil {
+		return nil, err
+	}
+	if interval > 1 {
+		if f.months != "" {
+			return nil, errors.New("-m can't be used with a numeric -mo for the MONTHLY schedule")
+		}
+		months = 0
+		for i := 0; i < 12; i += interval {
+			months |= taskmaster.Month(1 << ((int(start.Month()) - 1 + i) % 12))
+		}
+	}
+
+	daysOfMonth := taskmaster.DayOfMonth(0)
+	if f.days == "" {
+		f.days = "1"
+	}
+	for _, day := range strings.Split(f.days, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(day))
+		if err != nil || n < 1 || n > 31 {
+			return nil, fmt.Errorf("invalid day of the month %q", day)
+		}
+		dayOfMonth, err := taskmaster.IntToDayOfMonth(n)
+		if err != nil {
+			return nil, err
+		}
+		daysOfMonth |= dayOfMonth
+	}
+
+	return taskmaster.NewMonthlyTrigger(start, daysOfMonth, months, repetition)
+}
+
+// repetition returns the repetition pattern set by -ri and -du. The MINUTE and
+// HOURLY schedules replace its interval with -mo.
+func (f scheduleFlags) repetition() (taskmaster.RepetitionPattern, error) {
+	var repetition taskmaster.RepetitionPattern
+	if f.interval < 0 {
+		return repetition, errors.New("-ri must be a positive number of minutes")
+	}
+	if f.interval > 0 {
+		repetition.RepetitionInterval = period.NewHMS(0, f.interval, 0)
+	}
+	if f.duration != "" {
+		hours, minutes, err := parseClock(f.duration, 9999)
+		if err != nil {
+			return repetition, fmt.Errorf("invalid duration %q: %v", f.duration, err)
+		}
+		repetition.RepetitionDuration = period.NewHMS(hours, minutes, 0)
+	}
+
+	return repetition, nil
+}
+
+// intModifier returns -mo as a number between min and max, or 1 if it isn't set.
+func (f scheduleFlags) intModifier(min, max int) (int, error) {
+	if f.modifier == "" {
+		return 1, nil
+	}
+
+	modifier, err := strconv.Atoi(f.modifier)
+	if err != nil || modifier < min || modifier > max {
+		return 0, fmt.Errorf("-mo must be between %d and %d for the %s schedule", min, max, strings.ToUpper(f.schedule))
+	}
+
+	return modifier, nil
+}
+
+// parseDateTime parses a date in the form yyyy-mm-dd or yyyy/mm/dd and a time of
+// day in the form HH:mm in the local time zone. Each of them defaults to the
+// date or time of now if it is empty.
+func parseDateTime(date, clock string, now time.Time) (time.Time, error) {
+	year, month, day := now.Date()
+	if date != "" {
+		d, err := time.ParseInLocation("2006-01-02", strings.ReplaceAll(date, "/", "-"), time.Local)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q, expected yyyy-mm-dd", date)
+		}
+		year, month, day = d.Date()
+	}
+
+	hour, minute := now.Hour(), now.Minute()
+	if clock != "" {
+		var err error
+		if hour, minute, err = parseClock(clock, 23); err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %v", clock, err)
+		}
+	}
+
+	return time.Date(year, month, day, hour, minute, 0, 0, time.Local), nil
+}
+
+// parseClock parses a duration or time of day in the form HH:mm, whose hours
+// can't be greater than maxHours.
+func parseClock(s string, maxHours int) (int, int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, errors.New("expected HH:mm")
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > maxHours {
+		return 0, 0, fmt.Errorf("hours must be between 0 and %d", maxHours)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 {
+		return 0, 0, errors.New("minutes must be between 0 and 59")
+	}
+
+	return h, m, nil
+}
+
+// parseDelay parses a delay in the form mmmm:ss.
+func parseDelay(s string) (period.Period, error) {
+	if s == "" {
+		return period.Period{}, nil
+	}
+
+	minutes, seconds, err := parseClock(s, 9999)
+	if err != nil {
+		return period.Period{}, fmt.Errorf("invalid delay %q: expected mmmm:ss", s)
+	}
+
+	return period.NewHMS(0, minutes, seconds), nil
+}
+
+// parseDaysOfWeek parses a comma separated list of days such as MON,FRI, or *
+// for every day. If days is empty, def is returned.
+func parseDaysOfWeek(days string, def time.Weekday) (taskmaster.DayOfWeek, error) {
+	if days == "" {
+		return taskmaster.DayOfWeek(1 << def), nil
+	}
+	if days == "*" {
+		return taskmaster.AllDays, nil
+	}
+
+	var daysOfWeek taskmaster.DayOfWeek
+	for _, day := range strings.Split(days, ",") {
+		dayOfWeek, ok := weekdayNames[strings.ToUpper(strings.TrimSpace(day))]
+		if !ok {
+			return 0, fmt.Errorf("invalid day of the week %q, expected one of MON, TUE, WED, THU, FRI, SAT, SUN", day)
+		}
+		daysOfWeek |= dayOfWeek
+	}
+
+	return daysOfWeek, nil
+}
+
+// parseMonths parses a comma separated list of months such as JAN,JUL, or * for
+// every month. If months is empty, every month is returned.
+func parseMonths(months string) (taskmaster.Month, error) {
+	if months == "" || months == "*" {
+		return taskmaster.AllMonths, nil
+	}
+
+	var monthsOfYear taskmaster.Month
+	for _, month := range strings.Split(months, ",") {
+		name := strings.ToUpper(strings.TrimSpace(month))
+		i := 0
+		for i < len(monthNames) && monthNames[i] != name {
+			i++
+		}
+		if i == len(monthNames) {
+			return 0, fmt.Errorf("invalid month %q, expected one of %s", month, strings.Join(monthNames, ", "))
+		}
+		monthsOfYear |= taskmaster.Month(1 << i)
+	}
+
+	return monthsOfYear, nil
+}
+
+// eventSubscription returns the subscription of an EventTrigger that fires for
+// the events of channel that match the XPath query.
+func eventSubscription(channel, query string) string {
+	var escapedChannel, escapedQuery strings.Builder
+	xml.EscapeText(&escapedChannel, []byte(channel))
+	xml.EscapeText(&escapedQuery, []byte(query))
+
+	return fmt.Sprintf(`<QueryList><Query Id="0" Path="%[1]s"><Select Path="%[1]s">%[2]s</Select></Query></QueryList>`, escapedChannel.String(), escapedQuery.String())
+}
+
+// setEndBoundary returns trigger with its end boundary set to end.
+func setEndBoundary(trigger taskmaster.Trigger, end time.Time) taskmaster.Trigger {
+	switch t := trigger.(type) {
+	case taskmaster.TimeTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.DailyTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.WeeklyTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.MonthlyTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.MonthlyDOWTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.BootTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.LogonTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.IdleTrigger:
+		t.EndBoundary = end
+		return t
+	case taskmaster.EventTrigger:
+		t.EndBoundary = end
+		return t
+	default:
+		return trigger
+	}
+}
@@ -0,0 +1,271 @@
+//go:build windows
+// +build windows
+
+package taskmaster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// callMethod calls method on obj through IDispatch, and logs the call, how long
+// it took and the HRESULT it failed with, if any, at debug level to the logger
+// passed to Connect with WithLogger. Every method that TaskService and
+// TaskFolderHandle call should go through it.
+func (t *TaskService) callMethod(obj *ole.IDispatch, method string, params ...interface{}) (*ole.VARIANT, error) {
+	logger := t.connectOptions.logger
+	if logger == nil || !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return t.applyProxyBlanket(oleutil.CallMethod(obj, method, params...))
+	}
+
+	start := time.Now()
+	res, err := oleutil.CallMethod(obj, method, params...)
+	duration := time.Since(start)
+
+	if err != nil {
+		hresult, _ := getOLEErrorCode(err)
+		logger.Debug("COM call failed", "method", method, "duration", duration, "hresult", fmt.Sprintf("0x%08X", hresult), "error", err)
+	} else {
+		logger.Debug("COM call", "method", method, "duration", duration)
+	}
+
+	return t.applyProxyBlanket(res, err)
+}
+
+// applyProxyBlanket sets the proxy blanket passed to Connect with WithProxyBlanket
+// on the object that a method returned, if it returned one. res is cleared if the
+// proxy blanket can't be set.
+func (t *TaskService) applyProxyBlanket(res *ole.VARIANT, err error) (*ole.VARIANT, error) {
+	if err != nil || res == nil || res.VT != ole.VT_DISPATCH {
+		return res, err
+	}
+	if err = t.connectOptions.proxyBlanket.apply(res.ToIDispatch()); err != nil {
+		res.Clear()
+		return nil, fmt.Errorf("error setting the proxy blanket: %v", err)
+	}
+
+	return res, nil
+}
+
+// Some Task Scheduler methods take arguments that can't be passed through
+// IDispatch, so they have to be called directly through the vtable of the
+// COM interface.
+
+var iidIRegisteredTask = ole.NewGUID("{9c86f320-dee3-4dd1-b972-a303f26b061e}")
+
+// iRegisteredTaskVtbl is the vtable of the IRegisteredTask interface. Only
+// the methods that can't be called through IDispatch are named.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-iregisteredtask
+type iRegisteredTaskVtbl struct {
+	ole.IDispatchVtbl
+	_           [17]uintptr // get_Name through Stop
+	GetRunTimes uintptr
+}
+
+func getIRegisteredTaskVtbl(task *ole.IUnknown) *iRegisteredTaskVtbl {
+	return (*iRegisteredTaskVtbl)(unsafe.Pointer(task.RawVTable))
+}
+
+func timeToSystemTime(t time.Time) syscall.Systemtime {
+	t = t.Local()
+
+	return syscall.Systemtime{
+		Year:         uint16(t.Year()),
+		Month:        uint16(t.Month()),
+		DayOfWeek:    uint16(t.Weekday()),
+		Day:          uint16(t.Day()),
+		Hour:         uint16(t.Hour()),
+		Minute:       uint16(t.Minute()),
+		Second:       uint16(t.Second()),
+		Milliseconds: uint16(t.Nanosecond() / int(time.Millisecond)),
+	}
+}
+
+func systemTimeToTime(st syscall.Systemtime) time.Time {
+	return time.Date(
+		int(st.Year),
+		time.Month(st.Month),
+		int(st.Day),
+		int(st.Hour),
+		int(st.Minute),
+		int(st.Second),
+		int(st.Milliseconds)*int(time.Millisecond),
+		time.Local,
+	)
+}
+
+const (
+	rpcCAuthnDefault = 0xFFFFFFFF // RPC_C_AUTHN_DEFAULT
+	rpcCAuthzDefault = 0xFFFFFFFF // RPC_C_AUTHZ_DEFAULT
+	eoacDefault      = 0x800      // EOAC_DEFAULT
+)
+
+var procCoSetProxyBlanket = syscall.NewLazyDLL("ole32.dll").NewProc("CoSetProxyBlanket")
+
+// apply sets the proxy blanket of obj, keeping the default authentication and
+// authorization services. It does nothing if p is nil or obj isn't a proxy, in
+// which case CoSetProxyBlanket fails with E_NOINTERFACE.
+func (p *proxyBlanket) apply(obj *ole.IDispatch) error {
+	if p == nil {
+		return nil
+	}
+
+	hr, _, _ := procCoSetProxyBlanket.Call(
+		uintptr(unsafe.Pointer(obj)),
+		rpcCAuthnDefault,
+		rpcCAuthzDefault,
+		0,
+		uintptr(p.authnLevel),
+		uintptr(p.impLevel),
+		0,
+		eoacDefault,
+	)
+	if hr != ole.S_OK && hr != ole.E_NOINTERFACE {
+		return ole.NewError(hr)
+	}
+
+	return nil
+}
+
+// forEach is like oleutil.ForEach, but sets the proxy blanket passed to Connect
+// with WithProxyBlanket on the objects of collection before fn is called.
+func (t *TaskService) forEach(collection *ole.IDispatch, fn func(v *ole.VARIANT) error) error {
+	blanket := t.connectOptions.proxyBlanket
+	if blanket == nil {
+		return oleutil.ForEach(collection, fn)
+	}
+
+	return oleutil.ForEach(collection, func(v *ole.VARIANT) error {
+		if v.VT == ole.VT_DISPATCH {
+			if err := blanket.apply(v.ToIDispatch()); err != nil {
+				v.Clear()
+				return fmt.Errorf("error setting the proxy blanket: %v", err)
+			}
+		}
+
+		return fn(v)
+	})
+}
+
+func folderCacheKey(path string) string {
+	if path != `\` {
+		path = strings.TrimSuffix(path, `\`)
+	}
+
+	return strings.ToLower(path)
+}
+
+// get returns the cached folder COM object at path, if any. The returned object
+// must be released.
+func (c *folderCache) get(path string) (*ole.IDispatch, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	folderObj, ok := c.folders[folderCacheKey(path)]
+	if ok {
+		folderObj.AddRef()
+	}
+
+	return folderObj, ok
+}
+
+// put caches the folder COM object at path if an operation is in progress. The
+// cache keeps its own reference to folderObj, so the caller must still release it.
+func (c *folderCache) put(path string, folderObj *ole.IDispatch) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.operations == 0 {
+		return
+	}
+	if c.folders == nil {
+		c.folders = make(map[string]*ole.IDispatch)
+	}
+	key := folderCacheKey(path)
+	if cached, ok := c.folders[key]; ok {
+		cached.Release()
+	}
+	folderObj.AddRef()
+	c.folders[key] = folderObj
+}
+
+// begin starts an operation, so that folders are cached until every operation
+// in progress has ended.
+func (c *folderCache) begin() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.operations++
+}
+
+// end ends an operation started by begin, and releases every cached folder COM
+// object if no other operation is in progress.
+func (c *folderCache) end() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.operations--
+	if c.operations == 0 {
+		for _, folderObj := range c.folders {
+			folderObj.Release()
+		}
+		c.folders = nil
+	}
+}
+
+// invalidate removes the folder at path and all of its subfolders from the cache.
+func (c *folderCache) invalidate(path string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := folderCacheKey(path)
+	for cachedKey, folderObj := range c.folders {
+		if cachedKey == key || strings.HasPrefix(cachedKey, strings.TrimSuffix(key, `\`)+`\`) {
+			folderObj.Release()
+			delete(c.folders, cachedKey)
+		}
+	}
+}
+
+// clear releases every cached folder COM object.
+func (c *folderCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, folderObj := range c.folders {
+		folderObj.Release()
+	}
+	c.folders = nil
+}
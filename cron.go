@@ -0,0 +1,317 @@
+package taskmaster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+// maxTriggers is the maximum number of triggers a task can have.
+const maxTriggers = 48
+
+const minutesPerDay = 24 * 60
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var cronDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronField is a parsed field of a cron expression.
+type cronField struct {
+	values     []int // the values the field matches, sorted
+	restricted bool  // false if the field matches every value
+	star       bool  // true if the field started with "*"
+}
+
+// TriggersFromCron converts a standard 5-field cron expression (minute, hour,
+// day of month, month and day of week) into triggers that fire at the same times.
+// Fields can be "*", numbers, ranges, lists and steps, such as "*/15" or
+// "1-5,10"; months and days of the week can also be three letter names, such as
+// "jan" or "mon". The macros @yearly, @annually, @monthly, @weekly, @daily,
+// @midnight and @hourly are also accepted.
+//
+// The triggers start today in the local time zone. Times of day that are evenly
+// spaced are expressed with a repetition pattern, otherwise a trigger is created
+// for every time of day. As in cron, if both the day of month and day of week
+// are restricted and neither starts with "*", the task runs when either matches;
+// otherwise it runs when both match. An error is returned if both must match and
+// both are restricted, such as in "0 0 */2 * mon", as that can't be expressed
+// with triggers, or if the expression needs more triggers than a task can have.
+func TriggersFromCron(expr string) ([]Trigger, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	minutes, err := parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: minute: %v", expr, err)
+	}
+	hours, err := parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: hour: %v", expr, err)
+	}
+	daysOfMonth, err := parseCronField(fields[2], 1, 31, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: day of month: %v", expr, err)
+	}
+	months, err := parseCronField(fields[3], 1, 12, cronMonthNames)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: month: %v", expr, err)
+	}
+	daysOfWeek, err := parseCronField(fields[4], 0, 7, cronDayNames)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: day of week: %v", expr, err)
+	}
+
+	if !daysOfMonth.star && !daysOfWeek.star {
+		// the task runs when either matches, so it runs every day if either
+		// matches every day
+		if !daysOfMonth.restricted || !daysOfWeek.restricted {
+			daysOfMonth.restricted, daysOfWeek.restricted = false, false
+		}
+	} else if daysOfMonth.restricted && daysOfWeek.restricted {
+		return nil, fmt.Errorf("invalid cron expression %q: days that match both the day of month and the day of week can't be expressed with triggers", expr)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	everyDay := !daysOfMonth.restricted && !daysOfWeek.restricted && !months.restricted
+	starts, repetition := cronTimesOfDay(minutes.values, hours.values, everyDay)
+
+	var triggers []Trigger
+	for _, start := range starts {
+		startTime := today.Add(time.Duration(start) * time.Minute)
+		if everyDay && !repetition.RepetitionInterval.IsZero() && repetition.RepetitionDuration.IsZero() {
+			// the task repeats all day, every day
+			triggers = append(triggers, TimeTrigger{TaskTrigger: newTaskTrigger(startTime, []RepetitionPattern{repetition})})
+			continue
+		}
+		triggers = append(triggers, cronDayTriggers(startTime, repetition, daysOfMonth, months, daysOfWeek)...)
+	}
+	if len(triggers) > maxTriggers {
+		return nil, fmt.Errorf("invalid cron expression %q: %d triggers are needed, but a task can have at most %d", expr, len(triggers), maxTriggers)
+	}
+
+	return triggers, nil
+}
+
+// cronTimesOfDay returns the minutes of the day when triggers should start, and
+// the repetition pattern of each trigger. If everyDay is true and the times of
+// day are evenly spaced across the whole day, the repetition pattern repeats
+// indefinitely.
+func cronTimesOfDay(minutes, hours []int, everyDay bool) ([]int, RepetitionPattern) {
+	times := make([]int, 0, len(minutes)*len(hours))
+	for _, hour := range hours {
+		for _, minute := range minutes {
+			times = append(times, hour*60+minute)
+		}
+	}
+	if len(times) == 1 {
+		return times, RepetitionPattern{}
+	}
+
+	// the times of the whole day are evenly spaced
+	if interval, ok := cronStep(times); ok {
+		if everyDay && interval*len(times) == minutesPerDay {
+			return times[:1], RepetitionPattern{RepetitionInterval: minutesToPeriod(interval)}
+		}
+		return times[:1], RepetitionPattern{
+			RepetitionInterval: minutesToPeriod(interval),
+			RepetitionDuration: minutesToPeriod(times[len(times)-1] - times[0] + 1),
+		}
+	}
+
+	// the times of every hour are evenly spaced
+	if interval, ok := cronStep(minutes); ok {
+		starts := make([]int, len(hours))
+		for i, hour := range hours {
+			starts[i] = hour*60 + minutes[0]
+		}
+		return starts, RepetitionPattern{
+			RepetitionInterval: minutesToPeriod(interval),
+			RepetitionDuration: minutesToPeriod(minutes[len(minutes)-1] - minutes[0] + 1),
+		}
+	}
+
+	return times, RepetitionPattern{}
+}
+
+// cronDayTriggers returns the triggers that start at the time of day of start
+// on the days matched by daysOfMonth, months and daysOfWeek.
+func cronDayTriggers(start time.Time, repetition RepetitionPattern, daysOfMonth, months, daysOfWeek cronField) []Trigger {
+	taskTrigger := newTaskTrigger(start, []RepetitionPattern{repetition})
+
+	var monthsOfYear Month
+	for _, month := range months.values {
+		monthsOfYear |= 1 << uint(month-1)
+	}
+	var weekDays DayOfWeek
+	for _, day := range daysOfWeek.values {
+		weekDays |= 1 << uint(day)
+	}
+
+	var triggers []Trigger
+	if daysOfMonth.restricted || !daysOfWeek.restricted {
+		if !daysOfMonth.restricted && !months.restricted {
+			return []Trigger{DailyTrigger{TaskTrigger: taskTrigger, DayInterval: EveryDay}}
+		}
+
+		var monthDays DayOfMonth
+		for _, day := range daysOfMonth.values {
+			monthDays |= 1 << uint(day-1)
+		}
+		triggers = append(triggers, MonthlyTrigger{
+			TaskTrigger:  taskTrigger,
+			DaysOfMonth:  monthDays,
+			MonthsOfYear: monthsOfYear,
+		})
+	}
+	if daysOfWeek.restricted {
+		if months.restricted {
+			triggers = append(triggers, MonthlyDOWTrigger{
+				TaskTrigger:  taskTrigger,
+				DaysOfWeek:   weekDays,
+				MonthsOfYear: monthsOfYear,
+				WeeksOfMonth: AllWeeks,
+			})
+		} else {
+			triggers = append(triggers, WeeklyTrigger{
+				TaskTrigger:  taskTrigger,
+				DaysOfWeek:   weekDays,
+				WeekInterval: EveryWeek,
+			})
+		}
+	}
+
+	return triggers
+}
+
+// parseCronField parses a field of a cron expression whose values are between
+// min and max. names are the names of the values starting from min, if any.
+// The day of week 7 is converted to 0, as both mean Sunday.
+func parseCronField(field string, min, max int, names []string) (cronField, error) {
+	matched := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i != -1 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return cronField{}, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*":
+			low, high = min, max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return cronField{}, err
+			}
+			if high, err = parseCronValue(bounds[1], min, max, names); err != nil {
+				return cronField{}, err
+			}
+			if low > high {
+				return cronField{}, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			var err error
+			if low, err = parseCronValue(rangePart, min, max, names); err != nil {
+				return cronField{}, err
+			}
+			high = low
+			if step > 1 {
+				// "5/15" means from 5 to max, every 15
+				high = max
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			matched[value] = true
+		}
+	}
+	if max == 7 && matched[7] {
+		delete(matched, 7)
+		matched[0] = true
+	}
+
+	values := make([]int, 0, len(matched))
+	for value := range matched {
+		values = append(values, value)
+	}
+	sort.Ints(values)
+
+	count := max - min + 1
+	if max == 7 {
+		count--
+	}
+
+	return cronField{
+		values:     values,
+		restricted: len(values) != count,
+		star:       strings.HasPrefix(field, "*"),
+	}, nil
+}
+
+func parseCronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("value %d is not between %d and %d", value, min, max)
+	}
+
+	return value, nil
+}
+
+// cronStep returns the difference between consecutive values of values, and
+// true if all the values are evenly spaced.
+func cronStep(values []int) (int, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+
+	step := values[1] - values[0]
+	for i := 2; i < len(values); i++ {
+		if values[i]-values[i-1] != step {
+			return 0, false
+		}
+	}
+
+	return step, true
+}
+
+func minutesToPeriod(minutes int) period.Period {
+	return period.NewHMS(minutes/60, minutes%60, 0)
+}
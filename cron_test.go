@@ -0,0 +1,117 @@
+package taskmaster
+
+import (
+	"testing"
+
+	"github.com/rickb777/date/period"
+)
+
+func TestTriggersFromCron(t *testing.T) {
+	triggers, err := TriggersFromCron("30 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily, ok := triggers[0].(DailyTrigger)
+	if len(triggers) != 1 || !ok || daily.StartBoundary.Hour() != 3 || daily.StartBoundary.Minute() != 30 {
+		t.Fatalf("unexpected triggers for a daily expression: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeTrigger, ok := triggers[0].(TimeTrigger)
+	if len(triggers) != 1 || !ok || timeTrigger.RepetitionInterval != period.NewHMS(0, 15, 0) || !timeTrigger.RepetitionDuration.IsZero() {
+		t.Fatalf("unexpected triggers for an expression repeating all day: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("0 9-17 * * mon-fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekly, ok := triggers[0].(WeeklyTrigger)
+	if len(triggers) != 1 || !ok || weekly.DaysOfWeek != Monday|Tuesday|Wednesday|Thursday|Friday ||
+		weekly.StartBoundary.Hour() != 9 || weekly.RepetitionInterval != period.NewHMS(1, 0, 0) ||
+		weekly.RepetitionDuration != period.NewHMS(8, 1, 0) {
+		t.Fatalf("unexpected triggers for a weekday expression: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("0 0 1,15 * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 2 {
+		t.Fatalf("expected a monthly and a weekly trigger, got %+v", triggers)
+	}
+	if monthly, ok := triggers[0].(MonthlyTrigger); !ok || monthly.DaysOfMonth != 1|1<<14 || monthly.MonthsOfYear != AllMonths {
+		t.Fatalf("unexpected monthly trigger: %+v", triggers[0])
+	}
+	if weekly, ok := triggers[1].(WeeklyTrigger); !ok || weekly.DaysOfWeek != Sunday {
+		t.Fatalf("unexpected weekly trigger: %+v", triggers[1])
+	}
+
+	triggers, err = TriggersFromCron("0 12 * jan,jul 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monthlyDOW, ok := triggers[0].(MonthlyDOWTrigger); len(triggers) != 1 || !ok || monthlyDOW.DaysOfWeek != Sunday || monthlyDOW.MonthsOfYear != January|July {
+		t.Fatalf("unexpected triggers for a monthly day of week expression: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("0 0 */2 * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var oddDays DayOfMonth
+	for day := 1; day <= 31; day += 2 {
+		oddDays |= 1 << uint(day-1)
+	}
+	if monthly, ok := triggers[0].(MonthlyTrigger); len(triggers) != 1 || !ok || monthly.DaysOfMonth != oddDays || monthly.MonthsOfYear != AllMonths {
+		t.Fatalf("unexpected triggers for an expression with a day of month step: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("0 0 * * */2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weekly, ok := triggers[0].(WeeklyTrigger); len(triggers) != 1 || !ok || weekly.DaysOfWeek != Sunday|Tuesday|Thursday|Saturday {
+		t.Fatalf("unexpected triggers for an expression with a day of week step: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("0 0 * */3 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monthly, ok := triggers[0].(MonthlyTrigger); len(triggers) != 1 || !ok || monthly.MonthsOfYear != January|April|July|October {
+		t.Fatalf("unexpected triggers for an expression with a month step: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("0 0 1-31 * mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := triggers[0].(DailyTrigger); len(triggers) != 1 || !ok {
+		t.Fatalf("unexpected triggers for an expression matching every day of the month: %+v", triggers)
+	}
+
+	triggers, err = TriggersFromCron("5,20 8,12 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 2 || triggers[1].GetStartBoundary().Hour() != 12 || triggers[1].GetRepetitionInterval() != period.NewHMS(0, 15, 0) {
+		t.Fatalf("unexpected triggers for an expression repeating every hour: %+v", triggers)
+	}
+
+	if triggers, err = TriggersFromCron("@weekly"); err != nil || len(triggers) != 1 {
+		t.Fatalf("unexpected result for a macro: %+v, %v", triggers, err)
+	}
+	if err = validateTriggers(triggers); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "1,2,4 * * * *", "0 0 */2 * mon"} {
+		if _, err = TriggersFromCron(expr); err == nil {
+			t.Errorf("converting %q should fail", expr)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package taskmaster
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+// diffIgnoredFields are the fields of a definition that aren't compared by
+// Equal and Diff, as the Task Scheduler service sets them when a task is registered.
+var diffIgnoredFields = map[string]bool{
+	"AfterFill":             true,
+	"RegistrationInfo.Date": true,
+	"XMLText":               true,
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	periodType = reflect.TypeOf(period.Period{})
+)
+
+// Equal returns true if the definitions are semantically equal. See Diff
+// for details on how definitions are compared.
+func (d Definition) Equal(other Definition) bool {
+	return len(d.Diff(other)) == 0
+}
+
+// Diff returns a human-readable description of each field that differs between
+// the definitions. Times are compared with the precision that the Task Scheduler
+// service stores them with, periods are normalized before they are compared, and
+// AfterFill, RegistrationInfo.Date and XMLText are ignored. If the definitions
+// are equal, Diff returns nil.
+func (d Definition) Diff(other Definition) []string {
+	var changes []Change
+	diffValues("", reflect.ValueOf(d), reflect.ValueOf(other), &changes)
+
+	var diffs []string
+	for _, change := range changes {
+		diffs = append(diffs, fmt.Sprintf("%s: %s != %s", change.Path, change.Old, change.New))
+	}
+
+	return diffs
+}
+
+// DiffDefinitions returns the changes needed to turn definition a into definition
+// b. Fields are compared like Diff does, and nil pointers are equal to pointers
+// to zero values, but the order of triggers and actions is not significant:
+// triggers and actions that are in both definitions are matched regardless of
+// their position. Triggers or actions that remain unmatched are compared with
+// the remaining ones of the same type, in order, and reported field by field;
+// any that are left over are reported as added or removed. The path of a
+// trigger or action is its index in a, or in b if it was added. If the
+// definitions are equal, DiffDefinitions returns nil.
+func DiffDefinitions(a, b Definition) []Change {
+	var changes []Change
+
+	aActions, bActions := a.Actions, b.Actions
+	aTriggers, bTriggers := a.Triggers, b.Triggers
+	a.Actions, b.Actions = nil, nil
+	a.Triggers, b.Triggers = nil, nil
+	diffValues("", reflect.ValueOf(a), reflect.ValueOf(b), &changes)
+
+	diffUnordered("Actions", reflect.ValueOf(aActions), reflect.ValueOf(bActions), &changes)
+	diffUnordered("Triggers", reflect.ValueOf(aTriggers), reflect.ValueOf(bTriggers), &changes)
+
+	return changes
+}
+
+// withRegisteredDefaults returns desired with the fields that the Task Scheduler
+// service fills in when a task is registered, and that desired leaves empty,
+// copied from registered, so that the definitions can be compared.
+func withRegisteredDefaults(desired, registered Definition) Definition {
+	if desired.Context == "" {
+		desired.Context = registered.Context
+	}
+	if desired.Principal.ID == "" {
+		desired.Principal.ID = registered.Principal.ID
+	}
+	if desired.Principal.Name == "" {
+		desired.Principal.Name = registered.Principal.Name
+	}
+	// the connected user is used if neither a user nor a group is set, and the
+	// Task Scheduler service may store the user with or without its domain
+	if (desired.Principal.UserID == "" && desired.Principal.GroupID == "") ||
+		strings.EqualFold(trimUsernameDomain(desired.Principal.UserID), trimUsernameDomain(registered.Principal.UserID)) {
+		desired.Principal.UserID = registered.Principal.UserID
+	}
+	// the process token SID type only applies to service accounts
+	if desired.Principal.LogonType != TASK_LOGON_SERVICE_ACCOUNT {
+		desired.Principal.ProcessTokenSidType = registered.Principal.ProcessTokenSidType
+	}
+	if desired.RegistrationInfo.URI == "" {
+		desired.RegistrationInfo.URI = registered.RegistrationInfo.URI
+	}
+	if desired.RegistrationInfo.SecurityDescriptor == "" {
+		desired.RegistrationInfo.SecurityDescriptor = registered.RegistrationInfo.SecurityDescriptor
+	}
+
+	// actions are given IDs when they are registered, which aren't significant
+	actions := make([]Action, len(desired.Actions))
+	for i, action := range desired.Actions {
+		if i < len(registered.Actions) && action.GetID() == "" && action.GetType() == registered.Actions[i].GetType() {
+			action = withActionID(action, registered.Actions[i].GetID())
+		}
+		actions[i] = action
+	}
+	desired.Actions = actions
+
+	// registered monthly triggers only set RunOnLastDayOfMonth
+	triggers := make([]Trigger, len(desired.Triggers))
+	for i, trigger := range desired.Triggers {
+		if monthly, ok := trigger.(MonthlyTrigger); ok && monthly.RunOnLastWeekOfMonth {
+			monthly.RunOnLastDayOfMonth, monthly.RunOnLastWeekOfMonth = true, false
+			trigger = monthly
+		}
+		for _, registeredTrigger := range registered.Triggers {
+			if sameScheduleStartingLater(trigger, registeredTrigger) {
+				trigger = withStartBoundary(trigger, registeredTrigger.GetStartBoundary())
+				break
+			}
+		}
+		triggers[i] = trigger
+	}
+	desired.Triggers = triggers
+
+	return desired
+}
+
+// sameScheduleStartingLater returns true if desired is a trigger that runs every
+// day, every week or on days of the month, and only differs from registered by
+// starting on a later day at the same time of day. Such triggers run at the same
+// times from the day desired starts on, so a definition built with TaskBuilder or
+// TriggersFromCron, which start their triggers on the day they are built, doesn't
+// replace the registered task every day.
+func sameScheduleStartingLater(desired, registered Trigger) bool {
+	switch trigger := desired.(type) {
+	case DailyTrigger:
+		if trigger.DayInterval != EveryDay {
+			return false
+		}
+	case WeeklyTrigger:
+		if trigger.WeekInterval != EveryWeek {
+			return false
+		}
+	case MonthlyTrigger, MonthlyDOWTrigger:
+	default:
+		return false
+	}
+
+	desiredStart, registeredStart := desired.GetStartBoundary(), registered.GetStartBoundary()
+	if desiredStart.IsZero() || registeredStart.IsZero() || desiredStart.Before(registeredStart) {
+		return false
+	}
+	desiredHour, desiredMinute, desiredSecond := desiredStart.Clock()
+	registeredHour, registeredMinute, registeredSecond := registeredStart.Clock()
+	if desiredHour != registeredHour || desiredMinute != registeredMinute || desiredSecond != registeredSecond {
+		return false
+	}
+
+	var changes []Change
+	diffValues("", reflect.ValueOf(withStartBoundary(desired, registeredStart)), reflect.ValueOf(registered), &changes)
+
+	return len(changes) == 0
+}
+
+// withStartBoundary returns a copy of the trigger with its start boundary set to
+// start. Only the trigger types that sameScheduleStartingLater accepts are
+// supported.
+func withStartBoundary(trigger Trigger, start time.Time) Trigger {
+	switch t := trigger.(type) {
+	case DailyTrigger:
+		t.StartBoundary = start
+		return t
+	case WeeklyTrigger:
+		t.StartBoundary = start
+		return t
+	case MonthlyTrigger:
+		t.StartBoundary = start
+		return t
+	case MonthlyDOWTrigger:
+		t.StartBoundary = start
+		return t
+	}
+
+	return trigger
+}
+
+// withActionID returns a copy of action with its ID set to id.
+func withActionID(action Action, id string) Action {
+	switch a := action.(type) {
+	case ExecAction:
+		a.ID = id
+		return a
+	case ComHandlerAction:
+		a.ID = id
+		return a
+	case EmailAction:
+		a.ID = id
+		return a
+	case ShowMessageAction:
+		a.ID = id
+		return a
+	default:
+		return action
+	}
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case CHANGE_ADDED:
+		return fmt.Sprintf("%s added: %s", c.Path, c.New)
+	case CHANGE_REMOVED:
+		return fmt.Sprintf("%s removed: %s", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("%s changed from %s to %s", c.Path, c.Old, c.New)
+	}
+}
+
+func diffValues(path string, a, b reflect.Value, changes *[]Change) {
+	if diffIgnoredFields[path] {
+		return
+	}
+
+	modified := func(format string, aValue, bValue interface{}) {
+		*changes = append(*changes, Change{
+			Type: CHANGE_MODIFIED,
+			Path: path,
+			Old:  fmt.Sprintf(format, aValue),
+			New:  fmt.Sprintf(format, bValue),
+		})
+	}
+
+	switch a.Type() {
+	case timeType:
+		aTime := TimeToTaskDate(a.Interface().(time.Time))
+		bTime := TimeToTaskDate(b.Interface().(time.Time))
+		if aTime != bTime {
+			modified("%q", aTime, bTime)
+		}
+		return
+	case periodType:
+		aPeriod := PeriodToString(a.Interface().(period.Period).Normalise(true))
+		bPeriod := PeriodToString(b.Interface().(period.Period).Normalise(true))
+		if aPeriod != bPeriod {
+			modified("%q", aPeriod, bPeriod)
+		}
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				modified("%v", a.Interface(), b.Interface())
+			}
+			return
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			modified("%s", a.Elem().Type().Name(), b.Elem().Type().Name())
+			return
+		}
+		diffValues(path, a.Elem(), b.Elem(), changes)
+	case reflect.Ptr:
+		// a nil pointer is equal to a pointer to a zero value
+		diffValues(path, derefOrZero(a), derefOrZero(b), changes)
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			field := a.Type().Field(i)
+			fieldPath := path
+			if !field.Anonymous {
+				fieldPath = joinDiffPath(path, field.Name)
+			}
+			diffValues(fieldPath, a.Field(i), b.Field(i), changes)
+		}
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			modified("%d items", a.Len(), b.Len())
+		}
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i), changes)
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for key := range keys {
+			sortedKeys = append(sortedKeys, key)
+		}
+		sort.Strings(sortedKeys)
+
+		for _, key := range sortedKeys {
+			keyPath := fmt.Sprintf("%s[%s]", path, key)
+			aValue, bValue := a.MapIndex(keys[key]), b.MapIndex(keys[key])
+			if !aValue.IsValid() || !bValue.IsValid() {
+				change := Change{Type: CHANGE_MODIFIED, Path: keyPath, Old: "<missing>", New: "<missing>"}
+				if aValue.IsValid() {
+					change.Old = fmt.Sprint(aValue.Interface())
+				} else {
+					change.New = fmt.Sprint(bValue.Interface())
+				}
+				*changes = append(*changes, change)
+				continue
+			}
+			diffValues(keyPath, aValue, bValue, changes)
+		}
+	case reflect.String:
+		if a.String() != b.String() {
+			modified("%q", a.String(), b.String())
+		}
+	default:
+		if a.Interface() != b.Interface() {
+			modified("%v", a.Interface(), b.Interface())
+		}
+	}
+}
+
+// diffUnordered compares the slices of interfaces a and b without regard to the
+// order of their items. See DiffDefinitions for how items are matched.
+func diffUnordered(path string, a, b reflect.Value, changes *[]Change) {
+	matched := make([]bool, b.Len())
+	var unmatchedA []int
+	for i := 0; i < a.Len(); i++ {
+		found := false
+		for j := 0; j < b.Len(); j++ {
+			if !matched[j] && valuesEqual(a.Index(i), b.Index(j)) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatchedA = append(unmatchedA, i)
+		}
+	}
+
+	// pair the remaining items of the same type in order
+	for _, i := range unmatchedA {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		paired := false
+		for j := 0; j < b.Len(); j++ {
+			if !matched[j] && !a.Index(i).IsNil() && !b.Index(j).IsNil() && a.Index(i).Elem().Type() == b.Index(j).Elem().Type() {
+				matched[j] = true
+				paired = true
+				diffValues(itemPath, a.Index(i), b.Index(j), changes)
+				break
+			}
+		}
+		if !paired {
+			*changes = append(*changes, Change{
+				Type: CHANGE_REMOVED,
+				Path: itemPath,
+				Old:  describeValue(a.Index(i)),
+			})
+		}
+	}
+
+	for j := 0; j < b.Len(); j++ {
+		if !matched[j] {
+			*changes = append(*changes, Change{
+				Type: CHANGE_ADDED,
+				Path: fmt.Sprintf("%s[%d]", path, j),
+				New:  describeValue(b.Index(j)),
+			})
+		}
+	}
+}
+
+func valuesEqual(a, b reflect.Value) bool {
+	var changes []Change
+	diffValues("", a, b, &changes)
+
+	return len(changes) == 0
+}
+
+// describeValue formats a trigger or action with its type name.
+func describeValue(v reflect.Value) string {
+	if v.IsNil() {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("%s%+v", v.Elem().Type().Name(), v.Elem().Interface())
+}
+
+func derefOrZero(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+
+	return v.Elem()
+}
+
+func joinDiffPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,177 @@
+package taskmaster
+
+import (
+	"testing"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/rickb777/date/period"
+)
+
+func TestDefinitionDiff(t *testing.T) {
+	start := time.Now()
+
+	def := Definition{}
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.AddTrigger(mustTrigger(NewDailyTrigger(start, EveryDay)))
+	def.Settings.TimeLimit = period.NewHMS(0, 60, 0)
+	def.RegistrationInfo.Date = start
+
+	// the Task Scheduler service stores times without sub-second precision
+	// or monotonic clock readings, and normalizes periods
+	other := Definition{}
+	other.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	other.AddTrigger(mustTrigger(NewDailyTrigger(start.Round(0).Truncate(time.Second), EveryDay)))
+	other.Settings.TimeLimit = period.NewHMS(1, 0, 0)
+	other.Settings.MaintenanceSettings = &MaintenanceSettings{}
+	other.AfterFill = func(*ole.IDispatch) error { return nil }
+	if !def.Equal(other) {
+		t.Fatalf("definitions should be equal, got differences: %v", def.Diff(other))
+	}
+
+	other.Actions[0] = ExecAction{
+		Path: "notepad.exe",
+	}
+	other.AddTrigger(mustTrigger(NewBootTrigger(period.Period{})))
+	other.Principal.RunLevel = TASK_RUNLEVEL_HIGHEST
+	diffs := def.Diff(other)
+	if len(diffs) != 3 {
+		t.Fatalf("should have 3 differences, got %d instead: %v", len(diffs), diffs)
+	}
+}
+
+func TestDiffDefinitions(t *testing.T) {
+	start := time.Now()
+
+	def := Definition{}
+	def.AddAction(ExecAction{Path: "backup.exe", Args: "/full"})
+	def.AddAction(ComHandlerAction{ClassID: "{00000000-0000-0000-0000-000000000000}"})
+	def.AddTrigger(mustTrigger(NewBootTrigger(period.Period{})))
+	def.AddTrigger(mustTrigger(NewDailyTrigger(start, EveryDay)))
+
+	// the order of actions and triggers is not significant
+	other := Definition{}
+	other.AddAction(ComHandlerAction{ClassID: "{00000000-0000-0000-0000-000000000000}"})
+	other.AddAction(ExecAction{Path: "backup.exe", Args: "/full"})
+	other.AddTrigger(mustTrigger(NewDailyTrigger(start, EveryDay)))
+	other.AddTrigger(mustTrigger(NewBootTrigger(period.Period{})))
+	if changes := DiffDefinitions(def, other); len(changes) != 0 {
+		t.Fatalf("definitions should be equal, got changes: %v", changes)
+	}
+
+	other.Actions[1] = ExecAction{Path: "backup.exe", Args: "/incremental"}
+	other.Triggers = other.Triggers[:1]
+	other.AddTrigger(mustTrigger(NewTimeTrigger(start)))
+	other.Settings.Priority = 4
+	changes := DiffDefinitions(def, other)
+
+	expected := []Change{
+		{Type: CHANGE_MODIFIED, Path: "Settings.Priority", Old: "0", New: "4"},
+		{Type: CHANGE_MODIFIED, Path: "Actions[0].Args", Old: `"/full"`, New: `"/incremental"`},
+	}
+	if len(changes) != 4 {
+		t.Fatalf("should have 4 changes, got %d instead: %v", len(changes), changes)
+	}
+	for i, change := range expected {
+		if changes[i] != change {
+			t.Errorf("expected change %v, got %v", change, changes[i])
+		}
+	}
+	if changes[2].Type != CHANGE_REMOVED || changes[2].Path != "Triggers[0]" {
+		t.Errorf("expected the boot trigger to be removed, got %v", changes[2])
+	}
+	if changes[3].Type != CHANGE_ADDED || changes[3].Path != "Triggers[1]" {
+		t.Errorf("expected the time trigger to be added, got %v", changes[3])
+	}
+}
+
+func TestWithRegisteredDefaults(t *testing.T) {
+	desired := Definition{}
+	desired.AddAction(ExecAction{Path: "calc.exe"})
+
+	registered := Definition{}
+	registered.AddAction(ExecAction{ID: "Action1", Path: "calc.exe"})
+	registered.Context = "Author"
+	registered.Principal = Principal{ID: "Author", UserID: "DOMAIN\\user"}
+	registered.RegistrationInfo.URI = "\\Taskmaster\\Task"
+	if changes := DiffDefinitions(registered, withRegisteredDefaults(desired, registered)); len(changes) != 0 {
+		t.Fatalf("definitions should be equal, got changes: %v", changes)
+	}
+
+	desired.Principal.UserID = "user"
+	if changes := DiffDefinitions(registered, withRegisteredDefaults(desired, registered)); len(changes) != 0 {
+		t.Fatalf("users with and without a domain should be equal, got changes: %v", changes)
+	}
+
+	start := time.Date(2020, time.January, 1, 8, 0, 0, 0, time.UTC)
+	desired.Principal.UserID = ""
+	desired.AddTrigger(MonthlyTrigger{TaskTrigger: TaskTrigger{StartBoundary: start}, MonthsOfYear: AllMonths, RunOnLastWeekOfMonth: true})
+	registered.AddTrigger(MonthlyTrigger{TaskTrigger: TaskTrigger{StartBoundary: start}, MonthsOfYear: AllMonths, RunOnLastDayOfMonth: true})
+	if changes := DiffDefinitions(registered, withRegisteredDefaults(desired, registered)); len(changes) != 0 {
+		t.Fatalf("the deprecated RunOnLastWeekOfMonth should equal RunOnLastDayOfMonth, got changes: %v", changes)
+	}
+
+	desired.Principal.UserID = "other"
+	if changes := DiffDefinitions(registered, withRegisteredDefaults(desired, registered)); len(changes) != 1 {
+		t.Fatalf("should have 1 change, got %d instead: %v", len(changes), changes)
+	}
+}
+
+func TestWithRegisteredDefaultsLaterStart(t *testing.T) {
+	start := time.Date(2021, time.January, 1, 3, 0, 0, 0, time.Local)
+	tests := []struct {
+		name       string
+		registered Trigger
+		desired    Trigger
+		equal      bool
+	}{
+		{
+			"daily trigger starting a day later",
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DayInterval: EveryDay},
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start.AddDate(0, 0, 1)}, DayInterval: EveryDay},
+			true,
+		},
+		{
+			"weekly trigger starting a month later",
+			WeeklyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DaysOfWeek: Monday, WeekInterval: EveryWeek},
+			WeeklyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start.AddDate(0, 1, 0)}, DaysOfWeek: Monday, WeekInterval: EveryWeek},
+			true,
+		},
+		{
+			"daily trigger starting a day earlier",
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DayInterval: EveryDay},
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start.AddDate(0, 0, -1)}, DayInterval: EveryDay},
+			false,
+		},
+		{
+			"daily trigger at another time",
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DayInterval: EveryDay},
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start.Add(25 * time.Hour)}, DayInterval: EveryDay},
+			false,
+		},
+		{
+			"trigger every other day starting a day later",
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DayInterval: EveryOtherDay},
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start.AddDate(0, 0, 1)}, DayInterval: EveryOtherDay},
+			false,
+		},
+		{
+			"time trigger starting a day later",
+			TimeTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}},
+			TimeTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start.AddDate(0, 0, 1)}},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		registered := Definition{Triggers: []Trigger{test.registered}}
+		desired := Definition{Triggers: []Trigger{test.desired}}
+		if changes := DiffDefinitions(registered, withRegisteredDefaults(desired, registered)); (len(changes) == 0) != test.equal {
+			t.Errorf("%s: expected equal to be %t, got changes: %v", test.name, test.equal, changes)
+		}
+	}
+}
@@ -1,10 +1,8 @@
-//go:build windows
-// +build windows
-
 package taskmaster
 
 import (
 	"errors"
+	"fmt"
 	"syscall"
 
 	ole "github.com/go-ole/go-ole"
@@ -17,41 +15,201 @@ var (
 	ErrNoActions            = errors.New("definition must have at least one action")
 	ErrInvalidPrincipal     = errors.New("both UserId and GroupId are defined for the principal; they are mutually exclusive")
 	ErrRunningTaskCompleted = errors.New("the running task completed while it was getting parsed")
+	ErrMalformedXML         = errors.New("the task XML is malformed")
+	ErrTaskNotFound         = errors.New("the registered task does not exist")
+	ErrFolderNotFound       = errors.New("the task folder does not exist")
+	ErrAlreadyExists        = errors.New("a registered task or task folder already exists at the specified path")
+	ErrNotFound             = errors.New("the registered task or task folder does not exist")
+	ErrAccessDenied         = errors.New("access is denied")
+	ErrInvalidTask          = errors.New("the task object could not be found or is invalid")
+	ErrAccountNotSet        = errors.New("the task's account information is not set")
+	ErrTaskNotV1Compat      = errors.New("the task is not compatible with the Task Scheduler 1.0 interface")
+	ErrServiceNotRunning    = errors.New("the Task Scheduler service is not running")
+	ErrLogonFailure         = errors.New("the user name or password is incorrect")
+	ErrAccountRestriction   = errors.New("the account is restricted from logging on, for example because it has a blank password")
+	ErrUnsupportedPlatform  = errors.New("the Task Scheduler service is only available on Windows")
+	ErrConnectionLost       = errors.New("the connection to the Task Scheduler service was lost")
+
+	ErrIdleWaitTimeoutTooShort = errors.New("invalid idle settings: WaitTimeout is shorter than IdleDuration")
+	ErrInvalidPriority         = errors.New("invalid task settings: Priority must be between 0 and 10")
+	ErrInvalidInstancesPolicy  = errors.New("invalid task settings: MultipleInstances is not a valid TaskInstancesPolicy")
+	ErrEndBoundaryBeforeStart  = errors.New("invalid trigger: EndBoundary is before StartBoundary")
+	ErrIncompatibleDefinition  = errors.New("invalid definition: a feature isn't supported by the compatibility level of the task")
 )
 
+// TaskSchedulerError is returned when a call to the Task Scheduler service fails.
+// Unwrapping it returns one of the sentinel errors above if the error code is
+// recognized, or the error code as a syscall.Errno otherwise.
+type TaskSchedulerError struct {
+	// Code is the HRESULT returned by the Task Scheduler service.
+	Code uint32
+	// Message describes the error.
+	Message string
+	// Op is the name of the Task Scheduler method that failed, if known.
+	Op string
+	// Path is the path of the registered task or task folder the method
+	// was called on, if known.
+	Path string
+	err  error
+}
+
+func (e *TaskSchedulerError) Error() string {
+	return fmt.Sprintf("%s (0x%08X)", e.Message, e.Code)
+}
+
+func (e *TaskSchedulerError) Unwrap() error {
+	return e.err
+}
+
+// TaskParseError describes a registered task that couldn't be parsed, such as
+// a task whose definition has a malformed duration. It is returned by
+// TaskService.GetRegisteredTasksWithErrors for each task that was skipped.
+type TaskParseError struct {
+	// Path is the path of the registered task, if it could be read.
+	Path string
+	// Err is the error that occurred while parsing the registered task.
+	Err error
+}
+
+func (e TaskParseError) Error() string {
+	return fmt.Sprintf("error parsing registered task %s: %v", e.Path, e.Err)
+}
+
+func (e TaskParseError) Unwrap() error {
+	return e.Err
+}
+
 func getTaskSchedulerError(err error) error {
+	return getTaskSchedulerPathError(err, "", "")
+}
+
+// getTaskSchedulerPathError converts an OLE error returned by the Task Scheduler
+// method op, called on the registered task or task folder at path, into a
+// *TaskSchedulerError.
+func getTaskSchedulerPathError(err error, op, path string) error {
 	errCode, parseErr := getOLEErrorCode(err)
 	if parseErr != nil {
 		return parseErr
 	}
 
+	var baseErr error
 	switch errCode {
 	case 50:
-		return ErrTargetUnsupported
+		baseErr = ErrTargetUnsupported
 	case 0x80070032, 53:
-		return ErrConnectionFailure
-	default:
-		return syscall.Errno(errCode)
+		baseErr = ErrConnectionFailure
+	case 0x8004131A, 0x80041316, 0x80041319: // SCHED_E_MALFORMEDXML, SCHED_E_UNEXPECTEDNODE, SCHED_E_MISSINGNODE
+		baseErr = ErrMalformedXML
+	case 0x80070002, 0x80070003: // ERROR_FILE_NOT_FOUND, ERROR_PATH_NOT_FOUND
+		baseErr = ErrNotFound
+	case 0x80070005: // E_ACCESSDENIED
+		baseErr = ErrAccessDenied
+	case 0x8004130E: // SCHED_E_INVALID_TASK
+		baseErr = ErrInvalidTask
+	case 0x8004130F: // SCHED_E_ACCOUNT_INFORMATION_NOT_SET
+		baseErr = ErrAccountNotSet
+	case 0x80041327: // SCHED_E_TASK_NOT_V1_COMPAT
+		baseErr = ErrTaskNotV1Compat
+	case 0x80041315: // SCHED_E_SERVICE_NOT_RUNNING
+		baseErr = ErrServiceNotRunning
+	case 0x8007052E: // ERROR_LOGON_FAILURE
+		baseErr = ErrLogonFailure
+	case 0x8007052F: // ERROR_ACCOUNT_RESTRICTION
+		baseErr = ErrAccountRestriction
+	case 0x800700B7: // ERROR_ALREADY_EXISTS
+		baseErr = ErrAlreadyExists
+	case 0x800706BA, 0x800706BE, 0x800706BF, 0x80010007, 0x80010012, 0x80010108: // RPC_S_SERVER_UNAVAILABLE, RPC_S_CALL_FAILED, RPC_S_CALL_FAILED_DNE, RPC_E_SERVER_DIED, RPC_E_SERVER_DIED_DNE, RPC_E_DISCONNECTED
+		baseErr = ErrConnectionLost
 	}
+
+	return newTaskSchedulerError(err, errCode, baseErr, op, path)
 }
 
-func getRunningTaskError(err error) error {
+func getRunningTaskError(err error, op, path string) error {
 	errCode, parseErr := getOLEErrorCode(err)
 	if parseErr != nil {
 		return parseErr
 	}
 
-	if errCode == 0x8004130B {
-		return ErrRunningTaskCompleted
+	var baseErr error
+	if errCode == 0x8004130B { // SCHED_E_TASK_NOT_RUNNING
+		baseErr = ErrRunningTaskCompleted
+	}
+
+	return newTaskSchedulerError(err, errCode, baseErr, op, path)
+}
+
+// newTaskSchedulerError creates a *TaskSchedulerError from an OLE error and its
+// error code. If baseErr is nil, the error code is wrapped as a syscall.Errno.
+func newTaskSchedulerError(err error, errCode uint32, baseErr error, op, path string) *TaskSchedulerError {
+	schedErr := &TaskSchedulerError{
+		Code: errCode,
+		Op:   op,
+		Path: path,
+		err:  baseErr,
+	}
+
+	if baseErr != nil {
+		schedErr.Message = baseErr.Error()
+		return schedErr
+	}
+
+	schedErr.err = syscall.Errno(errCode)
+	if excepInfo, ok := err.(*ole.OleError).SubError().(ole.EXCEPINFO); ok {
+		// the exception info has a description of the error from the
+		// Task Scheduler service, which is more helpful than the system
+		// message of the error code
+		schedErr.Message = excepInfo.Error()
+	} else {
+		schedErr.Message = schedErr.err.Error()
+	}
+
+	return schedErr
+}
+
+// isNotFoundError returns true if err is an OLE error signaling that a
+// task or task folder does not exist.
+func isNotFoundError(err error) bool {
+	errCode, parseErr := getOLEErrorCode(err)
+	if parseErr != nil {
+		return false
+	}
+
+	// ERROR_FILE_NOT_FOUND, ERROR_PATH_NOT_FOUND
+	return errCode == 0x80070002 || errCode == 0x80070003
+}
+
+// isAlreadyExistsError returns true if err is an OLE error signaling that a
+// task or task folder already exists.
+func isAlreadyExistsError(err error) bool {
+	errCode, parseErr := getOLEErrorCode(err)
+	if parseErr != nil {
+		return false
+	}
+
+	// ERROR_ALREADY_EXISTS
+	return errCode == 0x800700B7
+}
+
+// isFolderNotEmptyError returns true if err is an OLE error signaling that a
+// task folder couldn't be deleted because it isn't empty.
+func isFolderNotEmptyError(err error) bool {
+	errCode, parseErr := getOLEErrorCode(err)
+	if parseErr != nil {
+		return false
 	}
 
-	return syscall.Errno(errCode)
+	// ERROR_DIR_NOT_EMPTY
+	return errCode == 0x80070091
 }
 
 func getOLEErrorCode(err error) (uint32, error) {
 	if oleErr, ok1 := err.(*ole.OleError); ok1 {
 		if excepInfo, ok2 := oleErr.SubError().(ole.EXCEPINFO); ok2 {
 			return excepInfo.SCODE(), nil
+		} else if oleErr.SubError() == nil {
+			// errors from methods not called through IDispatch have no sub-error
+			return uint32(oleErr.Code()), nil
 		} else {
 			return uint32(oleErr.Code()), errors.New("failed to extract OLE sub-error code")
 		}
@@ -0,0 +1,75 @@
+package taskmaster
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestGetTaskSchedulerError(t *testing.T) {
+	tests := []struct {
+		code uintptr
+		err  error
+	}{
+		{0x80070002, ErrNotFound},
+		{0x80070005, ErrAccessDenied},
+		{0x8004130E, ErrInvalidTask},
+		{0x8004130F, ErrAccountNotSet},
+		{0x80041327, ErrTaskNotV1Compat},
+		{0x80041315, ErrServiceNotRunning},
+		{0x8007052E, ErrLogonFailure},
+		{0x8007052F, ErrAccountRestriction},
+		{0x800700B7, ErrAlreadyExists},
+		{0x800706BA, ErrConnectionLost},
+		{0x80010108, ErrConnectionLost},
+	}
+
+	for _, test := range tests {
+		err := fmt.Errorf("error: %w", getTaskSchedulerError(ole.NewError(test.code)))
+		if !errors.Is(err, test.err) {
+			t.Fatalf("expected 0x%X to map to %v, got %v", test.code, test.err, err)
+		}
+	}
+}
+
+func TestTaskSchedulerError(t *testing.T) {
+	err := fmt.Errorf("error: %w", getTaskSchedulerPathError(ole.NewError(0x80070005), "GetTask", "\\Taskmaster\\Task"))
+	var schedErr *TaskSchedulerError
+	if !errors.As(err, &schedErr) {
+		t.Fatalf("expected a *TaskSchedulerError, got %T", errors.Unwrap(err))
+	}
+	if schedErr.Code != 0x80070005 || schedErr.Op != "GetTask" || schedErr.Path != "\\Taskmaster\\Task" {
+		t.Fatalf("unexpected error fields: %+v", schedErr)
+	}
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected error to wrap ErrAccessDenied, got %v", err)
+	}
+
+	err = getRunningTaskError(ole.NewError(0x8004130B), "Stop", "\\Taskmaster\\Task")
+	if !errors.As(err, &schedErr) || schedErr.Op != "Stop" || schedErr.Path != "\\Taskmaster\\Task" {
+		t.Fatalf("unexpected running task error: %+v", err)
+	}
+	if !errors.Is(err, ErrRunningTaskCompleted) {
+		t.Fatalf("expected error to wrap ErrRunningTaskCompleted, got %v", err)
+	}
+
+	// unrecognized error codes should unwrap to the error code
+	err = getTaskSchedulerError(ole.NewError(0x80041301))
+	if !errors.Is(err, syscall.Errno(0x80041301)) {
+		t.Fatalf("expected error to wrap the error code, got %v", err)
+	}
+}
+
+func TestTaskParseError(t *testing.T) {
+	err := fmt.Errorf("error: %w", TaskParseError{Path: "\\Taskmaster\\Task", Err: ErrMalformedXML})
+	if !errors.Is(err, ErrMalformedXML) {
+		t.Fatalf("expected error to wrap ErrMalformedXML, got %v", err)
+	}
+	var parseErr TaskParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != "\\Taskmaster\\Task" {
+		t.Fatalf("expected a TaskParseError for \\Taskmaster\\Task, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+//go:build windows
+// +build windows
+
+package taskmaster
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// flags and error codes of the Windows Event Log API
+const (
+	evtQueryChannelPath      = 0x1
+	evtQueryForwardDirection = 0x100
+	evtRenderEventXML        = 1
+
+	errorInsufficientBuffer = 122
+	errorNoMoreItems        = 259
+)
+
+var (
+	modwevtapi = syscall.NewLazyDLL("wevtapi.dll")
+
+	procEvtQuery  = modwevtapi.NewProc("EvtQuery")
+	procEvtNext   = modwevtapi.NewProc("EvtNext")
+	procEvtRender = modwevtapi.NewProc("EvtRender")
+	procEvtClose  = modwevtapi.NewProc("EvtClose")
+)
+
+func queryTaskEvents(query string) ([]TaskEvent, error) {
+	channelPtr, err := syscall.UTF16PtrFromString(taskSchedulerEventChannel)
+	if err != nil {
+		return nil, err
+	}
+	queryPtr, err := syscall.UTF16PtrFromString(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resultSet, _, err := procEvtQuery.Call(0, uintptr(unsafe.Pointer(channelPtr)), uintptr(unsafe.Pointer(queryPtr)), evtQueryChannelPath|evtQueryForwardDirection)
+	if resultSet == 0 {
+		return nil, fmt.Errorf("error querying event log: %w", err)
+	}
+	defer procEvtClose.Call(resultSet)
+
+	var events []TaskEvent
+	var buf []uint16
+	eventHandles := make([]uintptr, 16)
+	for {
+		var returned uint32
+		ret, _, err := procEvtNext.Call(resultSet, uintptr(len(eventHandles)), uintptr(unsafe.Pointer(&eventHandles[0])), syscall.INFINITE, 0, uintptr(unsafe.Pointer(&returned)))
+		if ret == 0 {
+			if err == syscall.Errno(errorNoMoreItems) {
+				break
+			}
+			return nil, fmt.Errorf("error reading events: %w", err)
+		}
+
+		for i, eventHandle := range eventHandles[:returned] {
+			var event TaskEvent
+			event, buf, err = renderTaskEvent(eventHandle, buf)
+			if err != nil {
+				for _, h := range eventHandles[i:returned] {
+					procEvtClose.Call(h)
+				}
+				return nil, err
+			}
+			procEvtClose.Call(eventHandle)
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// renderTaskEvent renders the event as XML and parses it. buf is reused to
+// render the event if it's large enough, and the buffer that was used is returned.
+func renderTaskEvent(eventHandle uintptr, buf []uint16) (TaskEvent, []uint16, error) {
+	for {
+		var bufUsed, propertyCount uint32
+		var bufPtr uintptr
+		if len(buf) > 0 {
+			bufPtr = uintptr(unsafe.Pointer(&buf[0]))
+		}
+
+		ret, _, err := procEvtRender.Call(0, eventHandle, evtRenderEventXML, uintptr(len(buf)*2), bufPtr, uintptr(unsafe.Pointer(&bufUsed)), uintptr(unsafe.Pointer(&propertyCount)))
+		if ret == 0 {
+			if err == syscall.Errno(errorInsufficientBuffer) {
+				buf = make([]uint16, bufUsed/2+1)
+				continue
+			}
+			return TaskEvent{}, buf, fmt.Errorf("error rendering event: %w", err)
+		}
+
+		event, err := parseTaskEvent(syscall.UTF16ToString(buf[:bufUsed/2]))
+		return event, buf, err
+	}
+}
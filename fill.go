@@ -25,7 +25,9 @@ func fillDefinitionObj(definition Definition, definitionObj *ole.IDispatch) erro
 
 	principalObj := oleutil.MustGetProperty(definitionObj, "Principal").ToIDispatch()
 	defer principalObj.Release()
-	fillPrincipalObj(definition.Principal, principalObj)
+	if err = fillPrincipalObj(definition.Principal, principalObj); err != nil {
+		return fmt.Errorf("error filling IPrincipal object: %v", err)
+	}
 
 	regInfoObj := oleutil.MustGetProperty(definitionObj, "RegistrationInfo").ToIDispatch()
 	defer regInfoObj.Release()
@@ -33,7 +35,10 @@ func fillDefinitionObj(definition Definition, definitionObj *ole.IDispatch) erro
 
 	settingsObj := oleutil.MustGetProperty(definitionObj, "Settings").ToIDispatch()
 	defer settingsObj.Release()
-	fillTaskSettingsObj(definition.Settings, settingsObj)
+	err = fillTaskSettingsObj(definition.Settings, settingsObj)
+	if err != nil {
+		return fmt.Errorf("error filling ITaskSettings object: %v", err)
+	}
 
 	triggersObj := oleutil.MustGetProperty(definitionObj, "Triggers").ToIDispatch()
 	defer triggersObj.Release()
@@ -42,6 +47,12 @@ func fillDefinitionObj(definition Definition, definitionObj *ole.IDispatch) erro
 		return fmt.Errorf("error filling ITrigger objects: %v", err)
 	}
 
+	if definition.AfterFill != nil {
+		if err = definition.AfterFill(definitionObj); err != nil {
+			return fmt.Errorf("error in AfterFill: %v", err)
+		}
+	}
+
 	return nil
 }
 func fillActionsObj(actions []Action, actionsObj *ole.IDispatch) error {
@@ -49,7 +60,7 @@ func fillActionsObj(actions []Action, actionsObj *ole.IDispatch) error {
 		actionType := action.GetType()
 		res, err := oleutil.CallMethod(actionsObj, "Create", uint(actionType))
 		if err != nil {
-			return fmt.Errorf("error creating IAction object: %v", getTaskSchedulerError(err))
+			return fmt.Errorf("error creating IAction object: %w", getTaskSchedulerError(err))
 		}
 		actionObj := res.ToIDispatch()
 		defer actionObj.Release()
@@ -71,19 +82,76 @@ func fillActionsObj(actions []Action, actionsObj *ole.IDispatch) error {
 
 			oleutil.MustPutProperty(comHandlerActionObj, "ClassId", comHandlerAction.ClassID)
 			oleutil.MustPutProperty(comHandlerActionObj, "Data", comHandlerAction.Data)
+		case TASK_ACTION_SEND_EMAIL:
+			emailAction := action.(EmailAction)
+			emailActionObj := actionObj.MustQueryInterface(ole.NewGUID("{10f62c64-7e16-4314-a0c2-0c3683f99d40}"))
+			defer emailActionObj.Release()
+
+			oleutil.MustPutProperty(emailActionObj, "Server", emailAction.Server)
+			oleutil.MustPutProperty(emailActionObj, "Subject", emailAction.Subject)
+			oleutil.MustPutProperty(emailActionObj, "To", emailAction.To)
+			oleutil.MustPutProperty(emailActionObj, "Cc", emailAction.Cc)
+			oleutil.MustPutProperty(emailActionObj, "Bcc", emailAction.Bcc)
+			oleutil.MustPutProperty(emailActionObj, "ReplyTo", emailAction.ReplyTo)
+			oleutil.MustPutProperty(emailActionObj, "From", emailAction.From)
+			oleutil.MustPutProperty(emailActionObj, "Body", emailAction.Body)
+			if len(emailAction.Attachments) > 0 {
+				// go-ole passes a []string as a SAFEARRAY of BSTR
+				if _, err = oleutil.PutProperty(emailActionObj, "Attachments", emailAction.Attachments); err != nil {
+					return fmt.Errorf("error setting email attachments: %w", getTaskSchedulerError(err))
+				}
+			}
+			headerFieldsObj := oleutil.MustGetProperty(emailActionObj, "HeaderFields").ToIDispatch()
+			defer headerFieldsObj.Release()
+
+			for name, value := range emailAction.HeaderFields {
+				_, err = oleutil.CallMethod(headerFieldsObj, "Create", name, value)
+				if err != nil {
+					return fmt.Errorf("error creating header field %s: %w", name, getTaskSchedulerError(err))
+				}
+			}
+		case TASK_ACTION_SHOW_MESSAGE:
+			showMessageAction := action.(ShowMessageAction)
+			showMessageActionObj := actionObj.MustQueryInterface(ole.NewGUID("{505e9e68-af89-46b8-a30f-56162a83d537}"))
+			defer showMessageActionObj.Release()
+
+			oleutil.MustPutProperty(showMessageActionObj, "Title", showMessageAction.Title)
+			oleutil.MustPutProperty(showMessageActionObj, "MessageBody", showMessageAction.MessageBody)
 		}
 	}
 
 	return nil
 }
 
-func fillPrincipalObj(principal Principal, principalObj *ole.IDispatch) {
+func fillPrincipalObj(principal Principal, principalObj *ole.IDispatch) error {
 	oleutil.MustPutProperty(principalObj, "DisplayName", principal.Name)
 	oleutil.MustPutProperty(principalObj, "GroupId", principal.GroupID)
 	oleutil.MustPutProperty(principalObj, "Id", principal.ID)
 	oleutil.MustPutProperty(principalObj, "LogonType", uint(principal.LogonType))
 	oleutil.MustPutProperty(principalObj, "RunLevel", uint(principal.RunLevel))
 	oleutil.MustPutProperty(principalObj, "UserId", principal.UserID)
+
+	if principal.LogonType != TASK_LOGON_SERVICE_ACCOUNT ||
+		(principal.ProcessTokenSidType == TASK_PROCESSTOKENSID_DEFAULT && len(principal.RequiredPrivileges) == 0) {
+		return nil
+	}
+
+	// IPrincipal2 doesn't exist before Windows 7
+	principal2Obj, err := principalObj.QueryInterface(ole.NewGUID("{248919ae-e345-4a6d-8aeb-e0d3165c904e}"))
+	if err != nil {
+		return fmt.Errorf("error getting IPrincipal2 interface: %w", getTaskSchedulerError(err))
+	}
+	defer principal2Obj.Release()
+
+	oleutil.MustPutProperty(principal2Obj, "ProcessTokenSidType", principal.ProcessTokenSidType.apiValue())
+	for _, privilege := range principal.RequiredPrivileges {
+		_, err = oleutil.CallMethod(principal2Obj, "AddRequiredPrivilege", privilege)
+		if err != nil {
+			return fmt.Errorf("error adding required privilege %s: %w", privilege, getTaskSchedulerError(err))
+		}
+	}
+
+	return nil
 }
 
 func fillRegistrationInfoObj(regInfo RegistrationInfo, regInfoObj *ole.IDispatch) {
@@ -97,12 +165,15 @@ func fillRegistrationInfoObj(regInfo RegistrationInfo, regInfoObj *ole.IDispatch
 	oleutil.MustPutProperty(regInfoObj, "Version", regInfo.Version)
 }
 
-func fillTaskSettingsObj(settings TaskSettings, settingsObj *ole.IDispatch) {
+func fillTaskSettingsObj(settings TaskSettings, settingsObj *ole.IDispatch) error {
 	oleutil.MustPutProperty(settingsObj, "AllowDemandStart", settings.AllowDemandStart)
 	oleutil.MustPutProperty(settingsObj, "AllowHardTerminate", settings.AllowHardTerminate)
 	oleutil.MustPutProperty(settingsObj, "Compatibility", uint(settings.Compatibility))
-	oleutil.MustPutProperty(settingsObj, "DeleteExpiredTaskAfter", settings.DeleteExpiredTaskAfter)
+	oleutil.MustPutProperty(settingsObj, "DeleteExpiredTaskAfter", PeriodToString(settings.DeleteExpiredTaskAfter))
 	oleutil.MustPutProperty(settingsObj, "DisallowStartIfOnBatteries", settings.DontStartOnBatteries)
+	if err := putOptionalProperty(settingsObj, "DisallowStartOnRemoteAppSession", settings.DisallowStartOnRemoteAppSession); err != nil {
+		return err
+	}
 	oleutil.MustPutProperty(settingsObj, "Enabled", settings.Enabled)
 	oleutil.MustPutProperty(settingsObj, "ExecutionTimeLimit", settings.TimeLimit.String())
 	oleutil.MustPutProperty(settingsObj, "Hidden", settings.Hidden)
@@ -115,15 +186,21 @@ func fillTaskSettingsObj(settings TaskSettings, settingsObj *ole.IDispatch) {
 	oleutil.MustPutProperty(idlesettingsObj, "WaitTimeout", settings.IdleSettings.WaitTimeout.String())
 
 	if settings.MaintenanceSettings != nil {
+		// the MaintenanceSettings property doesn't exist before Windows 8
 		maintenanceProperty, err := oleutil.GetProperty(settingsObj, "MaintenanceSettings")
-		if err == nil {
-			maintenanceObject := maintenanceProperty.ToIDispatch()
-			defer maintenanceObject.Release()
-
-			oleutil.PutProperty(maintenanceObject, "Period", settings.MaintenanceSettings.Period.String())
-			oleutil.PutProperty(maintenanceObject, "Deadline", settings.MaintenanceSettings.Deadline.String())
-			oleutil.PutProperty(maintenanceObject, "Exclusive", settings.MaintenanceSettings.Exclusive)
+		if err != nil {
+			return fmt.Errorf("error getting MaintenanceSettings property: %w", getTaskSchedulerError(err))
+		}
+		maintenanceObject := maintenanceProperty.ToIDispatch()
+		if maintenanceObject == nil {
+			// maintenance settings have to be created if they haven't been set yet
+			maintenanceObject = oleutil.MustCallMethod(settingsObj, "CreateMaintenanceSettings").ToIDispatch()
 		}
+		defer maintenanceObject.Release()
+
+		oleutil.MustPutProperty(maintenanceObject, "Period", PeriodToString(settings.MaintenanceSettings.Period))
+		oleutil.MustPutProperty(maintenanceObject, "Deadline", PeriodToString(settings.MaintenanceSettings.Deadline))
+		oleutil.MustPutProperty(maintenanceObject, "Exclusive", settings.MaintenanceSettings.Exclusive)
 	}
 
 	oleutil.MustPutProperty(settingsObj, "MultipleInstances", uint(settings.MultipleInstances))
@@ -140,30 +217,38 @@ func fillTaskSettingsObj(settings TaskSettings, settingsObj *ole.IDispatch) {
 	oleutil.MustPutProperty(settingsObj, "RunOnlyIfNetworkAvailable", settings.RunOnlyIfNetworkAvailable)
 	oleutil.MustPutProperty(settingsObj, "StartWhenAvailable", settings.StartWhenAvailable)
 	oleutil.MustPutProperty(settingsObj, "StopIfGoingOnBatteries", settings.StopIfGoingOnBatteries)
+	if err := putOptionalProperty(settingsObj, "UseUnifiedSchedulingEngine", settings.UseUnifiedSchedulingEngine); err != nil {
+		return err
+	}
+	if err := putOptionalProperty(settingsObj, "Volatile", settings.Volatile); err != nil {
+		return err
+	}
 	oleutil.MustPutProperty(settingsObj, "WakeToRun", settings.WakeToRun)
+
+	return nil
 }
 
 func fillTaskTriggersObj(triggers []Trigger, triggersObj *ole.IDispatch) error {
 	for _, trigger := range triggers {
 		res, err := oleutil.CallMethod(triggersObj, "Create", uint(trigger.GetType()))
 		if err != nil {
-			return fmt.Errorf("error creating ITrigger object: %v", getTaskSchedulerError(err))
+			return fmt.Errorf("error creating ITrigger object: %w", getTaskSchedulerError(err))
 		}
 		triggerObj := res.ToIDispatch()
 		defer triggerObj.Release()
 
 		oleutil.MustPutProperty(triggerObj, "Enabled", trigger.GetEnabled())
-		oleutil.MustPutProperty(triggerObj, "EndBoundary", TimeToTaskDate(trigger.GetEndBoundary()))
+		oleutil.MustPutProperty(triggerObj, "EndBoundary", boundaryToTaskDate(trigger.GetEndBoundary(), trigger.GetSynchronizeAcrossTimeZones()))
 		oleutil.MustPutProperty(triggerObj, "ExecutionTimeLimit", trigger.GetExecutionTimeLimit().String())
 		oleutil.MustPutProperty(triggerObj, "Id", trigger.GetID())
 
 		repetitionObj := oleutil.MustGetProperty(triggerObj, "Repetition").ToIDispatch()
 		defer repetitionObj.Release()
-		oleutil.MustPutProperty(repetitionObj, "Duration", trigger.GetRepetitionDuration().String())
+		oleutil.MustPutProperty(repetitionObj, "Duration", PeriodToString(trigger.GetRepetitionDuration()))
 		oleutil.MustPutProperty(repetitionObj, "Interval", PeriodToString(trigger.GetRepetitionInterval()))
 		oleutil.MustPutProperty(repetitionObj, "StopAtDurationEnd", trigger.GetStopAtDurationEnd())
 
-		oleutil.MustPutProperty(triggerObj, "StartBoundary", TimeToTaskDate(trigger.GetStartBoundary()))
+		oleutil.MustPutProperty(triggerObj, "StartBoundary", boundaryToTaskDate(trigger.GetStartBoundary(), trigger.GetSynchronizeAcrossTimeZones()))
 
 		switch t := trigger.(type) {
 		case BootTrigger:
@@ -189,7 +274,7 @@ func fillTaskTriggersObj(triggers []Trigger, triggersObj *ole.IDispatch) error {
 			for name, value := range t.ValueQueries {
 				_, err = oleutil.CallMethod(valueQueriesObj, "Create", name, value)
 				if err != nil {
-					return fmt.Errorf("error creating value %s: %v", name, getTaskSchedulerError(err))
+					return fmt.Errorf("error creating value %s: %w", name, getTaskSchedulerError(err))
 				}
 			}
 		case IdleTrigger:
@@ -217,7 +302,7 @@ func fillTaskTriggersObj(triggers []Trigger, triggersObj *ole.IDispatch) error {
 			oleutil.MustPutProperty(monthlyTriggerObj, "DaysOfMonth", uint(t.DaysOfMonth))
 			oleutil.MustPutProperty(monthlyTriggerObj, "MonthsOfYear", uint(t.MonthsOfYear))
 			oleutil.MustPutProperty(monthlyTriggerObj, "RandomDelay", t.RandomDelay.String())
-			oleutil.MustPutProperty(monthlyTriggerObj, "RunOnLastDayOfMonth", t.RunOnLastWeekOfMonth)
+			oleutil.MustPutProperty(monthlyTriggerObj, "RunOnLastDayOfMonth", t.runsOnLastDayOfMonth())
 		case RegistrationTrigger:
 			registrationTriggerObj := triggerObj.MustQueryInterface(ole.NewGUID("{4c8fec3a-c218-4e0c-b23d-629024db91a2}"))
 			defer registrationTriggerObj.Release()
@@ -241,7 +326,7 @@ func fillTaskTriggersObj(triggers []Trigger, triggersObj *ole.IDispatch) error {
 
 			oleutil.MustPutProperty(sessionStateChangeTriggerObj, "Delay", t.Delay.String())
 			oleutil.MustPutProperty(sessionStateChangeTriggerObj, "StateChange", uint(t.StateChange))
-			oleutil.MustPutProperty(sessionStateChangeTriggerObj, "UserId", t.UserId)
+			oleutil.MustPutProperty(sessionStateChangeTriggerObj, "UserId", t.UserID)
 			// need to find GUID
 			/*case TASK_TRIGGER_CUSTOM_TRIGGER_01:
 			return nil*/
@@ -250,3 +335,18 @@ func fillTaskTriggersObj(triggers []Trigger, triggersObj *ole.IDispatch) error {
 
 	return nil
 }
+
+// putOptionalProperty sets a property that doesn't exist on older versions of
+// Windows. The property is only set if value is true, so that definitions that
+// don't use it can still be registered on versions of Windows that lack it, and
+// an error is returned if it can't be set.
+func putOptionalProperty(obj *ole.IDispatch, name string, value bool) error {
+	if !value {
+		return nil
+	}
+	if _, err := oleutil.PutProperty(obj, name, value); err != nil {
+		return fmt.Errorf("error setting %s property, which this version of Windows may not support: %w", name, getTaskSchedulerError(err))
+	}
+
+	return nil
+}
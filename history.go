@@ -0,0 +1,153 @@
+package taskmaster
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The history of a task is read from the Task Scheduler operational event log
+// through the Windows Event Log API, as the Task Scheduler COM interfaces don't
+// expose it.
+
+const taskSchedulerEventChannel = "Microsoft-Windows-TaskScheduler/Operational"
+
+// TaskEventID is the ID of an event logged by the Task Scheduler service.
+type TaskEventID uint32
+
+const (
+	TASK_EVENT_STARTED               TaskEventID = 100 // an instance of the task started
+	TASK_EVENT_START_FAILED          TaskEventID = 101 // the task failed to start
+	TASK_EVENT_COMPLETED             TaskEventID = 102 // an instance of the task completed
+	TASK_EVENT_ACTION_START_FAILED   TaskEventID = 103 // an action of the task failed to start
+	TASK_EVENT_TRIGGERED_BY_SCHEDULE TaskEventID = 107 // the task was triggered on its schedule
+	TASK_EVENT_TRIGGERED_BY_USER     TaskEventID = 110 // the task was run on demand
+	TASK_EVENT_TERMINATED            TaskEventID = 111 // an instance of the task was terminated
+	TASK_EVENT_PROCESS_CREATED       TaskEventID = 129 // a process was created for an action of the task
+	TASK_EVENT_ACTION_STARTED        TaskEventID = 200 // an action of the task started
+	TASK_EVENT_ACTION_COMPLETED      TaskEventID = 201 // an action of the task completed
+	TASK_EVENT_ACTION_FAILED         TaskEventID = 203 // an action of the task failed
+)
+
+func (e TaskEventID) String() string {
+	switch e {
+	case TASK_EVENT_STARTED:
+		return "Task Started"
+	case TASK_EVENT_START_FAILED:
+		return "Task Start Failed"
+	case TASK_EVENT_COMPLETED:
+		return "Task Completed"
+	case TASK_EVENT_ACTION_START_FAILED:
+		return "Action Start Failed"
+	case TASK_EVENT_TRIGGERED_BY_SCHEDULE:
+		return "Task Triggered On Schedule"
+	case TASK_EVENT_TRIGGERED_BY_USER:
+		return "Task Triggered By User"
+	case TASK_EVENT_TERMINATED:
+		return "Task Terminated"
+	case TASK_EVENT_PROCESS_CREATED:
+		return "Process Created"
+	case TASK_EVENT_ACTION_STARTED:
+		return "Action Started"
+	case TASK_EVENT_ACTION_COMPLETED:
+		return "Action Completed"
+	case TASK_EVENT_ACTION_FAILED:
+		return "Action Failed"
+	default:
+		return ""
+	}
+}
+
+// TaskEvent is an event logged by the Task Scheduler service about a registered task.
+type TaskEvent struct {
+	ID         TaskEventID       // the ID of the event
+	Time       time.Time         // when the event was logged
+	TaskPath   string            // the path to where the task is stored
+	InstanceID string            // the GUID identifier of the task instance the event is about, if any
+	ActionName string            // the action the event is about, if any
+	ResultCode TaskResult        // the result or exit code reported by the event, if any
+	Data       map[string]string // all the data of the event, by name
+}
+
+// eventXML is the XML representation of an event rendered by EvtRender.
+type eventXML struct {
+	System struct {
+		EventID     uint32 `xml:"EventID"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		Correlation struct {
+			ActivityID string `xml:"ActivityID,attr"`
+		} `xml:"Correlation"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+}
+
+// History returns the events that the Task Scheduler service logged about the
+// registered task since the specified time, oldest first. If since is the zero
+// time, all logged events are returned. The events are read from the event log
+// of the local computer, and only if the history of the Task Scheduler service
+// is enabled.
+func (r *RegisteredTask) History(since time.Time) ([]TaskEvent, error) {
+	events, err := queryTaskEvents(taskEventQuery(r.Path, since))
+	if err != nil {
+		return nil, fmt.Errorf("error getting history of registered task %s: %w", r.Path, err)
+	}
+
+	return events, nil
+}
+
+// taskEventQuery returns an XPath query that selects the events logged about
+// the task at path since the specified time.
+func taskEventQuery(path string, since time.Time) string {
+	// task paths can't contain double quotes, but can contain single quotes
+	query := fmt.Sprintf(`EventData[Data[@Name="TaskName"]="%s"]`, path)
+	if !since.IsZero() {
+		query = fmt.Sprintf(`System[TimeCreated[@SystemTime>="%s"]] and %s`, since.UTC().Format("2006-01-02T15:04:05.000Z"), query)
+	}
+
+	return "*[" + query + "]"
+}
+
+func parseTaskEvent(eventText string) (TaskEvent, error) {
+	var e eventXML
+	if err := xml.Unmarshal([]byte(eventText), &e); err != nil {
+		return TaskEvent{}, fmt.Errorf("error parsing event: %v", err)
+	}
+
+	event := TaskEvent{
+		ID:         TaskEventID(e.System.EventID),
+		InstanceID: e.System.Correlation.ActivityID,
+		Data:       make(map[string]string, len(e.EventData.Data)),
+	}
+	if eventTime, err := time.Parse(time.RFC3339Nano, e.System.TimeCreated.SystemTime); err == nil {
+		event.Time = eventTime.Local()
+	}
+
+	for _, data := range e.EventData.Data {
+		value := strings.TrimSpace(data.Value)
+		event.Data[data.Name] = value
+
+		switch data.Name {
+		case "TaskName":
+			event.TaskPath = value
+		case "InstanceId", "TaskInstanceId":
+			event.InstanceID = value
+		case "ActionName":
+			event.ActionName = value
+		case "ResultCode":
+			if resultCode, err := strconv.ParseUint(value, 0, 32); err == nil {
+				event.ResultCode = TaskResult(resultCode)
+			}
+		}
+	}
+
+	return event, nil
+}
@@ -0,0 +1,58 @@
+package taskmaster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTaskEvent(t *testing.T) {
+	eventText := `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+	<System>
+		<Provider Name="Microsoft-Windows-TaskScheduler" Guid="{de7b24ea-73c8-4a09-985d-5bdadcfa9017}"/>
+		<EventID>201</EventID>
+		<TimeCreated SystemTime="2024-03-01T10:15:30.1234567Z"/>
+		<Correlation ActivityID="{5bd4bb21-6b85-4a3c-9a4b-4ee4b3d2c0f0}"/>
+	</System>
+	<EventData Name="ActionSuccess">
+		<Data Name="TaskName">\Taskmaster\TestTask</Data>
+		<Data Name="TaskInstanceId">{5bd4bb21-6b85-4a3c-9a4b-4ee4b3d2c0f0}</Data>
+		<Data Name="ActionName">C:\Windows\System32\cmd.exe</Data>
+		<Data Name="ResultCode">2147942402</Data>
+	</EventData>
+</Event>`
+
+	event, err := parseTaskEvent(eventText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != TASK_EVENT_ACTION_COMPLETED {
+		t.Errorf("expected event ID %d, got %d", TASK_EVENT_ACTION_COMPLETED, event.ID)
+	}
+	if !event.Time.Equal(time.Date(2024, 3, 1, 10, 15, 30, 123456700, time.UTC)) {
+		t.Errorf("unexpected event time %s", event.Time)
+	}
+	if event.TaskPath != `\Taskmaster\TestTask` {
+		t.Errorf("unexpected task path %s", event.TaskPath)
+	}
+	if event.InstanceID != "{5bd4bb21-6b85-4a3c-9a4b-4ee4b3d2c0f0}" {
+		t.Errorf("unexpected instance ID %s", event.InstanceID)
+	}
+	if event.ActionName != `C:\Windows\System32\cmd.exe` {
+		t.Errorf("unexpected action name %s", event.ActionName)
+	}
+	if event.ResultCode != 0x80070002 {
+		t.Errorf("expected result code 0x80070002, got 0x%X", uint32(event.ResultCode))
+	}
+}
+
+func TestTaskEventQuery(t *testing.T) {
+	query := taskEventQuery(`\Taskmaster\TestTask`, time.Time{})
+	if query != `*[EventData[Data[@Name="TaskName"]="\Taskmaster\TestTask"]]` {
+		t.Errorf("unexpected query %s", query)
+	}
+
+	query = taskEventQuery(`\Taskmaster\TestTask`, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	if query != `*[System[TimeCreated[@SystemTime>="2024-03-01T10:00:00.000Z"]] and EventData[Data[@Name="TaskName"]="\Taskmaster\TestTask"]]` {
+		t.Errorf("unexpected query %s", query)
+	}
+}
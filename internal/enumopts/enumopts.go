@@ -0,0 +1,20 @@
+// Package enumopts holds the configuration that taskmaster.EnumOptions set, so
+// that the implementations of taskmaster.Service in the other packages of the
+// module can honor them.
+package enumopts
+
+// Config configures which registered tasks are enumerated, and how.
+type Config struct {
+	ExcludeHidden     bool
+	OnlyEnabled       bool
+	OnlyDisabled      bool
+	IncludeSubfolders bool
+	Parallel          bool
+	Workers           int
+}
+
+// Includes returns true if a task that is hidden or not, and enabled or not,
+// should be enumerated.
+func (c Config) Includes(hidden, enabled bool) bool {
+	return !(c.ExcludeHidden && hidden) && !(c.OnlyEnabled && !enabled) && !(c.OnlyDisabled && enabled)
+}
@@ -0,0 +1,392 @@
+package taskmaster
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Actions and triggers are stored in a Definition as interfaces, so a "type"
+// field is added to each JSON encoded action and trigger so they can be
+// decoded back into the correct concrete type.
+//
+// RegisteredTask and RunningTask don't need custom encoding: their exported
+// fields are encoded as is, and their COM objects are left out. A decoded
+// RegisteredTask or RunningTask therefore can't be used to call the Task
+// Scheduler service.
+
+var actionTypeNames = map[TaskActionType]string{
+	TASK_ACTION_EXEC:         "Exec",
+	TASK_ACTION_COM_HANDLER:  "ComHandler",
+	TASK_ACTION_SEND_EMAIL:   "SendEmail",
+	TASK_ACTION_SHOW_MESSAGE: "ShowMessage",
+}
+
+var triggerTypeNames = map[TaskTriggerType]string{
+	TASK_TRIGGER_BOOT:                 "Boot",
+	TASK_TRIGGER_DAILY:                "Daily",
+	TASK_TRIGGER_EVENT:                "Event",
+	TASK_TRIGGER_IDLE:                 "Idle",
+	TASK_TRIGGER_LOGON:                "Logon",
+	TASK_TRIGGER_MONTHLYDOW:           "MonthlyDOW",
+	TASK_TRIGGER_MONTHLY:              "Monthly",
+	TASK_TRIGGER_REGISTRATION:         "Registration",
+	TASK_TRIGGER_SESSION_STATE_CHANGE: "SessionStateChange",
+	TASK_TRIGGER_TIME:                 "Time",
+	TASK_TRIGGER_WEEKLY:               "Weekly",
+	TASK_TRIGGER_CUSTOM_TRIGGER_01:    "Custom",
+}
+
+// definitionAlias has the same fields as Definition but none of its methods,
+// so it can be encoded without recursing into MarshalJSON.
+type definitionAlias Definition
+
+type definitionJSON struct {
+	definitionAlias
+	Actions  []json.RawMessage
+	Triggers []json.RawMessage
+}
+
+type typeJSON struct {
+	Type string `json:"type"`
+}
+
+// MarshalJSON encodes the definition as JSON. Periods are encoded as ISO 8601
+// duration strings, times as RFC 3339 strings, enums such as TaskLogonType as the
+// strings returned by their String methods, and every action and trigger has a
+// "type" field identifying its concrete type.
+func (d Definition) MarshalJSON() ([]byte, error) {
+	aux := definitionJSON{
+		definitionAlias: definitionAlias(d),
+	}
+
+	for _, action := range d.Actions {
+		typeName, ok := actionTypeNames[action.GetType()]
+		if !ok {
+			return nil, fmt.Errorf("error encoding action: unsupported action type %s", action.GetType())
+		}
+		data, err := marshalWithType(action, typeName)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s action: %v", typeName, err)
+		}
+		aux.Actions = append(aux.Actions, data)
+	}
+
+	for _, trigger := range d.Triggers {
+		typeName, ok := triggerTypeNames[trigger.GetType()]
+		if !ok {
+			return nil, fmt.Errorf("error encoding trigger: unsupported trigger type %s", trigger.GetType())
+		}
+		data, err := marshalWithType(trigger, typeName)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s trigger: %v", typeName, err)
+		}
+		aux.Triggers = append(aux.Triggers, data)
+	}
+
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON decodes a definition that was encoded with MarshalJSON.
+func (d *Definition) UnmarshalJSON(data []byte) error {
+	var aux definitionJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	def := Definition(aux.definitionAlias)
+	def.Actions = nil
+	def.Triggers = nil
+
+	for _, data := range aux.Actions {
+		action, err := unmarshalAction(data)
+		if err != nil {
+			return err
+		}
+		def.Actions = append(def.Actions, action)
+	}
+
+	for _, data := range aux.Triggers {
+		trigger, err := unmarshalTrigger(data)
+		if err != nil {
+			return err
+		}
+		def.Triggers = append(def.Triggers, trigger)
+	}
+
+	*d = def
+
+	return nil
+}
+
+// marshalWithType encodes v as a JSON object with an added "type" field.
+func marshalWithType(v interface{}, typeName string) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	typeField, err := json.Marshal(typeJSON{Type: typeName})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) <= 2 {
+		// v was encoded as an empty object
+		return typeField, nil
+	}
+
+	// splice the "type" field in front of the fields of v
+	typeField[len(typeField)-1] = ','
+	return append(typeField, data[1:]...), nil
+}
+
+func unmarshalAction(data []byte) (Action, error) {
+	var t typeJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("error decoding action: %v", err)
+	}
+
+	var err error
+	var action Action
+	switch t.Type {
+	case actionTypeNames[TASK_ACTION_EXEC]:
+		var execAction ExecAction
+		err = json.Unmarshal(data, &execAction)
+		action = execAction
+	case actionTypeNames[TASK_ACTION_COM_HANDLER]:
+		var comHandlerAction ComHandlerAction
+		err = json.Unmarshal(data, &comHandlerAction)
+		action = comHandlerAction
+	case actionTypeNames[TASK_ACTION_SEND_EMAIL]:
+		var emailAction EmailAction
+		err = json.Unmarshal(data, &emailAction)
+		action = emailAction
+	case actionTypeNames[TASK_ACTION_SHOW_MESSAGE]:
+		var showMessageAction ShowMessageAction
+		err = json.Unmarshal(data, &showMessageAction)
+		action = showMessageAction
+	default:
+		return nil, fmt.Errorf("error decoding action: unsupported action type %q", t.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s action: %v", t.Type, err)
+	}
+
+	return action, nil
+}
+
+func unmarshalTrigger(data []byte) (Trigger, error) {
+	var t typeJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("error decoding trigger: %v", err)
+	}
+
+	var err error
+	var trigger Trigger
+	switch t.Type {
+	case triggerTypeNames[TASK_TRIGGER_BOOT]:
+		var bootTrigger BootTrigger
+		err = json.Unmarshal(data, &bootTrigger)
+		trigger = bootTrigger
+	case triggerTypeNames[TASK_TRIGGER_DAILY]:
+		var dailyTrigger DailyTrigger
+		err = json.Unmarshal(data, &dailyTrigger)
+		trigger = dailyTrigger
+	case triggerTypeNames[TASK_TRIGGER_EVENT]:
+		var eventTrigger EventTrigger
+		err = json.Unmarshal(data, &eventTrigger)
+		trigger = eventTrigger
+	case triggerTypeNames[TASK_TRIGGER_IDLE]:
+		var idleTrigger IdleTrigger
+		err = json.Unmarshal(data, &idleTrigger)
+		trigger = idleTrigger
+	case triggerTypeNames[TASK_TRIGGER_LOGON]:
+		var logonTrigger LogonTrigger
+		err = json.Unmarshal(data, &logonTrigger)
+		trigger = logonTrigger
+	case triggerTypeNames[TASK_TRIGGER_MONTHLYDOW]:
+		var monthlyDOWTrigger MonthlyDOWTrigger
+		err = json.Unmarshal(data, &monthlyDOWTrigger)
+		trigger = monthlyDOWTrigger
+	case triggerTypeNames[TASK_TRIGGER_MONTHLY]:
+		var monthlyTrigger MonthlyTrigger
+		err = json.Unmarshal(data, &monthlyTrigger)
+		trigger = monthlyTrigger
+	case triggerTypeNames[TASK_TRIGGER_REGISTRATION]:
+		var registrationTrigger RegistrationTrigger
+		err = json.Unmarshal(data, &registrationTrigger)
+		trigger = registrationTrigger
+	case triggerTypeNames[TASK_TRIGGER_SESSION_STATE_CHANGE]:
+		var sessionStateChangeTrigger SessionStateChangeTrigger
+		err = json.Unmarshal(data, &sessionStateChangeTrigger)
+		trigger = sessionStateChangeTrigger
+	case triggerTypeNames[TASK_TRIGGER_TIME]:
+		var timeTrigger TimeTrigger
+		err = json.Unmarshal(data, &timeTrigger)
+		trigger = timeTrigger
+	case triggerTypeNames[TASK_TRIGGER_WEEKLY]:
+		var weeklyTrigger WeeklyTrigger
+		err = json.Unmarshal(data, &weeklyTrigger)
+		trigger = weeklyTrigger
+	case triggerTypeNames[TASK_TRIGGER_CUSTOM_TRIGGER_01]:
+		var customTrigger CustomTrigger
+		err = json.Unmarshal(data, &customTrigger)
+		trigger = customTrigger
+	default:
+		return nil, fmt.Errorf("error decoding trigger: unsupported trigger type %q", t.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s trigger: %v", t.Type, err)
+	}
+
+	return trigger, nil
+}
+
+func (c TaskCompatibility) MarshalText() ([]byte, error) {
+	s := c.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskCompatibility %d", c)
+	}
+
+	return []byte(s), nil
+}
+
+func (c *TaskCompatibility) UnmarshalText(text []byte) error {
+	for compatibility := TASK_COMPATIBILITY_AT; compatibility <= TASK_COMPATIBILITY_V2_4; compatibility++ {
+		if compatibility.String() == string(text) {
+			*c = compatibility
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskCompatibility %q", text)
+}
+
+func (t TaskInstancesPolicy) MarshalText() ([]byte, error) {
+	s := t.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskInstancesPolicy %d", t)
+	}
+
+	return []byte(s), nil
+}
+
+func (t *TaskInstancesPolicy) UnmarshalText(text []byte) error {
+	for policy := TASK_INSTANCES_PARALLEL; policy <= TASK_INSTANCES_STOP_EXISTING; policy++ {
+		if policy.String() == string(text) {
+			*t = policy
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskInstancesPolicy %q", text)
+}
+
+func (t TaskLogonType) MarshalText() ([]byte, error) {
+	s := t.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskLogonType %d", t)
+	}
+
+	return []byte(s), nil
+}
+
+func (t *TaskLogonType) UnmarshalText(text []byte) error {
+	for logonType := TASK_LOGON_NONE; logonType <= TASK_LOGON_INTERACTIVE_TOKEN_OR_PASSWORD; logonType++ {
+		if logonType.String() == string(text) {
+			*t = logonType
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskLogonType %q", text)
+}
+
+func (t TaskProcessTokenSidType) MarshalText() ([]byte, error) {
+	s := t.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskProcessTokenSidType %d", t)
+	}
+
+	return []byte(s), nil
+}
+
+func (t *TaskProcessTokenSidType) UnmarshalText(text []byte) error {
+	for sidType := TASK_PROCESSTOKENSID_DEFAULT; sidType <= TASK_PROCESSTOKENSID_UNRESTRICTED; sidType++ {
+		if sidType.String() == string(text) {
+			*t = sidType
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskProcessTokenSidType %q", text)
+}
+
+func (t TaskRunLevel) MarshalText() ([]byte, error) {
+	s := t.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskRunLevel %d", t)
+	}
+
+	return []byte(s), nil
+}
+
+func (t *TaskRunLevel) UnmarshalText(text []byte) error {
+	for runLevel := TASK_RUNLEVEL_LUA; runLevel <= TASK_RUNLEVEL_HIGHEST; runLevel++ {
+		if runLevel.String() == string(text) {
+			*t = runLevel
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskRunLevel %q", text)
+}
+
+func (t TaskSessionStateChangeType) MarshalText() ([]byte, error) {
+	if t == 0 {
+		// the state change of a trigger that hasn't been set yet
+		return []byte{}, nil
+	}
+
+	s := t.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskSessionStateChangeType %d", t)
+	}
+
+	return []byte(s), nil
+}
+
+func (t *TaskSessionStateChangeType) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*t = 0
+		return nil
+	}
+
+	for stateChange := TASK_CONSOLE_CONNECT; stateChange <= TASK_SESSION_UNLOCK; stateChange++ {
+		if stateChange.String() != "" && stateChange.String() == string(text) {
+			*t = stateChange
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskSessionStateChangeType %q", text)
+}
+
+func (t TaskState) MarshalText() ([]byte, error) {
+	s := t.String()
+	if s == "" {
+		return nil, fmt.Errorf("invalid TaskState %d", t)
+	}
+
+	return []byte(s), nil
+}
+
+func (t *TaskState) UnmarshalText(text []byte) error {
+	for state := TASK_STATE_UNKNOWN; state <= TASK_STATE_RUNNING; state++ {
+		if state.String() == string(text) {
+			*t = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid TaskState %q", text)
+}
@@ -0,0 +1,133 @@
+package taskmaster
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+func TestDefinitionJSON(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 8, 0, 0, 0, time.UTC)
+
+	def := Definition{
+		Principal: Principal{
+			LogonType: TASK_LOGON_INTERACTIVE_TOKEN,
+			RunLevel:  TASK_RUNLEVEL_HIGHEST,
+		},
+		RegistrationInfo: RegistrationInfo{
+			Author: "taskmaster",
+		},
+		Settings: TaskSettings{
+			Compatibility:     TASK_COMPATIBILITY_V2_1,
+			Enabled:           true,
+			MultipleInstances: TASK_INSTANCES_QUEUE,
+			TimeLimit:         period.NewHMS(72, 0, 0),
+			MaintenanceSettings: &MaintenanceSettings{
+				Period: period.NewYMD(0, 0, 1),
+			},
+		},
+	}
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+		Args: "/c timeout $(Arg0)",
+	})
+	def.AddAction(ComHandlerAction{
+		ClassID: "{F0001111-0000-0000-0000-0000FEEDACDC}",
+	})
+	def.AddAction(EmailAction{
+		Server:       "smtp.example.com",
+		Subject:      "Task ran",
+		To:           "admin@example.com",
+		From:         "tasks@example.com",
+		HeaderFields: map[string]string{"X-Priority": "1", "X-Mailer": "Taskmaster"},
+		Body:         "The task ran.",
+		Attachments:  []string{`C:\logs\task.log`},
+	})
+	def.AddAction(ShowMessageAction{
+		Title:       "Task ran",
+		MessageBody: "The task ran.",
+	})
+	def.AddTrigger(mustTrigger(NewBootTrigger(period.Period{})))
+	def.AddTrigger(mustTrigger(NewDailyTrigger(start, EveryDay, RepetitionPattern{
+		RepetitionDuration: period.NewHMS(1, 0, 0),
+		RepetitionInterval: period.NewHMS(0, 5, 0),
+	})))
+	def.AddTrigger(mustTrigger(NewWeeklyTrigger(start, EveryOtherWeek, Monday|Friday)))
+	def.AddTrigger(EventTrigger{
+		Subscription: "<QueryList></QueryList>",
+		ValueQueries: map[string]string{"id": "Event/System/EventID"},
+	})
+	def.AddTrigger(SessionStateChangeTrigger{
+		StateChange: TASK_SESSION_LOCK,
+	})
+
+	data, err := json.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decodedDef Definition
+	if err = json.Unmarshal(data, &decodedDef); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(def, decodedDef) {
+		t.Fatalf("definition wasn't decoded correctly:\n%+v\n%+v\n%s", def, decodedDef, data)
+	}
+
+	if !strings.Contains(string(data), `"ProcessTokenSidType":"Default"`) {
+		t.Fatalf("the unset ProcessTokenSidType wasn't encoded as Default: %s", data)
+	}
+	decodedDef = Definition{}
+	if err = json.Unmarshal([]byte(`{"Principal":{"UserID":"SYSTEM"}}`), &decodedDef); err != nil {
+		t.Fatal(err)
+	}
+	if decodedDef.Principal.ProcessTokenSidType != TASK_PROCESSTOKENSID_DEFAULT {
+		t.Fatalf("expected ProcessTokenSidType %s, got %s", TASK_PROCESSTOKENSID_DEFAULT, decodedDef.Principal.ProcessTokenSidType)
+	}
+
+	if err = json.Unmarshal([]byte(`{"Triggers":[{"type":"Hourly"}]}`), &decodedDef); err == nil {
+		t.Fatal("decoding an unknown trigger type should fail")
+	}
+}
+
+func TestRegisteredTaskJSON(t *testing.T) {
+	lastRun := time.Date(2020, time.January, 1, 8, 0, 0, 0, time.UTC)
+
+	task := RegisteredTask{
+		Name:        "TestTask",
+		Path:        `\Taskmaster\TestTask`,
+		Enabled:     true,
+		State:       TASK_STATE_READY,
+		LastRunTime: lastRun,
+	}
+	task.Definition.AddAction(ExecAction{
+		Path: "cmd.exe",
+	})
+	task.Definition.AddTrigger(SessionStateChangeTrigger{
+		StateChange: TASK_SESSION_UNLOCK,
+	})
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"State":"Ready"`) || !strings.Contains(string(data), `"StateChange":"Session Unlock"`) {
+		t.Fatalf("enums weren't encoded as strings: %s", data)
+	}
+
+	var decodedTask RegisteredTask
+	if err = json.Unmarshal(data, &decodedTask); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(task, decodedTask) {
+		t.Fatalf("registered task wasn't decoded correctly:\n%+v\n%+v\n%s", task, decodedTask, data)
+	}
+
+	if err = json.Unmarshal([]byte(`{"State":"Sleeping"}`), &decodedTask); err == nil {
+		t.Fatal("decoding an unknown task state should fail")
+	}
+}
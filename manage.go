@@ -4,25 +4,30 @@
 package taskmaster
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	ole "github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
-	"github.com/rickb777/date/period"
+
+	"github.com/stratg5/taskmaster/internal/enumopts"
 )
 
 // S_FALSE is returned by CoInitialize if it was already called on this thread.
 const S_FALSE = 0x00000001
 
-func (t *TaskService) initialize() error {
+func (t *TaskService) initialize(coinit uint32) error {
 	var err error
 
-	err = ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
+	err = ole.CoInitializeEx(0, coinit)
 	if err != nil {
 		code := err.(*ole.OleError).Code()
 		if code != ole.S_OK && code != S_FALSE {
@@ -30,34 +35,83 @@ func (t *TaskService) initialize() error {
 		}
 	}
 
-	schedClassID, err := ole.ClassIDFrom("Schedule.Service.1")
+	err = t.createTaskServiceObj()
 	if err != nil {
 		ole.CoUninitialize()
+		return err
+	}
+	t.isInitialized = true
+	t.apartmentThreaded = coinit == ole.COINIT_APARTMENTTHREADED
+
+	return nil
+}
+
+// createTaskServiceObj creates a new ITaskService COM object. COM must already
+// be initialized.
+func (t *TaskService) createTaskServiceObj() error {
+	schedClassID, err := ole.ClassIDFrom("Schedule.Service.1")
+	if err != nil {
 		return getTaskSchedulerError(err)
 	}
 	taskSchedulerObj, err := ole.CreateInstance(schedClassID, nil)
 	if err != nil {
-		ole.CoUninitialize()
 		return getTaskSchedulerError(err)
 	}
 	if taskSchedulerObj == nil {
-		ole.CoUninitialize()
 		return errors.New("Could not create ITaskService object")
 	}
 	defer taskSchedulerObj.Release()
 
 	tskSchdlr := taskSchedulerObj.MustQueryInterface(ole.IID_IDispatch)
 	t.taskServiceObj = tskSchdlr
-	t.isInitialized = true
 
 	return nil
 }
 
-// Connect connects to the local Task Scheduler service, using the current
-// token for authentication. This function must run before any other functions
-// in taskmaster can be used.
-func Connect() (TaskService, error) {
-	return ConnectWithOptions("", "", "", "")
+// initializeThread locks the calling goroutine to its current OS thread and
+// initializes COM on it, so that goroutines other than the one that called
+// Connect can safely make COM calls. The returned function must be called
+// once the goroutine is done making COM calls.
+func initializeThread() (func(), error) {
+	runtime.LockOSThread()
+
+	err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
+	if err != nil {
+		code := err.(*ole.OleError).Code()
+		if code != ole.S_OK && code != S_FALSE {
+			runtime.UnlockOSThread()
+			return nil, err
+		}
+	}
+
+	return func() {
+		ole.CoUninitialize()
+		runtime.UnlockOSThread()
+	}, nil
+}
+
+// Connect connects to a Task Scheduler service. This function must run before any
+// other functions in taskmaster can be used. Without any options, Connect connects
+// to the local Task Scheduler service, using the current token for authentication.
+func Connect(opts ...ConnectOption) (TaskService, error) {
+	config := connectConfig{
+		coinit: ole.COINIT_MULTITHREADED,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.timeout == 0 {
+		return connectWithConfig(config)
+	}
+	if config.coinit != ole.COINIT_MULTITHREADED {
+		return TaskService{}, errors.New("error connecting to Task Scheduler service: a connect timeout can't be used with a single-threaded apartment")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.timeout)
+	defer cancel()
+
+	return connectCtx(ctx, config)
 }
 
 // ConnectWithOptions connects to a local or remote Task Scheduler service. This
@@ -66,25 +120,113 @@ func Connect() (TaskService, error) {
 // will be attempted. If the user and password parameters are empty, the current
 // token will be used for authentication.
 func ConnectWithOptions(serverName, domain, username, password string) (TaskService, error) {
+	return Connect(WithServer(serverName), WithCredentials(domain, username, password))
+}
+
+func connectWithConfig(config connectConfig) (TaskService, error) {
 	var err error
 	var taskService TaskService
 
+	taskService.mu = new(sync.RWMutex)
 	if !taskService.isInitialized {
-		err = taskService.initialize()
+		err = taskService.initialize(config.coinit)
 		if err != nil {
 			return TaskService{}, fmt.Errorf("error initializing ITaskService object: %v", err)
 		}
 	}
 
-	_, err = oleutil.CallMethod(taskService.taskServiceObj, "Connect", serverName, username, domain, password)
+	err = taskService.connect(config.connectOptions)
 	if err != nil {
-		return TaskService{}, fmt.Errorf("error connecting to Task Scheduler service: %v", getTaskSchedulerError(err))
+		taskService.Disconnect()
+		return TaskService{}, err
+	}
+
+	return taskService, nil
+}
+
+// ConnectWithOptionsCtx is like ConnectWithOptions, but returns ctx.Err() if ctx
+// is canceled or its deadline is exceeded before the connection is established.
+// A connection attempt that is still in progress when ctx is done can't be
+// interrupted, so it's disconnected once it completes.
+func ConnectWithOptionsCtx(ctx context.Context, serverName, domain, username, password string) (TaskService, error) {
+	return connectCtx(ctx, connectConfig{
+		connectOptions: connectOptions{
+			serverName: serverName,
+			domain:     domain,
+			username:   username,
+			password:   password,
+		},
+		coinit: ole.COINIT_MULTITHREADED,
+	})
+}
+
+func connectCtx(ctx context.Context, config connectConfig) (TaskService, error) {
+	if err := ctx.Err(); err != nil {
+		return TaskService{}, err
+	}
+
+	// COM is initialized on the calling thread like with Connect, so that
+	// Disconnect uninitializes it on the same thread, and only the connection,
+	// which can take a long time with a remote computer, is made in another
+	// goroutine
+	var taskService TaskService
+	taskService.mu = new(sync.RWMutex)
+	if err := taskService.initialize(config.coinit); err != nil {
+		return TaskService{}, fmt.Errorf("error initializing ITaskService object: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		uninitialize, err := initializeThread()
+		if err == nil {
+			defer uninitialize()
+			err = taskService.connect(config.connectOptions)
+		}
+
+		select {
+		case done <- err:
+		case <-ctx.Done():
+			// the caller has given up on the connection and only uninitialized
+			// COM on its thread, so the objects are released here
+			taskService.release()
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			taskService.Disconnect()
+			return TaskService{}, err
+		}
+		return taskService, nil
+	case <-ctx.Done():
+		ole.CoUninitialize()
+		return TaskService{}, ctx.Err()
+	}
+}
+
+// connect connects the ITaskService object to a Task Scheduler service and
+// gets the root folder.
+func (t *TaskService) connect(opts connectOptions) error {
+	var err error
+
+	serverName, domain, username := opts.serverName, opts.domain, opts.username
+	t.connectOptions = opts
+	if t.folders == nil {
+		t.folders = &folderCache{}
+	}
+	_, err = t.callMethod(t.taskServiceObj, "Connect", serverName, username, domain, opts.password)
+	if err != nil {
+		return fmt.Errorf("error connecting to Task Scheduler service: %w", getTaskSchedulerError(err))
+	}
+	if err = opts.proxyBlanket.apply(t.taskServiceObj); err != nil {
+		return fmt.Errorf("error setting the proxy blanket of the ITaskService object: %v", err)
 	}
 
 	if serverName == "" {
 		serverName, err = os.Hostname()
 		if err != nil {
-			return TaskService{}, err
+			return err
 		}
 	}
 	if domain == "" {
@@ -93,32 +235,178 @@ func ConnectWithOptions(serverName, domain, username, password string) (TaskServ
 	if username == "" {
 		currentUser, err := user.Current()
 		if err != nil {
-			return TaskService{}, err
+			return err
 		}
-		username = strings.Split(currentUser.Username, `\`)[1]
+		username = trimUsernameDomain(currentUser.Username)
 	}
-	taskService.connectedDomain = domain
-	taskService.connectedComputerName = serverName
-	taskService.connectedUser = username
+	t.connectedDomain = domain
+	t.connectedComputerName = serverName
+	t.connectedUser = username
 
-	res, err := oleutil.CallMethod(taskService.taskServiceObj, "GetFolder", `\`)
+	highestVersion, err := oleutil.GetProperty(t.taskServiceObj, "HighestVersion")
 	if err != nil {
-		return TaskService{}, fmt.Errorf("error getting the root folder: %v", getTaskSchedulerError(err))
+		return fmt.Errorf("error getting the highest version of the Task Scheduler service: %w", getTaskSchedulerError(err))
+	}
+	t.highestVersion = TaskSchedulerVersion{
+		Major: uint(highestVersion.Val >> 16),
+		Minor: uint(highestVersion.Val & 0xFFFF),
 	}
-	taskService.rootFolderObj = res.ToIDispatch()
-	taskService.isConnected = true
 
-	return taskService, nil
+	res, err := t.callMethod(t.taskServiceObj, "GetFolder", `\`)
+	if err != nil {
+		return fmt.Errorf("error getting the root folder: %w", getTaskSchedulerError(err))
+	}
+	t.rootFolderObj = res.ToIDispatch()
+	t.isConnected = true
+
+	return nil
+}
+
+// Connected checks whether the connection to the Task Scheduler service is
+// still alive by getting the root folder. Unlike IsConnected, which only
+// reports whether Connect succeeded, Connected will return false if the
+// connection to a remote Task Scheduler service was lost.
+func (t *TaskService) Connected() bool {
+	if t.mu == nil {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.isConnected {
+		return false
+	}
+
+	res, err := t.callMethod(t.taskServiceObj, "GetFolder", `\`)
+	if err != nil {
+		return false
+	}
+	res.ToIDispatch().Release()
+
+	return true
+}
+
+// IsHealthy is an alias for Connected.
+func (t *TaskService) IsHealthy() bool {
+	return t.Connected()
+}
+
+// Retry runs fn, and if it returns an error wrapping ErrConnectionLost, waits,
+// reconnects with Reconnect and runs fn again, according to the RetryPolicy
+// passed to Connect with WithRetryPolicy. Since reconnecting invalidates the
+// registered and running tasks returned before, fn should get any tasks it uses
+// itself. Retry returns the error of the last attempt, which may be the error of
+// reconnecting; in that case the TaskService can't be used until Reconnect
+// succeeds. Errors other than ErrConnectionLost and ErrConnectionFailure are
+// returned right away.
+func (t *TaskService) Retry(fn func() error) error {
+	if t.mu == nil {
+		return errors.New("error retrying operation: task service was never connected")
+	}
+
+	t.mu.RLock()
+	policy := t.connectOptions.retryPolicy
+	t.mu.RUnlock()
+
+	var err error
+	reconnect := false
+	for attempt := 1; attempt == 1 || attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(policy.backoff(attempt - 1))
+		}
+		// if the computer isn't back yet, reconnecting fails and is retried
+		if reconnect {
+			if err = t.Reconnect(); err != nil {
+				if !errors.Is(err, ErrConnectionLost) && !errors.Is(err, ErrConnectionFailure) {
+					return err
+				}
+				continue
+			}
+			reconnect = false
+		}
+
+		if err = fn(); !errors.Is(err, ErrConnectionLost) {
+			return err
+		}
+		reconnect = true
+	}
+
+	return err
+}
+
+// Reconnect releases the current connection to the Task Scheduler service and
+// connects again using the same options that were passed to Connect.
+// Registered and running tasks returned before Reconnect was called must not be
+// used afterwards, nor should copies of the TaskService made before Reconnect
+// was called.
+func (t *TaskService) Reconnect() error {
+	if t.mu == nil {
+		return errors.New("error reconnecting to Task Scheduler service: task service was never connected")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.isInitialized {
+		return errors.New("error reconnecting to Task Scheduler service: task service was never connected")
+	}
+
+	t.folders.clear()
+	if t.rootFolderObj != nil {
+		t.rootFolderObj.Release()
+		t.rootFolderObj = nil
+	}
+	if t.taskServiceObj != nil {
+		t.taskServiceObj.Release()
+		t.taskServiceObj = nil
+	}
+	t.isConnected = false
+
+	err := t.createTaskServiceObj()
+	if err != nil {
+		return fmt.Errorf("error initializing ITaskService object: %v", err)
+	}
+
+	return t.connect(t.connectOptions)
+}
+
+// Clone creates a new connection to the Task Scheduler service that the TaskService
+// is connected to, using the same options that were passed to Connect. The new
+// connection is independent of the TaskService, and must be disconnected separately.
+// The new connection always uses the multithreaded apartment. As with Connect, COM
+// is initialized on the calling OS thread, so a goroutine that clones a TaskService
+// should call runtime.LockOSThread until it has disconnected the clone.
+func (t *TaskService) Clone() (TaskService, error) {
+	if t.mu == nil {
+		return TaskService{}, errors.New("error cloning task service: task service was never connected")
+	}
+
+	t.mu.RLock()
+	opts := t.connectOptions
+	isConnected := t.isConnected
+	t.mu.RUnlock()
+
+	if !isConnected {
+		return TaskService{}, errors.New("error cloning task service: task service is not connected")
+	}
+
+	return connectWithConfig(connectConfig{
+		connectOptions: opts,
+		coinit:         ole.COINIT_MULTITHREADED,
+	})
 }
 
 // Disconnect frees all the Task Scheduler COM objects that have been created.
 // If this function is not called before the parent program terminates,
-// memory leaks will occur.
+// memory leaks will occur. Calling Disconnect more than once is a no-op.
 func (t *TaskService) Disconnect() {
-	if t.isConnected {
-		t.taskServiceObj.Release()
-		t.rootFolderObj.Release()
+	if t.mu != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
 	}
+
+	t.release()
 	if t.isInitialized {
 		ole.CoUninitialize()
 	}
@@ -127,23 +415,48 @@ func (t *TaskService) Disconnect() {
 	t.isConnected = false
 }
 
+// release releases the COM objects of the TaskService without uninitializing COM.
+func (t *TaskService) release() {
+	t.folders.clear()
+	if t.rootFolderObj != nil {
+		t.rootFolderObj.Release()
+		t.rootFolderObj = nil
+	}
+	if t.taskServiceObj != nil {
+		t.taskServiceObj.Release()
+		t.taskServiceObj = nil
+	}
+}
+
 // GetRunningTasks enumerates the Task Scheduler database for all currently running tasks.
+// Running tasks that complete while they are being enumerated are skipped.
 func (t *TaskService) GetRunningTasks() (RunningTaskCollection, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.getRunningTasks()
+}
+
+func (t *TaskService) getRunningTasks() (RunningTaskCollection, error) {
 	var runningTasks RunningTaskCollection
 
-	res, err := oleutil.CallMethod(t.taskServiceObj, "GetRunningTasks", int(TASK_ENUM_HIDDEN))
+	res, err := t.callMethod(t.taskServiceObj, "GetRunningTasks", int(TASK_ENUM_HIDDEN))
 	if err != nil {
-		return nil, fmt.Errorf("error getting running tasks: %v", getTaskSchedulerError(err))
+		return nil, fmt.Errorf("error getting running tasks: %w", getTaskSchedulerError(err))
 	}
 	defer res.Clear()
 
 	runningTasksObj := res.ToIDispatch()
 	defer runningTasksObj.Release()
-	err = oleutil.ForEach(runningTasksObj, func(v *ole.VARIANT) error {
+	err = t.forEach(runningTasksObj, func(v *ole.VARIANT) error {
 		task := v.ToIDispatch()
 
 		runningTask, err := parseRunningTask(task)
 		if err != nil {
+			task.Release()
+			if errors.Is(err, ErrRunningTaskCompleted) {
+				return nil
+			}
 			return fmt.Errorf("error parsing running task: %v", err)
 		}
 		runningTasks = append(runningTasks, runningTask)
@@ -157,167 +470,818 @@ func (t *TaskService) GetRunningTasks() (RunningTaskCollection, error) {
 	return runningTasks, nil
 }
 
-// GetRegisteredTasks enumerates the Task Scheduler database for all currently registered tasks.
-func (t *TaskService) GetRegisteredTasks() (RegisteredTaskCollection, error) {
-	var (
-		err             error
-		registeredTasks RegisteredTaskCollection
-	)
+// GetRegisteredTasks enumerates the Task Scheduler database for all currently registered tasks,
+// including hidden tasks unless ExcludeHidden is passed.
+func (t *TaskService) GetRegisteredTasks(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return t.getRegisteredTasks(context.Background(), nil, false, newEnumConfig(opts))
+}
 
-	// get tasks from root folder
-	res, err := oleutil.CallMethod(t.rootFolderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
-	if err != nil {
-		return nil, fmt.Errorf("error getting tasks of root folder: %v", getTaskSchedulerError(err))
+// GetRegisteredTasksOptions enumerates the Task Scheduler database for all currently
+// registered tasks. Hidden tasks are only included if includeHidden is true.
+//
+// Deprecated: use GetRegisteredTasks, passing ExcludeHidden() to skip hidden tasks.
+func (t *TaskService) GetRegisteredTasksOptions(includeHidden bool) (RegisteredTaskCollection, error) {
+	return t.getRegisteredTasks(context.Background(), nil, false, enumConfig{enumopts.Config{ExcludeHidden: !includeHidden}})
+}
+
+// enumFlags returns the flags to enumerate tasks with.
+func enumFlags(includeHidden bool) TaskEnumFlags {
+	return enumConfig{enumopts.Config{ExcludeHidden: !includeHidden}}.flags()
+}
+
+// filter returns fn wrapped so that the tasks that the config excludes because
+// of their enabled state are released without calling fn.
+func (c enumConfig) filter(fn func(*ole.IDispatch) error) func(*ole.IDispatch) error {
+	if !c.OnlyEnabled && !c.OnlyDisabled {
+		return fn
 	}
-	rootTaskCollection := res.ToIDispatch()
-	defer rootTaskCollection.Release()
-	err = oleutil.ForEach(rootTaskCollection, func(v *ole.VARIANT) error {
-		task := v.ToIDispatch()
-		defer task.Release()
 
-		registeredTask, path, err := parseRegisteredTask(task)
+	return func(task *ole.IDispatch) error {
+		enabledVar, err := oleutil.GetProperty(task, "Enabled")
 		if err != nil {
-			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+			task.Release()
+			return fmt.Errorf("error getting enabled state of registered task: %w", getTaskSchedulerError(err))
+		}
+		if !c.includes(enabledVar.Value().(bool)) {
+			task.Release()
+			return nil
 		}
-		registeredTasks = append(registeredTasks, registeredTask)
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		return fn(task)
 	}
+}
 
-	res, err = oleutil.CallMethod(t.rootFolderObj, "GetFolders", 0)
-	if err != nil {
-		return nil, fmt.Errorf("error getting task folders of root folder: %v", getTaskSchedulerError(err))
+// GetRegisteredTasksWithXML enumerates the Task Scheduler database for all currently
+// registered tasks, and stores the XML representation of each registered task in
+// its RawXML field as it is enumerated.
+func (t *TaskService) GetRegisteredTasksWithXML(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return t.getRegisteredTasks(context.Background(), nil, true, newEnumConfig(opts))
+}
+
+// GetRegisteredTasksMatching enumerates the Task Scheduler database for all currently
+// registered tasks whose path matches pattern. The pattern syntax is the same as in
+// filepath.Match, so '*' will not match across folders; `\MyApp\*` matches all tasks
+// directly inside the MyApp folder, but not tasks in its subfolders. Tasks that don't
+// match are skipped without being parsed. If pattern is malformed, filepath.ErrBadPattern
+// is returned.
+func (t *TaskService) GetRegisteredTasksMatching(pattern string, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
 	}
-	taskFolderList := res.ToIDispatch()
-	defer taskFolderList.Release()
 
-	// recursively enumerate folders and tasks
-	var enumTaskFolders func(*ole.VARIANT) error
-	enumTaskFolders = func(v *ole.VARIANT) error {
-		taskFolder := v.ToIDispatch()
-		defer taskFolder.Release()
+	return t.getRegisteredTasks(context.Background(), func(path string) bool {
+		matched, _ := filepath.Match(pattern, path)
+		return matched
+	}, false, newEnumConfig(opts))
+}
 
-		res, err := oleutil.CallMethod(taskFolder, "GetTasks", int(TASK_ENUM_HIDDEN))
-		if err != nil {
-			return fmt.Errorf("error getting tasks of folder: %v", getTaskSchedulerError(err))
-		}
-		taskCollection := res.ToIDispatch()
-		defer taskCollection.Release()
+// GetRegisteredTasksCtx is like GetRegisteredTasks, but stops enumerating and
+// returns ctx.Err() if ctx is canceled or its deadline is exceeded before all
+// registered tasks have been enumerated.
+func (t *TaskService) GetRegisteredTasksCtx(ctx context.Context, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return t.getRegisteredTasks(ctx, nil, false, newEnumConfig(opts))
+}
 
-		err = oleutil.ForEach(taskCollection, func(v *ole.VARIANT) error {
-			task := v.ToIDispatch()
+// getRegisteredTasks enumerates the registered tasks that config includes. If
+// match is not nil, only tasks whose path match is true for are parsed and
+// returned. Enumeration stops once ctx is done.
+func (t *TaskService) getRegisteredTasks(ctx context.Context, match func(path string) bool, captureXML bool, config enumConfig) (RegisteredTaskCollection, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-			registeredTask, path, err := parseRegisteredTask(task)
-			if err != nil {
-				return fmt.Errorf("error parsing registered task %s: %v", path, err)
-			}
-			registeredTasks = append(registeredTasks, registeredTask)
+	var (
+		mu              sync.Mutex
+		registeredTasks RegisteredTaskCollection
+	)
 
-			return nil
-		})
-		if err != nil {
+	err := t.walkTasks(config, func(task *ole.IDispatch) error {
+		if err := ctx.Err(); err != nil {
+			task.Release()
 			return err
 		}
-
-		res, err = oleutil.CallMethod(taskFolder, "GetFolders", 0)
-		if err != nil {
-			return fmt.Errorf("error getting subfolders of folder: %v", getTaskSchedulerError(err))
+		if match != nil {
+			pathVar, err := oleutil.GetProperty(task, "Path")
+			if err != nil {
+				task.Release()
+				return fmt.Errorf("error getting path of registered task: %w", getTaskSchedulerError(err))
+			}
+			if !match(pathVar.ToString()) {
+				task.Release()
+				return nil
+			}
 		}
-		taskFolderList := res.ToIDispatch()
-		defer taskFolderList.Release()
 
-		err = oleutil.ForEach(taskFolderList, enumTaskFolders)
+		registeredTask, path, err := parseRegisteredTask(task)
 		if err != nil {
-			return err
+			task.Release()
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+		}
+		if captureXML {
+			xml, err := oleutil.GetProperty(task, "Xml")
+			if err != nil {
+				registeredTask.Release()
+				return fmt.Errorf("error getting XML of registered task %s: %w", path, getTaskSchedulerPathError(err, "Xml", path))
+			}
+			registeredTask.RawXML = xml.ToString()
 		}
+		mu.Lock()
+		registeredTasks = append(registeredTasks, registeredTask)
+		mu.Unlock()
 
 		return nil
-	}
-
-	err = oleutil.ForEach(taskFolderList, enumTaskFolders)
+	})
 	if err != nil {
+		registeredTasks.Release()
 		return nil, err
 	}
 
 	return registeredTasks, nil
 }
 
-// GetRegisteredTask attempts to find the specified registered task and returns a
-// pointer to it if it exists. If it doesn't exist, nil will be returned in place of
-// the registered task.
-func (t *TaskService) GetRegisteredTask(path string) (RegisteredTask, error) {
-	if path[0] != '\\' {
-		return RegisteredTask{}, ErrInvalidPath
-	}
+// GetRegisteredTasksWithErrors enumerates the Task Scheduler database for all
+// currently registered tasks like GetRegisteredTasks, but tasks that can't be
+// parsed are skipped instead of aborting the enumeration, and are returned as
+// TaskParseErrors alongside the tasks that were parsed. An error is only
+// returned if the enumeration itself fails.
+func (t *TaskService) GetRegisteredTasksWithErrors(opts ...EnumOption) (RegisteredTaskCollection, []TaskParseError, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	taskObj, err := oleutil.CallMethod(t.rootFolderObj, "GetTask", path)
-	if err != nil {
-		return RegisteredTask{}, fmt.Errorf("error getting registered task %s: %v", path, getTaskSchedulerError(err))
-	}
+	var (
+		mu              sync.Mutex
+		registeredTasks RegisteredTaskCollection
+		parseErrs       []TaskParseError
+	)
 
-	task, _, err := parseRegisteredTask(taskObj.ToIDispatch())
+	err := t.walkTasks(newEnumConfig(opts), func(task *ole.IDispatch) error {
+		registeredTask, path, err := parseRegisteredTask(task)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			task.Release()
+			parseErrs = append(parseErrs, TaskParseError{Path: path, Err: err})
+			return nil
+		}
+		registeredTasks = append(registeredTasks, registeredTask)
+
+		return nil
+	})
 	if err != nil {
-		return RegisteredTask{}, fmt.Errorf("error parsing registered task %s: %v", path, err)
+		registeredTasks.Release()
+		return nil, nil, err
 	}
 
-	return task, nil
+	return registeredTasks, parseErrs, nil
 }
 
-// GetTaskFolders enumerates the Task Schedule database for all task folders and currently
-// registered tasks.
-func (t TaskService) GetTaskFolders() (TaskFolder, error) {
-	return t.GetTaskFolder(`\`)
-}
+// ListTasks enumerates the Task Scheduler database for all currently registered
+// tasks, including hidden tasks unless ExcludeHidden is passed, but only reads
+// the properties of each task that are cheap to get: Name, Path, Enabled, State,
+// MissedRuns, NextRunTime, LastRunTime and LastTaskResult. The Definition of each task is left unset,
+// which makes ListTasks considerably faster than GetRegisteredTasks when there
+// are many tasks. Call RegisteredTask.LoadDefinition to get the definition of a
+// task when it's needed.
+func (t *TaskService) ListTasks(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-// GetTaskFolder enumerates the Task Schedule database for all task sub folders and currently
-// registered tasks under the folder specified, if it exists. If it doesn't exist, nil will be
-// returned in place of the task folder.
-func (t TaskService) GetTaskFolder(path string) (TaskFolder, error) {
-	if path[0] != '\\' {
-		return TaskFolder{}, ErrInvalidPath
-	}
+	var (
+		mu              sync.Mutex
+		registeredTasks RegisteredTaskCollection
+	)
 
-	var topFolderObj *ole.IDispatch
-	if path == `\` {
-		topFolderObj = t.rootFolderObj
-	} else {
-		topFolder, err := oleutil.CallMethod(t.taskServiceObj, "GetFolder", path)
+	err := t.walkTasks(newEnumConfig(opts), func(task *ole.IDispatch) error {
+		registeredTask, path, err := parseRegisteredTaskInfo(task)
 		if err != nil {
-			return TaskFolder{}, fmt.Errorf("error getting folder %s: %v", path, getTaskSchedulerError(err))
+			task.Release()
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
 		}
-		topFolderObj = topFolder.ToIDispatch()
-		defer topFolderObj.Release()
-	}
+		mu.Lock()
+		registeredTasks = append(registeredTasks, registeredTask)
+		mu.Unlock()
 
-	// get tasks from the top folder
-	res, err := oleutil.CallMethod(topFolderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
+		return nil
+	})
 	if err != nil {
-		return TaskFolder{}, fmt.Errorf("error getting tasks of folder %s: %v", path, getTaskSchedulerError(err))
+		registeredTasks.Release()
+		return nil, err
 	}
-	topFolderTaskCollection := res.ToIDispatch()
-	defer topFolderTaskCollection.Release()
-	topFolder := TaskFolder{Path: `\`}
-	err = oleutil.ForEach(topFolderTaskCollection, func(v *ole.VARIANT) error {
-		task := v.ToIDispatch()
 
+	return registeredTasks, nil
+}
+
+// ForEachRegisteredTask enumerates the Task Scheduler database for all currently
+// registered tasks, including hidden tasks unless ExcludeHidden is passed, and
+// calls fn with each task as soon as it's parsed, so that only one task is held
+// in memory at a time. Each task is released once fn returns, so fn must not keep
+// a reference to it. If fn returns an error, enumeration stops and the error is
+// returned. With WithParallelism, tasks are parsed concurrently, but fn is never
+// called concurrently.
+func (t *TaskService) ForEachRegisteredTask(fn func(RegisteredTask) error, opts ...EnumOption) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var mu sync.Mutex
+	return t.walkTasks(newEnumConfig(opts), func(task *ole.IDispatch) error {
 		registeredTask, path, err := parseRegisteredTask(task)
 		if err != nil {
+			task.Release()
 			return fmt.Errorf("error parsing registered task %s: %v", path, err)
 		}
-		topFolder.RegisteredTasks = append(topFolder.RegisteredTasks, registeredTask)
+		defer registeredTask.Release()
 
-		return nil
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(registeredTask)
 	})
-	if err != nil {
-		return TaskFolder{}, err
+}
+
+// GetRegisteredTasksParallel enumerates the Task Scheduler database for all currently
+// registered tasks like GetRegisteredTasks, but processes folders concurrently using
+// up to workers goroutines.
+//
+// Deprecated: use GetRegisteredTasks(WithParallelism(workers)).
+func (t *TaskService) GetRegisteredTasksParallel(workers int, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return t.GetRegisteredTasks(append(opts, WithParallelism(workers))...)
+}
+
+// walkTasks calls fn with every registered task that config includes. If config
+// is parallel, fn is called concurrently from multiple goroutines. fn takes
+// ownership of the task COM object.
+func (t *TaskService) walkTasks(config enumConfig, fn func(*ole.IDispatch) error) error {
+	if !config.Parallel {
+		return t.walkRegisteredTasks(t.rootFolderObj, config.flags(), config.filter(fn))
+	}
+	if t.apartmentThreaded {
+		return errors.New("error enumerating tasks: parallel enumeration can't be used with a single-threaded apartment")
 	}
 
-	res, err = oleutil.CallMethod(topFolderObj, "GetFolders", 0)
-	if err != nil {
-		return TaskFolder{}, fmt.Errorf("error getting subfolders of folder %s: %v", path, getTaskSchedulerError(err))
+	workers := config.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		mu          sync.Mutex
+		firstErr    error
+		pending     sync.WaitGroup
+		workersDone sync.WaitGroup
+	)
+	folderPaths := make(chan string)
+
+	// queueFolders must be called with pending incremented by len(paths). Folders
+	// are queued from a separate goroutine so workers never block each other
+	queueFolders := func(paths []string) {
+		go func() {
+			for _, path := range paths {
+				folderPaths <- path
+			}
+		}()
+	}
+
+	for i := 0; i < workers; i++ {
+		workersDone.Add(1)
+		go func() {
+			defer workersDone.Done()
+
+			// each worker needs COM initialized on its own OS thread
+			uninitialize, initErr := initializeThread()
+			if initErr == nil {
+				defer uninitialize()
+			}
+
+			for path := range folderPaths {
+				mu.Lock()
+				if initErr != nil && firstErr == nil {
+					firstErr = fmt.Errorf("error initializing COM: %v", initErr)
+				}
+				failed := firstErr != nil
+				mu.Unlock()
+
+				// once an error has occurred, drain the remaining folders
+				// without processing them
+				if !failed {
+					subFolderPaths, err := t.walkFolder(path, config, fn)
+					if err != nil {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						mu.Unlock()
+					}
+
+					pending.Add(len(subFolderPaths))
+					queueFolders(subFolderPaths)
+				}
+				pending.Done()
+			}
+		}()
+	}
+
+	pending.Add(1)
+	queueFolders([]string{`\`})
+	pending.Wait()
+	close(folderPaths)
+	workersDone.Wait()
+
+	return firstErr
+}
+
+// walkFolder calls fn with every registered task that config includes directly
+// inside the folder at path, and returns the paths of its subfolders.
+func (t *TaskService) walkFolder(path string, config enumConfig, fn func(*ole.IDispatch) error) ([]string, error) {
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return nil, err
+	}
+	defer folderObj.Release()
+
+	res, err := t.callMethod(folderObj, "GetTasks", int(config.flags()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting tasks of folder %s: %w", path, getTaskSchedulerPathError(err, "GetTasks", path))
+	}
+	taskCollection := res.ToIDispatch()
+	defer taskCollection.Release()
+
+	fn = config.filter(fn)
+	err = t.forEach(taskCollection, func(v *ole.VARIANT) error {
+		return fn(v.ToIDispatch())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = t.callMethod(folderObj, "GetFolders", 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subfolders of folder %s: %w", path, getTaskSchedulerPathError(err, "GetFolders", path))
+	}
+	taskFolderList := res.ToIDispatch()
+	defer taskFolderList.Release()
+
+	var subFolderPaths []string
+	err = t.forEach(taskFolderList, func(v *ole.VARIANT) error {
+		taskFolder := v.ToIDispatch()
+		defer taskFolder.Release()
+
+		subFolderPaths = append(subFolderPaths, oleutil.MustGetProperty(taskFolder, "Path").ToString())
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subFolderPaths, nil
+}
+
+// walkRegisteredTasks calls fn with every registered task in folderObj and all
+// of its subfolders, recursively. fn takes ownership of the task COM object.
+func (t *TaskService) walkRegisteredTasks(folderObj *ole.IDispatch, flags TaskEnumFlags, fn func(*ole.IDispatch) error) error {
+	err := t.forEachTaskInFolder(folderObj, flags, fn)
+	if err != nil {
+		return err
+	}
+
+	res, err := t.callMethod(folderObj, "GetFolders", 0)
+	if err != nil {
+		return fmt.Errorf("error getting subfolders of folder: %w", getTaskSchedulerError(err))
+	}
+	taskFolderList := res.ToIDispatch()
+	defer taskFolderList.Release()
+
+	// recursively enumerate folders and tasks
+	return t.forEach(taskFolderList, func(v *ole.VARIANT) error {
+		taskFolder := v.ToIDispatch()
+		defer taskFolder.Release()
+
+		return t.walkRegisteredTasks(taskFolder, flags, fn)
+	})
+}
+
+// forEachTaskInFolder calls fn with every registered task directly inside
+// folderObj. fn takes ownership of the task COM object.
+func (t *TaskService) forEachTaskInFolder(folderObj *ole.IDispatch, flags TaskEnumFlags, fn func(*ole.IDispatch) error) error {
+	res, err := t.callMethod(folderObj, "GetTasks", int(flags))
+	if err != nil {
+		return fmt.Errorf("error getting tasks of folder: %w", getTaskSchedulerError(err))
+	}
+	taskCollection := res.ToIDispatch()
+	defer taskCollection.Release()
+
+	return t.forEach(taskCollection, func(v *ole.VARIANT) error {
+		return fn(v.ToIDispatch())
+	})
+}
+
+// GetRegisteredTasksInFolder returns the registered tasks inside the folder at path.
+// If recursive is true, tasks in all subfolders of the folder are returned as well.
+// Hidden tasks are only included if includeHidden is true. If the folder doesn't
+// exist, an error wrapping ErrFolderNotFound is returned.
+//
+// Deprecated: use GetTasksInFolder, passing IncludeSubfolders() to include the
+// subfolders and ExcludeHidden() to skip hidden tasks.
+func (t *TaskService) GetRegisteredTasksInFolder(path string, recursive, includeHidden bool) (RegisteredTaskCollection, error) {
+	return t.GetRegisteredTasksFiltered(path, recursive, includeHidden, TaskFilter{})
+}
+
+// GetRegisteredTasksFiltered is like GetRegisteredTasksInFolder, but only returns
+// the registered tasks that match filter. Tasks that don't match are skipped without
+// being parsed. If a pattern of filter is malformed, filepath.ErrBadPattern is returned.
+func (t *TaskService) GetRegisteredTasksFiltered(path string, recursive, includeHidden bool, filter TaskFilter) (RegisteredTaskCollection, error) {
+	if path[0] != '\\' {
+		return nil, ErrInvalidPath
+	}
+	if _, err := filepath.Match(filter.NamePattern, ""); err != nil {
+		return nil, err
+	}
+	if _, err := filepath.Match(filter.PathPattern, ""); err != nil {
+		return nil, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return nil, err
+	}
+	defer folderObj.Release()
+
+	var registeredTasks RegisteredTaskCollection
+	fn := func(task *ole.IDispatch) error {
+		matched, err := filter.matches(task)
+		if err != nil {
+			task.Release()
+			return err
+		}
+		if !matched {
+			task.Release()
+			return nil
+		}
+
+		registeredTask, path, err := parseRegisteredTask(task)
+		if err != nil {
+			task.Release()
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+		}
+		registeredTasks = append(registeredTasks, registeredTask)
+
+		return nil
+	}
+
+	flags := enumFlags(includeHidden)
+	if recursive {
+		err = t.walkRegisteredTasks(folderObj, flags, fn)
+	} else {
+		err = t.forEachTaskInFolder(folderObj, flags, fn)
+	}
+	if err != nil {
+		registeredTasks.Release()
+		return nil, err
+	}
+
+	return registeredTasks, nil
+}
+
+// FindTasks returns the registered tasks, including hidden tasks, in the folder
+// of query and its subfolders that match query. Each task is checked as soon as
+// it's enumerated, and tasks that don't match are released straight away, so
+// only the matching tasks are held in memory. If ActionPathMatches is malformed,
+// path.ErrBadPattern is returned.
+func (t *TaskService) FindTasks(query Query) (RegisteredTaskCollection, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	folder := query.Folder
+	if folder == "" {
+		folder = `\`
+	}
+	if folder[0] != '\\' {
+		return nil, ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	folderObj, err := t.getFolderObj(folder)
+	if err != nil {
+		return nil, err
+	}
+	defer folderObj.Release()
+
+	var registeredTasks RegisteredTaskCollection
+	err = t.walkRegisteredTasks(folderObj, TASK_ENUM_HIDDEN, func(task *ole.IDispatch) error {
+		registeredTask, path, err := parseRegisteredTask(task)
+		if err != nil {
+			task.Release()
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+		}
+		if !query.Matches(registeredTask.Definition) {
+			registeredTask.Release()
+			return nil
+		}
+		registeredTasks = append(registeredTasks, registeredTask)
+
+		return nil
+	})
+	if err != nil {
+		registeredTasks.Release()
+		return nil, err
+	}
+
+	return registeredTasks, nil
+}
+
+// matches returns true if the registered task COM object matches the filter.
+func (f TaskFilter) matches(task *ole.IDispatch) (bool, error) {
+	if f.NamePattern != "" {
+		name, err := oleutil.GetProperty(task, "Name")
+		if err != nil {
+			return false, fmt.Errorf("error getting name of registered task: %w", getTaskSchedulerError(err))
+		}
+		if matched, _ := filepath.Match(f.NamePattern, name.ToString()); !matched {
+			return false, nil
+		}
+	}
+	if f.PathPattern != "" {
+		path, err := oleutil.GetProperty(task, "Path")
+		if err != nil {
+			return false, fmt.Errorf("error getting path of registered task: %w", getTaskSchedulerError(err))
+		}
+		if matched, _ := filepath.Match(f.PathPattern, path.ToString()); !matched {
+			return false, nil
+		}
+	}
+	if len(f.States) > 0 {
+		stateVar, err := oleutil.GetProperty(task, "State")
+		if err != nil {
+			return false, fmt.Errorf("error getting state of registered task: %w", getTaskSchedulerError(err))
+		}
+		state := TaskState(stateVar.Val)
+		for _, s := range f.States {
+			if s == state {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetRegisteredTask attempts to find the specified registered task and returns it
+// if it exists. If it doesn't exist, an empty registered task will be returned along
+// with an error wrapping ErrTaskNotFound.
+func (t *TaskService) GetRegisteredTask(path string) (RegisteredTask, error) {
+	if path[0] != '\\' {
+		return RegisteredTask{}, ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.getRegisteredTask(path)
+}
+
+// GetRegisteredTaskWithXML is like GetRegisteredTask, but also returns the XML of
+// the task as it is stored by the Task Scheduler service, which is also set as the
+// task's RawXML. The XML is read from the same task object that is parsed, so both
+// reflect the same version of the task.
+func (t *TaskService) GetRegisteredTaskWithXML(path string) (RegisteredTask, string, error) {
+	if path[0] != '\\' {
+		return RegisteredTask{}, "", ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	task, err := t.getRegisteredTask(path)
+	if err != nil {
+		return RegisteredTask{}, "", err
+	}
+
+	xml, err := oleutil.GetProperty(task.taskObj, "Xml")
+	if err != nil {
+		task.Release()
+		return RegisteredTask{}, "", fmt.Errorf("error getting XML of registered task %s: %w", path, getTaskSchedulerPathError(err, "Xml", path))
+	}
+	task.RawXML = xml.ToString()
+
+	return task, task.RawXML, nil
+}
+
+func (t *TaskService) getRegisteredTask(path string) (RegisteredTask, error) {
+	taskObj, err := t.callMethod(t.rootFolderObj, "GetTask", path)
+	if err != nil {
+		if isNotFoundError(err) {
+			return RegisteredTask{}, fmt.Errorf("error getting registered task %s: %w", path, ErrTaskNotFound)
+		}
+		return RegisteredTask{}, fmt.Errorf("error getting registered task %s: %w", path, getTaskSchedulerPathError(err, "GetTask", path))
+	}
+
+	task, _, err := parseRegisteredTask(taskObj.ToIDispatch())
+	if err != nil {
+		return RegisteredTask{}, fmt.Errorf("error parsing registered task %s: %v", path, err)
+	}
+
+	return task, nil
+}
+
+// GetTasksInFolder returns the registered tasks that are directly inside the
+// folder specified, including hidden tasks unless ExcludeHidden is passed. The
+// subfolders of the folder are only enumerated if IncludeSubfolders is passed.
+// If the folder doesn't exist, an error wrapping ErrFolderNotFound is returned.
+func (t *TaskService) GetTasksInFolder(path string, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return t.getTasksInFolder(path, newEnumConfig(opts))
+}
+
+// GetTasksInFolderOptions is like GetTasksInFolder, but hidden tasks are only
+// included if includeHidden is true.
+//
+// Deprecated: use GetTasksInFolder, passing ExcludeHidden() to skip hidden tasks.
+func (t *TaskService) GetTasksInFolderOptions(path string, includeHidden bool) (RegisteredTaskCollection, error) {
+	return t.getTasksInFolder(path, enumConfig{enumopts.Config{ExcludeHidden: !includeHidden}})
+}
+
+func (t *TaskService) getTasksInFolder(path string, config enumConfig) (RegisteredTaskCollection, error) {
+	if path[0] != '\\' {
+		return nil, ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return nil, err
+	}
+	defer folderObj.Release()
+
+	if !config.IncludeSubfolders {
+		return t.parseTasksInFolder(folderObj, path, config)
+	}
+
+	var registeredTasks RegisteredTaskCollection
+	err = t.walkRegisteredTasks(folderObj, config.flags(), config.filter(func(task *ole.IDispatch) error {
+		registeredTask, path, err := parseRegisteredTask(task)
+		if err != nil {
+			task.Release()
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+		}
+		registeredTasks = append(registeredTasks, registeredTask)
+
+		return nil
+	}))
+	if err != nil {
+		registeredTasks.Release()
+		return nil, err
+	}
+
+	return registeredTasks, nil
+}
+
+// parseTasksInFolder parses the registered tasks that config includes that are
+// directly inside folderObj, which is the task folder at path.
+func (t *TaskService) parseTasksInFolder(folderObj *ole.IDispatch, path string, config enumConfig) (RegisteredTaskCollection, error) {
+	res, err := t.callMethod(folderObj, "GetTasks", int(config.flags()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting tasks of folder %s: %w", path, getTaskSchedulerPathError(err, "GetTasks", path))
+	}
+	taskCollection := res.ToIDispatch()
+	defer taskCollection.Release()
+
+	var registeredTasks RegisteredTaskCollection
+	parse := config.filter(func(task *ole.IDispatch) error {
+		registeredTask, path, err := parseRegisteredTask(task)
+		if err != nil {
+			task.Release()
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+		}
+		registeredTasks = append(registeredTasks, registeredTask)
+
+		return nil
+	})
+	err = t.forEach(taskCollection, func(v *ole.VARIANT) error {
+		return parse(v.ToIDispatch())
+	})
+	if err != nil {
+		registeredTasks.Release()
+		return nil, err
+	}
+
+	return registeredTasks, nil
+}
+
+// cacheFolders caches the folders that are looked up until the returned function
+// is called, so that an operation only looks each folder up once.
+func (t *TaskService) cacheFolders() func() {
+	t.folders.begin()
+
+	return t.folders.end
+}
+
+// getFolderObj returns the task folder COM object at path. If the folder doesn't
+// exist, an error wrapping ErrFolderNotFound is returned. The returned object
+// must be released.
+func (t *TaskService) getFolderObj(path string) (*ole.IDispatch, error) {
+	if folderObj, ok := t.folders.get(path); ok {
+		return folderObj, nil
+	}
+
+	folder, err := t.callMethod(t.taskServiceObj, "GetFolder", path)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, fmt.Errorf("error getting folder %s: %w", path, ErrFolderNotFound)
+		}
+		return nil, fmt.Errorf("error getting folder %s: %w", path, getTaskSchedulerPathError(err, "GetFolder", path))
+	}
+	folderObj := folder.ToIDispatch()
+	t.folders.put(path, folderObj)
+
+	return folderObj, nil
+}
+
+// OpenTaskFolder returns a handle to the task folder at path, which can be used to
+// manage the tasks and subfolders of the folder with paths relative to it. Unlike
+// a TaskFolder, the handle keeps a reference to the folder COM object, so it must be
+// released. If the folder doesn't exist, an error wrapping ErrFolderNotFound is returned.
+func (t *TaskService) OpenTaskFolder(path string) (TaskFolderHandle, error) {
+	if path[0] != '\\' {
+		return TaskFolderHandle{}, ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return TaskFolderHandle{}, err
+	}
+
+	return newTaskFolderHandle(t, folderObj), nil
+}
+
+// GetTaskFolders enumerates the Task Schedule database for all task folders and currently
+// registered tasks.
+func (t TaskService) GetTaskFolders() (TaskFolder, error) {
+	return t.GetTaskFolder(`\`)
+}
+
+// GetTaskFolder enumerates the Task Schedule database for all task sub folders and currently
+// registered tasks under the folder specified, if it exists, including hidden tasks. If it
+// doesn't exist, an empty task folder will be returned along with an error wrapping
+// ErrFolderNotFound.
+func (t TaskService) GetTaskFolder(path string) (TaskFolder, error) {
+	return t.getTaskFolder(path, TASK_ENUM_HIDDEN)
+}
+
+// GetTaskFolderOptions is like GetTaskFolder, but hidden tasks are only included
+// if includeHidden is true.
+func (t TaskService) GetTaskFolderOptions(path string, includeHidden bool) (TaskFolder, error) {
+	return t.getTaskFolder(path, enumFlags(includeHidden))
+}
+
+func (t TaskService) getTaskFolder(path string, flags TaskEnumFlags) (TaskFolder, error) {
+	if path[0] != '\\' {
+		return TaskFolder{}, ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	topFolderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return TaskFolder{}, err
+	}
+	defer topFolderObj.Release()
+
+	// get tasks from the top folder
+	res, err := t.callMethod(topFolderObj, "GetTasks", int(flags))
+	if err != nil {
+		return TaskFolder{}, fmt.Errorf("error getting tasks of folder %s: %w", path, getTaskSchedulerPathError(err, "GetTasks", path))
+	}
+	topFolderTaskCollection := res.ToIDispatch()
+	defer topFolderTaskCollection.Release()
+	topFolder := TaskFolder{Path: `\`}
+	err = t.forEach(topFolderTaskCollection, func(v *ole.VARIANT) error {
+		task := v.ToIDispatch()
+
+		registeredTask, path, err := parseRegisteredTask(task)
+		if err != nil {
+			return fmt.Errorf("error parsing registered task %s: %v", path, err)
+		}
+		topFolder.RegisteredTasks = append(topFolder.RegisteredTasks, registeredTask)
+
+		return nil
+	})
+	if err != nil {
+		return TaskFolder{}, err
+	}
+
+	res, err = t.callMethod(topFolderObj, "GetFolders", 0)
+	if err != nil {
+		return TaskFolder{}, fmt.Errorf("error getting subfolders of folder %s: %w", path, getTaskSchedulerPathError(err, "GetFolders", path))
 	}
 	taskFolderList := res.ToIDispatch()
 	defer taskFolderList.Release()
@@ -332,9 +1296,9 @@ func (t TaskService) GetTaskFolder(path string) (TaskFolder, error) {
 
 			name := oleutil.MustGetProperty(taskFolder, "Name").ToString()
 			path := oleutil.MustGetProperty(taskFolder, "Path").ToString()
-			res, err := oleutil.CallMethod(taskFolder, "GetTasks", int(TASK_ENUM_HIDDEN))
+			res, err := t.callMethod(taskFolder, "GetTasks", int(flags))
 			if err != nil {
-				return fmt.Errorf("error getting tasks of folder %s: %v", path, getTaskSchedulerError(err))
+				return fmt.Errorf("error getting tasks of folder %s: %w", path, getTaskSchedulerPathError(err, "GetTasks", path))
 			}
 			taskCollection := res.ToIDispatch()
 			defer taskCollection.Release()
@@ -344,7 +1308,7 @@ func (t TaskService) GetTaskFolder(path string) (TaskFolder, error) {
 				Path: path,
 			}
 
-			err = oleutil.ForEach(taskCollection, func(v *ole.VARIANT) error {
+			err = t.forEach(taskCollection, func(v *ole.VARIANT) error {
 				task := v.ToIDispatch()
 
 				registeredTask, path, err := parseRegisteredTask(task)
@@ -361,129 +1325,744 @@ func (t TaskService) GetTaskFolder(path string) (TaskFolder, error) {
 
 			parentFolder.SubFolders = append(parentFolder.SubFolders, taskSubFolder)
 
-			res, err = oleutil.CallMethod(taskFolder, "GetFolders", 0)
+			res, err = t.callMethod(taskFolder, "GetFolders", 0)
 			if err != nil {
-				return fmt.Errorf("error getting subfolders of folder %s: %v", path, getTaskSchedulerError(err))
+				return fmt.Errorf("error getting subfolders of folder %s: %w", path, getTaskSchedulerPathError(err, "GetFolders", path))
 			}
 			taskFolderList := res.ToIDispatch()
 			defer taskFolderList.Release()
 
-			err = oleutil.ForEach(taskFolderList, initEnumTaskFolders(taskSubFolder))
+			err = t.forEach(taskFolderList, initEnumTaskFolders(taskSubFolder))
 			if err != nil {
 				return err
 			}
 
-			return nil
-		}
+			return nil
+		}
+
+		return enumTaskFolders
+	}
+
+	err = t.forEach(taskFolderList, initEnumTaskFolders(&topFolder))
+	if err != nil {
+		return TaskFolder{}, err
+	}
+
+	return topFolder, nil
+}
+
+// NewTaskDefinition returns a new task definition that can be used to register a new task.
+// Task settings and properties are set to Task Scheduler default values.
+func (t TaskService) NewTaskDefinition() Definition {
+	newDef := defaultDefinition()
+	newDef.RegistrationInfo.Author = t.connectedDomain + `\` + t.connectedUser
+
+	return newDef
+}
+
+// CreateTask creates a registered task on the connected computer. The folder of
+// the task is created with CreateFolder if it doesn't exist, along with any of its
+// parent folders. CreateTask returns true if the task was successfully registered,
+// and false if the overwrite parameter is false and a task at the specified path
+// already exists.
+func (t *TaskService) CreateTask(path string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
+	return t.CreateTaskEx(path, newTaskDef, "", "", newTaskDef.Principal.LogonType, overwrite)
+}
+
+// CreateTaskCtx is like CreateTask, but returns ctx.Err() without registering
+// the task if ctx is done before the task is registered. ctx is checked before
+// any call to the Task Scheduler service and again before the task is registered,
+// so folders created for the task are kept if ctx is done in between. Each call
+// to the Task Scheduler service can't be interrupted once it has started.
+func (t *TaskService) CreateTaskCtx(ctx context.Context, path string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return RegisteredTask{}, false, err
+	}
+
+	return t.createTask(ctx, path, newTaskDef, "", "", newTaskDef.Principal.LogonType, "", overwrite)
+}
+
+// CreateTaskEx creates a registered task on the connected computer. CreateTaskEx returns
+// true if the task was successfully registered, and false if the overwrite parameter
+// is false and a task at the specified path already exists.
+func (t *TaskService) CreateTaskEx(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
+	return t.CreateTaskWithSDDL(path, newTaskDef, username, password, logonType, "", overwrite)
+}
+
+// CreateTaskWithSDDL creates a registered task on the connected computer, and applies
+// the security descriptor sddl to it. The security descriptor is specified in the
+// Security Descriptor Definition Language (SDDL). If sddl is empty, the default
+// security descriptor is used. CreateTaskWithSDDL returns true if the task was
+// successfully registered, and false if the overwrite parameter is false and a task
+// at the specified path already exists.
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/security-descriptor-definition-language
+func (t *TaskService) CreateTaskWithSDDL(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, sddl string, overwrite bool) (RegisteredTask, bool, error) {
+	return t.createTask(context.Background(), path, newTaskDef, username, password, logonType, sddl, overwrite)
+}
+
+// createTask registers a task like CreateTaskWithSDDL, returning ctx.Err() if
+// ctx is done once the folder of the task has been prepared.
+func (t *TaskService) createTask(ctx context.Context, path string, newTaskDef Definition, username, password string, logonType TaskLogonType, sddl string, overwrite bool) (RegisteredTask, bool, error) {
+	var err error
+
+	if path[0] != '\\' {
+		return RegisteredTask{}, false, ErrInvalidPath
+	} else if err = validateDefinition(newTaskDef); err != nil {
+		return RegisteredTask{}, false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cacheFolders()()
+
+	existingTask, exists, err := t.prepareTaskPath(path, overwrite)
+	if err != nil {
+		return RegisteredTask{}, false, err
+	} else if exists {
+		return existingTask, false, nil
+	}
+	if err = ctx.Err(); err != nil {
+		return RegisteredTask{}, false, err
+	}
+
+	newTaskObj, err := t.modifyTask(path, newTaskDef, username, password, logonType, sddl, TASK_CREATE)
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error creating registered task %s: %v", path, err)
+	}
+
+	newTask, _, err := parseRegisteredTask(newTaskObj)
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error parsing registered task %s: %v", path, err)
+	}
+
+	return newTask, true, nil
+}
+
+// EnsureTask makes sure the task registered at path has the definition def. If no
+// task exists at path, it is registered. If a task exists but its definition
+// differs from def according to DiffDefinitions, it is updated. Otherwise the task
+// is left untouched, so that its run history and stored credentials are kept.
+// Fields that the Task Scheduler service fills in when a task is registered, such
+// as the IDs of actions or the principal of a task without a user or group, are
+// only compared if def sets them. Daily, weekly and monthly triggers that only
+// differ by starting on a later day at the same time of day, such as the ones
+// TaskBuilder and TriggersFromCron start on the day they are built, are equal.
+// EnsureTask returns true if the task was registered or updated. Tasks that use TASK_LOGON_PASSWORD can only be updated
+// if opts contains the password.
+func (t *TaskService) EnsureTask(path string, def Definition, opts EnsureTaskOptions) (bool, error) {
+	if path[0] != '\\' {
+		return false, ErrInvalidPath
+	} else if err := validateDefinition(def); err != nil {
+		return false, err
+	}
+
+	logonType := opts.LogonType
+	if logonType == TASK_LOGON_NONE {
+		logonType = def.Principal.LogonType
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cacheFolders()()
+
+	flags := TASK_UPDATE
+	existingTask, err := t.getRegisteredTask(path)
+	if errors.Is(err, ErrTaskNotFound) {
+		if _, _, err = t.prepareTaskPath(path, false); err != nil {
+			return false, err
+		}
+		flags = TASK_CREATE
+	} else if err != nil {
+		return false, err
+	} else {
+		defer existingTask.Release()
+		if len(DiffDefinitions(existingTask.Definition, withRegisteredDefaults(def, existingTask.Definition))) == 0 {
+			return false, nil
+		}
+	}
+
+	newTaskObj, err := t.modifyTask(path, def, opts.Username, opts.Password, logonType, "", flags)
+	if err != nil {
+		return false, fmt.Errorf("error ensuring registered task %s: %v", path, err)
+	}
+	newTaskObj.Release()
+
+	return true, nil
+}
+
+// CreateTaskFromXML registers a task on the connected computer from its XML
+// representation, such as one returned by RegisteredTask.ExportXML. CreateTaskFromXML
+// returns true if the task was successfully registered, and false if the overwrite
+// parameter is false and a task at the specified path already exists. If the
+// XML is not valid, ErrMalformedXML is returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-registertask
+func (t *TaskService) CreateTaskFromXML(path, xml string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
+	if path[0] != '\\' {
+		return RegisteredTask{}, false, ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cacheFolders()()
+
+	existingTask, exists, err := t.prepareTaskPath(path, overwrite)
+	if err != nil {
+		return RegisteredTask{}, false, err
+	} else if exists {
+		return existingTask, false, nil
+	}
+
+	res, err := t.callMethod(t.rootFolderObj, "RegisterTask", path, xml, int(TASK_CREATE), "", "", int(logonType), "")
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error registering task %s: %w", path, getTaskSchedulerPathError(err, "RegisterTask", path))
+	}
+
+	newTask, _, err := parseRegisteredTask(res.ToIDispatch())
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error parsing registered task %s: %v", path, err)
+	}
+
+	return newTask, true, nil
+}
+
+// RegisterTaskFromXML is an alias for CreateTaskFromXML, matching the name of the
+// ITaskFolder::RegisterTask method it wraps. It can be used together with
+// RegisteredTask.ExportXML to back up tasks and restore them on another computer.
+func (t *TaskService) RegisterTaskFromXML(path, xml string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
+	return t.CreateTaskFromXML(path, xml, logonType, overwrite)
+}
+
+// CreateTasks registers the tasks described by specs, and returns the result of
+// registering each of them in the same order. Unlike calling CreateTaskEx for every
+// task, the folder of each task is only looked up once, and the existence of tasks
+// is checked by enumerating each folder once, which matters when connected to a
+// remote computer. Folders that don't exist are created. A task that can't be
+// registered doesn't prevent the remaining tasks from being registered; its
+// CreateTaskResult has Err set instead.
+func (t *TaskService) CreateTasks(specs []TaskSpec) []CreateTaskResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cacheFolders()()
+
+	folders := newBulkFolderCache(t)
+	defer folders.release()
+
+	results := make([]CreateTaskResult, len(specs))
+	for i, spec := range specs {
+		results[i] = t.createBulkTask(folders, spec)
+	}
+
+	return results
+}
+
+func (t *TaskService) createBulkTask(folders *bulkFolderCache, spec TaskSpec) CreateTaskResult {
+	result := CreateTaskResult{Path: spec.Path}
+
+	if spec.Path == "" || spec.Path[0] != '\\' {
+		result.Err = ErrInvalidPath
+		return result
+	} else if result.Err = validateDefinition(spec.Definition); result.Err != nil {
+		return result
+	}
+
+	folderPath, name := splitTaskPath(spec.Path)
+	folder, err := folders.get(folderPath, true)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if folder.taskNames[strings.ToLower(name)] {
+		if !spec.Overwrite {
+			res, err := t.callMethod(folder.folderObj, "GetTask", name)
+			if err != nil {
+				result.Err = fmt.Errorf("error getting registered task %s: %w", spec.Path, getTaskSchedulerPathError(err, "GetTask", spec.Path))
+				return result
+			}
+			if result.Task, _, err = parseRegisteredTask(res.ToIDispatch()); err != nil {
+				result.Err = fmt.Errorf("error parsing registered task %s: %v", spec.Path, err)
+			}
+			return result
+		}
+
+		if _, err = t.callMethod(folder.folderObj, "DeleteTask", name, 0); err != nil {
+			result.Err = fmt.Errorf("error deleting registered task %s: %w", spec.Path, getTaskSchedulerPathError(err, "DeleteTask", spec.Path))
+			return result
+		}
+		delete(folder.taskNames, strings.ToLower(name))
+	}
+
+	logonType := spec.LogonType
+	if logonType == TASK_LOGON_NONE {
+		logonType = spec.Definition.Principal.LogonType
+	}
+
+	newTaskDefObj, err := t.newDefinitionObj(spec.Definition)
+	if err != nil {
+		result.Err = fmt.Errorf("error creating registered task %s: %v", spec.Path, err)
+		return result
+	}
+	defer newTaskDefObj.Release()
+
+	newTaskObj, err := t.callMethod(folder.folderObj, "RegisterTaskDefinition", name, newTaskDefObj, int(TASK_CREATE), spec.Username, spec.Password, int(logonType), "")
+	if err != nil {
+		result.Err = fmt.Errorf("error registering task %s: %w", spec.Path, getTaskSchedulerPathError(err, "RegisterTaskDefinition", spec.Path))
+		return result
+	}
+	folder.taskNames[strings.ToLower(name)] = true
+
+	if result.Task, _, err = parseRegisteredTask(newTaskObj.ToIDispatch()); err != nil {
+		result.Err = fmt.Errorf("error parsing registered task %s: %v", spec.Path, err)
+		return result
+	}
+	result.Created = true
+
+	return result
+}
+
+// bulkFolder is a task folder used by bulk operations, along with the lowercased
+// names of the tasks inside it, as task names are case insensitive.
+type bulkFolder struct {
+	folderObj *ole.IDispatch
+	taskNames map[string]bool
+}
+
+// bulkFolderCache caches the folders used by bulk operations so each folder is
+// only looked up and enumerated once.
+type bulkFolderCache struct {
+	taskService *TaskService
+	folders     map[string]*bulkFolder
+}
+
+func newBulkFolderCache(taskService *TaskService) *bulkFolderCache {
+	return &bulkFolderCache{
+		taskService: taskService,
+		folders:     make(map[string]*bulkFolder),
+	}
+}
+
+// get returns the folder at path. If create is true, the folder is created if it
+// doesn't exist.
+func (c *bulkFolderCache) get(path string, create bool) (*bulkFolder, error) {
+	if folder, ok := c.folders[strings.ToLower(path)]; ok {
+		return folder, nil
+	}
+
+	folderObj, err := c.taskService.getFolderObj(path)
+	if errors.Is(err, ErrFolderNotFound) && create {
+		if err = c.taskService.createFolder(path, ""); err != nil {
+			return nil, err
+		}
+		folderObj, err = c.taskService.getFolderObj(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &bulkFolder{
+		folderObj: folderObj,
+		taskNames: make(map[string]bool),
+	}
+	err = c.taskService.forEachTaskInFolder(folderObj, TASK_ENUM_HIDDEN, func(task *ole.IDispatch) error {
+		defer task.Release()
+
+		name, err := oleutil.GetProperty(task, "Name")
+		if err != nil {
+			return fmt.Errorf("error getting name of registered task: %w", getTaskSchedulerError(err))
+		}
+		folder.taskNames[strings.ToLower(name.ToString())] = true
+
+		return nil
+	})
+	if err != nil {
+		folderObj.Release()
+		return nil, fmt.Errorf("error enumerating folder %s: %w", path, err)
+	}
+	c.folders[strings.ToLower(path)] = folder
+
+	return folder, nil
+}
+
+func (c *bulkFolderCache) release() {
+	for _, folder := range c.folders {
+		folder.folderObj.Release()
+	}
+}
+
+// prepareTaskPath makes sure a new task can be registered at path. The folder
+// the task will be stored in is created if it doesn't exist. If a task already
+// exists at path, it will be deleted if overwrite is true, otherwise the existing
+// task is returned along with true.
+func (t *TaskService) prepareTaskPath(path string, overwrite bool) (RegisteredTask, bool, error) {
+	var err error
+
+	nameIndex := strings.LastIndex(path, `\`)
+	folderPath := path[:nameIndex]
+
+	if !t.taskFolderExist(folderPath) {
+		if err = t.createFolder(folderPath, ""); err != nil {
+			return RegisteredTask{}, false, err
+		}
+	} else {
+		if t.registeredTaskExist(path) {
+			if !overwrite {
+				task, err := t.getRegisteredTask(path)
+				if err != nil {
+					return RegisteredTask{}, false, err
+				}
+
+				return task, true, nil
+			}
+			_, err = t.callMethod(t.rootFolderObj, "DeleteTask", path, 0)
+			if err != nil {
+				return RegisteredTask{}, false, fmt.Errorf("error deleting registered task %s: %w", path, getTaskSchedulerPathError(err, "DeleteTask", path))
+			}
+		}
+	}
+
+	return RegisteredTask{}, false, nil
+}
+
+// MoveTask moves the registered task at oldPath to newPath, which can be used
+// to rename a task or move it to a different folder. The folder of newPath is
+// created if it doesn't exist. If a task already exists at newPath, an error
+// wrapping ErrAlreadyExists is returned.
+func (t *TaskService) MoveTask(oldPath, newPath string) (RegisteredTask, error) {
+	return t.MoveTaskEx(oldPath, newPath, false)
+}
+
+// MoveTaskEx moves the registered task at oldPath to newPath, which can be used
+// to rename a task or move it to a different folder. The folder of newPath is
+// created if it doesn't exist. If a task already exists at newPath, it will be
+// replaced if overwrite is true, otherwise an error wrapping ErrAlreadyExists is
+// returned. The task is registered at newPath with its original definition,
+// principal and logon type; tasks that use TASK_LOGON_PASSWORD cannot be moved
+// as their password cannot be retrieved. If the original task can't be deleted,
+// the task registered at newPath is removed and a task it replaced is registered
+// again from its XML.
+func (t *TaskService) MoveTaskEx(oldPath, newPath string, overwrite bool) (RegisteredTask, error) {
+	if oldPath[0] != '\\' || newPath[0] != '\\' {
+		return RegisteredTask{}, ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cacheFolders()()
+
+	task, err := t.getRegisteredTask(oldPath)
+	if err != nil {
+		return RegisteredTask{}, err
+	}
+	if strings.EqualFold(oldPath, newPath) {
+		return task, nil
+	}
+	defer task.Release()
+
+	// an existing task at newPath is replaced when the task is registered rather
+	// than deleted beforehand, so that it is kept if registering the task fails
+	existingTask, exists, err := t.prepareTaskPath(newPath, false)
+	if err != nil {
+		return RegisteredTask{}, err
+	}
+	// the replaced task is exported so that it can be restored if the move
+	// has to be rolled back
+	var existingXML string
+	var existingLogonType TaskLogonType
+	if exists {
+		defer existingTask.Release()
+		if !overwrite {
+			return RegisteredTask{}, fmt.Errorf("error moving registered task %s to %s: %w", oldPath, newPath, ErrAlreadyExists)
+		}
+		if existingXML, err = existingTask.ExportXML(); err != nil {
+			return RegisteredTask{}, err
+		}
+		existingLogonType = existingTask.Definition.Principal.LogonType
+	}
+
+	definition, err := oleutil.GetProperty(task.taskObj, "Definition")
+	if err != nil {
+		return RegisteredTask{}, fmt.Errorf("error getting definition of registered task %s: %w", oldPath, getTaskSchedulerPathError(err, "Definition", oldPath))
+	}
+	definitionObj := definition.ToIDispatch()
+	defer definitionObj.Release()
+
+	logonType := task.Definition.Principal.LogonType
+	res, err := t.callMethod(t.rootFolderObj, "RegisterTaskDefinition", newPath, definitionObj, int(TASK_CREATE_OR_UPDATE), "", "", int(logonType), "")
+	if err != nil {
+		return RegisteredTask{}, fmt.Errorf("error registering task %s: %w", newPath, getTaskSchedulerPathError(err, "RegisterTaskDefinition", newPath))
+	}
+	newTaskObj := res.ToIDispatch()
+
+	_, err = t.callMethod(t.rootFolderObj, "DeleteTask", oldPath, 0)
+	if err != nil {
+		// don't leave two copies of the task behind, and put back the task
+		// that was replaced
+		newTaskObj.Release()
+		err = fmt.Errorf("error deleting registered task %s: %w", oldPath, getTaskSchedulerPathError(err, "DeleteTask", oldPath))
+		if exists {
+			res, restoreErr := t.callMethod(t.rootFolderObj, "RegisterTask", newPath, existingXML, int(TASK_CREATE_OR_UPDATE), "", "", int(existingLogonType), "")
+			if restoreErr != nil {
+				return RegisteredTask{}, errors.Join(err, fmt.Errorf("error restoring registered task %s: %w", newPath, getTaskSchedulerPathError(restoreErr, "RegisterTask", newPath)))
+			}
+			res.ToIDispatch().Release()
+		} else {
+			t.callMethod(t.rootFolderObj, "DeleteTask", newPath, 0)
+		}
+		return RegisteredTask{}, err
+	}
+
+	newTask, _, err := parseRegisteredTask(newTaskObj)
+	if err != nil {
+		return RegisteredTask{}, fmt.Errorf("error parsing registered task %s: %v", newPath, err)
+	}
+
+	return newTask, nil
+}
+
+// RenameTask renames the registered task at path to newName, keeping it in the
+// same folder. It is like calling MoveTask with the new path of the task, so the
+// task keeps its definition, principal and logon type, and the original task is
+// only deleted once the task has been registered under its new name. If a task
+// named newName already exists in the folder, an error wrapping ErrAlreadyExists
+// is returned.
+func (t *TaskService) RenameTask(path, newName string) (RegisteredTask, error) {
+	if path[0] != '\\' {
+		return RegisteredTask{}, ErrInvalidPath
+	}
+	if newName == "" || strings.Contains(newName, `\`) {
+		return RegisteredTask{}, fmt.Errorf("error renaming registered task %s: invalid task name %q", path, newName)
+	}
+
+	folderPath, _ := splitTaskPath(path)
+
+	return t.MoveTaskEx(path, joinTaskPath(folderPath, newName), false)
+}
+
+// CopyTask registers the registered task at srcPath of src at dstPath of dst,
+// which can be connected to different computers. The definition of the task,
+// including its triggers, actions and settings, is copied as is, except that its
+// principal and the working directories of its exec actions can be remapped with
+// opts. If a task already exists at dstPath, it is replaced if opts.Overwrite is
+// true, otherwise an error wrapping ErrAlreadyExists is returned.
+func CopyTask(src *TaskService, srcPath string, dst *TaskService, dstPath string, opts CopyTaskOptions) (RegisteredTask, error) {
+	task, err := src.GetRegisteredTask(srcPath)
+	if err != nil {
+		return RegisteredTask{}, err
+	}
+	def := task.Definition
+	task.Release()
+
+	if opts.Principal != nil {
+		def.Principal = *opts.Principal
+	}
+	if len(opts.WorkingDirs) > 0 {
+		actions := make([]Action, len(def.Actions))
+		for i, action := range def.Actions {
+			if execAction, ok := action.(ExecAction); ok {
+				if workingDir, ok := opts.WorkingDirs[execAction.WorkingDir]; ok {
+					execAction.WorkingDir = workingDir
+				}
+				action = execAction
+			}
+			actions[i] = action
+		}
+		def.Actions = actions
+	}
+
+	newTask, created, err := dst.CreateTaskEx(dstPath, def, opts.Username, opts.Password, def.Principal.LogonType, opts.Overwrite)
+	if err != nil {
+		return RegisteredTask{}, fmt.Errorf("error copying registered task %s to %s: %w", srcPath, dstPath, err)
+	} else if !created {
+		newTask.Release()
+		return RegisteredTask{}, fmt.Errorf("error copying registered task %s to %s: %w", srcPath, dstPath, ErrAlreadyExists)
+	}
+
+	return newTask, nil
+}
+
+// SetTaskCredentials re-registers the registered task at path with new credentials
+// and logon type, leaving the rest of the task's definition unchanged. This can be
+// used to supply a new password to a task that uses TASK_LOGON_PASSWORD. If the
+// Task Scheduler service rejects the credentials, an error wrapping ErrLogonFailure
+// or ErrAccountNotSet is returned.
+func (t *TaskService) SetTaskCredentials(path, username, password string, logonType TaskLogonType) (RegisteredTask, error) {
+	if path[0] != '\\' {
+		return RegisteredTask{}, ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		return enumTaskFolders
+	task, err := t.getRegisteredTask(path)
+	if err != nil {
+		return RegisteredTask{}, err
 	}
+	defer task.Release()
 
-	err = oleutil.ForEach(taskFolderList, initEnumTaskFolders(&topFolder))
+	// register the task's existing definition so nothing but the credentials change
+	definition, err := oleutil.GetProperty(task.taskObj, "Definition")
 	if err != nil {
-		return TaskFolder{}, err
+		return RegisteredTask{}, fmt.Errorf("error getting definition of registered task %s: %w", path, getTaskSchedulerPathError(err, "Definition", path))
 	}
+	definitionObj := definition.ToIDispatch()
+	defer definitionObj.Release()
 
-	return topFolder, nil
+	res, err := t.callMethod(t.rootFolderObj, "RegisterTaskDefinition", path, definitionObj, int(TASK_UPDATE), username, password, int(logonType), "")
+	if err != nil {
+		return RegisteredTask{}, fmt.Errorf("error setting credentials of registered task %s: %w", path, getTaskSchedulerPathError(err, "RegisterTaskDefinition", path))
+	}
+
+	newTaskObj := res.ToIDispatch()
+	newTask, _, err := parseRegisteredTask(newTaskObj)
+	if err != nil {
+		newTaskObj.Release()
+		return RegisteredTask{}, fmt.Errorf("error parsing registered task %s: %v", path, err)
+	}
+
+	return newTask, nil
 }
 
-// NewTaskDefinition returns a new task definition that can be used to register a new task.
-// Task settings and properties are set to Task Scheduler default values.
-func (t TaskService) NewTaskDefinition() Definition {
-	var newDef Definition
+// SetTaskEnabled enables or disables the registered task at path without
+// re-registering its definition, so stored credentials are preserved. If the
+// task doesn't exist, an error wrapping ErrTaskNotFound is returned.
+func (t *TaskService) SetTaskEnabled(path string, enabled bool) error {
+	if path[0] != '\\' {
+		return ErrInvalidPath
+	}
 
-	newDef.Principal.LogonType = TASK_LOGON_INTERACTIVE_TOKEN
-	newDef.Principal.RunLevel = TASK_RUNLEVEL_LUA
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	newDef.RegistrationInfo.Author = t.connectedDomain + `\` + t.connectedUser
-	newDef.RegistrationInfo.Date = time.Now()
-
-	newDef.Settings.AllowDemandStart = true
-	newDef.Settings.AllowHardTerminate = true
-	newDef.Settings.Compatibility = TASK_COMPATIBILITY_V2
-	newDef.Settings.DontStartOnBatteries = true
-	newDef.Settings.Enabled = true
-	newDef.Settings.Hidden = false
-	newDef.Settings.IdleSettings.IdleDuration = period.NewHMS(0, 10, 0) // PT10M
-	newDef.Settings.IdleSettings.WaitTimeout = period.NewHMS(1, 0, 0)   // PT1H
-	newDef.Settings.MultipleInstances = TASK_INSTANCES_IGNORE_NEW
-	newDef.Settings.Priority = 7
-	newDef.Settings.RestartCount = 0
-	newDef.Settings.RestartOnIdle = false
-	newDef.Settings.RunOnlyIfIdle = false
-	newDef.Settings.RunOnlyIfNetworkAvailable = false
-	newDef.Settings.StartWhenAvailable = false
-	newDef.Settings.StopIfGoingOnBatteries = true
-	newDef.Settings.StopOnIdleEnd = true
-	newDef.Settings.TimeLimit = period.NewHMS(72, 0, 0) // PT72H
-	newDef.Settings.WakeToRun = false
+	task, err := t.getRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
 
-	return newDef
+	return task.SetEnabled(enabled)
 }
 
-// CreateTask creates a registered task on the connected computer. CreateTask returns
-// true if the task was successfully registered, and false if the overwrite parameter
-// is false and a task at the specified path already exists.
-func (t *TaskService) CreateTask(path string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
-	return t.CreateTaskEx(path, newTaskDef, "", "", newTaskDef.Principal.LogonType, overwrite)
-}
+// SetFolderEnabled enables or disables every registered task, including hidden
+// tasks, directly inside the folder at path, and if recursive is true, in all of
+// its subfolders. Only the enabled state of each task is changed, so the tasks
+// don't need to be registered again. A task failing to be changed doesn't stop
+// the others from being changed; the outcome for each task is returned in the
+// BulkResult. An error is only returned if the tasks can't be enumerated, for
+// example because the folder doesn't exist, in which case it wraps ErrFolderNotFound.
+func (t *TaskService) SetFolderEnabled(path string, enabled, recursive bool) (BulkResult, error) {
+	if path[0] != '\\' {
+		return BulkResult{}, ErrInvalidPath
+	}
 
-// CreateTaskEx creates a registered task on the connected computer. CreateTaskEx returns
-// true if the task was successfully registered, and false if the overwrite parameter
-// is false and a task at the specified path already exists.
-func (t *TaskService) CreateTaskEx(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
-	var err error
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	if path[0] != '\\' {
-		return RegisteredTask{}, false, ErrInvalidPath
-	} else if err = validateDefinition(newTaskDef); err != nil {
-		return RegisteredTask{}, false, err
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return BulkResult{}, err
 	}
+	defer folderObj.Release()
 
-	nameIndex := strings.LastIndex(path, `\`)
-	folderPath := path[:nameIndex]
+	var result BulkResult
+	fn := func(task *ole.IDispatch) error {
+		defer task.Release()
 
-	if !t.taskFolderExist(folderPath) {
-		_, err = oleutil.CallMethod(t.rootFolderObj, "CreateFolder", folderPath, "")
+		pathVar, err := oleutil.GetProperty(task, "Path")
 		if err != nil {
-			return RegisteredTask{}, false, fmt.Errorf("error creating folder %s: %v", path, getTaskSchedulerError(err))
+			return fmt.Errorf("error getting path of registered task: %w", getTaskSchedulerError(err))
 		}
-	} else {
-		if t.registeredTaskExist(path) {
-			if !overwrite {
-				task, err := t.GetRegisteredTask(path)
-				if err != nil {
-					return RegisteredTask{}, false, err
-				}
+		taskResult := BulkTaskResult{Path: pathVar.ToString()}
 
-				return task, false, nil
-			}
-			_, err = oleutil.CallMethod(t.rootFolderObj, "DeleteTask", path, 0)
-			if err != nil {
-				return RegisteredTask{}, false, fmt.Errorf("error deleting registered task %s: %v", path, getTaskSchedulerError(err))
+		enabledVar, err := oleutil.GetProperty(task, "Enabled")
+		if err != nil {
+			taskResult.Err = fmt.Errorf("error getting enabled state of registered task %s: %w", taskResult.Path, getTaskSchedulerPathError(err, "Enabled", taskResult.Path))
+		} else if enabledVar.Value().(bool) != enabled {
+			if _, err := oleutil.PutProperty(task, "Enabled", enabled); err != nil {
+				taskResult.Err = fmt.Errorf("error setting enabled state of registered task %s: %w", taskResult.Path, getTaskSchedulerPathError(err, "Enabled", taskResult.Path))
+			} else {
+				taskResult.Changed = true
 			}
 		}
+		result.Tasks = append(result.Tasks, taskResult)
+
+		return nil
 	}
 
-	newTaskObj, err := t.modifyTask(path, newTaskDef, username, password, logonType, TASK_CREATE)
+	if recursive {
+		err = t.walkRegisteredTasks(folderObj, TASK_ENUM_HIDDEN, fn)
+	} else {
+		err = t.forEachTaskInFolder(folderObj, TASK_ENUM_HIDDEN, fn)
+	}
 	if err != nil {
-		return RegisteredTask{}, false, fmt.Errorf("error creating registered task %s: %v", path, err)
+		return result, err
 	}
 
-	newTask, _, err := parseRegisteredTask(newTaskObj)
+	return result, nil
+}
+
+// RunAndWait runs the registered task at path with args, waits for the instance that
+// was started to complete, and returns the exit code of the instance. See
+// RegisteredTask.RunAndWait for details.
+func (t *TaskService) RunAndWait(ctx context.Context, path string, args []string) (int, error) {
+	task, err := t.GetRegisteredTask(path)
 	if err != nil {
-		return RegisteredTask{}, false, fmt.Errorf("error parsing registered task %s: %v", path, err)
+		return 0, err
 	}
+	defer task.Release()
 
-	return newTask, true, nil
+	return task.RunAndWait(ctx, args)
+}
+
+// WaitForAllInstancesToExit blocks until no instance of the registered task at
+// path is running or ctx is done. See RegisteredTask.WaitForAllInstancesToExit
+// for details.
+func (t *TaskService) WaitForAllInstancesToExit(ctx context.Context, path string) error {
+	task, err := t.GetRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
+
+	return task.WaitForAllInstancesToExit(ctx)
+}
+
+// RunTask starts an instance of the registered task at path, passing args to its
+// actions, without waiting for it to complete. See RegisteredTask.Run for details.
+func (t *TaskService) RunTask(path string, args []string) error {
+	task, err := t.GetRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
+
+	runningTask, err := task.Run(args...)
+	if err != nil {
+		return err
+	}
+	runningTask.Release()
+
+	return nil
 }
 
-// UpdateTask updates a registered task.
+// StopTask stops every running instance of the registered task at path.
+func (t *TaskService) StopTask(path string) error {
+	task, err := t.GetRegisteredTask(path)
+	if err != nil {
+		return err
+	}
+	defer task.Release()
+
+	return task.Stop()
+}
+
+// UpdateTask updates a registered task. If the task doesn't exist, an error
+// wrapping ErrTaskNotFound is returned.
 func (t *TaskService) UpdateTask(path string, newTaskDef Definition) (RegisteredTask, error) {
 	return t.UpdateTaskEx(path, newTaskDef, "", "", newTaskDef.Principal.LogonType)
 }
 
-// UpdateTaskEx updates a registered task.
+// UpdateTaskEx updates a registered task. If the task doesn't exist, an error
+// wrapping ErrTaskNotFound is returned.
 func (t *TaskService) UpdateTaskEx(path string, newTaskDef Definition, username, password string, logonType TaskLogonType) (RegisteredTask, error) {
 	var err error
 
@@ -493,8 +2072,14 @@ func (t *TaskService) UpdateTaskEx(path string, newTaskDef Definition, username,
 		return RegisteredTask{}, err
 	}
 
-	newTaskObj, err := t.modifyTask(path, newTaskDef, username, password, logonType, TASK_UPDATE)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	newTaskObj, err := t.modifyTask(path, newTaskDef, username, password, logonType, "", TASK_UPDATE)
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return RegisteredTask{}, fmt.Errorf("error updating %s task: %w", path, ErrTaskNotFound)
+		}
 		return RegisteredTask{}, fmt.Errorf("error updating %s task: %v", path, err)
 	}
 
@@ -507,30 +2092,217 @@ func (t *TaskService) UpdateTaskEx(path string, newTaskDef Definition, username,
 	return newTask, nil
 }
 
-func (t *TaskService) modifyTask(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, flags TaskCreationFlags) (*ole.IDispatch, error) {
-	// set default UserID if UserID and GroupID both aren't set
-	if newTaskDef.Principal.UserID == "" && newTaskDef.Principal.GroupID == "" {
-		newTaskDef.Principal.UserID = t.connectedDomain + `\` + t.connectedUser
+func (t *TaskService) modifyTask(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, sddl string, flags TaskCreationFlags) (*ole.IDispatch, error) {
+	newTaskDefObj, err := t.newDefinitionObj(newTaskDef)
+	if err != nil {
+		return nil, err
+	}
+	defer newTaskDefObj.Release()
+
+	newTaskObj, err := t.callMethod(t.rootFolderObj, "RegisterTaskDefinition", path, newTaskDefObj, int(flags), username, password, int(logonType), sddl)
+	if err != nil {
+		return nil, fmt.Errorf("error registering task: %w", getTaskSchedulerError(err))
 	}
 
-	res, err := oleutil.CallMethod(t.taskServiceObj, "NewTask", 0)
+	return newTaskObj.ToIDispatch(), nil
+}
+
+// newDefinitionObj creates an ITaskDefinition COM object and fills it with the
+// task definition. The returned object must be released.
+func (t *TaskService) newDefinitionObj(newTaskDef Definition) (*ole.IDispatch, error) {
+	newTaskDef = t.withConnectedUser(newTaskDef)
+
+	res, err := t.callMethod(t.taskServiceObj, "NewTask", 0)
 	if err != nil {
-		return nil, fmt.Errorf("error creating new task: %v", getTaskSchedulerError(err))
+		return nil, fmt.Errorf("error creating new task: %w", getTaskSchedulerError(err))
 	}
 	newTaskDefObj := res.ToIDispatch()
-	defer newTaskDefObj.Release()
 
 	err = fillDefinitionObj(newTaskDef, newTaskDefObj)
 	if err != nil {
+		newTaskDefObj.Release()
 		return nil, fmt.Errorf("error filling ITaskDefinition: %v", err)
 	}
 
-	newTaskObj, err := oleutil.CallMethod(t.rootFolderObj, "RegisterTaskDefinition", path, newTaskDefObj, int(flags), username, password, int(logonType), "")
+	return newTaskDefObj, nil
+}
+
+// ValidateTaskDefinition checks that the Task Scheduler service would accept
+// the task definition, without registering a task.
+func (t *TaskService) ValidateTaskDefinition(def Definition) error {
+	if err := validateDefinition(def); err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	defObj, err := t.newDefinitionObj(def)
 	if err != nil {
-		return nil, fmt.Errorf("error registering task: %v", getTaskSchedulerError(err))
+		return err
 	}
+	defer defObj.Release()
 
-	return newTaskObj.ToIDispatch(), nil
+	// a nil path makes the Task Scheduler service generate a name for the task
+	res, err := t.callMethod(t.rootFolderObj, "RegisterTaskDefinition", nil, defObj, int(TASK_VALIDATE_ONLY), "", "", int(def.Principal.LogonType), "")
+	if err != nil {
+		return fmt.Errorf("error validating task definition: %w", getTaskSchedulerError(err))
+	}
+	res.Clear()
+
+	return nil
+}
+
+// PreviewTask returns the XML of the task that would be registered if def was
+// passed to CreateTask, without registering anything. Unlike Definition.XML, the
+// definition is validated first and the connected user is filled in if def has
+// neither a user nor a group, just like when a task is registered.
+func (t *TaskService) PreviewTask(def Definition) (string, error) {
+	if err := validateDefinition(def); err != nil {
+		return "", err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.definitionXML(t.withConnectedUser(def))
+}
+
+// withConnectedUser returns def with the connected user as its principal if def
+// has neither a user nor a group.
+func (t *TaskService) withConnectedUser(def Definition) Definition {
+	if def.Principal.UserID == "" && def.Principal.GroupID == "" {
+		def.Principal.UserID = t.connectedDomain + `\` + t.connectedUser
+	}
+
+	return def
+}
+
+// definitionXML returns the XML representation of a task definition, without
+// registering the task.
+func (t *TaskService) definitionXML(def Definition) (string, error) {
+	res, err := t.callMethod(t.taskServiceObj, "NewTask", 0)
+	if err != nil {
+		return "", fmt.Errorf("error creating new task: %w", getTaskSchedulerError(err))
+	}
+	defObj := res.ToIDispatch()
+	defer defObj.Release()
+
+	err = fillDefinitionObj(def, defObj)
+	if err != nil {
+		return "", fmt.Errorf("error filling ITaskDefinition: %v", err)
+	}
+
+	xmlText, err := oleutil.GetProperty(defObj, "XmlText")
+	if err != nil {
+		return "", fmt.Errorf("error getting XML of task definition: %w", getTaskSchedulerError(err))
+	}
+
+	return xmlText.ToString(), nil
+}
+
+// CreateFolder creates a task folder at path, along with any of its parent folders
+// that don't already exist. The security descriptor sddl is applied to the folder
+// at path only; if sddl is empty, the folder inherits the security descriptor of
+// its parent. Creating a folder that already exists is not an error.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-createfolder
+func (t *TaskService) CreateFolder(path, sddl string) error {
+	if path[0] != '\\' {
+		return ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.createFolder(path, sddl)
+}
+
+// createFolder creates the folder at path and its parent folders that don't
+// exist, as the Task Scheduler service only creates the last folder of a path,
+// and applies sddl to the folder at path. The created folders are cached if an
+// operation is in progress.
+// t.mu must be held.
+func (t *TaskService) createFolder(path, sddl string) error {
+	components := strings.Split(strings.Trim(path, `\`), `\`)
+	var folderPath string
+	for i, component := range components {
+		if component == "" {
+			continue
+		}
+		folderPath += `\` + component
+
+		var folderSDDL string
+		if i == len(components)-1 {
+			folderSDDL = sddl
+		}
+
+		res, err := t.callMethod(t.rootFolderObj, "CreateFolder", folderPath, folderSDDL)
+		if err != nil {
+			if isAlreadyExistsError(err) {
+				continue
+			}
+			return fmt.Errorf("error creating folder %s: %w", folderPath, getTaskSchedulerPathError(err, "CreateFolder", folderPath))
+		}
+		folderObj := res.ToIDispatch()
+		t.folders.put(folderPath, folderObj)
+		folderObj.Release()
+	}
+
+	return nil
+}
+
+// GetFolderSecurityDescriptor returns the security descriptor of the task folder at
+// path in the Security Descriptor Definition Language (SDDL). The info parameter
+// specifies which parts of the security descriptor are returned. If the folder
+// doesn't exist, an error wrapping ErrFolderNotFound is returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-getsecuritydescriptor
+func (t *TaskService) GetFolderSecurityDescriptor(path string, info SecurityInformation) (string, error) {
+	if path[0] != '\\' {
+		return "", ErrInvalidPath
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return "", err
+	}
+	defer folderObj.Release()
+
+	sddl, err := t.callMethod(folderObj, "GetSecurityDescriptor", int(info))
+	if err != nil {
+		return "", fmt.Errorf("error getting security descriptor of folder %s: %w", path, getTaskSchedulerPathError(err, "GetSecurityDescriptor", path))
+	}
+
+	return sddl.ToString(), nil
+}
+
+// SetFolderSecurityDescriptor sets the security descriptor of the task folder at path
+// to sddl, which must be in the Security Descriptor Definition Language (SDDL). The only
+// flag that flags can contain is TASK_DONT_ADD_PRINCIPAL_ACE. If the folder doesn't
+// exist, an error wrapping ErrFolderNotFound is returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-setsecuritydescriptor
+func (t *TaskService) SetFolderSecurityDescriptor(path, sddl string, flags TaskCreationFlags) error {
+	if path[0] != '\\' {
+		return ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return err
+	}
+	defer folderObj.Release()
+
+	_, err = t.callMethod(folderObj, "SetSecurityDescriptor", sddl, int(flags))
+	if err != nil {
+		return fmt.Errorf("error setting security descriptor of folder %s: %w", path, getTaskSchedulerPathError(err, "SetSecurityDescriptor", path))
+	}
+
+	return nil
 }
 
 // DeleteFolder removes a task folder from the connected computer. If the deleteRecursively parameter
@@ -543,16 +2315,20 @@ func (t *TaskService) DeleteFolder(path string, deleteRecursively bool) (bool, e
 		return false, ErrInvalidPath
 	}
 
-	taskFolder, err := oleutil.CallMethod(t.taskServiceObj, "GetFolder", path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.folders.invalidate(path)
+	taskFolder, err := t.callMethod(t.taskServiceObj, "GetFolder", path)
 	if err != nil {
-		return false, fmt.Errorf("error getting folder: %v", getTaskSchedulerError(err))
+		return false, fmt.Errorf("error getting folder: %w", getTaskSchedulerError(err))
 	}
 
 	taskFolderObj := taskFolder.ToIDispatch()
 	defer taskFolderObj.Release()
-	res, err := oleutil.CallMethod(taskFolderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
+	res, err := t.callMethod(taskFolderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
 	if err != nil {
-		return false, fmt.Errorf("error getting tasks of folder: %v", getTaskSchedulerError(err))
+		return false, fmt.Errorf("error getting tasks of folder: %w", getTaskSchedulerError(err))
 	}
 	taskCollection := res.ToIDispatch()
 	defer taskCollection.Release()
@@ -560,9 +2336,9 @@ func (t *TaskService) DeleteFolder(path string, deleteRecursively bool) (bool, e
 		return false, nil
 	}
 
-	res, err = oleutil.CallMethod(taskFolderObj, "GetFolders", int(TASK_ENUM_HIDDEN))
+	res, err = t.callMethod(taskFolderObj, "GetFolders", int(TASK_ENUM_HIDDEN))
 	if err != nil {
-		return false, fmt.Errorf("error getting the subfolders: %v", getTaskSchedulerError(err))
+		return false, fmt.Errorf("error getting the subfolders: %w", getTaskSchedulerError(err))
 	}
 	folderCollection := res.ToIDispatch()
 	defer folderCollection.Release()
@@ -578,9 +2354,9 @@ func (t *TaskService) DeleteFolder(path string, deleteRecursively bool) (bool, e
 
 			name := oleutil.MustGetProperty(taskObj, "Path").ToString()
 
-			return t.DeleteTask(name)
+			return t.deleteTask(name)
 		}
-		err = oleutil.ForEach(taskCollection, deleteAllTasks)
+		err = t.forEach(taskCollection, deleteAllTasks)
 		if err != nil {
 			return false, err
 		}
@@ -592,85 +2368,278 @@ func (t *TaskService) DeleteFolder(path string, deleteRecursively bool) (bool, e
 			folderObj := v.ToIDispatch()
 			defer folderObj.Release()
 
-			res, err := oleutil.CallMethod(folderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
+			res, err := t.callMethod(folderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
 			if err != nil {
-				return fmt.Errorf("error getting tasks of folder: %v", getTaskSchedulerError(err))
+				return fmt.Errorf("error getting tasks of folder: %w", getTaskSchedulerError(err))
 			}
 			tasks := res.ToIDispatch()
 			defer tasks.Release()
 
-			err = oleutil.ForEach(tasks, deleteAllTasks)
+			err = t.forEach(tasks, deleteAllTasks)
 			if err != nil {
 				return err
 			}
 
-			res, err = oleutil.CallMethod(folderObj, "GetFolders", int(TASK_ENUM_HIDDEN))
+			res, err = t.callMethod(folderObj, "GetFolders", int(TASK_ENUM_HIDDEN))
 			if err != nil {
-				return fmt.Errorf("error getting subfolders: %v", getTaskSchedulerError(err))
+				return fmt.Errorf("error getting subfolders: %w", getTaskSchedulerError(err))
 			}
 			subFolders := res.ToIDispatch()
 			defer subFolders.Release()
 
-			err = oleutil.ForEach(subFolders, deleteTasksRecursively)
+			err = t.forEach(subFolders, deleteTasksRecursively)
 			if err != nil {
 				return err
 			}
 
 			currentFolderPath := oleutil.MustGetProperty(folderObj, "Path").ToString()
-			_, err = oleutil.CallMethod(t.rootFolderObj, "DeleteFolder", currentFolderPath, 0)
+			_, err = t.callMethod(t.rootFolderObj, "DeleteFolder", currentFolderPath, 0)
 			if err != nil {
-				return fmt.Errorf("error deleting task folder %s: %v", path, getTaskSchedulerError(err))
+				return fmt.Errorf("error deleting task folder %s: %w", path, getTaskSchedulerPathError(err, "DeleteFolder", path))
 			}
 
 			return nil
 		}
 
 		// delete all subfolders and tasks recursively
-		err = oleutil.ForEach(folderCollection, deleteTasksRecursively)
+		err = t.forEach(folderCollection, deleteTasksRecursively)
 		if err != nil {
 			return false, err
 		}
 	}
 
 	// delete parent folder
-	_, err = oleutil.CallMethod(t.rootFolderObj, "DeleteFolder", path, 0)
+	_, err = t.callMethod(t.rootFolderObj, "DeleteFolder", path, 0)
 	if err != nil {
-		return false, fmt.Errorf("error deleting task folder %s: %v", path, getTaskSchedulerError(err))
+		return false, fmt.Errorf("error deleting task folder %s: %w", path, getTaskSchedulerPathError(err, "DeleteFolder", path))
 	}
 
 	return true, nil
 }
 
-// DeleteTask removes a registered task from the connected computer.
-func (t *TaskService) DeleteTask(path string) error {
-	var err error
+// DeleteEmptyFolders removes every folder under rootPath, including rootPath itself,
+// that contains no registered tasks and no subfolders that aren't empty, and returns
+// the paths of the removed folders, deepest first. The root folder is never removed.
+// Folders that stop being empty while DeleteEmptyFolders is running are skipped.
+func (t *TaskService) DeleteEmptyFolders(rootPath string) ([]string, error) {
+	if rootPath[0] != '\\' {
+		return nil, ErrInvalidPath
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var removed []string
+	_, err := t.deleteEmptyFolders(rootPath, &removed)
+
+	return removed, err
+}
+
+// deleteEmptyFolders recursively removes the empty folders under path, and path
+// itself if it's empty. It returns true if path was removed.
+func (t *TaskService) deleteEmptyFolders(path string, removed *[]string) (bool, error) {
+	folderObj, err := t.getFolderObj(path)
+	if err != nil {
+		return false, err
+	}
+	defer folderObj.Release()
+
+	res, err := t.callMethod(folderObj, "GetTasks", int(TASK_ENUM_HIDDEN))
+	if err != nil {
+		return false, fmt.Errorf("error getting tasks of folder %s: %w", path, getTaskSchedulerPathError(err, "GetTasks", path))
+	}
+	taskCollection := res.ToIDispatch()
+	isEmpty := oleutil.MustGetProperty(taskCollection, "Count").Val == 0
+	taskCollection.Release()
+
+	res, err = t.callMethod(folderObj, "GetFolders", 0)
+	if err != nil {
+		return false, fmt.Errorf("error getting subfolders of folder %s: %w", path, getTaskSchedulerPathError(err, "GetFolders", path))
+	}
+	folderCollection := res.ToIDispatch()
+	defer folderCollection.Release()
+
+	// get the paths of the subfolders first so the collection isn't modified
+	// while it's being enumerated
+	var subFolderPaths []string
+	err = t.forEach(folderCollection, func(v *ole.VARIANT) error {
+		subFolder := v.ToIDispatch()
+		defer subFolder.Release()
+
+		subFolderPaths = append(subFolderPaths, oleutil.MustGetProperty(subFolder, "Path").ToString())
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, subFolderPath := range subFolderPaths {
+		subFolderRemoved, err := t.deleteEmptyFolders(subFolderPath, removed)
+		if err != nil {
+			return false, err
+		}
+		if !subFolderRemoved {
+			isEmpty = false
+		}
+	}
+
+	if !isEmpty || path == `\` {
+		return false, nil
+	}
+
+	t.folders.invalidate(path)
+	_, err = t.callMethod(t.rootFolderObj, "DeleteFolder", path, 0)
+	if err != nil {
+		if isFolderNotEmptyError(err) {
+			// a task or folder was created in the folder since it was enumerated
+			return false, nil
+		}
+		return false, fmt.Errorf("error deleting task folder %s: %w", path, getTaskSchedulerPathError(err, "DeleteFolder", path))
+	}
+	*removed = append(*removed, path)
+
+	return true, nil
+}
 
+// DeleteTask removes a registered task from the connected computer. If the task
+// doesn't exist, an error wrapping ErrTaskNotFound is returned.
+func (t *TaskService) DeleteTask(path string) error {
 	if path[0] != '\\' {
 		return ErrInvalidPath
 	}
 
-	_, err = oleutil.CallMethod(t.rootFolderObj, "DeleteTask", path, 0)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.deleteTask(path)
+}
+
+// DeleteTasksMatching deletes every registered task whose full path matches
+// pattern, using the syntax of filepath.Match, and returns the paths of the
+// deleted tasks. If some tasks could not be deleted, the remaining matching
+// tasks are still deleted and an error combining every failure is returned
+// along with the paths of the tasks that were deleted.
+func (t *TaskService) DeleteTasksMatching(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var paths []string
+	err := t.walkRegisteredTasks(t.rootFolderObj, TASK_ENUM_HIDDEN, func(task *ole.IDispatch) error {
+		defer task.Release()
+
+		pathVar, err := oleutil.GetProperty(task, "Path")
+		if err != nil {
+			return fmt.Errorf("error getting path of registered task: %w", getTaskSchedulerError(err))
+		}
+		path := pathVar.ToString()
+		if matched, _ := filepath.Match(pattern, path); matched {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// tasks are deleted after enumerating so the collections being
+	// enumerated aren't modified
+	var deleted []string
+	var errs []error
+	for _, path := range paths {
+		if err := t.deleteTask(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, path)
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// DeleteTasks deletes the registered tasks at paths, and returns the error that
+// prevented each task from being deleted, or nil if it was deleted, in the same
+// order as paths. The folder of each task is only looked up once. If a task
+// doesn't exist, its error wraps ErrTaskNotFound.
+func (t *TaskService) DeleteTasks(paths []string) []error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cacheFolders()()
+
+	folders := newBulkFolderCache(t)
+	defer folders.release()
+
+	errs := make([]error, len(paths))
+	for i, path := range paths {
+		if path == "" || path[0] != '\\' {
+			errs[i] = ErrInvalidPath
+			continue
+		}
+
+		folderPath, name := splitTaskPath(path)
+		folder, err := folders.get(folderPath, false)
+		if errors.Is(err, ErrFolderNotFound) {
+			errs[i] = fmt.Errorf("error deleting task %s: %w", path, ErrTaskNotFound)
+			continue
+		} else if err != nil {
+			errs[i] = err
+			continue
+		}
+		if !folder.taskNames[strings.ToLower(name)] {
+			errs[i] = fmt.Errorf("error deleting task %s: %w", path, ErrTaskNotFound)
+			continue
+		}
+
+		if _, err = t.callMethod(folder.folderObj, "DeleteTask", name, 0); err != nil {
+			errs[i] = fmt.Errorf("error deleting task %s: %w", path, getTaskSchedulerPathError(err, "DeleteTask", path))
+			continue
+		}
+		delete(folder.taskNames, strings.ToLower(name))
+	}
+
+	return errs
+}
+
+func (t *TaskService) deleteTask(path string) error {
+	_, err := t.callMethod(t.rootFolderObj, "DeleteTask", path, 0)
 	if err != nil {
-		return fmt.Errorf("error deleting task %s: %v", path, getTaskSchedulerError(err))
+		if isNotFoundError(err) {
+			return fmt.Errorf("error deleting task %s: %w", path, ErrTaskNotFound)
+		}
+		return fmt.Errorf("error deleting task %s: %w", path, getTaskSchedulerPathError(err, "DeleteTask", path))
 	}
 
 	return nil
 }
 
 func (t *TaskService) registeredTaskExist(path string) bool {
-	_, err := oleutil.CallMethod(t.rootFolderObj, "GetTask", path)
+	folderPath, name := splitTaskPath(path)
+	folderObj, err := t.getFolderObj(folderPath)
+	if err != nil {
+		return false
+	}
+	defer folderObj.Release()
+
+	task, err := t.callMethod(folderObj, "GetTask", name)
 	if err != nil {
 		return false
 	}
+	task.ToIDispatch().Release()
 
 	return true
 }
 
 func (t *TaskService) taskFolderExist(path string) bool {
-	_, err := oleutil.CallMethod(t.taskServiceObj, "GetFolder", path)
+	folderObj, err := t.getFolderObj(path)
 	if err != nil {
 		return false
 	}
+	folderObj.Release()
 
 	return true
 }
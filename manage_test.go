@@ -3,9 +3,23 @@
 package taskmaster
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/rickb777/date/period"
 )
 
 func TestLocalConnect(t *testing.T) {
@@ -13,7 +27,27 @@ func TestLocalConnect(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// every supported version of Windows has at least Task Scheduler 2.0
+	if version := taskService.GetHighestVersion(); version.Compatibility() < TASK_COMPATIBILITY_V2 {
+		t.Fatalf("unexpected highest version %s (%s)", version, version.WindowsVersion())
+	}
+	taskService.Disconnect()
+
+	// disconnecting a second time should be a no-op
 	taskService.Disconnect()
+	if taskService.IsConnected() {
+		t.Fatal("task service should not be connected")
+	}
+}
+
+func TestTrimUsernameDomain(t *testing.T) {
+	if username := trimUsernameDomain(`DOMAIN\user`); username != "user" {
+		t.Fatalf("expected user, got %s", username)
+	}
+	// some accounts have no domain prefix
+	if username := trimUsernameDomain("SYSTEM"); username != "SYSTEM" {
+		t.Fatalf("expected SYSTEM, got %s", username)
+	}
 }
 
 func TestCreateTask(t *testing.T) {
@@ -226,6 +260,83 @@ func TestGetRegisteredTasks(t *testing.T) {
 	rtc.Release()
 }
 
+func TestGetRegisteredTasksWithXML(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	rtc, err := taskService.GetRegisteredTasksWithXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+
+	for _, task := range rtc {
+		if task.RawXML == "" {
+			t.Errorf("registered task %s has no XML", task.Path)
+		}
+	}
+}
+
+func TestGetRegisteredTaskWithXML(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	task, xml, err := taskService.GetRegisteredTaskWithXML("\\Taskmaster\\TestTask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	if xml == "" || xml != task.RawXML {
+		t.Fatalf("expected the XML of the registered task to be returned and set as RawXML")
+	}
+
+	_, _, err = taskService.GetRegisteredTaskWithXML("\\Taskmaster\\DoesNotExist")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestGetTasksInFolder(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	rtc, err := taskService.GetTasksInFolder("\\Taskmaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+
+	var found bool
+	for _, task := range rtc {
+		if task.Path == "\\Taskmaster\\TestTask" {
+			found = true
+		} else if strings.Count(task.Path, "\\") != 2 {
+			t.Errorf("task %s isn't directly inside the folder", task.Path)
+		}
+	}
+	if !found {
+		t.Error("test task wasn't returned")
+	}
+
+	_, err = taskService.GetTasksInFolder("\\Taskmaster\\DoesNotExist")
+	if !errors.Is(err, ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+}
+
 func TestGetTaskFolders(t *testing.T) {
 	taskService, err := Connect()
 	if err != nil {
@@ -254,8 +365,8 @@ func TestDeleteTask(t *testing.T) {
 	}
 
 	deletedTask, err := taskService.GetRegisteredTask("\\Taskmaster\\TestTask")
-	if err == nil {
-		t.Fatal("task shouldn't still exist")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
 	}
 	deletedTask.Release()
 }
@@ -290,8 +401,8 @@ func TestDeleteFolder(t *testing.T) {
 		t.Fatal(err)
 	}
 	taskmasterFolder, err := taskService.GetTaskFolder("\\Taskmaster")
-	if err == nil {
-		t.Fatal("folder shouldn't exist")
+	if !errors.Is(err, ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
 	}
 	if taskmasterFolder.Name != "" {
 		t.Error("folder struct should be defaultly constructed")
@@ -302,3 +413,2000 @@ func TestDeleteFolder(t *testing.T) {
 		}
 	}
 }
+
+func TestWatchStateChanges(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	events, err := taskService.WatchStateChanges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runningTask, err := testTask.Run("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runningTask.Release()
+
+	var started, stopped bool
+	for event := range events {
+		if event.Path != testTask.Path {
+			continue
+		}
+		if event.State == TASK_STATE_RUNNING {
+			started = true
+		} else {
+			stopped = true
+			break
+		}
+	}
+	if !started {
+		t.Error("task start wasn't reported")
+	}
+	if !stopped {
+		t.Error("task stop wasn't reported")
+	}
+}
+
+func TestWatchDisconnect(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := taskService.Watch(ctx, WatchOptions{PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskService.Disconnect()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the channel should be closed once the TaskService is disconnected")
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	events, err := taskService.Watch(ctx, WatchOptions{
+		Folder:       "\\Taskmaster\\",
+		PollInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForEvent := func(eventType WatchEventType, path string) {
+		for event := range events {
+			if event.Type == eventType && event.Path == path {
+				return
+			}
+		}
+		t.Fatalf("%s event for %s wasn't reported", eventType, path)
+	}
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+		Args: "/c timeout 1",
+	})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\WatchedTask", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+	waitForEvent(WATCH_TASK_REGISTERED, task.Path)
+
+	runningTask, err := task.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runningTask.Release()
+	waitForEvent(WATCH_TASK_STARTED, task.Path)
+	waitForEvent(WATCH_TASK_COMPLETED, task.Path)
+
+	if err = taskService.DeleteTask(task.Path); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent(WATCH_TASK_DELETED, task.Path)
+
+	if _, err = taskService.Watch(ctx, WatchOptions{Folder: "Taskmaster"}); !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestEnsureTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Ensure", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+		Args: "/c timeout $(Arg0)",
+	})
+	def.AddTrigger(mustTrigger(NewDailyTrigger(time.Now().Add(time.Hour), EveryDay)))
+
+	changed, err := taskService.EnsureTask("\\Taskmaster\\Ensure\\Task", def, EnsureTaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("task should have been registered")
+	}
+
+	changed, err = taskService.EnsureTask("\\Taskmaster\\Ensure\\Task", def, EnsureTaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		task, _ := taskService.GetRegisteredTask("\\Taskmaster\\Ensure\\Task")
+		defer task.Release()
+		t.Fatalf("task should not have been updated, changes: %v", DiffDefinitions(task.Definition, withRegisteredDefaults(def, task.Definition)))
+	}
+
+	def.Settings.Priority = 4
+	changed, err = taskService.EnsureTask("\\Taskmaster\\Ensure\\Task", def, EnsureTaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("task should have been updated")
+	}
+}
+
+func TestEnsureTaskAcrossDays(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\EnsureDaily", true)
+
+	def, err := NewTaskBuilder().Exec("cmd.exe", "/c", "exit").DailyAt(3, 0).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = taskService.EnsureTask("\\Taskmaster\\EnsureDaily\\Task", def, EnsureTaskOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same definition built on the next day starts a day later
+	trigger := def.Triggers[0].(DailyTrigger)
+	trigger.StartBoundary = trigger.StartBoundary.AddDate(0, 0, 1)
+	def.Triggers[0] = trigger
+	changed, err := taskService.EnsureTask("\\Taskmaster\\EnsureDaily\\Task", def, EnsureTaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Fatal("task should not have been updated when its trigger starts on a later day")
+	}
+}
+
+func TestCreateTaskFromXML(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	taskXML, err := testTask.ExportXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, created, err := taskService.CreateTaskFromXML("\\Taskmaster\\XMLTask", taskXML, TASK_LOGON_INTERACTIVE_TOKEN, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("task should have been created")
+	}
+	task.Release()
+
+	_, created, err = taskService.CreateTaskFromXML("\\Taskmaster\\XMLTask", taskXML, TASK_LOGON_INTERACTIVE_TOKEN, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("task shouldn't have been created")
+	}
+
+	_, _, err = taskService.CreateTaskFromXML("\\Taskmaster\\BadXMLTask", "<Task>", TASK_LOGON_INTERACTIVE_TOKEN, true)
+	if !errors.Is(err, ErrMalformedXML) {
+		t.Fatalf("expected ErrMalformedXML, got %v", err)
+	}
+}
+
+func TestCreateTaskWithSDDL(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+
+	// only allow Administrators and SYSTEM full access
+	sddl := "D:P(A;;FA;;;BA)(A;;FA;;;SY)"
+	task, _, err := taskService.CreateTaskWithSDDL("\\Taskmaster\\SDDLTask", def, "", "", def.Principal.LogonType, sddl, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	appliedSDDL, err := task.GetSecurityDescriptor(DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(appliedSDDL, "(A;;FA;;;BA)") {
+		t.Errorf("security descriptor wasn't applied, got %s", appliedSDDL)
+	}
+
+	// also allow Authenticated Users to read the task
+	sddl = "D:P(A;;FA;;;BA)(A;;FA;;;SY)(A;;FR;;;AU)"
+	if err = task.SetSecurityDescriptor(sddl, TASK_DONT_ADD_PRINCIPAL_ACE); err != nil {
+		t.Fatal(err)
+	}
+	appliedSDDL, err = task.GetSecurityDescriptor(DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(appliedSDDL, ";;;AU)") {
+		t.Errorf("security descriptor wasn't updated, got %s", appliedSDDL)
+	}
+}
+
+func TestMoveTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	movedTask, err := taskService.MoveTaskEx("\\Taskmaster\\TestTask", "\\Taskmaster\\Moved\\TestTask", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer movedTask.Release()
+	if movedTask.Path != "\\Taskmaster\\Moved\\TestTask" {
+		t.Errorf("task was moved to %s", movedTask.Path)
+	}
+
+	_, err = taskService.GetRegisteredTask("\\Taskmaster\\TestTask")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+
+	createTestTask(taskService)
+	_, err = taskService.MoveTask("\\Taskmaster\\TestTask", "\\Taskmaster\\Moved\\TestTask")
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	renamedTask, err := taskService.RenameTask("\\Taskmaster\\Moved\\TestTask", "Renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renamedTask.Release()
+	if renamedTask.Path != "\\Taskmaster\\Moved\\Renamed" {
+		t.Errorf("task was renamed to %s", renamedTask.Path)
+	}
+	if _, err = taskService.RenameTask("\\Taskmaster\\Moved\\Renamed", "Sub\\Renamed"); err == nil {
+		t.Fatal("renaming a task to a name containing a backslash should have failed")
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			task, err := taskService.GetRegisteredTask("\\Taskmaster\\TestTask")
+			if err != nil {
+				errs <- err
+				return
+			}
+			_, err = taskService.UpdateTask(task.Path, task.Definition)
+			task.Release()
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestGetRegisteredTasksMatching(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	rtc, err := taskService.GetRegisteredTasksMatching(`\Taskmaster\Test*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+
+	if len(rtc) == 0 {
+		t.Fatal("test task wasn't returned")
+	}
+	for _, task := range rtc {
+		if !strings.HasPrefix(task.Path, `\Taskmaster\Test`) {
+			t.Errorf("task %s doesn't match the pattern", task.Path)
+		}
+	}
+
+	_, err = taskService.GetRegisteredTasksMatching(`\Taskmaster\[`)
+	if err != filepath.ErrBadPattern {
+		t.Fatalf("expected filepath.ErrBadPattern, got %v", err)
+	}
+}
+
+func TestValidateTaskDefinition(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	err = taskService.ValidateTaskDefinition(def)
+	if err != ErrNoActions {
+		t.Fatalf("expected ErrNoActions, got %v", err)
+	}
+
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	err = taskService.ValidateTaskDefinition(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a repetition interval longer than the repetition duration is invalid
+	def.AddTrigger(mustTrigger(NewBootTrigger(period.Period{}, RepetitionPattern{
+		RepetitionDuration: period.NewHMS(0, 5, 0),
+		RepetitionInterval: period.NewHMS(1, 0, 0),
+	})))
+	err = taskService.ValidateTaskDefinition(def)
+	if err == nil {
+		t.Fatal("definition with invalid repetition pattern should not be valid")
+	}
+
+	comHandlerDef := taskService.NewTaskDefinition()
+	comHandlerDef.AddAction(ComHandlerAction{
+		ClassID: "F0001111-0000-0000-0000-0000FEEDACDC",
+	})
+	err = taskService.ValidateTaskDefinition(comHandlerDef)
+	if err == nil {
+		t.Fatal("definition with invalid ComHandlerAction ClassID should not be valid")
+	}
+
+	eventDef := taskService.NewTaskDefinition()
+	eventDef.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	eventDef.AddTrigger(EventTrigger{
+		Subscription: "<QueryList><Query Id='0'>",
+	})
+	err = taskService.ValidateTaskDefinition(eventDef)
+	if err == nil {
+		t.Fatal("definition with malformed EventTrigger Subscription should not be valid")
+	}
+
+	restartDef := taskService.NewTaskDefinition()
+	restartDef.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	restartDef.Settings.RestartCount = 3
+	restartDef.Settings.RestartInterval = period.NewHMS(0, 0, 30)
+	err = taskService.ValidateTaskDefinition(restartDef)
+	if err == nil {
+		t.Fatal("definition with RestartInterval shorter than one minute should not be valid")
+	}
+
+	restartDef.Settings.RestartInterval = period.NewHMS(0, 5, 0)
+	restartDef.Settings.DeleteExpiredTaskAfter = period.NewYMD(0, 0, 30)
+	err = taskService.ValidateTaskDefinition(restartDef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settingsDef := taskService.NewTaskDefinition()
+	settingsDef.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	settingsDef.Settings.IdleSettings.WaitTimeout = period.NewHMS(0, 5, 0)
+	err = taskService.ValidateTaskDefinition(settingsDef)
+	if !errors.Is(err, ErrIdleWaitTimeoutTooShort) {
+		t.Fatalf("expected ErrIdleWaitTimeoutTooShort, got %v", err)
+	}
+
+	settingsDef.Settings.IdleSettings.WaitTimeout = period.NewHMS(1, 0, 0)
+	settingsDef.Settings.Priority = 11
+	err = taskService.ValidateTaskDefinition(settingsDef)
+	if !errors.Is(err, ErrInvalidPriority) {
+		t.Fatalf("expected ErrInvalidPriority, got %v", err)
+	}
+
+	settingsDef.Settings.Priority = 7
+	settingsDef.Settings.MultipleInstances = TASK_INSTANCES_STOP_EXISTING + 1
+	err = taskService.ValidateTaskDefinition(settingsDef)
+	if !errors.Is(err, ErrInvalidInstancesPolicy) {
+		t.Fatalf("expected ErrInvalidInstancesPolicy, got %v", err)
+	}
+
+	boundaryDef := taskService.NewTaskDefinition()
+	boundaryDef.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	boundaryDef.AddTrigger(BootTrigger{})
+	boundaryDef.AddTrigger(TimeTrigger{
+		TaskTrigger: TaskTrigger{
+			StartBoundary: time.Now().Add(time.Hour),
+			EndBoundary:   time.Now(),
+		},
+	})
+	err = taskService.ValidateTaskDefinition(boundaryDef)
+	if !errors.Is(err, ErrEndBoundaryBeforeStart) {
+		t.Fatalf("expected ErrEndBoundaryBeforeStart, got %v", err)
+	}
+}
+
+func TestPreviewTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	if _, err = taskService.PreviewTask(def); err != ErrNoActions {
+		t.Fatalf("expected ErrNoActions, got %v", err)
+	}
+
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+		Args: "/c exit 0",
+	})
+	xmlText, err := taskService.PreviewTask(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(xmlText, "<Command>cmd.exe</Command>") || !strings.Contains(xmlText, "<UserId>") {
+		t.Fatalf("unexpected task XML: %s", xmlText)
+	}
+
+	// nothing should have been registered
+	tasks, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tasks.Release()
+	for _, task := range tasks {
+		if strings.Contains(task.Definition.XMLText, "/c exit 0") {
+			t.Fatalf("task %s was registered by PreviewTask", task.Path)
+		}
+	}
+}
+
+func TestAfterFillAndRawObject(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{Path: "cmd.exe", Args: "/c exit 0"})
+	def.AfterFill = func(definitionObj *ole.IDispatch) error {
+		regInfoObj := oleutil.MustGetProperty(definitionObj, "RegistrationInfo").ToIDispatch()
+		defer regInfoObj.Release()
+		_, err := oleutil.PutProperty(regInfoObj, "Source", "AfterFill")
+		return err
+	}
+
+	task, _, err := taskService.CreateTask(`\Taskmaster\AfterFill`, def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.DeleteTask(`\Taskmaster\AfterFill`)
+	defer task.Release()
+	if task.Definition.RegistrationInfo.Source != "AfterFill" {
+		t.Fatalf("expected the source set by AfterFill, got %q", task.Definition.RegistrationInfo.Source)
+	}
+
+	name, err := oleutil.GetProperty(task.RawObject(), "Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name.ToString() != "AfterFill" {
+		t.Fatalf("expected the name of the raw object to be AfterFill, got %q", name.ToString())
+	}
+	task.Release()
+	if task.RawObject() != nil {
+		t.Fatal("the raw object of a released task should be nil")
+	}
+
+	def.AfterFill = func(*ole.IDispatch) error { return errors.New("unsupported property") }
+	if _, err = taskService.PreviewTask(def); err == nil || !strings.Contains(err.Error(), "unsupported property") {
+		t.Fatalf("expected the error returned by AfterFill, got %v", err)
+	}
+}
+
+func TestCopyTask(t *testing.T) {
+	src, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(src)
+	defer src.Disconnect()
+
+	dst, err := src.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Disconnect()
+	defer dst.DeleteFolder("\\Taskmaster\\Copied", true)
+
+	copiedTask, err := CopyTask(&src, "\\Taskmaster\\TestTask", &dst, "\\Taskmaster\\Copied\\TestTask", CopyTaskOptions{
+		WorkingDirs: map[string]string{"": "C:\\Windows"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copiedTask.Release()
+	if copiedTask.Path != "\\Taskmaster\\Copied\\TestTask" {
+		t.Errorf("task was copied to %s", copiedTask.Path)
+	}
+	if workingDir := copiedTask.Definition.Actions[0].(ExecAction).WorkingDir; workingDir != "C:\\Windows" {
+		t.Errorf("working directory should have been remapped, got %q", workingDir)
+	}
+	if !src.registeredTaskExist("\\Taskmaster\\TestTask") {
+		t.Fatal("source task should not have been deleted")
+	}
+
+	_, err = CopyTask(&src, "\\Taskmaster\\TestTask", &dst, "\\Taskmaster\\Copied\\TestTask", CopyTaskOptions{})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+func TestExportImportFolder(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Archive", true)
+	defer taskService.DeleteFolder("\\Taskmaster\\Restored", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	for _, path := range []string{"\\Taskmaster\\Archive\\A", "\\Taskmaster\\Archive\\Sub\\B"} {
+		task, _, err := taskService.CreateTask(path, def, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Release()
+	}
+
+	archive, err := taskService.ExportFolder("\\Taskmaster\\Archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Folders) != 2 || archive.Folders[0].Path != "" || archive.Folders[1].Path != "Sub" {
+		t.Fatalf("unexpected archived folders: %+v", archive.Folders)
+	}
+	if len(archive.Tasks) != 2 || archive.Tasks[0].Path != "A" || archive.Tasks[1].Path != "Sub\\B" {
+		t.Fatalf("unexpected archived tasks: %+v", archive.Tasks)
+	}
+
+	opts := ImportFolderOptions{Path: "\\Taskmaster\\Restored"}
+	imported, err := taskService.ImportFolder(archive, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 2 || !taskService.registeredTaskExist("\\Taskmaster\\Restored\\Sub\\B") {
+		t.Fatalf("expected 2 tasks to be imported, got %v", imported)
+	}
+
+	imported, err = taskService.ImportFolder(archive, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 0 {
+		t.Fatalf("existing tasks should have been skipped, imported %v", imported)
+	}
+
+	opts.Conflict = IMPORT_CONFLICT_RENAME
+	imported, err = taskService.ImportFolder(archive, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 2 || imported[0] != "\\Taskmaster\\Restored\\A (2)" {
+		t.Fatalf("tasks should have been imported under new names, imported %v", imported)
+	}
+}
+
+func TestCreateTaskWithTriggerConstructors(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	repetition := RepetitionPattern{
+		RepetitionDuration: period.NewHMS(1, 0, 0),
+		RepetitionInterval: period.NewHMS(0, 5, 0),
+	}
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.AddTrigger(mustTrigger(NewBootTrigger(period.Period{})))
+	def.AddTrigger(mustTrigger(NewDailyTrigger(time.Now(), EveryDay, repetition)))
+	def.AddTrigger(mustTrigger(NewTimeTrigger(time.Now())))
+	def.AddTrigger(mustTrigger(NewWeeklyTrigger(time.Now(), EveryWeek, Monday|Friday)))
+	def.AddTrigger(mustTrigger(NewEventTrigger("System", "Microsoft-Windows-Kernel-General", 12)))
+	def.AddTrigger(mustTrigger(NewMonthlyTrigger(time.Now(), Fifteen|LastDayOfMonth, AllMonths)))
+	def.AddTrigger(mustTrigger(NewMonthlyDOWTrigger(time.Now(), Second|LastWeek, Tuesday, AllMonths)))
+
+	task, _, err := taskService.CreateTask("\\Taskmaster\\TriggerConstructors", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	if len(task.Definition.Triggers) != 7 {
+		t.Fatalf("should have 7 triggers, got %d instead", len(task.Definition.Triggers))
+	}
+	dailyTrigger, ok := task.Definition.Triggers[1].(DailyTrigger)
+	if !ok {
+		t.Fatalf("expected DailyTrigger, got %T", task.Definition.Triggers[1])
+	}
+	if !dailyTrigger.Enabled || dailyTrigger.RepetitionInterval != repetition.RepetitionInterval {
+		t.Fatalf("DailyTrigger wasn't created correctly: %+v", dailyTrigger)
+	}
+	monthlyTrigger, ok := task.Definition.Triggers[5].(MonthlyTrigger)
+	if !ok {
+		t.Fatalf("expected MonthlyTrigger, got %T", task.Definition.Triggers[5])
+	}
+	if monthlyTrigger.DaysOfMonth != Fifteen || !monthlyTrigger.RunOnLastDayOfMonth {
+		t.Fatalf("MonthlyTrigger wasn't created correctly: %+v", monthlyTrigger)
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	err = taskService.CreateFolder("\\Taskmaster\\CreateFolder\\Nested", "D:(A;;FA;;;BA)(A;;FA;;;SY)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.DeleteFolder("\\Taskmaster\\CreateFolder", true)
+
+	if !taskService.taskFolderExist("\\Taskmaster\\CreateFolder\\Nested") {
+		t.Fatal("folder should have been created")
+	}
+
+	// creating a folder that already exists is not an error
+	err = taskService.CreateFolder("\\Taskmaster\\CreateFolder\\Nested", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = taskService.CreateFolder("Taskmaster", "")
+	if err != ErrInvalidPath {
+		t.Fatalf("expected ErrInvalidPath, got %v", err)
+	}
+
+	// CreateTask creates every missing folder of the task's path
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{Path: "calc.exe"})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\CreateFolder\\A\\B\\C\\Task", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+	for _, path := range []string{"\\Taskmaster\\CreateFolder\\A", "\\Taskmaster\\CreateFolder\\A\\B", "\\Taskmaster\\CreateFolder\\A\\B\\C"} {
+		if !taskService.taskFolderExist(path) {
+			t.Fatalf("folder %s should have been created", path)
+		}
+	}
+}
+
+func TestDeleteTasksMatching(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	for _, path := range []string{"\\Taskmaster\\DeleteMatching\\A", "\\Taskmaster\\DeleteMatching\\B", "\\Taskmaster\\DeleteMatching\\Keep"} {
+		task, _, err := taskService.CreateTask(path, def, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Release()
+	}
+	defer taskService.DeleteFolder("\\Taskmaster\\DeleteMatching", true)
+
+	deleted, err := taskService.DeleteTasksMatching("\\Taskmaster\\DeleteMatching\\?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("should have deleted 2 tasks, deleted %d instead: %v", len(deleted), deleted)
+	}
+	if !taskService.registeredTaskExist("\\Taskmaster\\DeleteMatching\\Keep") {
+		t.Fatal("task that didn't match pattern should not have been deleted")
+	}
+}
+
+func TestCreateAndDeleteTasks(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Bulk", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	existing, _, err := taskService.CreateTask("\\Taskmaster\\Bulk\\Existing", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing.Release()
+
+	results := taskService.CreateTasks([]TaskSpec{
+		{Path: "\\Taskmaster\\Bulk\\A", Definition: def},
+		{Path: "\\Taskmaster\\Bulk\\Sub\\B", Definition: def},
+		{Path: "\\Taskmaster\\Bulk\\NoActions", Definition: taskService.NewTaskDefinition()},
+		{Path: "\\Taskmaster\\Bulk\\Existing", Definition: def},
+	})
+	for _, result := range results {
+		defer result.Task.Release()
+	}
+	if len(results) != 4 {
+		t.Fatalf("should have returned 4 results, returned %d instead", len(results))
+	}
+	for _, result := range results[:2] {
+		if result.Err != nil {
+			t.Fatalf("error creating task %s: %v", result.Path, result.Err)
+		}
+		if !result.Created || result.Task.Path != result.Path {
+			t.Fatalf("task %s should have been created", result.Path)
+		}
+	}
+	if !errors.Is(results[2].Err, ErrNoActions) {
+		t.Fatalf("creating task without actions should have returned ErrNoActions, returned %v instead", results[2].Err)
+	}
+	if results[3].Err != nil {
+		t.Fatal(results[3].Err)
+	}
+	if results[3].Created {
+		t.Fatal("existing task should not have been overwritten")
+	}
+
+	errs := taskService.DeleteTasks([]string{
+		"\\Taskmaster\\Bulk\\A",
+		"\\Taskmaster\\Bulk\\Sub\\B",
+		"\\Taskmaster\\Bulk\\Missing",
+		"\\Taskmaster\\Bulk\\MissingFolder\\Missing",
+	})
+	for _, err := range errs[:2] {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, err := range errs[2:] {
+		if !errors.Is(err, ErrTaskNotFound) {
+			t.Fatalf("deleting missing task should have returned ErrTaskNotFound, returned %v instead", err)
+		}
+	}
+	if taskService.registeredTaskExist("\\Taskmaster\\Bulk\\A") {
+		t.Fatal("task should have been deleted")
+	}
+}
+
+func TestGetRegisteredTasksWithErrors(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	rtc, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+
+	tasks, parseErrs, err := taskService.GetRegisteredTasksWithErrors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tasks.Release()
+
+	// GetRegisteredTasks would have failed if any task couldn't be parsed
+	if len(parseErrs) != 0 {
+		t.Fatalf("expected no parse errors, got %v", parseErrs)
+	}
+	if len(tasks) != len(rtc) {
+		t.Fatalf("expected %d registered tasks, got %d", len(rtc), len(tasks))
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	rtc, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+
+	tasks, err := taskService.ListTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tasks.Release()
+
+	if len(tasks) != len(rtc) {
+		t.Fatalf("expected %d registered tasks, got %d", len(rtc), len(tasks))
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Path != "\\Taskmaster\\TestTask" {
+			continue
+		}
+		if len(task.Definition.Actions) != 0 {
+			t.Fatal("definition should not have been parsed")
+		}
+		if err = task.LoadDefinition(); err != nil {
+			t.Fatal(err)
+		}
+		if len(task.Definition.Actions) == 0 {
+			t.Fatal("definition should have been loaded")
+		}
+		return
+	}
+	t.Fatal("test task was not listed")
+}
+
+func TestGetRegisteredTasksParallel(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	tasks, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tasks.Release()
+
+	parallelTasks, err := taskService.GetRegisteredTasksParallel(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parallelTasks.Release()
+
+	if len(tasks) != len(parallelTasks) {
+		t.Fatalf("expected %d registered tasks, got %d instead", len(tasks), len(parallelTasks))
+	}
+
+	listedTasks, err := taskService.ListTasks(WithParallelism(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listedTasks.Release()
+
+	if len(tasks) != len(listedTasks) {
+		t.Fatalf("expected %d listed tasks, got %d instead", len(tasks), len(listedTasks))
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	if !taskService.Connected() {
+		t.Fatal("task service should be connected")
+	}
+
+	if err = taskService.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if !taskService.Connected() {
+		t.Fatal("task service should be connected after reconnecting")
+	}
+
+	tasks, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks.Release()
+
+	taskService.Disconnect()
+	if taskService.Connected() {
+		t.Fatal("task service should not be connected after disconnecting")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	taskService, err := Connect(WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	attempts := 0
+	err = taskService.Retry(func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("error getting registered tasks: %w", ErrConnectionLost)
+		}
+
+		tasks, err := taskService.GetRegisteredTasks()
+		if err != nil {
+			return err
+		}
+		tasks.Release()
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+
+	// other errors aren't retried
+	attempts = 0
+	err = taskService.Retry(func() error {
+		attempts++
+		return ErrAccessDenied
+	})
+	if err != ErrAccessDenied || attempts != 1 {
+		t.Fatalf("expected ErrAccessDenied after 1 attempt, got %v after %d attempts", err, attempts)
+	}
+
+	// the error of the last attempt is returned
+	attempts = 0
+	err = taskService.Retry(func() error {
+		attempts++
+		return ErrConnectionLost
+	})
+	if err != ErrConnectionLost || attempts != 3 {
+		t.Fatalf("expected ErrConnectionLost after 3 attempts, got %v after %d attempts", err, attempts)
+	}
+	if !taskService.IsHealthy() {
+		t.Fatal("task service should be healthy after reconnecting")
+	}
+}
+
+func TestConnectWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	taskService, err := Connect(WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	if !strings.Contains(buf.String(), "method=Connect") {
+		t.Fatalf("expected the Connect call to be logged, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if _, err = taskService.GetRegisteredTask("\\Taskmaster\\DoesNotExist"); err == nil {
+		t.Fatal("getting a task that doesn't exist should fail")
+	}
+	if !strings.Contains(buf.String(), "COM call failed") || !strings.Contains(buf.String(), "method=GetTask") || !strings.Contains(buf.String(), "hresult=0x8007000") {
+		t.Fatalf("expected the failed GetTask call to be logged, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	rtc, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtc.Release()
+	if !strings.Contains(buf.String(), "method=GetTasks") || !strings.Contains(buf.String(), "method=GetFolders") {
+		t.Fatalf("expected the enumeration calls to be logged, got:\n%s", buf.String())
+	}
+}
+
+func TestConnectWithProxyBlanket(t *testing.T) {
+	taskService, err := Connect(WithProxyBlanket(RPC_C_AUTHN_LEVEL_PKT_PRIVACY, RPC_C_IMP_LEVEL_IMPERSONATE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	createTestTask(taskService)
+	defer taskService.DeleteTask("\\Taskmaster\\TestTask")
+
+	rtc, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtc.Release()
+
+	if err = taskService.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	task, err := taskService.GetRegisteredTask("\\Taskmaster\\TestTask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+}
+
+func TestCreateTaskWithMaintenanceSettings(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.Settings.Compatibility = TASK_COMPATIBILITY_V2_2
+	def.Settings.MaintenanceSettings = &MaintenanceSettings{
+		Period:   period.NewYMD(0, 0, 1),
+		Deadline: period.NewYMD(0, 0, 2),
+	}
+	def.Settings.NetworkSettings = NetworkSettings{
+		Name: "Taskmaster",
+	}
+	def.Settings.RunOnlyIfNetworkAvailable = true
+
+	task, _, err := taskService.CreateTask("\\Taskmaster\\MaintenanceSettings", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	maintenanceSettings := task.Definition.Settings.MaintenanceSettings
+	if maintenanceSettings == nil {
+		t.Fatal("maintenance settings should have been set")
+	}
+	if maintenanceSettings.Period != def.Settings.MaintenanceSettings.Period || maintenanceSettings.Deadline != def.Settings.MaintenanceSettings.Deadline {
+		t.Fatalf("maintenance settings weren't set correctly: %+v", maintenanceSettings)
+	}
+	if task.Definition.Settings.NetworkSettings.Name != "Taskmaster" {
+		t.Fatalf("expected network profile Taskmaster, got %s", task.Definition.Settings.NetworkSettings.Name)
+	}
+
+	// tasks without maintenance settings should have nil maintenance settings
+	testTask := createTestTask(taskService)
+	defer testTask.Release()
+	if testTask.Definition.Settings.MaintenanceSettings != nil {
+		t.Fatalf("expected nil maintenance settings, got %+v", testTask.Definition.Settings.MaintenanceSettings)
+	}
+}
+
+func TestCreateTaskWithSettings3(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.Settings.Compatibility = TASK_COMPATIBILITY_V2_2
+	def.Settings.DisallowStartOnRemoteAppSession = true
+	def.Settings.UseUnifiedSchedulingEngine = true
+	def.Settings.Volatile = true
+
+	task, _, err := taskService.CreateTask("\\Taskmaster\\Settings3", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	settings := task.Definition.Settings
+	if !settings.DisallowStartOnRemoteAppSession || !settings.UseUnifiedSchedulingEngine || !settings.Volatile {
+		t.Fatalf("settings weren't set correctly: %+v", settings)
+	}
+
+	testTask := createTestTask(taskService)
+	defer testTask.Release()
+	if testTask.Definition.Settings.Volatile {
+		t.Fatal("expected task not to be volatile")
+	}
+}
+
+func TestCreateTaskWithRequiredPrivileges(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.Principal.UserID = SYSTEM
+	def.Principal.LogonType = TASK_LOGON_SERVICE_ACCOUNT
+	def.Principal.ProcessTokenSidType = TASK_PROCESSTOKENSID_UNRESTRICTED
+	def.Principal.RequiredPrivileges = []string{"SeBackupPrivilege"}
+	def.Settings.Compatibility = TASK_COMPATIBILITY_V2_1
+
+	task, _, err := taskService.CreateTask("\\Taskmaster\\RequiredPrivileges", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	principal := task.Definition.Principal
+	if principal.ProcessTokenSidType != TASK_PROCESSTOKENSID_UNRESTRICTED {
+		t.Fatalf("expected ProcessTokenSidType %s, got %s", TASK_PROCESSTOKENSID_UNRESTRICTED, principal.ProcessTokenSidType)
+	}
+	if len(principal.RequiredPrivileges) != 1 || principal.RequiredPrivileges[0] != "SeBackupPrivilege" {
+		t.Fatalf("expected only SeBackupPrivilege, got %v", principal.RequiredPrivileges)
+	}
+}
+
+func TestSetTaskCredentials(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer testTask.Release()
+	defer taskService.Disconnect()
+
+	username := taskService.GetConnectedDomain() + `\` + taskService.GetConnectedUser()
+	task, err := taskService.SetTaskCredentials(testTask.Path, username, "", TASK_LOGON_INTERACTIVE_TOKEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+	if diffs := testTask.Definition.Diff(task.Definition); len(diffs) != 0 {
+		t.Fatalf("definition should not have changed: %v", diffs)
+	}
+
+	_, err = taskService.SetTaskCredentials(testTask.Path, username, "not the password", TASK_LOGON_PASSWORD)
+	if !errors.Is(err, ErrLogonFailure) {
+		t.Fatalf("expected ErrLogonFailure, got %v", err)
+	}
+}
+
+func TestFolderSecurityDescriptor(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	path := "\\Taskmaster\\FolderSecurityDescriptor"
+	if err = taskService.CreateFolder(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.DeleteFolder(path, true)
+
+	// grant full access to administrators and SYSTEM only
+	sddl := "D:P(A;;FA;;;BA)(A;;FA;;;SY)"
+	if err = taskService.SetFolderSecurityDescriptor(path, sddl, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	folderSDDL, err := taskService.GetFolderSecurityDescriptor(path, DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(folderSDDL, "(A;;FA;;;BA)") {
+		t.Fatalf("expected security descriptor to grant administrators full access, got %s", folderSDDL)
+	}
+
+	_, err = taskService.GetFolderSecurityDescriptor("\\Taskmaster\\DoesNotExist", DACL_SECURITY_INFORMATION)
+	if !errors.Is(err, ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+}
+
+func TestRunAndWait(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+		Args: "/c exit $(Arg0)",
+	})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\RunAndWait", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exitCode, err := taskService.RunAndWait(ctx, "\\Taskmaster\\RunAndWait", []string{"3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", exitCode)
+	}
+}
+
+func TestEnumerateHiddenTasks(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.Settings.Hidden = true
+	task, _, err := taskService.CreateTask("\\Taskmaster\\HiddenTask", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+	defer taskService.DeleteTask("\\Taskmaster\\HiddenTask")
+
+	containsHiddenTask := func(tasks RegisteredTaskCollection) bool {
+		for _, task := range tasks {
+			if task.Path == "\\Taskmaster\\HiddenTask" {
+				return true
+			}
+		}
+		return false
+	}
+
+	tasks, err := taskService.GetTasksInFolderOptions("\\Taskmaster", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tasks.Release()
+	if containsHiddenTask(tasks) {
+		t.Fatal("hidden task should not have been enumerated")
+	}
+
+	allTasks, err := taskService.GetTasksInFolderOptions("\\Taskmaster", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer allTasks.Release()
+	if !containsHiddenTask(allTasks) {
+		t.Fatal("hidden task should have been enumerated")
+	}
+
+	visibleTasks, err := taskService.GetRegisteredTasksOptions(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer visibleTasks.Release()
+	if containsHiddenTask(visibleTasks) {
+		t.Fatal("hidden task should not have been enumerated")
+	}
+
+	listedTasks, err := taskService.ListTasks(ExcludeHidden())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listedTasks.Release()
+	if containsHiddenTask(listedTasks) {
+		t.Fatal("hidden task should not have been listed")
+	}
+
+	if err := taskService.SetTaskEnabled("\\Taskmaster\\HiddenTask", false); err != nil {
+		t.Fatal(err)
+	}
+	enabledTasks, err := taskService.GetTasksInFolder("\\Taskmaster", OnlyEnabled())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enabledTasks.Release()
+	if containsHiddenTask(enabledTasks) {
+		t.Fatal("disabled task should not have been enumerated")
+	}
+
+	disabledTasks, err := taskService.GetTasksInFolder("\\Taskmaster", OnlyDisabled())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disabledTasks.Release()
+	if !containsHiddenTask(disabledTasks) {
+		t.Fatal("disabled task should have been enumerated")
+	}
+}
+
+func TestLogonAndSessionStateChangeTriggers(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	username := taskService.GetConnectedDomain() + `\` + taskService.GetConnectedUser()
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.AddTrigger(LogonTrigger{
+		TaskTrigger: TaskTrigger{
+			Enabled: true,
+		},
+		UserID: username,
+	})
+	def.AddTrigger(SessionStateChangeTrigger{
+		TaskTrigger: TaskTrigger{
+			Enabled: true,
+		},
+		StateChange: TASK_SESSION_UNLOCK,
+	})
+	def.AddTrigger(mustTrigger(NewSessionStateChangeTrigger(TASK_REMOTE_DISCONNECT, username, period.NewHMS(0, 5, 0))))
+
+	task, _, err := taskService.CreateTask("\\Taskmaster\\LogonAndSessionTriggers", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	logonTrigger, ok := task.Definition.Triggers[0].(LogonTrigger)
+	if !ok {
+		t.Fatalf("expected LogonTrigger, got %T", task.Definition.Triggers[0])
+	}
+	if !strings.EqualFold(logonTrigger.UserID, username) {
+		t.Fatalf("expected LogonTrigger UserID %s, got %s", username, logonTrigger.UserID)
+	}
+
+	sessionTrigger, ok := task.Definition.Triggers[1].(SessionStateChangeTrigger)
+	if !ok {
+		t.Fatalf("expected SessionStateChangeTrigger, got %T", task.Definition.Triggers[1])
+	}
+	// an empty UserID means any user
+	if sessionTrigger.StateChange != TASK_SESSION_UNLOCK || sessionTrigger.UserID != "" {
+		t.Fatalf("SessionStateChangeTrigger wasn't created correctly: %+v", sessionTrigger)
+	}
+
+	disconnectTrigger, ok := task.Definition.Triggers[2].(SessionStateChangeTrigger)
+	if !ok {
+		t.Fatalf("expected SessionStateChangeTrigger, got %T", task.Definition.Triggers[2])
+	}
+	if disconnectTrigger.StateChange != TASK_REMOTE_DISCONNECT || !strings.EqualFold(disconnectTrigger.UserID, username) || disconnectTrigger.Delay != period.NewHMS(0, 5, 0) {
+		t.Fatalf("SessionStateChangeTrigger wasn't created correctly: %+v", disconnectTrigger)
+	}
+}
+
+func TestDeleteEmptyFolders(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	for _, path := range []string{"\\Taskmaster\\Prune\\Empty\\Nested", "\\Taskmaster\\Prune\\NotEmpty"} {
+		if err = taskService.CreateFolder(path, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer taskService.DeleteFolder("\\Taskmaster\\Prune", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\Prune\\NotEmpty\\Task", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+
+	removed, err := taskService.DeleteEmptyFolders("\\Taskmaster\\Prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0] != "\\Taskmaster\\Prune\\Empty\\Nested" || removed[1] != "\\Taskmaster\\Prune\\Empty" {
+		t.Fatalf("expected the Empty folders to be removed, removed %v instead", removed)
+	}
+	if !taskService.taskFolderExist("\\Taskmaster\\Prune\\NotEmpty") {
+		t.Fatal("folder with a task should not have been removed")
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	taskService, err := ConnectWithOptionsCtx(context.Background(), "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = taskService.GetRegisteredTasksCtx(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	_, _, err = taskService.CreateTaskCtx(ctx, "\\Taskmaster\\CanceledTask", def, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	_, err = taskService.GetRegisteredTask("\\Taskmaster\\CanceledTask")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatal("task should not have been created")
+	}
+
+	_, err = ConnectWithOptionsCtx(ctx, "", "", "", "")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestConnectCtxUninitializesCallingThread(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	taskService, err := ConnectWithOptionsCtx(context.Background(), "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskService.Disconnect()
+
+	// initializing a single-threaded apartment fails with RPC_E_CHANGED_MODE if
+	// the multithreaded apartment is still initialized on this thread
+	if err = ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED); err != nil {
+		t.Fatalf("COM should have been uninitialized on the calling thread: %v", err)
+	}
+	ole.CoUninitialize()
+}
+
+func TestForEachRegisteredTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	rtc, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+
+	var count int
+	err = taskService.ForEachRegisteredTask(func(task RegisteredTask) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(rtc) {
+		t.Fatalf("expected %d registered tasks, got %d", len(rtc), count)
+	}
+
+	errStop := errors.New("stop")
+	err = taskService.ForEachRegisteredTask(func(task RegisteredTask) error {
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected the error returned by the callback, got %v", err)
+	}
+}
+
+func TestTaskFolderHandle(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	if err = taskService.CreateFolder("\\Taskmaster\\Handle", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.DeleteFolder("\\Taskmaster\\Handle", true)
+
+	folder, err := taskService.OpenTaskFolder("\\Taskmaster\\Handle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer folder.Release()
+
+	subFolder, err := folder.CreateSubFolder("Sub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subFolder.Release()
+	if subFolder.Path != "\\Taskmaster\\Handle\\Sub" {
+		t.Fatalf("expected subfolder path to be \\Taskmaster\\Handle\\Sub, got %s", subFolder.Path)
+	}
+	_, err = folder.CreateSubFolder("Sub", "")
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	task, created, err := subFolder.CreateTask("Task", def, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+	if !created {
+		t.Fatal("task should have been created")
+	}
+	task, created, err = subFolder.CreateTask("Task", def, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+	if created {
+		t.Fatal("existing task should not have been overwritten")
+	}
+
+	tasks, err := subFolder.GetTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Path != "\\Taskmaster\\Handle\\Sub\\Task" {
+		t.Fatalf("expected the folder to contain the created task")
+	}
+	tasks.Release()
+
+	subFolders, err := folder.GetFolders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subFolders) != 1 || subFolders[0].Name != "Sub" {
+		t.Fatal("expected the folder to contain the created subfolder")
+	}
+	for i := range subFolders {
+		subFolders[i].Release()
+	}
+
+	if err = subFolder.DeleteTask("Task"); err != nil {
+		t.Fatal(err)
+	}
+	err = subFolder.DeleteTask("Task")
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestGetRegisteredTasksInFolder(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Filter", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	for _, path := range []string{"\\Taskmaster\\Filter\\TaskA", "\\Taskmaster\\Filter\\TaskB", "\\Taskmaster\\Filter\\Sub\\TaskA"} {
+		task, _, err := taskService.CreateTask(path, def, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Release()
+	}
+
+	rtc, err := taskService.GetRegisteredTasksInFolder("\\Taskmaster\\Filter", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rtc) != 2 {
+		t.Fatalf("expected 2 tasks directly inside the folder, got %d", len(rtc))
+	}
+	rtc.Release()
+
+	rtc, err = taskService.GetRegisteredTasksInFolder("\\Taskmaster\\Filter", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rtc) != 3 {
+		t.Fatalf("expected 3 tasks inside the folder and its subfolders, got %d", len(rtc))
+	}
+	rtc.Release()
+
+	rtc, err = taskService.GetTasksInFolder("\\Taskmaster\\Filter", IncludeSubfolders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rtc) != 3 {
+		t.Fatalf("expected IncludeSubfolders to return 3 tasks, got %d", len(rtc))
+	}
+	rtc.Release()
+
+	rtc, err = taskService.GetRegisteredTasksFiltered("\\Taskmaster\\Filter", true, true, TaskFilter{
+		NamePattern: "*A",
+		States:      []TaskState{TASK_STATE_READY},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rtc) != 2 {
+		t.Fatalf("expected 2 ready tasks whose name ends with A, got %d", len(rtc))
+	}
+	rtc.Release()
+
+	_, err = taskService.GetRegisteredTasksFiltered("\\Taskmaster\\Filter", true, true, TaskFilter{
+		PathPattern: "[",
+	})
+	if err != filepath.ErrBadPattern {
+		t.Fatalf("expected filepath.ErrBadPattern, got %v", err)
+	}
+}
+
+func TestConnectOptions(t *testing.T) {
+	taskService, err := Connect(WithServer(""), WithConnectTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !taskService.Connected() {
+		t.Fatal("task service should be connected")
+	}
+	taskService.Disconnect()
+
+	_, err = Connect(WithApartmentThreaded(), WithConnectTimeout(time.Minute))
+	if err == nil {
+		t.Fatal("a connect timeout should not be allowed with a single-threaded apartment")
+	}
+}
+
+func TestParallelismApartmentThreaded(t *testing.T) {
+	// the thread is terminated when the test returns, as it's still locked
+	runtime.LockOSThread()
+
+	taskService, err := Connect(WithApartmentThreaded())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	if _, err = taskService.GetRegisteredTasks(WithParallelism(4)); err == nil {
+		t.Fatal("a parallel enumeration should not be allowed with a single-threaded apartment")
+	}
+	rtc, err := taskService.GetRegisteredTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtc.Release()
+}
+
+func TestCloneTaskService(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+
+			clone, err := taskService.Clone()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer clone.Disconnect()
+
+			rtc, err := clone.GetRegisteredTasks()
+			if err != nil {
+				errs <- err
+				return
+			}
+			rtc.Release()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestFindTasks(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Find", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+	})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\Find\\Regular", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+
+	def.Principal.RunLevel = TASK_RUNLEVEL_HIGHEST
+	def.Settings.Hidden = true
+	task, _, err = taskService.CreateTask("\\Taskmaster\\Find\\Sub\\Elevated", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+
+	rtc, err := taskService.FindTasks(Query{
+		Folder:            "\\Taskmaster\\Find",
+		HighestRunLevel:   true,
+		HiddenOnly:        true,
+		ActionPathMatches: "*.exe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+	if len(rtc) != 1 || rtc[0].Path != "\\Taskmaster\\Find\\Sub\\Elevated" {
+		t.Fatalf("expected only the elevated task to be found, got %d tasks", len(rtc))
+	}
+
+	_, err = taskService.FindTasks(Query{ActionPathMatches: "["})
+	if err != path.ErrBadPattern {
+		t.Fatalf("expected path.ErrBadPattern, got %v", err)
+	}
+}
+
+func TestSetFolderEnabled(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Bulk", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	for _, path := range []string{"\\Taskmaster\\Bulk\\TaskA", "\\Taskmaster\\Bulk\\Sub\\TaskB"} {
+		task, _, err := taskService.CreateTask(path, def, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Release()
+	}
+
+	result, err := taskService.SetFolderEnabled("\\Taskmaster\\Bulk", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Tasks) != 1 || !result.Tasks[0].Changed || result.Err() != nil {
+		t.Fatalf("expected only the task directly inside the folder to be disabled, got %+v", result)
+	}
+
+	result, err = taskService.SetFolderEnabled("\\Taskmaster\\Bulk", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Tasks) != 2 || result.Err() != nil {
+		t.Fatalf("expected 2 tasks, got %+v", result)
+	}
+	for _, task := range result.Tasks {
+		if task.Changed != (task.Path == "\\Taskmaster\\Bulk\\Sub\\TaskB") {
+			t.Fatalf("only the task that was still enabled should have been changed, got %+v", result)
+		}
+	}
+
+	task, err := taskService.GetRegisteredTask("\\Taskmaster\\Bulk\\Sub\\TaskB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+	if task.Enabled {
+		t.Fatal("task should have been disabled")
+	}
+
+	_, err = taskService.SetFolderEnabled("\\Taskmaster\\DoesNotExist", true, true)
+	if !errors.Is(err, ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+}
+
+// BenchmarkGetRegisteredTasks compares enumerating tasks serially and in
+// parallel. Set TASKMASTER_BENCHMARK_SERVER to the name of a remote computer to
+// measure the speedup over DCOM, where it's the most significant.
+func BenchmarkGetRegisteredTasks(b *testing.B) {
+	taskService, err := Connect(WithServer(os.Getenv("TASKMASTER_BENCHMARK_SERVER")))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	benchmarks := []struct {
+		name string
+		opts []EnumOption
+	}{
+		{"Serial", nil},
+		{"Parallel4", []EnumOption{WithParallelism(4)}},
+		{"Parallel16", []EnumOption{WithParallelism(16)}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tasks, err := taskService.ListTasks(bm.opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				tasks.Release()
+			}
+		})
+	}
+}
+
+func TestFolderCache(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	taskService, err := Connect(WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+	defer taskService.DeleteFolder("\\Taskmaster\\Cache", true)
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\Cache\\TaskA", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+
+	// the folder is only looked up once while the task is registered
+	buf.Reset()
+	task, _, err = taskService.CreateTask("\\Taskmaster\\Cache\\TaskB", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+	if calls := strings.Count(buf.String(), "method=GetFolder "); calls != 1 {
+		t.Fatalf("expected the folder to be looked up once, got %d calls:\n%s", calls, buf.String())
+	}
+
+	// folders deleted by another process aren't cached
+	otherTaskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherTaskService.Disconnect()
+	if _, err = otherTaskService.DeleteFolder("\\Taskmaster\\Cache", true); err != nil {
+		t.Fatal(err)
+	}
+	if taskService.taskFolderExist("\\Taskmaster\\Cache") {
+		t.Fatal("expected the deleted folder not to exist")
+	}
+	task, _, err = taskService.CreateTask("\\Taskmaster\\Cache\\TaskA", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+
+	rtc, err := taskService.GetTasksInFolder("\\Taskmaster\\Cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtc.Release()
+	if len(rtc) != 1 {
+		t.Fatalf("expected 1 task in the recreated folder, got %d", len(rtc))
+	}
+}
@@ -0,0 +1,230 @@
+package taskmaster
+
+import (
+	"log/slog"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+
+	"github.com/stratg5/taskmaster/internal/enumopts"
+)
+
+// ConnectOption configures how Connect connects to a Task Scheduler service.
+type ConnectOption func(*connectConfig)
+
+// RetryPolicy configures how TaskService.Retry retries operations that fail
+// because the connection to the Task Scheduler service was lost, for example
+// because the remote computer rebooted.
+type RetryPolicy struct {
+	MaxAttempts    int           // the maximum number of times the operation is run, including the first attempt
+	InitialBackoff time.Duration // how long to wait before reconnecting after the first failed attempt
+	MaxBackoff     time.Duration // the maximum time to wait between attempts. The wait doubles after every failed attempt until it reaches MaxBackoff. If zero, the wait isn't capped
+}
+
+// backoff returns how long to wait after the attempt numbered attempt, starting
+// at 1, failed.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return backoff
+}
+
+type connectConfig struct {
+	connectOptions
+	timeout time.Duration
+	coinit  uint32
+}
+
+// WithServer makes Connect connect to the Task Scheduler service on the computer
+// serverName. If serverName is empty, the local Task Scheduler service is used.
+func WithServer(serverName string) ConnectOption {
+	return func(c *connectConfig) {
+		c.serverName = serverName
+	}
+}
+
+// WithCredentials makes Connect authenticate as the specified user instead of
+// using the current token.
+func WithCredentials(domain, username, password string) ConnectOption {
+	return func(c *connectConfig) {
+		c.domain = domain
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithConnectTimeout makes Connect give up with context.DeadlineExceeded if the
+// connection isn't established within timeout. See ConnectWithOptionsCtx for how
+// connection attempts that time out are handled.
+func WithConnectTimeout(timeout time.Duration) ConnectOption {
+	return func(c *connectConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithProxyBlanket makes the TaskService set the authentication and impersonation
+// levels of the ITaskService object with CoSetProxyBlanket once it's connected,
+// and of the folder and task objects it gets from the Task Scheduler service,
+// which some hardened remote computers require. The proxy blanket is set again by
+// Reconnect. Objects that aren't COM proxies are left as they are.
+// https://docs.microsoft.com/en-us/windows/win32/api/combaseapi/nf-combaseapi-cosetproxyblanket
+func WithProxyBlanket(authnLevel AuthenticationLevel, impLevel ImpersonationLevel) ConnectOption {
+	return func(c *connectConfig) {
+		c.proxyBlanket = &proxyBlanket{
+			authnLevel: authnLevel,
+			impLevel:   impLevel,
+		}
+	}
+}
+
+// WithLogger makes the TaskService log the Task Scheduler methods that it and the
+// TaskFolderHandles it opens call, how long each call took, and the HRESULT of
+// calls that fail, at debug level to logger. The logger is also used by
+// TaskServices created with Clone. Reading and writing COM properties, which is
+// most of what parsing and filling task definitions does, isn't logged, and
+// neither are the methods called by RegisteredTask and RunningTask.
+func WithLogger(logger *slog.Logger) ConnectOption {
+	return func(c *connectConfig) {
+		c.logger = logger
+	}
+}
+
+// WithRetryPolicy sets the policy that TaskService.Retry uses to retry operations
+// after the connection to the Task Scheduler service was lost. Without
+// WithRetryPolicy, Retry runs operations only once.
+func WithRetryPolicy(policy RetryPolicy) ConnectOption {
+	return func(c *connectConfig) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithApartmentThreaded makes Connect initialize COM in a single-threaded apartment
+// instead of the multithreaded apartment. The returned TaskService may then only
+// be used from the OS thread that called Connect, so the calling goroutine should
+// call runtime.LockOSThread first. WithApartmentThreaded can't be combined with
+// WithConnectTimeout, and enumerations with WithParallelism return an error.
+func WithApartmentThreaded() ConnectOption {
+	return func(c *connectConfig) {
+		c.coinit = ole.COINIT_APARTMENTTHREADED
+	}
+}
+
+// EnumOption configures which registered tasks are enumerated. Without any
+// EnumOptions, all tasks are enumerated, including hidden and disabled tasks.
+type EnumOption func(*enumopts.Config)
+
+type enumConfig struct {
+	enumopts.Config
+}
+
+func newEnumConfig(opts []EnumOption) enumConfig {
+	var config enumConfig
+	for _, opt := range opts {
+		opt(&config.Config)
+	}
+
+	return config
+}
+
+// flags returns the flags to enumerate tasks with.
+func (c enumConfig) flags() TaskEnumFlags {
+	if c.ExcludeHidden {
+		return 0
+	}
+
+	return TASK_ENUM_HIDDEN
+}
+
+// includes returns true if a task that is enabled or not should be enumerated.
+func (c enumConfig) includes(enabled bool) bool {
+	return c.Includes(false, enabled)
+}
+
+// ExcludeHidden skips hidden tasks when enumerating tasks.
+func ExcludeHidden() EnumOption {
+	return func(c *enumopts.Config) {
+		c.ExcludeHidden = true
+	}
+}
+
+// IncludeSubfolders makes GetTasksInFolder also return the tasks in all the
+// subfolders of the folder. Enumerations of the whole Task Scheduler database
+// always include every folder.
+func IncludeSubfolders() EnumOption {
+	return func(c *enumopts.Config) {
+		c.IncludeSubfolders = true
+	}
+}
+
+// OnlyEnabled skips disabled tasks when enumerating tasks. Tasks that are skipped
+// aren't parsed.
+func OnlyEnabled() EnumOption {
+	return func(c *enumopts.Config) {
+		c.OnlyEnabled = true
+		c.OnlyDisabled = false
+	}
+}
+
+// OnlyDisabled skips enabled tasks when enumerating tasks. Tasks that are skipped
+// aren't parsed.
+func OnlyDisabled() EnumOption {
+	return func(c *enumopts.Config) {
+		c.OnlyDisabled = true
+		c.OnlyEnabled = false
+	}
+}
+
+// WithParallelism makes enumerations process folders concurrently using up to
+// workers goroutines, each of which locks its own OS thread and initializes COM
+// on it. This is considerably faster when there are many folders, especially
+// when connected to a remote computer, as every Task Scheduler call is a round
+// trip over DCOM. If workers is less than 1, runtime.NumCPU() workers are used.
+// The order of the enumerated tasks is not defined. Parallel enumerations return
+// an error if the TaskService was connected with WithApartmentThreaded.
+func WithParallelism(workers int) EnumOption {
+	return func(c *enumopts.Config) {
+		c.Parallel = true
+		c.Workers = workers
+	}
+}
+
+// AuthenticationLevel specifies the level of authentication used for COM calls.
+// https://docs.microsoft.com/en-us/windows/win32/com/com-authentication-level-constants
+type AuthenticationLevel uint32
+
+const (
+	RPC_C_AUTHN_LEVEL_DEFAULT       AuthenticationLevel = iota // the default authentication level is used
+	RPC_C_AUTHN_LEVEL_NONE                                     // no authentication
+	RPC_C_AUTHN_LEVEL_CONNECT                                  // the client is authenticated when it connects to the server
+	RPC_C_AUTHN_LEVEL_CALL                                     // the client is authenticated at the beginning of each call
+	RPC_C_AUTHN_LEVEL_PKT                                      // all received data is authenticated to be from the expected client
+	RPC_C_AUTHN_LEVEL_PKT_INTEGRITY                            // all data is authenticated and verified to not have been modified
+	RPC_C_AUTHN_LEVEL_PKT_PRIVACY                              // all data is authenticated, verified and encrypted
+)
+
+// ImpersonationLevel specifies the amount of authority given to the server when
+// it impersonates the client.
+// https://docs.microsoft.com/en-us/windows/win32/com/com-impersonation-level-constants
+type ImpersonationLevel uint32
+
+const (
+	RPC_C_IMP_LEVEL_DEFAULT     ImpersonationLevel = iota // the default impersonation level is used
+	RPC_C_IMP_LEVEL_ANONYMOUS                             // the client is anonymous to the server
+	RPC_C_IMP_LEVEL_IDENTIFY                              // the server can get the client's identity, but not impersonate it
+	RPC_C_IMP_LEVEL_IMPERSONATE                           // the server can impersonate the client on the server computer
+	RPC_C_IMP_LEVEL_DELEGATE                              // the server can impersonate the client on other computers
+)
+
+type proxyBlanket struct {
+	authnLevel AuthenticationLevel
+	impLevel   ImpersonationLevel
+}
@@ -0,0 +1,44 @@
+package taskmaster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, backoff := range expected {
+		if got := policy.backoff(i + 1); got != backoff {
+			t.Errorf("attempt %d: expected backoff %s, got %s", i+1, backoff, got)
+		}
+	}
+
+	policy.MaxBackoff = 0
+	if got := policy.backoff(5); got != 16*time.Second {
+		t.Errorf("expected uncapped backoff 16s, got %s", got)
+	}
+}
+
+func TestEnumOptions(t *testing.T) {
+	config := newEnumConfig(nil)
+	if config.flags() != TASK_ENUM_HIDDEN || !config.includes(true) || !config.includes(false) {
+		t.Error("all tasks should be enumerated without options")
+	}
+
+	config = newEnumConfig([]EnumOption{ExcludeHidden(), OnlyEnabled()})
+	if config.flags() != 0 {
+		t.Error("hidden tasks should be excluded")
+	}
+	if !config.includes(true) || config.includes(false) {
+		t.Error("only enabled tasks should be included")
+	}
+
+	config = newEnumConfig([]EnumOption{OnlyEnabled(), OnlyDisabled()})
+	if config.includes(true) || !config.includes(false) {
+		t.Error("the last option should win")
+	}
+}
@@ -17,27 +17,27 @@ func parseRunningTask(task *ole.IDispatch) (RunningTask, error) {
 
 	currentAction, err := oleutil.GetProperty(task, "CurrentAction")
 	if err != nil {
-		return RunningTask{}, getRunningTaskError(err)
+		return RunningTask{}, getRunningTaskError(err, "CurrentAction", "")
 	}
 	enginePID, err := oleutil.GetProperty(task, "EnginePid")
 	if err != nil {
-		return RunningTask{}, getRunningTaskError(err)
+		return RunningTask{}, getRunningTaskError(err, "EnginePid", "")
 	}
 	instanceGUID, err := oleutil.GetProperty(task, "InstanceGuid")
 	if err != nil {
-		return RunningTask{}, getRunningTaskError(err)
+		return RunningTask{}, getRunningTaskError(err, "InstanceGuid", "")
 	}
 	name, err := oleutil.GetProperty(task, "Name")
 	if err != nil {
-		return RunningTask{}, getRunningTaskError(err)
+		return RunningTask{}, getRunningTaskError(err, "Name", "")
 	}
 	path, err := oleutil.GetProperty(task, "Path")
 	if err != nil {
-		return RunningTask{}, getRunningTaskError(err)
+		return RunningTask{}, getRunningTaskError(err, "Path", "")
 	}
 	state, err := oleutil.GetProperty(task, "State")
 	if err != nil {
-		return RunningTask{}, getRunningTaskError(err)
+		return RunningTask{}, getRunningTaskError(err, "State", "")
 	}
 
 	runningTask := RunningTask{
@@ -54,6 +54,22 @@ func parseRunningTask(task *ole.IDispatch) (RunningTask, error) {
 }
 
 func parseRegisteredTask(task *ole.IDispatch) (RegisteredTask, string, error) {
+	registeredTask, path, err := parseRegisteredTaskInfo(task)
+	if err != nil {
+		return RegisteredTask{}, path, err
+	}
+
+	registeredTask.Definition, err = parseRegisteredTaskDefinition(task)
+	if err != nil {
+		return RegisteredTask{}, path, err
+	}
+
+	return registeredTask, path, nil
+}
+
+// parseRegisteredTaskInfo parses the properties of a registered task that are
+// cheap to read, leaving its Definition unset.
+func parseRegisteredTaskInfo(task *ole.IDispatch) (RegisteredTask, string, error) {
 	var err error
 
 	nameVar, err := oleutil.GetProperty(task, "Name")
@@ -104,22 +120,39 @@ func parseRegisteredTask(task *ole.IDispatch) (RegisteredTask, string, error) {
 	}
 	lastTaskResult := TaskResult(lastTaskResultVar.Val)
 
+	registeredTask := RegisteredTask{
+		taskObj:        task,
+		Name:           name,
+		Path:           path,
+		Enabled:        enabled,
+		State:          state,
+		MissedRuns:     missedRuns,
+		NextRunTime:    nextRunTime,
+		LastRunTime:    lastRunTime,
+		LastTaskResult: lastTaskResult,
+	}
+
+	return registeredTask, path, nil
+}
+
+// parseRegisteredTaskDefinition parses the definition of a registered task.
+func parseRegisteredTaskDefinition(task *ole.IDispatch) (Definition, error) {
 	definitionVar, err := oleutil.GetProperty(task, "Definition")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	definition := definitionVar.ToIDispatch()
 	defer definition.Release()
 	actionsVar, err := oleutil.GetProperty(definition, "Actions")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	actions := actionsVar.ToIDispatch()
 	defer actions.Release()
 
 	contextVar, err := oleutil.GetProperty(actions, "Context")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	context := contextVar.ToString()
 
@@ -138,49 +171,52 @@ func parseRegisteredTask(task *ole.IDispatch) (RegisteredTask, string, error) {
 		return nil
 	})
 	if err != nil {
-		return RegisteredTask{}, path, fmt.Errorf("error parsing IAction object: %v", err)
+		return Definition{}, fmt.Errorf("error parsing IAction object: %v", err)
 	}
 
 	principalVar, err := oleutil.GetProperty(definition, "Principal")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	principal := principalVar.ToIDispatch()
 
 	xmlTextVar, err := oleutil.GetProperty(definition, "XmlText")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	xmlText := xmlTextVar.ToString()
 
 	defer principal.Release()
-	taskPrincipal := parsePrincipal(principal)
+	taskPrincipal, err := parsePrincipal(principal)
+	if err != nil {
+		return Definition{}, fmt.Errorf("error parsing IPrincipal object: %v", err)
+	}
 
 	regInfoVar, err := oleutil.GetProperty(definition, "RegistrationInfo")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	regInfo := regInfoVar.ToIDispatch()
 	defer regInfo.Release()
 	registrationInfo, err := parseRegistrationInfo(regInfo)
 	if err != nil {
-		return RegisteredTask{}, path, fmt.Errorf("error parsing IRegistrationInfo object: %v", err)
+		return Definition{}, fmt.Errorf("error parsing IRegistrationInfo object: %v", err)
 	}
 
 	settingsVar, err := oleutil.GetProperty(definition, "Settings")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	settings := settingsVar.ToIDispatch()
 	defer settings.Release()
 	taskSettings, err := parseTaskSettings(settings)
 	if err != nil {
-		return RegisteredTask{}, path, fmt.Errorf("error parsing ITaskSettings object: %v", err)
+		return Definition{}, fmt.Errorf("error parsing ITaskSettings object: %v", err)
 	}
 
 	triggersVar, err := oleutil.GetProperty(definition, "Triggers")
 	if err != nil {
-		return RegisteredTask{}, "", err
+		return Definition{}, err
 	}
 	triggers := triggersVar.ToIDispatch()
 	defer triggers.Release()
@@ -199,7 +235,7 @@ func parseRegisteredTask(task *ole.IDispatch) (RegisteredTask, string, error) {
 		return nil
 	})
 	if err != nil {
-		return RegisteredTask{}, path, fmt.Errorf("error parsing ITrigger object: %v", err)
+		return Definition{}, fmt.Errorf("error parsing ITrigger object: %v", err)
 	}
 
 	taskDef := Definition{
@@ -212,20 +248,7 @@ func parseRegisteredTask(task *ole.IDispatch) (RegisteredTask, string, error) {
 		XMLText:          xmlText,
 	}
 
-	registeredTask := RegisteredTask{
-		taskObj:        task,
-		Name:           name,
-		Path:           path,
-		Definition:     taskDef,
-		Enabled:        enabled,
-		State:          state,
-		MissedRuns:     missedRuns,
-		NextRunTime:    nextRunTime,
-		LastRunTime:    lastRunTime,
-		LastTaskResult: lastTaskResult,
-	}
-
-	return registeredTask, path, nil
+	return taskDef, nil
 }
 
 func parseTaskAction(action *ole.IDispatch) (Action, error) {
@@ -257,12 +280,62 @@ func parseTaskAction(action *ole.IDispatch) (Action, error) {
 		}
 
 		return comHandlerAction, nil
+	case TASK_ACTION_SEND_EMAIL:
+		headerFieldsObj := oleutil.MustGetProperty(action, "HeaderFields").ToIDispatch()
+		defer headerFieldsObj.Release()
+
+		headerFields := make(map[string]string)
+		err := oleutil.ForEach(headerFieldsObj, func(v *ole.VARIANT) error {
+			headerField := v.ToIDispatch()
+			defer headerField.Release()
+
+			name := oleutil.MustGetProperty(headerField, "Name").ToString()
+			value := oleutil.MustGetProperty(headerField, "Value").ToString()
+			headerFields[name] = value
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error parsing IEmailAction object: error parsing HeaderFields field: %v", err)
+		}
+
+		var attachments []string
+		attachmentsVar := oleutil.MustGetProperty(action, "Attachments")
+		if attachmentsVar.VT&ole.VT_ARRAY != 0 {
+			if array := attachmentsVar.ToArray(); array != nil {
+				attachments = array.ToStringArray()
+			}
+		}
+
+		emailAction := EmailAction{
+			ID:           id,
+			Server:       oleutil.MustGetProperty(action, "Server").ToString(),
+			Subject:      oleutil.MustGetProperty(action, "Subject").ToString(),
+			To:           oleutil.MustGetProperty(action, "To").ToString(),
+			Cc:           oleutil.MustGetProperty(action, "Cc").ToString(),
+			Bcc:          oleutil.MustGetProperty(action, "Bcc").ToString(),
+			ReplyTo:      oleutil.MustGetProperty(action, "ReplyTo").ToString(),
+			From:         oleutil.MustGetProperty(action, "From").ToString(),
+			HeaderFields: headerFields,
+			Body:         oleutil.MustGetProperty(action, "Body").ToString(),
+			Attachments:  attachments,
+		}
+
+		return emailAction, nil
+	case TASK_ACTION_SHOW_MESSAGE:
+		showMessageAction := ShowMessageAction{
+			ID:          id,
+			Title:       oleutil.MustGetProperty(action, "Title").ToString(),
+			MessageBody: oleutil.MustGetProperty(action, "MessageBody").ToString(),
+		}
+
+		return showMessageAction, nil
 	default:
 		return nil, errors.New("unsupported IAction type")
 	}
 }
 
-func parsePrincipal(principleObj *ole.IDispatch) Principal {
+func parsePrincipal(principleObj *ole.IDispatch) (Principal, error) {
 	name := oleutil.MustGetProperty(principleObj, "DisplayName").ToString()
 	groupID := oleutil.MustGetProperty(principleObj, "GroupId").ToString()
 	id := oleutil.MustGetProperty(principleObj, "Id").ToString()
@@ -271,15 +344,33 @@ func parsePrincipal(principleObj *ole.IDispatch) Principal {
 	userID := oleutil.MustGetProperty(principleObj, "UserId").ToString()
 
 	principle := Principal{
-		Name:      name,
-		GroupID:   groupID,
-		ID:        id,
-		LogonType: logonType,
-		RunLevel:  runLevel,
-		UserID:    userID,
+		Name:                name,
+		GroupID:             groupID,
+		ID:                  id,
+		LogonType:           logonType,
+		RunLevel:            runLevel,
+		UserID:              userID,
+		ProcessTokenSidType: TASK_PROCESSTOKENSID_DEFAULT,
+	}
+
+	// IPrincipal2 doesn't exist before Windows 7
+	principal2Obj, err := principleObj.QueryInterface(ole.NewGUID("{248919ae-e345-4a6d-8aeb-e0d3165c904e}"))
+	if err != nil {
+		return principle, nil
+	}
+	defer principal2Obj.Release()
+
+	principle.ProcessTokenSidType = processTokenSidTypeFromAPI(uint(oleutil.MustGetProperty(principal2Obj, "ProcessTokenSidType").Val))
+	privilegeCount := int(oleutil.MustGetProperty(principal2Obj, "RequiredPrivilegeCount").Val)
+	for i := 0; i < privilegeCount; i++ {
+		privilege, err := oleutil.GetProperty(principal2Obj, "RequiredPrivilege", i)
+		if err != nil {
+			return Principal{}, fmt.Errorf("error parsing RequiredPrivilege field: %v", getTaskSchedulerError(err))
+		}
+		principle.RequiredPrivileges = append(principle.RequiredPrivileges, privilege.ToString())
 	}
 
-	return principle
+	return principle, nil
 }
 
 func parseRegistrationInfo(regInfo *ole.IDispatch) (*RegistrationInfo, error) {
@@ -313,7 +404,10 @@ func parseTaskSettings(settings *ole.IDispatch) (*TaskSettings, error) {
 	allowDemandStart := oleutil.MustGetProperty(settings, "AllowDemandStart").Value().(bool)
 	allowHardTerminate := oleutil.MustGetProperty(settings, "AllowHardTerminate").Value().(bool)
 	compatibility := TaskCompatibility(oleutil.MustGetProperty(settings, "Compatibility").Val)
-	deleteExpiredTaskAfter := oleutil.MustGetProperty(settings, "DeleteExpiredTaskAfter").ToString()
+	deleteExpiredTaskAfter, err := StringToPeriod(oleutil.MustGetProperty(settings, "DeleteExpiredTaskAfter").ToString())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing DeleteExpiredTaskAfter field: %v", err)
+	}
 	dontStartOnBatteries := oleutil.MustGetProperty(settings, "DisallowStartIfOnBatteries").Value().(bool)
 	enabled := oleutil.MustGetProperty(settings, "Enabled").Value().(bool)
 	timeLimit, err := StringToPeriod(oleutil.MustGetProperty(settings, "ExecutionTimeLimit").ToString())
@@ -337,39 +431,40 @@ func parseTaskSettings(settings *ole.IDispatch) (*TaskSettings, error) {
 
 	var maintenanceSettings *MaintenanceSettings
 
+	// the MaintenanceSettings property doesn't exist before Windows 8, and is
+	// nil if automatic maintenance was never configured for the task
 	maintenanceProperty, err := oleutil.GetProperty(settings, "MaintenanceSettings")
-	if err == nil {
-		maintenanceSettings = &MaintenanceSettings{}
-
-		parsedSettings := maintenanceProperty.ToIDispatch()
+	if err == nil && maintenanceProperty.ToIDispatch() != nil {
+		maintenanceObj := maintenanceProperty.ToIDispatch()
+		defer maintenanceObj.Release()
 
-		deadlineProperty, err := oleutil.GetProperty(parsedSettings, "Deadline")
-		if err == nil {
-			deadline, err := StringToPeriod(deadlineProperty.ToString())
-			if err == nil {
-				maintenanceSettings.Deadline = deadline
-			}
+		period, err := StringToPeriod(oleutil.MustGetProperty(maintenanceObj, "Period").ToString())
+		if err != nil {
+			return nil, fmt.Errorf("error parsing maintenance Period field: %v", err)
 		}
-
-		exclusiveProperty, err := oleutil.GetProperty(parsedSettings, "Exclusive")
-		if err == nil {
-			exclusive, ok := exclusiveProperty.Value().(bool)
-			if ok {
-				maintenanceSettings.Exclusive = exclusive
-			}
+		deadline, err := StringToPeriod(oleutil.MustGetProperty(maintenanceObj, "Deadline").ToString())
+		if err != nil {
+			return nil, fmt.Errorf("error parsing maintenance Deadline field: %v", err)
 		}
-
-		periodProperty, err := oleutil.GetProperty(parsedSettings, "Period")
-		if err == nil {
-			period, err := StringToPeriod(periodProperty.ToString())
-			if err == nil {
-				maintenanceSettings.Period = period
+		exclusive := oleutil.MustGetProperty(maintenanceObj, "Exclusive").Value().(bool)
+
+		// a period is required for automatic maintenance to be enabled
+		if !period.IsZero() {
+			maintenanceSettings = &MaintenanceSettings{
+				Deadline:  deadline,
+				Exclusive: exclusive,
+				Period:    period,
 			}
 		}
 	}
 
 	multipleInstances := TaskInstancesPolicy(oleutil.MustGetProperty(settings, "MultipleInstances").Val)
 
+	// these properties don't exist before Windows 7 or Windows 8
+	disallowStartOnRemoteAppSession := getOptionalBoolProperty(settings, "DisallowStartOnRemoteAppSession")
+	useUnifiedSchedulingEngine := getOptionalBoolProperty(settings, "UseUnifiedSchedulingEngine")
+	volatile := getOptionalBoolProperty(settings, "Volatile")
+
 	networkSettings := oleutil.MustGetProperty(settings, "NetworkSettings").ToIDispatch()
 	defer networkSettings.Release()
 	id := oleutil.MustGetProperty(networkSettings, "Id").ToString()
@@ -400,26 +495,29 @@ func parseTaskSettings(settings *ole.IDispatch) (*TaskSettings, error) {
 	}
 
 	taskSettings := &TaskSettings{
-		AllowDemandStart:          allowDemandStart,
-		AllowHardTerminate:        allowHardTerminate,
-		Compatibility:             compatibility,
-		DeleteExpiredTaskAfter:    deleteExpiredTaskAfter,
-		DontStartOnBatteries:      dontStartOnBatteries,
-		Enabled:                   enabled,
-		TimeLimit:                 timeLimit,
-		Hidden:                    hidden,
-		IdleSettings:              idleTaskSettings,
-		MaintenanceSettings:       maintenanceSettings,
-		MultipleInstances:         multipleInstances,
-		NetworkSettings:           networkTaskSettings,
-		Priority:                  priority,
-		RestartCount:              restartCount,
-		RestartInterval:           restartInterval,
-		RunOnlyIfIdle:             runOnlyIfIdle,
-		RunOnlyIfNetworkAvailable: runOnlyIfNetworkAvailable,
-		StartWhenAvailable:        startWhenAvailable,
-		StopIfGoingOnBatteries:    stopIfGoingOnBatteries,
-		WakeToRun:                 wakeToRun,
+		AllowDemandStart:                allowDemandStart,
+		AllowHardTerminate:              allowHardTerminate,
+		Compatibility:                   compatibility,
+		DeleteExpiredTaskAfter:          deleteExpiredTaskAfter,
+		DisallowStartOnRemoteAppSession: disallowStartOnRemoteAppSession,
+		DontStartOnBatteries:            dontStartOnBatteries,
+		Enabled:                         enabled,
+		TimeLimit:                       timeLimit,
+		Hidden:                          hidden,
+		IdleSettings:                    idleTaskSettings,
+		MaintenanceSettings:             maintenanceSettings,
+		MultipleInstances:               multipleInstances,
+		NetworkSettings:                 networkTaskSettings,
+		Priority:                        priority,
+		RestartCount:                    restartCount,
+		RestartInterval:                 restartInterval,
+		RunOnlyIfIdle:                   runOnlyIfIdle,
+		RunOnlyIfNetworkAvailable:       runOnlyIfNetworkAvailable,
+		StartWhenAvailable:              startWhenAvailable,
+		StopIfGoingOnBatteries:          stopIfGoingOnBatteries,
+		UseUnifiedSchedulingEngine:      useUnifiedSchedulingEngine,
+		Volatile:                        volatile,
+		WakeToRun:                       wakeToRun,
 	}
 
 	return taskSettings, nil
@@ -427,7 +525,8 @@ func parseTaskSettings(settings *ole.IDispatch) (*TaskSettings, error) {
 
 func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 	enabled := oleutil.MustGetProperty(trigger, "Enabled").Value().(bool)
-	endBoundary, err := TaskDateToTime(oleutil.MustGetProperty(trigger, "EndBoundary").ToString())
+	endBoundaryText := oleutil.MustGetProperty(trigger, "EndBoundary").ToString()
+	endBoundary, err := TaskDateToTime(endBoundaryText)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing EndBoundary field: %v", err)
 	}
@@ -449,7 +548,8 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 	}
 	stopAtDurationEnd := oleutil.MustGetProperty(repetition, "StopAtDurationEnd").Value().(bool)
 
-	startBoundary, err := TaskDateToTime(oleutil.MustGetProperty(trigger, "StartBoundary").ToString())
+	startBoundaryText := oleutil.MustGetProperty(trigger, "StartBoundary").ToString()
+	startBoundary, err := TaskDateToTime(startBoundaryText)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing StartBoundary field: %v", err)
 	}
@@ -465,7 +565,8 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 			RepetitionInterval: interval,
 			StopAtDurationEnd:  stopAtDurationEnd,
 		},
-		StartBoundary: startBoundary,
+		StartBoundary:              startBoundary,
+		SynchronizeAcrossTimeZones: boundariesHaveTimeZone(startBoundaryText, endBoundaryText),
 	}
 
 	switch triggerType {
@@ -505,7 +606,7 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 		defer valueQueriesObj.Release()
 
 		valQueryMap := make(map[string]string)
-		oleutil.ForEach(valueQueriesObj, func(v *ole.VARIANT) error {
+		err = oleutil.ForEach(valueQueriesObj, func(v *ole.VARIANT) error {
 			valueQuery := v.ToIDispatch()
 			defer valueQuery.Release()
 
@@ -516,6 +617,9 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 
 			return nil
 		})
+		if err != nil {
+			return nil, fmt.Errorf("error parsing IEventTrigger object: error parsing ValueQueries field: %v", err)
+		}
 
 		eventTrigger := EventTrigger{
 			TaskTrigger:  taskTriggerObj,
@@ -572,14 +676,14 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error parsing IMonthlyTrigger object: error parsing RandomDelay field: %v", err)
 		}
-		runOnLastWeekOfMonth := oleutil.MustGetProperty(trigger, "RunOnLastDayOfMonth").Value().(bool)
+		runOnLastDayOfMonth := oleutil.MustGetProperty(trigger, "RunOnLastDayOfMonth").Value().(bool)
 
 		monthlyTrigger := MonthlyTrigger{
-			TaskTrigger:          taskTriggerObj,
-			DaysOfMonth:          daysOfMonth,
-			MonthsOfYear:         monthsOfYear,
-			RandomDelay:          randomDelay,
-			RunOnLastWeekOfMonth: runOnLastWeekOfMonth,
+			TaskTrigger:         taskTriggerObj,
+			DaysOfMonth:         daysOfMonth,
+			MonthsOfYear:        monthsOfYear,
+			RandomDelay:         randomDelay,
+			RunOnLastDayOfMonth: runOnLastDayOfMonth,
 		}
 
 		return monthlyTrigger, nil
@@ -624,7 +728,7 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 	case TASK_TRIGGER_SESSION_STATE_CHANGE:
 		delay, err := StringToPeriod(oleutil.MustGetProperty(trigger, "Delay").ToString())
 		if err != nil {
-			return nil, fmt.Errorf("error parsing ISessionStateChangeTrigger object: error parsing RandomDelay field: %v", err)
+			return nil, fmt.Errorf("error parsing ISessionStateChangeTrigger object: error parsing Delay field: %v", err)
 		}
 		stateChange := TaskSessionStateChangeType(oleutil.MustGetProperty(trigger, "StateChange").Val)
 		userID := oleutil.MustGetProperty(trigger, "UserId").ToString()
@@ -633,7 +737,7 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 			TaskTrigger: taskTriggerObj,
 			Delay:       delay,
 			StateChange: stateChange,
-			UserId:      userID,
+			UserID:      userID,
 		}
 
 		return sessionStateChangeTrigger, nil
@@ -648,3 +752,15 @@ func parseTaskTrigger(trigger *ole.IDispatch) (Trigger, error) {
 		return nil, errors.New("unsupported ITrigger type")
 	}
 }
+
+// getOptionalBoolProperty returns the value of a property that doesn't exist on
+// older versions of Windows, or false if it doesn't exist.
+func getOptionalBoolProperty(obj *ole.IDispatch, name string) bool {
+	property, err := oleutil.GetProperty(obj, name)
+	if err != nil {
+		return false
+	}
+	value, _ := property.Value().(bool)
+
+	return value
+}
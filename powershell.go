@@ -0,0 +1,756 @@
+package taskmaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rickb777/date/period"
+)
+
+// PowerShellTask is a registered task in the shape of the MSFT_ScheduledTask
+// objects of the ScheduledTasks PowerShell module, as encoded by
+// `Get-ScheduledTask | ConvertTo-Json`. Its MarshalJSON and UnmarshalJSON
+// methods convert between that shape and a Definition, so that tasks can be
+// exchanged with PowerShell tooling.
+//
+// Enums such as LogonType are encoded as numbers, and decoded from either numbers
+// or the names that ConvertTo-Json -EnumsAsStrings produces. The type of each
+// action and trigger is identified by its CimClass, which ConvertTo-Json encodes
+// either as a string such as "Root/Microsoft/Windows/TaskScheduler:MSFT_TaskDailyTrigger"
+// or, with a higher -Depth, as an object with a CimClassName field. The
+// ScheduledTasks module doesn't support monthly triggers, email and message
+// actions, so definitions that use them can't be converted.
+// https://docs.microsoft.com/en-us/powershell/module/scheduledtasks/get-scheduledtask
+type PowerShellTask struct {
+	Path       string    // the path of the task, which is encoded as the TaskPath and TaskName fields
+	State      TaskState // the state of the task. It's ignored by Register-ScheduledTask
+	Definition Definition
+}
+
+// ParsePowerShellJSON decodes the output of `Get-ScheduledTask | ConvertTo-Json`,
+// which is a single object if one task was selected, and an array otherwise.
+func ParsePowerShellJSON(data []byte) ([]PowerShellTask, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var tasks []PowerShellTask
+		if err := json.Unmarshal(data, &tasks); err != nil {
+			return nil, err
+		}
+		return tasks, nil
+	}
+
+	var task PowerShellTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+
+	return []PowerShellTask{task}, nil
+}
+
+const (
+	psCimNamespace = "Root/Microsoft/Windows/TaskScheduler"
+
+	psExecActionClass                = "MSFT_TaskExecAction"
+	psComHandlerActionClass          = "MSFT_TaskComHandlerAction"
+	psBootTriggerClass               = "MSFT_TaskBootTrigger"
+	psDailyTriggerClass              = "MSFT_TaskDailyTrigger"
+	psEventTriggerClass              = "MSFT_TaskEventTrigger"
+	psIdleTriggerClass               = "MSFT_TaskIdleTrigger"
+	psLogonTriggerClass              = "MSFT_TaskLogonTrigger"
+	psRegistrationTriggerClass       = "MSFT_TaskRegistrationTrigger"
+	psSessionStateChangeTriggerClass = "MSFT_TaskSessionStateChangeTrigger"
+	psTimeTriggerClass               = "MSFT_TaskTimeTrigger"
+	psWeeklyTriggerClass             = "MSFT_TaskWeeklyTrigger"
+)
+
+// the names of the values of the enums of the ScheduledTasks module, indexed by value
+var (
+	psCompatibilityNames       = []string{"At", "V1", "Vista", "Win7", "Win8"}
+	psLogonTypeNames           = []string{"None", "Password", "S4U", "Interactive", "Group", "ServiceAccount", "InteractiveOrPassword"}
+	psMultipleInstancesNames   = []string{"Parallel", "Queue", "IgnoreNew", "StopExisting"}
+	psProcessTokenSidTypeNames = []string{"None", "Unrestricted", "Default"}
+	psRunLevelNames            = []string{"Limited", "Highest"}
+	psStateNames               = []string{"Unknown", "Disabled", "Queued", "Ready", "Running"}
+)
+
+type psTaskJSON struct {
+	Actions            []psActionJSON  `json:"Actions"`
+	Author             *string         `json:"Author"`
+	Date               *string         `json:"Date"`
+	Description        *string         `json:"Description"`
+	Documentation      *string         `json:"Documentation"`
+	Principal          psPrincipalJSON `json:"Principal"`
+	SecurityDescriptor *string         `json:"SecurityDescriptor"`
+	Settings           psSettingsJSON  `json:"Settings"`
+	Source             *string         `json:"Source"`
+	State              psEnum          `json:"State"`
+	TaskName           string          `json:"TaskName"`
+	TaskPath           string          `json:"TaskPath"`
+	Triggers           []psTriggerJSON `json:"Triggers"`
+	URI                *string         `json:"URI"`
+	Version            *string         `json:"Version"`
+}
+
+type psActionJSON struct {
+	CimClass         psCimClass `json:"CimClass"`
+	ID               *string    `json:"Id"`
+	Arguments        *string    `json:"Arguments,omitempty"`
+	Execute          *string    `json:"Execute,omitempty"`
+	WorkingDirectory *string    `json:"WorkingDirectory,omitempty"`
+	ClassID          *string    `json:"ClassId,omitempty"`
+	Data             *string    `json:"Data,omitempty"`
+}
+
+type psPrincipalJSON struct {
+	DisplayName         *string  `json:"DisplayName"`
+	GroupID             *string  `json:"GroupId"`
+	ID                  *string  `json:"Id"`
+	LogonType           psEnum   `json:"LogonType"`
+	RunLevel            psEnum   `json:"RunLevel"`
+	UserID              *string  `json:"UserId"`
+	ProcessTokenSidType psEnum   `json:"ProcessTokenSidType"`
+	RequiredPrivilege   []string `json:"RequiredPrivilege"`
+}
+
+type psSettingsJSON struct {
+	AllowDemandStart                bool                       `json:"AllowDemandStart"`
+	AllowHardTerminate              bool                       `json:"AllowHardTerminate"`
+	Compatibility                   psEnum                     `json:"Compatibility"`
+	DeleteExpiredTaskAfter          *string                    `json:"DeleteExpiredTaskAfter"`
+	DisallowStartIfOnBatteries      bool                       `json:"DisallowStartIfOnBatteries"`
+	Enabled                         bool                       `json:"Enabled"`
+	ExecutionTimeLimit              *string                    `json:"ExecutionTimeLimit"`
+	Hidden                          bool                       `json:"Hidden"`
+	IdleSettings                    psIdleSettingsJSON         `json:"IdleSettings"`
+	MultipleInstances               psEnum                     `json:"MultipleInstances"`
+	NetworkSettings                 psNetworkSettingsJSON      `json:"NetworkSettings"`
+	Priority                        uint                       `json:"Priority"`
+	RestartCount                    uint                       `json:"RestartCount"`
+	RestartInterval                 *string                    `json:"RestartInterval"`
+	RunOnlyIfIdle                   bool                       `json:"RunOnlyIfIdle"`
+	RunOnlyIfNetworkAvailable       bool                       `json:"RunOnlyIfNetworkAvailable"`
+	StartWhenAvailable              bool                       `json:"StartWhenAvailable"`
+	StopIfGoingOnBatteries          bool                       `json:"StopIfGoingOnBatteries"`
+	WakeToRun                       bool                       `json:"WakeToRun"`
+	DisallowStartOnRemoteAppSession bool                       `json:"DisallowStartOnRemoteAppSession"`
+	UseUnifiedSchedulingEngine      bool                       `json:"UseUnifiedSchedulingEngine"`
+	MaintenanceSettings             *psMaintenanceSettingsJSON `json:"MaintenanceSettings"`
+	Volatile                        bool                       `json:"volatile"`
+}
+
+type psIdleSettingsJSON struct {
+	IdleDuration  *string `json:"IdleDuration"`
+	RestartOnIdle bool    `json:"RestartOnIdle"`
+	StopOnIdleEnd bool    `json:"StopOnIdleEnd"`
+	WaitTimeout   *string `json:"WaitTimeout"`
+}
+
+type psNetworkSettingsJSON struct {
+	ID   *string `json:"Id"`
+	Name *string `json:"Name"`
+}
+
+type psMaintenanceSettingsJSON struct {
+	Deadline  *string `json:"Deadline"`
+	Exclusive bool    `json:"Exclusive"`
+	Period    *string `json:"Period"`
+}
+
+type psTriggerJSON struct {
+	CimClass           psCimClass        `json:"CimClass"`
+	Enabled            *bool             `json:"Enabled"`
+	EndBoundary        *string           `json:"EndBoundary"`
+	ExecutionTimeLimit *string           `json:"ExecutionTimeLimit"`
+	ID                 *string           `json:"Id"`
+	Repetition         psRepetitionJSON  `json:"Repetition"`
+	StartBoundary      *string           `json:"StartBoundary"`
+	DaysInterval       *uint             `json:"DaysInterval,omitempty"`
+	DaysOfWeek         *uint             `json:"DaysOfWeek,omitempty"`
+	WeeksInterval      *uint             `json:"WeeksInterval,omitempty"`
+	RandomDelay        *string           `json:"RandomDelay,omitempty"`
+	Delay              *string           `json:"Delay,omitempty"`
+	UserID             *string           `json:"UserId,omitempty"`
+	Subscription       *string           `json:"Subscription,omitempty"`
+	ValueQueries       []psNameValueJSON `json:"ValueQueries,omitempty"`
+	StateChange        *uint             `json:"StateChange,omitempty"`
+}
+
+type psRepetitionJSON struct {
+	Duration          *string `json:"Duration"`
+	Interval          *string `json:"Interval"`
+	StopAtDurationEnd bool    `json:"StopAtDurationEnd"`
+}
+
+type psNameValueJSON struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// psCimClass is the name of the CIM class of an object, such as MSFT_TaskExecAction.
+type psCimClass string
+
+func (c psCimClass) MarshalJSON() ([]byte, error) {
+	return json.Marshal(psCimNamespace + ":" + string(c))
+}
+
+func (c *psCimClass) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var name string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+	} else {
+		var class struct {
+			CimClassName string
+		}
+		if err := json.Unmarshal(data, &class); err != nil {
+			return err
+		}
+		name = class.CimClassName
+	}
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		name = name[i+1:]
+	}
+	*c = psCimClass(name)
+
+	return nil
+}
+
+// psEnum is the value of an enum, which is encoded as a number, and decoded
+// from either a number or the name of a value.
+type psEnum struct {
+	set   bool
+	value uint
+	name  string
+}
+
+func newPSEnum(value uint) psEnum {
+	return psEnum{set: true, value: value}
+}
+
+func (e psEnum) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.value)
+}
+
+func (e *psEnum) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	e.set = true
+
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &e.name); err != nil {
+			return err
+		}
+		if value, err := strconv.ParseUint(e.name, 10, 32); err == nil {
+			e.value, e.name = uint(value), ""
+		}
+		return nil
+	}
+
+	return json.Unmarshal(data, &e.value)
+}
+
+// resolve returns the value of the enum, looking up its name in names if it was
+// decoded from a name, or def if it wasn't set.
+func (e psEnum) resolve(names []string, field string, def uint) (uint, error) {
+	if !e.set {
+		return def, nil
+	}
+	if e.name == "" {
+		return e.value, nil
+	}
+	for value, name := range names {
+		if strings.EqualFold(name, e.name) {
+			return uint(value), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown %s %q", field, e.name)
+}
+
+func (t PowerShellTask) MarshalJSON() ([]byte, error) {
+	aux, err := newPSTaskJSON(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(aux)
+}
+
+func (t *PowerShellTask) UnmarshalJSON(data []byte) error {
+	var aux psTaskJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	task, err := aux.toPowerShellTask()
+	if err != nil {
+		path := aux.TaskPath + aux.TaskName
+		return fmt.Errorf("error decoding task %s: %v", path, err)
+	}
+	*t = task
+
+	return nil
+}
+
+func newPSTaskJSON(t PowerShellTask) (psTaskJSON, error) {
+	d := t.Definition
+	folder, name := splitTaskPath(t.Path)
+	if !strings.HasSuffix(folder, `\`) {
+		folder += `\`
+	}
+
+	aux := psTaskJSON{
+		Author:             psString(d.RegistrationInfo.Author),
+		Date:               psString(TimeToTaskDate(d.RegistrationInfo.Date)),
+		Description:        psString(d.RegistrationInfo.Description),
+		Documentation:      psString(d.RegistrationInfo.Documentation),
+		SecurityDescriptor: psString(d.RegistrationInfo.SecurityDescriptor),
+		Source:             psString(d.RegistrationInfo.Source),
+		State:              newPSEnum(uint(t.State)),
+		TaskName:           name,
+		TaskPath:           folder,
+		URI:                psString(d.RegistrationInfo.URI),
+		Version:            psString(d.RegistrationInfo.Version),
+		Principal: psPrincipalJSON{
+			DisplayName:         psString(d.Principal.Name),
+			GroupID:             psString(d.Principal.GroupID),
+			ID:                  psString(d.Principal.ID),
+			LogonType:           newPSEnum(uint(d.Principal.LogonType)),
+			RunLevel:            newPSEnum(uint(d.Principal.RunLevel)),
+			UserID:              psString(d.Principal.UserID),
+			ProcessTokenSidType: newPSEnum(d.Principal.ProcessTokenSidType.apiValue()),
+			RequiredPrivilege:   d.Principal.RequiredPrivileges,
+		},
+		Settings: newPSSettingsJSON(d.Settings),
+	}
+
+	for _, action := range d.Actions {
+		switch a := action.(type) {
+		case ExecAction:
+			aux.Actions = append(aux.Actions, psActionJSON{
+				CimClass:         psExecActionClass,
+				ID:               psString(a.ID),
+				Arguments:        psString(a.Args),
+				Execute:          psString(a.Path),
+				WorkingDirectory: psString(a.WorkingDir),
+			})
+		case ComHandlerAction:
+			aux.Actions = append(aux.Actions, psActionJSON{
+				CimClass: psComHandlerActionClass,
+				ID:       psString(a.ID),
+				ClassID:  psString(a.ClassID),
+				Data:     psString(a.Data),
+			})
+		default:
+			return psTaskJSON{}, fmt.Errorf("error encoding task %s: %s actions aren't supported by the ScheduledTasks module", t.Path, action.GetType())
+		}
+	}
+
+	for _, trigger := range d.Triggers {
+		triggerJSON, err := newPSTriggerJSON(trigger)
+		if err != nil {
+			return psTaskJSON{}, fmt.Errorf("error encoding task %s: %v", t.Path, err)
+		}
+		aux.Triggers = append(aux.Triggers, triggerJSON)
+	}
+
+	return aux, nil
+}
+
+func newPSSettingsJSON(s TaskSettings) psSettingsJSON {
+	settings := psSettingsJSON{
+		AllowDemandStart:           s.AllowDemandStart,
+		AllowHardTerminate:         s.AllowHardTerminate,
+		Compatibility:              newPSEnum(uint(s.Compatibility)),
+		DeleteExpiredTaskAfter:     psPeriod(s.DeleteExpiredTaskAfter),
+		DisallowStartIfOnBatteries: s.DontStartOnBatteries,
+		Enabled:                    s.Enabled,
+		ExecutionTimeLimit:         psPeriod(s.TimeLimit),
+		Hidden:                     s.Hidden,
+		IdleSettings: psIdleSettingsJSON{
+			IdleDuration:  psPeriod(s.IdleDuration),
+			RestartOnIdle: s.RestartOnIdle,
+			StopOnIdleEnd: s.StopOnIdleEnd,
+			WaitTimeout:   psPeriod(s.WaitTimeout),
+		},
+		MultipleInstances: newPSEnum(uint(s.MultipleInstances)),
+		NetworkSettings: psNetworkSettingsJSON{
+			ID:   psString(s.NetworkSettings.ID),
+			Name: psString(s.NetworkSettings.Name),
+		},
+		Priority:                        s.Priority,
+		RestartCount:                    s.RestartCount,
+		RestartInterval:                 psPeriod(s.RestartInterval),
+		RunOnlyIfIdle:                   s.RunOnlyIfIdle,
+		RunOnlyIfNetworkAvailable:       s.RunOnlyIfNetworkAvailable,
+		StartWhenAvailable:              s.StartWhenAvailable,
+		StopIfGoingOnBatteries:          s.StopIfGoingOnBatteries,
+		WakeToRun:                       s.WakeToRun,
+		DisallowStartOnRemoteAppSession: s.DisallowStartOnRemoteAppSession,
+		UseUnifiedSchedulingEngine:      s.UseUnifiedSchedulingEngine,
+		Volatile:                        s.Volatile,
+	}
+	if s.MaintenanceSettings != nil {
+		settings.MaintenanceSettings = &psMaintenanceSettingsJSON{
+			Deadline:  psPeriod(s.MaintenanceSettings.Deadline),
+			Exclusive: s.MaintenanceSettings.Exclusive,
+			Period:    psPeriod(s.MaintenanceSettings.Period),
+		}
+	}
+
+	return settings
+}
+
+func newPSTriggerJSON(trigger Trigger) (psTriggerJSON, error) {
+	enabled := trigger.GetEnabled()
+	aux := psTriggerJSON{
+		Enabled:            &enabled,
+		EndBoundary:        psString(boundaryToTaskDate(trigger.GetEndBoundary(), trigger.GetSynchronizeAcrossTimeZones())),
+		ExecutionTimeLimit: psPeriod(trigger.GetExecutionTimeLimit()),
+		ID:                 psString(trigger.GetID()),
+		Repetition: psRepetitionJSON{
+			Duration:          psPeriod(trigger.GetRepetitionDuration()),
+			Interval:          psPeriod(trigger.GetRepetitionInterval()),
+			StopAtDurationEnd: trigger.GetStopAtDurationEnd(),
+		},
+		StartBoundary: psString(boundaryToTaskDate(trigger.GetStartBoundary(), trigger.GetSynchronizeAcrossTimeZones())),
+	}
+
+	switch t := trigger.(type) {
+	case BootTrigger:
+		aux.CimClass = psBootTriggerClass
+		aux.Delay = psPeriod(t.Delay)
+	case DailyTrigger:
+		aux.CimClass = psDailyTriggerClass
+		aux.DaysInterval = psUint(uint(t.DayInterval))
+		aux.RandomDelay = psPeriod(t.RandomDelay)
+	case EventTrigger:
+		aux.CimClass = psEventTriggerClass
+		aux.Delay = psPeriod(t.Delay)
+		aux.Subscription = psString(t.Subscription)
+		names := make([]string, 0, len(t.ValueQueries))
+		for name := range t.ValueQueries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			aux.ValueQueries = append(aux.ValueQueries, psNameValueJSON{Name: name, Value: t.ValueQueries[name]})
+		}
+	case IdleTrigger:
+		aux.CimClass = psIdleTriggerClass
+	case LogonTrigger:
+		aux.CimClass = psLogonTriggerClass
+		aux.Delay = psPeriod(t.Delay)
+		aux.UserID = psString(t.UserID)
+	case RegistrationTrigger:
+		aux.CimClass = psRegistrationTriggerClass
+		aux.Delay = psPeriod(t.Delay)
+	case SessionStateChangeTrigger:
+		aux.CimClass = psSessionStateChangeTriggerClass
+		aux.Delay = psPeriod(t.Delay)
+		aux.StateChange = psUint(uint(t.StateChange))
+		aux.UserID = psString(t.UserID)
+	case TimeTrigger:
+		aux.CimClass = psTimeTriggerClass
+		aux.RandomDelay = psPeriod(t.RandomDelay)
+	case WeeklyTrigger:
+		aux.CimClass = psWeeklyTriggerClass
+		aux.DaysOfWeek = psUint(uint(t.DaysOfWeek))
+		aux.RandomDelay = psPeriod(t.RandomDelay)
+		aux.WeeksInterval = psUint(uint(t.WeekInterval))
+	default:
+		return psTriggerJSON{}, fmt.Errorf("%s triggers aren't supported by the ScheduledTasks module", trigger.GetType())
+	}
+
+	return aux, nil
+}
+
+func (aux psTaskJSON) toPowerShellTask() (PowerShellTask, error) {
+	task := PowerShellTask{
+		Path: strings.TrimSuffix(aux.TaskPath, `\`) + `\` + aux.TaskName,
+	}
+	state, err := aux.State.resolve(psStateNames, "State", uint(TASK_STATE_UNKNOWN))
+	if err != nil {
+		return PowerShellTask{}, err
+	}
+	task.State = TaskState(state)
+
+	d := &task.Definition
+	d.RegistrationInfo = RegistrationInfo{
+		Author:             psValue(aux.Author),
+		Description:        psValue(aux.Description),
+		Documentation:      psValue(aux.Documentation),
+		SecurityDescriptor: psValue(aux.SecurityDescriptor),
+		Source:             psValue(aux.Source),
+		URI:                psValue(aux.URI),
+		Version:            psValue(aux.Version),
+	}
+	if d.RegistrationInfo.Date, err = TaskDateToTime(psValue(aux.Date)); err != nil {
+		return PowerShellTask{}, fmt.Errorf("error parsing Date: %v", err)
+	}
+
+	if d.Principal, err = aux.Principal.toPrincipal(); err != nil {
+		return PowerShellTask{}, err
+	}
+	if d.Settings, err = aux.Settings.toTaskSettings(); err != nil {
+		return PowerShellTask{}, err
+	}
+
+	for i, action := range aux.Actions {
+		switch action.CimClass {
+		case psExecActionClass:
+			d.Actions = append(d.Actions, ExecAction{
+				ID:         psValue(action.ID),
+				Path:       psValue(action.Execute),
+				Args:       psValue(action.Arguments),
+				WorkingDir: psValue(action.WorkingDirectory),
+			})
+		case psComHandlerActionClass:
+			d.Actions = append(d.Actions, ComHandlerAction{
+				ID:      psValue(action.ID),
+				ClassID: psValue(action.ClassID),
+				Data:    psValue(action.Data),
+			})
+		default:
+			return PowerShellTask{}, fmt.Errorf("action %d has unsupported CimClass %q", i, action.CimClass)
+		}
+	}
+
+	for i, triggerJSON := range aux.Triggers {
+		trigger, err := triggerJSON.toTrigger()
+		if err != nil {
+			return PowerShellTask{}, fmt.Errorf("error decoding trigger %d: %v", i, err)
+		}
+		d.Triggers = append(d.Triggers, trigger)
+	}
+
+	return task, nil
+}
+
+func (aux psPrincipalJSON) toPrincipal() (Principal, error) {
+	logonType, err := aux.LogonType.resolve(psLogonTypeNames, "LogonType", uint(TASK_LOGON_NONE))
+	if err != nil {
+		return Principal{}, err
+	}
+	runLevel, err := aux.RunLevel.resolve(psRunLevelNames, "RunLevel", uint(TASK_RUNLEVEL_LUA))
+	if err != nil {
+		return Principal{}, err
+	}
+	sidType, err := aux.ProcessTokenSidType.resolve(psProcessTokenSidTypeNames, "ProcessTokenSidType", TASK_PROCESSTOKENSID_DEFAULT.apiValue())
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{
+		Name:                psValue(aux.DisplayName),
+		GroupID:             psValue(aux.GroupID),
+		ID:                  psValue(aux.ID),
+		LogonType:           TaskLogonType(logonType),
+		RunLevel:            TaskRunLevel(runLevel),
+		UserID:              psValue(aux.UserID),
+		ProcessTokenSidType: processTokenSidTypeFromAPI(sidType),
+		RequiredPrivileges:  aux.RequiredPrivilege,
+	}, nil
+}
+
+func (aux psSettingsJSON) toTaskSettings() (TaskSettings, error) {
+	compatibility, err := aux.Compatibility.resolve(psCompatibilityNames, "Compatibility", uint(TASK_COMPATIBILITY_V2))
+	if err != nil {
+		return TaskSettings{}, err
+	}
+	multipleInstances, err := aux.MultipleInstances.resolve(psMultipleInstancesNames, "MultipleInstances", uint(TASK_INSTANCES_IGNORE_NEW))
+	if err != nil {
+		return TaskSettings{}, err
+	}
+
+	settings := TaskSettings{
+		AllowDemandStart:                aux.AllowDemandStart,
+		AllowHardTerminate:              aux.AllowHardTerminate,
+		Compatibility:                   TaskCompatibility(compatibility),
+		DisallowStartOnRemoteAppSession: aux.DisallowStartOnRemoteAppSession,
+		DontStartOnBatteries:            aux.DisallowStartIfOnBatteries,
+		Enabled:                         aux.Enabled,
+		Hidden:                          aux.Hidden,
+		IdleSettings: IdleSettings{
+			RestartOnIdle: aux.IdleSettings.RestartOnIdle,
+			StopOnIdleEnd: aux.IdleSettings.StopOnIdleEnd,
+		},
+		MultipleInstances: TaskInstancesPolicy(multipleInstances),
+		NetworkSettings: NetworkSettings{
+			ID:   psValue(aux.NetworkSettings.ID),
+			Name: psValue(aux.NetworkSettings.Name),
+		},
+		Priority:                   aux.Priority,
+		RestartCount:               aux.RestartCount,
+		RunOnlyIfIdle:              aux.RunOnlyIfIdle,
+		RunOnlyIfNetworkAvailable:  aux.RunOnlyIfNetworkAvailable,
+		StartWhenAvailable:         aux.StartWhenAvailable,
+		StopIfGoingOnBatteries:     aux.StopIfGoingOnBatteries,
+		UseUnifiedSchedulingEngine: aux.UseUnifiedSchedulingEngine,
+		Volatile:                   aux.Volatile,
+		WakeToRun:                  aux.WakeToRun,
+	}
+
+	periods := []struct {
+		value *string
+		name  string
+		dst   *period.Period
+	}{
+		{aux.DeleteExpiredTaskAfter, "DeleteExpiredTaskAfter", &settings.DeleteExpiredTaskAfter},
+		{aux.ExecutionTimeLimit, "ExecutionTimeLimit", &settings.TimeLimit},
+		{aux.IdleSettings.IdleDuration, "IdleDuration", &settings.IdleDuration},
+		{aux.IdleSettings.WaitTimeout, "WaitTimeout", &settings.WaitTimeout},
+		{aux.RestartInterval, "RestartInterval", &settings.RestartInterval},
+	}
+	if aux.MaintenanceSettings != nil {
+		settings.MaintenanceSettings = &MaintenanceSettings{Exclusive: aux.MaintenanceSettings.Exclusive}
+		periods = append(periods, []struct {
+			value *string
+			name  string
+			dst   *period.Period
+		}{
+			{aux.MaintenanceSettings.Deadline, "Deadline", &settings.MaintenanceSettings.Deadline},
+			{aux.MaintenanceSettings.Period, "Period", &settings.MaintenanceSettings.Period},
+		}...)
+	}
+	for _, p := range periods {
+		if *p.dst, err = parsePSPeriod(p.value, p.name); err != nil {
+			return TaskSettings{}, err
+		}
+	}
+
+	return settings, nil
+}
+
+func (aux psTriggerJSON) toTrigger() (Trigger, error) {
+	var err error
+	taskTrigger := TaskTrigger{
+		Enabled: aux.Enabled == nil || *aux.Enabled,
+		ID:      psValue(aux.ID),
+		RepetitionPattern: RepetitionPattern{
+			StopAtDurationEnd: aux.Repetition.StopAtDurationEnd,
+		},
+		SynchronizeAcrossTimeZones: boundariesHaveTimeZone(psValue(aux.StartBoundary), psValue(aux.EndBoundary)),
+	}
+	if taskTrigger.StartBoundary, err = TaskDateToTime(psValue(aux.StartBoundary)); err != nil {
+		return nil, fmt.Errorf("error parsing StartBoundary: %v", err)
+	}
+	if taskTrigger.EndBoundary, err = TaskDateToTime(psValue(aux.EndBoundary)); err != nil {
+		return nil, fmt.Errorf("error parsing EndBoundary: %v", err)
+	}
+	if taskTrigger.ExecutionTimeLimit, err = parsePSPeriod(aux.ExecutionTimeLimit, "ExecutionTimeLimit"); err != nil {
+		return nil, err
+	}
+	if taskTrigger.RepetitionDuration, err = parsePSPeriod(aux.Repetition.Duration, "Duration"); err != nil {
+		return nil, err
+	}
+	if taskTrigger.RepetitionInterval, err = parsePSPeriod(aux.Repetition.Interval, "Interval"); err != nil {
+		return nil, err
+	}
+
+	delay, err := parsePSPeriod(aux.Delay, "Delay")
+	if err != nil {
+		return nil, err
+	}
+	randomDelay, err := parsePSPeriod(aux.RandomDelay, "RandomDelay")
+	if err != nil {
+		return nil, err
+	}
+
+	switch aux.CimClass {
+	case psBootTriggerClass:
+		return BootTrigger{TaskTrigger: taskTrigger, Delay: delay}, nil
+	case psDailyTriggerClass:
+		return DailyTrigger{TaskTrigger: taskTrigger, DayInterval: DayInterval(psUintValue(aux.DaysInterval, 1)), RandomDelay: randomDelay}, nil
+	case psEventTriggerClass:
+		trigger := EventTrigger{TaskTrigger: taskTrigger, Delay: delay, Subscription: psValue(aux.Subscription)}
+		if len(aux.ValueQueries) > 0 {
+			trigger.ValueQueries = make(map[string]string, len(aux.ValueQueries))
+			for _, query := range aux.ValueQueries {
+				trigger.ValueQueries[query.Name] = query.Value
+			}
+		}
+		return trigger, nil
+	case psIdleTriggerClass:
+		return IdleTrigger{TaskTrigger: taskTrigger}, nil
+	case psLogonTriggerClass:
+		return LogonTrigger{TaskTrigger: taskTrigger, Delay: delay, UserID: psValue(aux.UserID)}, nil
+	case psRegistrationTriggerClass:
+		return RegistrationTrigger{TaskTrigger: taskTrigger, Delay: delay}, nil
+	case psSessionStateChangeTriggerClass:
+		return SessionStateChangeTrigger{
+			TaskTrigger: taskTrigger,
+			Delay:       delay,
+			StateChange: TaskSessionStateChangeType(psUintValue(aux.StateChange, 0)),
+			UserID:      psValue(aux.UserID),
+		}, nil
+	case psTimeTriggerClass:
+		return TimeTrigger{TaskTrigger: taskTrigger, RandomDelay: randomDelay}, nil
+	case psWeeklyTriggerClass:
+		return WeeklyTrigger{
+			TaskTrigger:  taskTrigger,
+			DaysOfWeek:   DayOfWeek(psUintValue(aux.DaysOfWeek, 0)),
+			RandomDelay:  randomDelay,
+			WeekInterval: WeekInterval(psUintValue(aux.WeeksInterval, 1)),
+		}, nil
+	case "":
+		return nil, errors.New("the trigger has no CimClass, so its type can't be determined")
+	default:
+		// the ScheduledTasks module returns triggers it doesn't support, such as
+		// monthly triggers, as MSFT_TaskTrigger without their schedule
+		return nil, fmt.Errorf("unsupported CimClass %q", aux.CimClass)
+	}
+}
+
+// psString returns nil for empty strings, which PowerShell encodes as null.
+func psString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func psValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func psPeriod(p period.Period) *string {
+	return psString(PeriodToString(p))
+}
+
+func parsePSPeriod(s *string, field string) (period.Period, error) {
+	p, err := StringToPeriod(psValue(s))
+	if err != nil {
+		return period.Period{}, fmt.Errorf("error parsing %s: %v", field, err)
+	}
+
+	return p, nil
+}
+
+func psUint(u uint) *uint {
+	return &u
+}
+
+func psUintValue(u *uint, def uint) uint {
+	if u == nil {
+		return def
+	}
+
+	return *u
+}
@@ -0,0 +1,235 @@
+package taskmaster
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+const powerShellTaskJSON = `{
+    "Actions": [
+        {
+            "Arguments": "-NoProfile -File C:\\scripts\\backup.ps1",
+            "Execute": "powershell.exe",
+            "Id": null,
+            "WorkingDirectory": null,
+            "PSComputerName": null,
+            "CimClass": "Root/Microsoft/Windows/TaskScheduler:MSFT_TaskExecAction",
+            "CimInstanceProperties": "Id Arguments Execute WorkingDirectory",
+            "CimSystemProperties": "Microsoft.Management.Infrastructure.CimSystemProperties"
+        }
+    ],
+    "Author": "DOMAIN\\admin",
+    "Date": "2021-01-01T09:00:00",
+    "Description": "Backs up things",
+    "Documentation": null,
+    "Principal": {
+        "DisplayName": null,
+        "GroupId": null,
+        "Id": "Author",
+        "LogonType": "ServiceAccount",
+        "RunLevel": "Highest",
+        "UserId": "SYSTEM",
+        "ProcessTokenSidType": 2,
+        "RequiredPrivilege": null
+    },
+    "SecurityDescriptor": null,
+    "Settings": {
+        "AllowDemandStart": true,
+        "AllowHardTerminate": true,
+        "Compatibility": 3,
+        "DeleteExpiredTaskAfter": null,
+        "DisallowStartIfOnBatteries": true,
+        "Enabled": true,
+        "ExecutionTimeLimit": "PT72H",
+        "Hidden": false,
+        "IdleSettings": {
+            "IdleDuration": "PT10M",
+            "RestartOnIdle": false,
+            "StopOnIdleEnd": true,
+            "WaitTimeout": "PT1H"
+        },
+        "MultipleInstances": "IgnoreNew",
+        "NetworkSettings": {"Id": null, "Name": null},
+        "Priority": 7,
+        "RestartCount": 0,
+        "RestartInterval": null,
+        "RunOnlyIfIdle": false,
+        "RunOnlyIfNetworkAvailable": false,
+        "StartWhenAvailable": false,
+        "StopIfGoingOnBatteries": true,
+        "WakeToRun": false,
+        "DisallowStartOnRemoteAppSession": false,
+        "UseUnifiedSchedulingEngine": true,
+        "MaintenanceSettings": null,
+        "volatile": false
+    },
+    "Source": null,
+    "State": 3,
+    "TaskName": "Backup",
+    "TaskPath": "\\Taskmaster\\",
+    "Triggers": [
+        {
+            "Enabled": true,
+            "EndBoundary": null,
+            "ExecutionTimeLimit": null,
+            "Id": null,
+            "Repetition": {"Duration": null, "Interval": "PT1H", "StopAtDurationEnd": false},
+            "StartBoundary": "2021-01-01T09:00:00",
+            "DaysInterval": 2,
+            "RandomDelay": null,
+            "CimClass": {"CimClassName": "MSFT_TaskDailyTrigger"}
+        },
+        {
+            "Enabled": false,
+            "EndBoundary": null,
+            "ExecutionTimeLimit": null,
+            "Id": null,
+            "Repetition": {"Duration": null, "Interval": null, "StopAtDurationEnd": false},
+            "StartBoundary": null,
+            "Delay": "PT30S",
+            "UserId": "DOMAIN\\user",
+            "CimClass": "Root/Microsoft/Windows/TaskScheduler:MSFT_TaskLogonTrigger"
+        }
+    ],
+    "URI": "\\Taskmaster\\Backup",
+    "Version": null
+}`
+
+func TestParsePowerShellJSON(t *testing.T) {
+	tasks, err := ParsePowerShellJSON([]byte(powerShellTaskJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(tasks))
+	}
+
+	task := tasks[0]
+	if task.Path != `\Taskmaster\Backup` || task.State != TASK_STATE_READY {
+		t.Fatalf("unexpected path or state: %s %s", task.Path, task.State)
+	}
+
+	def := task.Definition
+	expectedActions := []Action{ExecAction{Path: "powershell.exe", Args: `-NoProfile -File C:\scripts\backup.ps1`}}
+	if !reflect.DeepEqual(def.Actions, expectedActions) {
+		t.Errorf("expected actions %+v, got %+v", expectedActions, def.Actions)
+	}
+	expectedPrincipal := Principal{
+		ID:                  "Author",
+		LogonType:           TASK_LOGON_SERVICE_ACCOUNT,
+		RunLevel:            TASK_RUNLEVEL_HIGHEST,
+		UserID:              "SYSTEM",
+		ProcessTokenSidType: TASK_PROCESSTOKENSID_DEFAULT,
+	}
+	if !reflect.DeepEqual(def.Principal, expectedPrincipal) {
+		t.Errorf("expected principal %+v, got %+v", expectedPrincipal, def.Principal)
+	}
+	if def.Settings.TimeLimit != period.NewHMS(72, 0, 0) || !def.Settings.DontStartOnBatteries || def.Settings.MultipleInstances != TASK_INSTANCES_IGNORE_NEW {
+		t.Errorf("unexpected settings %+v", def.Settings)
+	}
+	if def.RegistrationInfo.Author != `DOMAIN\admin` || !def.RegistrationInfo.Date.Equal(time.Date(2021, time.January, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected registration info %+v", def.RegistrationInfo)
+	}
+
+	if len(def.Triggers) != 2 {
+		t.Fatalf("expected 2 triggers, got %d", len(def.Triggers))
+	}
+	daily, ok := def.Triggers[0].(DailyTrigger)
+	if !ok || daily.DayInterval != EveryOtherDay || daily.RepetitionInterval != period.NewHMS(1, 0, 0) || !daily.Enabled {
+		t.Errorf("unexpected daily trigger %+v", def.Triggers[0])
+	}
+	logon, ok := def.Triggers[1].(LogonTrigger)
+	if !ok || logon.UserID != `DOMAIN\user` || logon.Delay != period.NewHMS(0, 0, 30) || logon.Enabled {
+		t.Errorf("unexpected logon trigger %+v", def.Triggers[1])
+	}
+
+	// arrays of tasks are decoded as well
+	tasks, err = ParsePowerShellJSON([]byte("[" + powerShellTaskJSON + "," + powerShellTaskJSON + "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+
+	if _, err = ParsePowerShellJSON([]byte(strings.Replace(powerShellTaskJSON, `"Highest"`, `"Lowest"`, 1))); err == nil {
+		t.Error("expected an error for an unknown RunLevel")
+	}
+	if _, err = ParsePowerShellJSON([]byte(strings.Replace(powerShellTaskJSON, "MSFT_TaskLogonTrigger", "MSFT_TaskTrigger", 1))); err == nil {
+		t.Error("expected an error for an unsupported trigger")
+	}
+}
+
+func TestPowerShellTaskRoundTrip(t *testing.T) {
+	def := defaultDefinition()
+	def.RegistrationInfo.Date = time.Date(2021, time.January, 1, 9, 0, 0, 0, time.UTC)
+	def.AddAction(ExecAction{ID: "run", Path: "cmd.exe", Args: "/c exit 0", WorkingDir: `C:\`})
+	def.AddAction(ComHandlerAction{ClassID: "{F0001111-0000-0000-0000-0000FEEDACDC}", Data: "data"})
+	def.AddTrigger(WeeklyTrigger{
+		TaskTrigger:  TaskTrigger{Enabled: true, StartBoundary: time.Date(2021, time.January, 1, 9, 0, 0, 0, time.UTC)},
+		DaysOfWeek:   Monday | Friday,
+		WeekInterval: EveryOtherWeek,
+		RandomDelay:  period.NewHMS(0, 5, 0),
+	})
+	def.AddTrigger(EventTrigger{
+		TaskTrigger:  TaskTrigger{Enabled: true},
+		Subscription: "<QueryList></QueryList>",
+		ValueQueries: map[string]string{"id": "Event/System/EventID"},
+	})
+	def.AddTrigger(SessionStateChangeTrigger{TaskTrigger: TaskTrigger{Enabled: true}, StateChange: TASK_SESSION_LOCK})
+	def.Settings.MaintenanceSettings = &MaintenanceSettings{Period: period.NewYMD(0, 0, 1)}
+
+	task := PowerShellTask{Path: `\Taskmaster\RoundTrip`, State: TASK_STATE_DISABLED, Definition: def}
+	data, err := json.Marshal(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded PowerShellTask
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, task) {
+		t.Fatalf("expected %+v, got %+v", task, decoded)
+	}
+
+	def.AddTrigger(MonthlyTrigger{TaskTrigger: TaskTrigger{Enabled: true}, DaysOfMonth: One, MonthsOfYear: January})
+	if _, err = json.Marshal(PowerShellTask{Path: `\Monthly`, Definition: def}); err == nil {
+		t.Error("expected an error for a monthly trigger")
+	}
+}
+
+func TestPowerShellTriggerNegativeUTCOffset(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	start := time.Date(2021, time.January, 1, 3, 0, 0, 0, zone)
+	trigger := mustTrigger(NewTimeTrigger(start))
+	trigger.EndBoundary = start.AddDate(0, 1, 0)
+	trigger.SynchronizeAcrossTimeZones = true
+
+	def := defaultDefinition()
+	def.AddAction(ExecAction{Path: "cmd.exe"})
+	def.AddTrigger(trigger)
+	data, err := json.Marshal(PowerShellTask{Path: `\Taskmaster\Offset`, Definition: def})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decodedTask PowerShellTask
+	if err = json.Unmarshal(data, &decodedTask); err != nil {
+		t.Fatal(err)
+	}
+	decoded := decodedTask.Definition.Triggers[0]
+	if !decoded.GetSynchronizeAcrossTimeZones() {
+		t.Error("expected the decoded trigger to synchronize across time zones")
+	}
+	for _, boundary := range [][2]time.Time{{decoded.GetStartBoundary(), trigger.StartBoundary}, {decoded.GetEndBoundary(), trigger.EndBoundary}} {
+		if _, offset := boundary[0].Zone(); !boundary[0].Equal(boundary[1]) || offset != -5*60*60 {
+			t.Errorf("expected boundary %s, got %s", boundary[1], boundary[0])
+		}
+	}
+}
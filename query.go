@@ -0,0 +1,101 @@
+package taskmaster
+
+import (
+	"path"
+	"strings"
+)
+
+// Matches returns true if the definition matches every field of the query that
+// is set. Users are compared case-insensitively, and SYSTEM matches the account
+// with or without the NT AUTHORITY domain, as well as its SID S-1-5-18.
+//
+// ActionPathMatches uses the pattern syntax of path.Match, with backslashes
+// treated as path separators, and is compared case-insensitively. A pattern
+// without a separator, such as `*.ps1`, is matched against the file name of the
+// action's path, otherwise it is matched against the whole path. Malformed
+// patterns don't match any path, but FindTasks returns an error for them.
+func (q Query) Matches(def Definition) bool {
+	principal := def.Principal
+	if q.RunAsSystem && !isSystemAccount(principal.UserID) {
+		return false
+	}
+	if q.HighestRunLevel && principal.RunLevel != TASK_RUNLEVEL_HIGHEST {
+		return false
+	}
+	if q.HiddenOnly && !def.Settings.Hidden {
+		return false
+	}
+	if q.Privilege != "" && !containsFold(principal.RequiredPrivileges, q.Privilege) {
+		return false
+	}
+	if q.UserID != "" && !sameUser(q.UserID, principal.UserID) && !sameUser(q.UserID, principal.GroupID) {
+		return false
+	}
+	if q.ActionPathMatches != "" && !q.matchesActionPath(def.Actions) {
+		return false
+	}
+
+	return true
+}
+
+// Validate returns path.ErrBadPattern if ActionPathMatches is malformed.
+func (q Query) Validate() error {
+	_, err := path.Match(normalizeActionPath(q.ActionPathMatches), "")
+
+	return err
+}
+
+func (q Query) matchesActionPath(actions []Action) bool {
+	pattern := normalizeActionPath(q.ActionPathMatches)
+	matchName := !strings.Contains(pattern, "/")
+
+	for _, action := range actions {
+		execAction, ok := action.(ExecAction)
+		if !ok {
+			continue
+		}
+		actionPath := normalizeActionPath(strings.Trim(execAction.Path, `"`))
+		if matchName {
+			actionPath = path.Base(actionPath)
+		}
+		if matched, _ := path.Match(pattern, actionPath); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeActionPath(p string) string {
+	return strings.ToLower(strings.ReplaceAll(p, `\`, "/"))
+}
+
+func isSystemAccount(user string) bool {
+	return strings.EqualFold(user, "S-1-5-18") || strings.EqualFold(strings.TrimPrefix(strings.ToUpper(user), `NT AUTHORITY\`), SYSTEM)
+}
+
+// sameUser returns true if the users are the same, ignoring their domain if
+// either of them doesn't have one.
+func sameUser(a, b string) bool {
+	if b == "" {
+		return false
+	}
+	if isSystemAccount(a) && isSystemAccount(b) {
+		return true
+	}
+	if strings.Contains(a, `\`) && strings.Contains(b, `\`) {
+		return strings.EqualFold(a, b)
+	}
+
+	return strings.EqualFold(trimUsernameDomain(a), trimUsernameDomain(b))
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package taskmaster
+
+import "testing"
+
+func TestQueryMatches(t *testing.T) {
+	def := defaultDefinition()
+	def.AddAction(ComHandlerAction{ClassID: "{F0001111-0000-0000-0000-0000FEEDACDC}"})
+	def.AddAction(ExecAction{Path: `"C:\Scripts\Clean Up.PS1"`})
+	def.Principal.UserID = `NT AUTHORITY\SYSTEM`
+	def.Principal.LogonType = TASK_LOGON_SERVICE_ACCOUNT
+	def.Principal.RunLevel = TASK_RUNLEVEL_HIGHEST
+	def.Principal.RequiredPrivileges = []string{"SeBackupPrivilege"}
+
+	tests := []struct {
+		query   Query
+		matches bool
+	}{
+		{Query{}, true},
+		{Query{RunAsSystem: true, HighestRunLevel: true}, true},
+		{Query{HiddenOnly: true}, false},
+		{Query{Privilege: "sebackupprivilege"}, true},
+		{Query{Privilege: "SeDebugPrivilege"}, false},
+		{Query{UserID: "S-1-5-18"}, true},
+		{Query{UserID: `DOMAIN\Administrator`}, false},
+		{Query{ActionPathMatches: "*.ps1"}, true},
+		{Query{ActionPathMatches: `C:\Scripts\*`}, true},
+		{Query{ActionPathMatches: `C:\Windows\*`}, false},
+		{Query{ActionPathMatches: "*.exe"}, false},
+		{Query{RunAsSystem: true, ActionPathMatches: "*.exe"}, false},
+	}
+
+	for _, test := range tests {
+		if matches := test.query.Matches(def); matches != test.matches {
+			t.Errorf("%+v: expected %t, got %t", test.query, test.matches, matches)
+		}
+	}
+
+	def.Principal.UserID = `DOMAIN\User`
+	if !(Query{UserID: "user"}).Matches(def) {
+		t.Error("a user without a domain should match the same user with a domain")
+	}
+	if (Query{RunAsSystem: true}).Matches(def) {
+		t.Error("a task that runs as a user shouldn't match RunAsSystem")
+	}
+	if err := (Query{ActionPathMatches: "["}).Validate(); err == nil {
+		t.Error("expected a malformed pattern to be rejected")
+	}
+}
@@ -0,0 +1,220 @@
+package taskmaster
+
+import (
+	"sort"
+	"time"
+)
+
+// maxScheduleYears is how far after StartBoundary the occurrences of a trigger
+// are searched for, so that triggers that never fire, such as a MonthlyTrigger
+// that only runs on February 30, don't make NextOccurrences loop forever.
+const maxScheduleYears = 100
+
+// NextOccurrences returns nil, as triggers that don't fire on a schedule, such
+// as a BootTrigger or an EventTrigger, have no occurrences that can be computed.
+func (TaskTrigger) NextOccurrences(from time.Time, n int) []time.Time {
+	return nil
+}
+
+// NextOccurrences returns the first n times at or after from that the trigger
+// fires, including the times the task is repeated at by its repetition pattern.
+// The times are computed locally, without the Task Scheduler service. If the
+// trigger has a RandomDelay, the task starts up to RandomDelay after each time.
+// A disabled trigger has no occurrences.
+func (t TimeTrigger) NextOccurrences(from time.Time, n int) []time.Time {
+	return nextOccurrences(t, from, n, 1, func(i int) []time.Time {
+		return []time.Time{t.StartBoundary}
+	})
+}
+
+// NextOccurrences returns the first n times at or after from that the trigger
+// fires. See TimeTrigger.NextOccurrences for details.
+func (t DailyTrigger) NextOccurrences(from time.Time, n int) []time.Time {
+	interval := int(t.DayInterval)
+	if interval == 0 {
+		interval = 1
+	}
+
+	return nextOccurrences(t, from, n, maxScheduleYears*366/interval, func(i int) []time.Time {
+		return []time.Time{t.StartBoundary.AddDate(0, 0, i*interval)}
+	})
+}
+
+// NextOccurrences returns the first n times at or after from that the trigger
+// fires. Weeks start on Sunday, and the first week is the one that contains
+// StartBoundary. See TimeTrigger.NextOccurrences for details.
+func (t WeeklyTrigger) NextOccurrences(from time.Time, n int) []time.Time {
+	interval := int(t.WeekInterval)
+	if interval == 0 {
+		interval = 1
+	}
+	firstSunday := t.StartBoundary.AddDate(0, 0, -int(t.StartBoundary.Weekday()))
+
+	return nextOccurrences(t, from, n, maxScheduleYears*53/interval, func(i int) []time.Time {
+		sunday := firstSunday.AddDate(0, 0, i*interval*7)
+
+		var starts []time.Time
+		for day := 0; day < 7; day++ {
+			if t.DaysOfWeek&(1<<day) != 0 {
+				starts = append(starts, sunday.AddDate(0, 0, day))
+			}
+		}
+		return starts
+	})
+}
+
+// NextOccurrences returns the first n times at or after from that the trigger
+// fires. Days that don't exist in a month, such as the 31st of April, are
+// skipped. See TimeTrigger.NextOccurrences for details.
+func (t MonthlyTrigger) NextOccurrences(from time.Time, n int) []time.Time {
+	return nextOccurrences(t, from, n, maxScheduleYears*12, func(i int) []time.Time {
+		monthStart, ok := scheduledMonth(t.StartBoundary, i, t.MonthsOfYear)
+		if !ok {
+			return nil
+		}
+		days := daysInMonth(monthStart)
+
+		var starts []time.Time
+		for day := 1; day <= days; day++ {
+			lastDay := day == days && (t.runsOnLastDayOfMonth() || t.DaysOfMonth&LastDayOfMonth != 0)
+			if t.DaysOfMonth&(1<<(day-1)) != 0 || lastDay {
+				starts = append(starts, monthStart.AddDate(0, 0, day-1))
+			}
+		}
+		return starts
+	})
+}
+
+// NextOccurrences returns the first n times at or after from that the trigger
+// fires. The first week of a month is the one that contains the first of each
+// day of the week, so for example the first Monday of a month is always in its
+// first week. See TimeTrigger.NextOccurrences for details.
+func (t MonthlyDOWTrigger) NextOccurrences(from time.Time, n int) []time.Time {
+	return nextOccurrences(t, from, n, maxScheduleYears*12, func(i int) []time.Time {
+		monthStart, ok := scheduledMonth(t.StartBoundary, i, t.MonthsOfYear)
+		if !ok {
+			return nil
+		}
+		days := daysInMonth(monthStart)
+
+		var starts []time.Time
+		for day := 1; day <= days; day++ {
+			date := monthStart.AddDate(0, 0, day-1)
+			if t.DaysOfWeek&(1<<date.Weekday()) == 0 {
+				continue
+			}
+			week := (day - 1) / 7
+			lastWeek := day+7 > days && (t.RunOnLastWeekOfMonth || t.WeeksOfMonth&LastWeek != 0)
+			if (week < 4 && t.WeeksOfMonth&(1<<week) != 0) || lastWeek {
+				starts = append(starts, date)
+			}
+		}
+		return starts
+	})
+}
+
+// nextOccurrences returns the first n times at or after from that trigger fires.
+// startsInPeriod returns the times the trigger starts at during the period
+// numbered i, such as a day or a month, counting from StartBoundary; the task is
+// then repeated according to the repetition pattern of the trigger.
+func nextOccurrences(trigger Trigger, from time.Time, n, maxPeriods int, startsInPeriod func(i int) []time.Time) []time.Time {
+	if n <= 0 || !trigger.GetEnabled() {
+		return nil
+	}
+	start, end := trigger.GetStartBoundary(), trigger.GetEndBoundary()
+	interval := trigger.GetRepetitionInterval().DurationApprox()
+	duration := trigger.GetRepetitionDuration().DurationApprox()
+
+	var occurrences []time.Time
+	for i := 0; i < maxPeriods; i++ {
+		starts := startsInPeriod(i)
+		sort.Slice(starts, func(a, b int) bool {
+			return starts[a].Before(starts[b])
+		})
+
+		for _, periodStart := range starts {
+			if !end.IsZero() && periodStart.After(end) {
+				return occurrences
+			}
+			// later starts can't fire before the nth occurrence
+			if len(occurrences) == n && periodStart.After(occurrences[n-1]) {
+				return occurrences
+			}
+			if periodStart.Before(start) {
+				continue
+			}
+
+			occurrences = mergeOccurrences(occurrences, repetitions(periodStart, from, end, interval, duration, n), n)
+		}
+	}
+
+	return occurrences
+}
+
+// repetitions returns up to n times at or after from and not after end, if end
+// is set, that a task started at start is run at, given the interval and the
+// duration of its repetition pattern.
+func repetitions(start, from, end time.Time, interval, duration time.Duration, n int) []time.Time {
+	if interval <= 0 {
+		if start.Before(from) || (!end.IsZero() && start.After(end)) {
+			return nil
+		}
+		return []time.Time{start}
+	}
+
+	// skip the repetitions before from
+	next := start
+	if next.Before(from) {
+		skipped := (from.Sub(start) + interval - 1) / interval
+		next = start.Add(skipped * interval)
+	}
+
+	var times []time.Time
+	for len(times) < n {
+		// a zero duration repeats the task indefinitely
+		if duration > 0 && next.Sub(start) >= duration {
+			break
+		}
+		if !end.IsZero() && next.After(end) {
+			break
+		}
+		times = append(times, next)
+		next = next.Add(interval)
+	}
+
+	return times
+}
+
+// mergeOccurrences merges the sorted slices a and b, removing duplicates, and
+// returns at most the first n times.
+func mergeOccurrences(a, b []time.Time, n int) []time.Time {
+	merged := make([]time.Time, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		var next time.Time
+		if len(b) == 0 || (len(a) > 0 && !b[0].Before(a[0])) {
+			next, a = a[0], a[1:]
+		} else {
+			next, b = b[0], b[1:]
+		}
+		if len(merged) == 0 || !merged[len(merged)-1].Equal(next) {
+			merged = append(merged, next)
+		}
+	}
+	if len(merged) > n {
+		merged = merged[:n]
+	}
+
+	return merged
+}
+
+// scheduledMonth returns the start of the month numbered i counting from the
+// month of start, at the time of day of start, and whether months includes it.
+func scheduledMonth(start time.Time, i int, months Month) (time.Time, bool) {
+	monthStart := time.Date(start.Year(), start.Month()+time.Month(i), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+
+	return monthStart, months&(1<<(monthStart.Month()-1)) != 0
+}
+
+func daysInMonth(monthStart time.Time) int {
+	return time.Date(monthStart.Year(), monthStart.Month()+1, 0, 0, 0, 0, 0, monthStart.Location()).Day()
+}
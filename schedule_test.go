@@ -0,0 +1,148 @@
+package taskmaster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+func TestNextOccurrences(t *testing.T) {
+	// 2021-01-01 is a Friday
+	start := time.Date(2021, time.January, 1, 9, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2021, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		trigger  Trigger
+		from     time.Time
+		n        int
+		expected []time.Time
+	}{
+		{
+			"TimeTrigger",
+			TimeTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}},
+			start.Add(-time.Hour), 3,
+			[]time.Time{start},
+		},
+		{
+			"TimeTrigger in the past",
+			TimeTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}},
+			start.Add(time.Second), 3,
+			nil,
+		},
+		{
+			"disabled TimeTrigger",
+			TimeTrigger{TaskTrigger: TaskTrigger{StartBoundary: start}},
+			start, 3,
+			nil,
+		},
+		{
+			"DailyTrigger",
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DayInterval: EveryOtherDay},
+			at(time.January, 2, 0, 0), 3,
+			[]time.Time{at(time.January, 3, 9, 0), at(time.January, 5, 9, 0), at(time.January, 7, 9, 0)},
+		},
+		{
+			"DailyTrigger with EndBoundary",
+			DailyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start, EndBoundary: at(time.January, 2, 12, 0)}, DayInterval: EveryDay},
+			start, 5,
+			[]time.Time{at(time.January, 1, 9, 0), at(time.January, 2, 9, 0)},
+		},
+		{
+			"DailyTrigger with repetition",
+			DailyTrigger{
+				TaskTrigger: TaskTrigger{
+					Enabled:       true,
+					StartBoundary: start,
+					RepetitionPattern: RepetitionPattern{
+						RepetitionInterval: period.NewHMS(0, 30, 0),
+						RepetitionDuration: period.NewHMS(1, 0, 0),
+					},
+				},
+				DayInterval: EveryDay,
+			},
+			at(time.January, 1, 9, 10), 3,
+			[]time.Time{at(time.January, 1, 9, 30), at(time.January, 2, 9, 0), at(time.January, 2, 9, 30)},
+		},
+		{
+			"TimeTrigger repeated indefinitely",
+			TimeTrigger{
+				TaskTrigger: TaskTrigger{
+					Enabled:           true,
+					StartBoundary:     start,
+					RepetitionPattern: RepetitionPattern{RepetitionInterval: period.NewHMS(0, 5, 0)},
+				},
+			},
+			at(time.March, 1, 0, 1), 2,
+			[]time.Time{at(time.March, 1, 0, 5), at(time.March, 1, 0, 10)},
+		},
+		{
+			"WeeklyTrigger",
+			WeeklyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DaysOfWeek: Monday | Friday, WeekInterval: EveryOtherWeek},
+			start, 4,
+			[]time.Time{at(time.January, 1, 9, 0), at(time.January, 11, 9, 0), at(time.January, 15, 9, 0), at(time.January, 25, 9, 0)},
+		},
+		{
+			"MonthlyTrigger",
+			MonthlyTrigger{
+				TaskTrigger:         TaskTrigger{Enabled: true, StartBoundary: start},
+				DaysOfMonth:         Fifteen | ThirtyOne,
+				MonthsOfYear:        January | February | April,
+				RunOnLastDayOfMonth: true,
+			},
+			start, 5,
+			[]time.Time{at(time.January, 15, 9, 0), at(time.January, 31, 9, 0), at(time.February, 15, 9, 0), at(time.February, 28, 9, 0), at(time.April, 15, 9, 0)},
+		},
+		{
+			"MonthlyTrigger with the deprecated RunOnLastWeekOfMonth",
+			MonthlyTrigger{
+				TaskTrigger:          TaskTrigger{Enabled: true, StartBoundary: start},
+				MonthsOfYear:         February,
+				RunOnLastWeekOfMonth: true,
+			},
+			start, 1,
+			[]time.Time{at(time.February, 28, 9, 0)},
+		},
+		{
+			"MonthlyTrigger that never fires",
+			MonthlyTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}, DaysOfMonth: Thirty, MonthsOfYear: February},
+			start, 1,
+			nil,
+		},
+		{
+			"MonthlyDOWTrigger",
+			MonthlyDOWTrigger{
+				TaskTrigger:          TaskTrigger{Enabled: true, StartBoundary: start},
+				DaysOfWeek:           Monday,
+				MonthsOfYear:         January | March,
+				WeeksOfMonth:         First,
+				RunOnLastWeekOfMonth: true,
+			},
+			start, 4,
+			[]time.Time{at(time.January, 4, 9, 0), at(time.January, 25, 9, 0), at(time.March, 1, 9, 0), at(time.March, 29, 9, 0)},
+		},
+		{
+			"BootTrigger",
+			BootTrigger{TaskTrigger: TaskTrigger{Enabled: true, StartBoundary: start}},
+			start, 1,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		occurrences := test.trigger.NextOccurrences(test.from, test.n)
+		if len(occurrences) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, occurrences)
+			continue
+		}
+		for i := range occurrences {
+			if !occurrences[i].Equal(test.expected[i]) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, occurrences)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package taskmaster
+
+import "context"
+
+// Service is the set of TaskService operations that manage task folders and
+// registered tasks by path. Code that schedules tasks can depend on Service
+// instead of *TaskService, so that it can be unit tested with the in-memory
+// implementation returned by taskmastertest.NewFakeService, without a Task
+// Scheduler service.
+//
+// The registered and running tasks returned by implementations other than
+// *TaskService aren't backed by the Task Scheduler service, so only their fields
+// can be used; their methods, other than Release, return errors. Use the methods
+// of Service that take a path instead, such as RunTask rather than
+// RegisteredTask.Run.
+type Service interface {
+	Connected() bool
+	Disconnect()
+	NewTaskDefinition() Definition
+
+	GetRunningTasks() (RunningTaskCollection, error)
+	GetRegisteredTask(path string) (RegisteredTask, error)
+	GetRegisteredTasks(opts ...EnumOption) (RegisteredTaskCollection, error)
+	GetTasksInFolder(path string, opts ...EnumOption) (RegisteredTaskCollection, error)
+	FindTasks(query Query) (RegisteredTaskCollection, error)
+
+	CreateTask(path string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error)
+	CreateTaskEx(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error)
+	UpdateTask(path string, newTaskDef Definition) (RegisteredTask, error)
+	DeleteTask(path string) error
+	SetTaskEnabled(path string, enabled bool) error
+
+	RunTask(path string, args []string) error
+	StopTask(path string) error
+	RunAndWait(ctx context.Context, path string, args []string) (int, error)
+	WaitForAllInstancesToExit(ctx context.Context, path string) error
+
+	CreateFolder(path, sddl string) error
+	DeleteFolder(path string, deleteRecursively bool) (bool, error)
+}
+
+var _ Service = (*TaskService)(nil)
@@ -0,0 +1,185 @@
+// Package taskmastermetrics exposes metrics about scheduled tasks in the
+// Prometheus text exposition format, so that failing or stuck tasks can be
+// alerted on by scraping the computer that runs them.
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+package taskmastermetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stratg5/taskmaster"
+)
+
+// the Task Scheduler service reports 1999-11-30 as the last run time of tasks
+// that have never run, and a zero date as the next run time of tasks that aren't
+// scheduled to run, so times before 2000 mean the task hasn't or won't run
+var neverRun = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var taskStates = []taskmaster.TaskState{
+	taskmaster.TASK_STATE_UNKNOWN,
+	taskmaster.TASK_STATE_DISABLED,
+	taskmaster.TASK_STATE_QUEUED,
+	taskmaster.TASK_STATE_READY,
+	taskmaster.TASK_STATE_RUNNING,
+}
+
+// Collector collects metrics about the registered tasks of a TaskService. It
+// implements http.Handler, so it can be served as a metrics endpoint directly.
+// The following metrics are reported for every task, labeled with its path:
+//
+//   - taskmaster_task_state: 1 for the current state of the task, labeled with state, and 0 for the other states
+//   - taskmaster_task_enabled: 1 if the task is enabled, 0 otherwise
+//   - taskmaster_task_last_result: the result code of the last run of the task
+//   - taskmaster_task_seconds_since_last_run: omitted if the task has never run
+//   - taskmaster_task_missed_runs: the number of times the task has missed a scheduled run
+//   - taskmaster_task_next_run_lag_seconds: how long ago the next run time of an enabled task has passed, or 0
+type Collector struct {
+	taskService *taskmaster.TaskService
+	folder      string
+	now         func() time.Time
+}
+
+// NewCollector returns a Collector that reports metrics about the tasks in the
+// folder at path and its subfolders. The TaskService must stay connected for as
+// long as the Collector is used.
+func NewCollector(taskService *taskmaster.TaskService, path string) *Collector {
+	return &Collector{
+		taskService: taskService,
+		folder:      taskFolder(path),
+		now:         time.Now,
+	}
+}
+
+// WriteTo writes the current metrics to w in the Prometheus text exposition
+// format. The tasks are enumerated every time WriteTo is called.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	tasks, err := c.taskService.GetTasksInFolder(c.folder, taskmaster.IncludeSubfolders())
+	if err != nil {
+		return 0, fmt.Errorf("error listing registered tasks in %s: %v", c.folder, err)
+	}
+	defer tasks.Release()
+
+	return writeMetrics(w, tasks, c.now())
+}
+
+// ServeHTTP serves the current metrics. If the tasks can't be enumerated, an
+// internal server error is returned.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf strings.Builder
+	if _, err := c.WriteTo(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, buf.String())
+}
+
+// taskFolder returns the folder at path as the Task Scheduler service expects
+// it, which is the root folder if path is empty and without a trailing backslash
+// otherwise.
+func taskFolder(path string) string {
+	if path = strings.TrimSuffix(path, `\`); path == "" {
+		return `\`
+	}
+
+	return path
+}
+
+// writeMetrics writes the metrics of tasks at the time now to w.
+func writeMetrics(w io.Writer, tasks []taskmaster.RegisteredTask, now time.Time) (int64, error) {
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Path < tasks[j].Path
+	})
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	writeHeader(cw, "taskmaster_task_state", "The state of the registered task.")
+	for _, task := range tasks {
+		for _, state := range taskStates {
+			fmt.Fprintf(cw, "taskmaster_task_state{path=%s,state=%s} %d\n", quoteLabel(task.Path), quoteLabel(state.String()), boolValue(task.State == state))
+		}
+	}
+
+	writeHeader(cw, "taskmaster_task_enabled", "Whether the registered task is enabled.")
+	for _, task := range tasks {
+		fmt.Fprintf(cw, "taskmaster_task_enabled{path=%s} %d\n", quoteLabel(task.Path), boolValue(task.Enabled))
+	}
+
+	writeHeader(cw, "taskmaster_task_last_result", "The result code of the last run of the registered task.")
+	for _, task := range tasks {
+		fmt.Fprintf(cw, "taskmaster_task_last_result{path=%s} %d\n", quoteLabel(task.Path), uint32(task.LastTaskResult))
+	}
+
+	writeHeader(cw, "taskmaster_task_seconds_since_last_run", "The number of seconds since the registered task last ran.")
+	for _, task := range tasks {
+		if task.LastRunTime.Before(neverRun) {
+			continue
+		}
+		fmt.Fprintf(cw, "taskmaster_task_seconds_since_last_run{path=%s} %g\n", quoteLabel(task.Path), now.Sub(task.LastRunTime).Seconds())
+	}
+
+	writeHeader(cw, "taskmaster_task_missed_runs", "The number of times the registered task has missed a scheduled run.")
+	for _, task := range tasks {
+		fmt.Fprintf(cw, "taskmaster_task_missed_runs{path=%s} %d\n", quoteLabel(task.Path), task.MissedRuns)
+	}
+
+	writeHeader(cw, "taskmaster_task_next_run_lag_seconds", "The number of seconds since the next run time of the registered task has passed without it running.")
+	for _, task := range tasks {
+		var lag float64
+		if task.Enabled && !task.NextRunTime.Before(neverRun) && task.NextRunTime.Before(now) {
+			lag = now.Sub(task.NextRunTime).Seconds()
+		}
+		fmt.Fprintf(cw, "taskmaster_task_next_run_lag_seconds{path=%s} %g\n", quoteLabel(task.Path), lag)
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+func writeHeader(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// quoteLabel quotes a label value, escaping backslashes, double quotes and
+// newlines as the exposition format requires.
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// countingWriter counts the bytes written to w and remembers the first error,
+// so that writes after an error are skipped.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+
+	return n, err
+}
@@ -0,0 +1,79 @@
+package taskmastermetrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stratg5/taskmaster"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []taskmaster.RegisteredTask{
+		{
+			Path:           `\Taskmaster\Backup`,
+			Enabled:        true,
+			State:          taskmaster.TASK_STATE_READY,
+			MissedRuns:     2,
+			LastRunTime:    now.Add(-time.Hour),
+			NextRunTime:    now.Add(-90 * time.Second),
+			LastTaskResult: 0x80070005,
+		},
+		{
+			Path:        `\Taskmaster\Never "Run"`,
+			State:       taskmaster.TASK_STATE_DISABLED,
+			LastRunTime: time.Date(1999, time.November, 30, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	var buf strings.Builder
+	n, err := writeMetrics(&buf, tasks, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("expected %d bytes to be written, got %d", buf.Len(), n)
+	}
+
+	metrics := buf.String()
+	expected := []string{
+		"# TYPE taskmaster_task_state gauge\n",
+		`taskmaster_task_state{path="\\Taskmaster\\Backup",state="Ready"} 1` + "\n",
+		`taskmaster_task_state{path="\\Taskmaster\\Backup",state="Running"} 0` + "\n",
+		`taskmaster_task_state{path="\\Taskmaster\\Never \"Run\"",state="Disabled"} 1` + "\n",
+		`taskmaster_task_enabled{path="\\Taskmaster\\Backup"} 1` + "\n",
+		`taskmaster_task_last_result{path="\\Taskmaster\\Backup"} 2147942405` + "\n",
+		`taskmaster_task_seconds_since_last_run{path="\\Taskmaster\\Backup"} 3600` + "\n",
+		`taskmaster_task_missed_runs{path="\\Taskmaster\\Backup"} 2` + "\n",
+		`taskmaster_task_next_run_lag_seconds{path="\\Taskmaster\\Backup"} 90` + "\n",
+		`taskmaster_task_next_run_lag_seconds{path="\\Taskmaster\\Never \"Run\""} 0` + "\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+	if strings.Contains(metrics, `taskmaster_task_seconds_since_last_run{path="\\Taskmaster\\Never`) {
+		t.Errorf("a task that has never run shouldn't have a last run metric:\n%s", metrics)
+	}
+}
+
+func TestTaskFolder(t *testing.T) {
+	tests := []struct {
+		path   string
+		folder string
+	}{
+		{`\`, `\`},
+		{"", `\`},
+		{`\Taskmaster`, `\Taskmaster`},
+		{`\Taskmaster\`, `\Taskmaster`},
+		{`\Taskmaster\Sub`, `\Taskmaster\Sub`},
+	}
+
+	for _, test := range tests {
+		if folder := taskFolder(test.path); folder != test.folder {
+			t.Errorf("taskFolder(%q): expected %q, got %q", test.path, test.folder, folder)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package taskmastertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stratg5/taskmaster"
+)
+
+// testServiceContract checks the errors that every implementation of
+// taskmaster.Service must return, so that code tested against FakeService
+// handles the errors of TaskService the same way.
+func testServiceContract(t *testing.T, service taskmaster.Service) {
+	const folder = `\TaskmasterContract`
+	defer service.DeleteFolder(folder, true)
+
+	def := service.NewTaskDefinition()
+	def.AddAction(taskmaster.ExecAction{Path: "cmd.exe", Args: "/c exit 0"})
+
+	task, _, err := service.CreateTask(folder+`\Task`, def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Release()
+	if err = service.DeleteTask(folder + `\Task`); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := folder + `\Task`
+	if _, err = service.GetRegisteredTask(missing); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Errorf("GetRegisteredTask: expected ErrTaskNotFound, got %v", err)
+	}
+	if _, err = service.UpdateTask(missing, def); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Errorf("UpdateTask: expected ErrTaskNotFound, got %v", err)
+	}
+	if err = service.DeleteTask(missing); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Errorf("DeleteTask: expected ErrTaskNotFound, got %v", err)
+	}
+	if err = service.RunTask(missing, nil); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Errorf("RunTask: expected ErrTaskNotFound, got %v", err)
+	}
+	if err = service.SetTaskEnabled(missing, false); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Errorf("SetTaskEnabled: expected ErrTaskNotFound, got %v", err)
+	}
+	if _, err = service.GetTasksInFolder(folder + `\Missing`); !errors.Is(err, taskmaster.ErrFolderNotFound) {
+		t.Errorf("GetTasksInFolder: expected ErrFolderNotFound, got %v", err)
+	}
+	if _, _, err = service.CreateTask("Task", def, false); !errors.Is(err, taskmaster.ErrInvalidPath) {
+		t.Errorf("CreateTask: expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestFakeServiceContract(t *testing.T) {
+	testServiceContract(t, NewFakeService())
+}
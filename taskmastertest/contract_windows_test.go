@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package taskmastertest
+
+import (
+	"testing"
+
+	"github.com/stratg5/taskmaster"
+)
+
+func TestTaskServiceContract(t *testing.T) {
+	taskService, err := taskmaster.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	testServiceContract(t, &taskService)
+}
@@ -0,0 +1,759 @@
+// Package taskmastertest provides an in-memory implementation of
+// taskmaster.Service, so that code which manages scheduled tasks can be unit
+// tested on any platform, without a Task Scheduler service or administrator
+// rights.
+package taskmastertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stratg5/taskmaster"
+	"github.com/stratg5/taskmaster/internal/enumopts"
+)
+
+// FakeService is an in-memory taskmaster.Service. It simulates task folders,
+// the registration of tasks, their enabled and running states, and the instances
+// that running them starts, including the MultipleInstances policy of each task.
+// Definitions are validated with Definition.Validate, but the checks that only
+// the Task Scheduler service makes, such as of credentials, aren't simulated,
+// and the AfterFill hooks of definitions aren't called.
+// Errors wrap the same taskmaster errors as those of TaskService, such as
+// ErrTaskNotFound. A FakeService is safe for concurrent use.
+//
+// Instances keep running until Complete or StopTask is called for their task,
+// unless RunHandler is set.
+type FakeService struct {
+	// RunHandler, if not nil, is called with the path and arguments of every
+	// instance that starts, and the instance completes with the exit code it
+	// returns as soon as it returns.
+	RunHandler func(path string, args []string) int
+	// Now returns the current time, which is used for the run times of tasks.
+	// Defaults to time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	changed   chan struct{} // closed and replaced whenever an instance starts or completes
+	connected bool
+	folders   map[string]string    // the paths of the folders, keyed by their lower-cased path
+	tasks     map[string]*fakeTask // keyed by the lower-cased path of the task
+	instances int                  // the number of instances started so far, used for their GUIDs
+}
+
+type fakeTask struct {
+	task      taskmaster.RegisteredTask
+	instances []*fakeInstance // the running instances, followed by the queued ones
+}
+
+type fakeInstance struct {
+	guid     string
+	args     []string
+	queued   bool
+	done     bool
+	exitCode int
+}
+
+var _ taskmaster.Service = (*FakeService)(nil)
+
+// NewFakeService returns a connected FakeService without any tasks or folders
+// other than the root folder.
+func NewFakeService() *FakeService {
+	return &FakeService{
+		changed:   make(chan struct{}),
+		connected: true,
+		folders:   map[string]string{`\`: `\`},
+		tasks:     make(map[string]*fakeTask),
+	}
+}
+
+// Connected returns true until Disconnect is called. Disconnecting doesn't
+// affect the other methods, so that deferred calls to Disconnect are harmless.
+func (f *FakeService) Connected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.connected
+}
+
+func (f *FakeService) Disconnect() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connected = false
+}
+
+func (f *FakeService) NewTaskDefinition() taskmaster.Definition {
+	return taskmaster.NewDefinition()
+}
+
+func (f *FakeService) GetRunningTasks() (taskmaster.RunningTaskCollection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	runningTasks := make(taskmaster.RunningTaskCollection, 0)
+	for _, task := range f.sortedTasks() {
+		for _, instance := range task.instances {
+			runningTasks = append(runningTasks, task.runningTask(instance))
+		}
+	}
+
+	return runningTasks, nil
+}
+
+func (f *FakeService) GetRegisteredTask(path string) (taskmaster.RegisteredTask, error) {
+	if !isValidPath(path) {
+		return taskmaster.RegisteredTask{}, taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		return taskmaster.RegisteredTask{}, fmt.Errorf("error getting registered task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+
+	return f.registeredTask(task), nil
+}
+
+// GetRegisteredTasks returns every registered task, sorted by path.
+func (f *FakeService) GetRegisteredTasks(opts ...taskmaster.EnumOption) (taskmaster.RegisteredTaskCollection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tasks := make(taskmaster.RegisteredTaskCollection, 0, len(f.tasks))
+	for _, task := range f.sortedTasks() {
+		tasks = append(tasks, f.registeredTask(task))
+	}
+
+	return filterTasks(tasks, opts), nil
+}
+
+// GetTasksInFolder returns the registered tasks directly inside the folder at
+// path, or in its subfolders too if IncludeSubfolders is passed, sorted by path.
+func (f *FakeService) GetTasksInFolder(path string, opts ...taskmaster.EnumOption) (taskmaster.RegisteredTaskCollection, error) {
+	if !isValidPath(path) {
+		return nil, taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.folders[key(path)]; !ok {
+		return nil, fmt.Errorf("error getting folder %s: %w", path, taskmaster.ErrFolderNotFound)
+	}
+
+	includeSubfolders := newEnumConfig(opts).IncludeSubfolders
+	tasks := make(taskmaster.RegisteredTaskCollection, 0)
+	for _, task := range f.sortedTasks() {
+		if strings.EqualFold(parentFolder(task.task.Path), path) || (includeSubfolders && isInFolder(task.task.Path, path)) {
+			tasks = append(tasks, f.registeredTask(task))
+		}
+	}
+
+	return filterTasks(tasks, opts), nil
+}
+
+// FindTasks returns the registered tasks in the folder of query and its
+// subfolders that match query, sorted by path.
+func (f *FakeService) FindTasks(query taskmaster.Query) (taskmaster.RegisteredTaskCollection, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	folder := query.Folder
+	if folder == "" {
+		folder = `\`
+	}
+	if !isValidPath(folder) {
+		return nil, taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.folders[key(folder)]; !ok {
+		return nil, fmt.Errorf("error getting folder %s: %w", folder, taskmaster.ErrFolderNotFound)
+	}
+
+	var tasks taskmaster.RegisteredTaskCollection
+	for _, task := range f.sortedTasks() {
+		if isInFolder(task.task.Path, folder) && query.Matches(task.task.Definition) {
+			tasks = append(tasks, f.registeredTask(task))
+		}
+	}
+
+	return tasks, nil
+}
+
+func (f *FakeService) CreateTask(path string, newTaskDef taskmaster.Definition, overwrite bool) (taskmaster.RegisteredTask, bool, error) {
+	return f.CreateTaskEx(path, newTaskDef, "", "", newTaskDef.Principal.LogonType, overwrite)
+}
+
+// CreateTaskEx registers newTaskDef at path, creating the folders of path that
+// don't exist. If username isn't empty, the task runs as username. It returns
+// false without changing the existing task if overwrite is false and a task
+// already exists at path. Replacing a task doesn't affect its running instances.
+func (f *FakeService) CreateTaskEx(path string, newTaskDef taskmaster.Definition, username, password string, logonType taskmaster.TaskLogonType, overwrite bool) (taskmaster.RegisteredTask, bool, error) {
+	if !isValidPath(path) || strings.HasSuffix(path, `\`) {
+		return taskmaster.RegisteredTask{}, false, taskmaster.ErrInvalidPath
+	}
+	if err := newTaskDef.Validate(); err != nil {
+		return taskmaster.RegisteredTask{}, false, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.folders[key(path)]; ok {
+		return taskmaster.RegisteredTask{}, false, fmt.Errorf("error creating registered task %s: %w", path, taskmaster.ErrAlreadyExists)
+	}
+	task, exists := f.tasks[key(path)]
+	if exists && !overwrite {
+		return f.registeredTask(task), false, nil
+	}
+	if !exists {
+		task = &fakeTask{}
+		f.tasks[key(path)] = task
+	}
+	f.createFolder(parentFolder(path))
+
+	def := cloneDefinition(newTaskDef)
+	if username != "" {
+		def.Principal.UserID = username
+		def.Principal.GroupID = ""
+	}
+	def.Principal.LogonType = logonType
+
+	// the Task Scheduler service keeps the run history of a task when it's
+	// registered again
+	lastRunTime, lastTaskResult := task.task.LastRunTime, task.task.LastTaskResult
+	if !exists {
+		lastTaskResult = taskmaster.SCHED_S_TASK_HAS_NOT_RUN
+	}
+
+	name := path[strings.LastIndex(path, `\`)+1:]
+	task.task = taskmaster.RegisteredTask{
+		Name:           name,
+		Path:           path,
+		Definition:     def,
+		Enabled:        def.Settings.Enabled,
+		LastRunTime:    lastRunTime,
+		LastTaskResult: lastTaskResult,
+	}
+	task.updateState()
+
+	return f.registeredTask(task), true, nil
+}
+
+// UpdateTask replaces the definition of the registered task at path. If the task
+// doesn't exist, an error wrapping ErrTaskNotFound is returned.
+func (f *FakeService) UpdateTask(path string, newTaskDef taskmaster.Definition) (taskmaster.RegisteredTask, error) {
+	if !isValidPath(path) {
+		return taskmaster.RegisteredTask{}, taskmaster.ErrInvalidPath
+	}
+	if err := newTaskDef.Validate(); err != nil {
+		return taskmaster.RegisteredTask{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		return taskmaster.RegisteredTask{}, fmt.Errorf("error updating %s task: %w", path, taskmaster.ErrTaskNotFound)
+	}
+	task.task.Definition = cloneDefinition(newTaskDef)
+	task.task.Enabled = newTaskDef.Settings.Enabled
+	task.updateState()
+
+	return f.registeredTask(task), nil
+}
+
+// DeleteTask deletes the registered task at path. Its running instances are
+// stopped, so that code waiting for them returns.
+func (f *FakeService) DeleteTask(path string) error {
+	if !isValidPath(path) {
+		return taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		return fmt.Errorf("error deleting task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+	f.deleteTask(task)
+
+	return nil
+}
+
+func (f *FakeService) SetTaskEnabled(path string, enabled bool) error {
+	if !isValidPath(path) {
+		return taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		return fmt.Errorf("error getting registered task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+	task.task.Enabled = enabled
+	task.task.Definition.Settings.Enabled = enabled
+	task.updateState()
+
+	return nil
+}
+
+// RunTask starts an instance of the registered task at path. Like the Task
+// Scheduler service, it follows the MultipleInstances policy of the task when an
+// instance is already running: with TASK_INSTANCES_IGNORE_NEW no instance is
+// started, with TASK_INSTANCES_QUEUE the instance is queued until the running
+// ones complete, and with TASK_INSTANCES_STOP_EXISTING the running instances are
+// stopped first.
+func (f *FakeService) RunTask(path string, args []string) error {
+	_, err := f.run(path, args)
+	return err
+}
+
+// StopTask stops every running and queued instance of the registered task at
+// path. Stopped instances complete with the exit code SCHED_S_TASK_TERMINATED.
+func (f *FakeService) StopTask(path string) error {
+	if !isValidPath(path) {
+		return taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		return fmt.Errorf("error getting registered task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+	f.stopInstances(task)
+
+	return nil
+}
+
+// RunAndWait runs the registered task at path like RunTask, waits for the
+// instance that was started to complete, and returns its exit code. If no
+// instance was started because one was already running, RunAndWait waits for
+// every instance to complete instead and returns the last exit code of the task.
+func (f *FakeService) RunAndWait(ctx context.Context, path string, args []string) (int, error) {
+	instance, err := f.run(path, args)
+	if err != nil {
+		return 0, err
+	}
+
+	if instance == nil {
+		if err = f.WaitForAllInstancesToExit(ctx, path); err != nil {
+			return 0, err
+		}
+		task, err := f.GetRegisteredTask(path)
+		if err != nil {
+			return 0, err
+		}
+		return int(task.LastTaskResult), nil
+	}
+
+	var exitCode int
+	err = f.wait(ctx, func() bool {
+		exitCode = instance.exitCode
+		return instance.done
+	})
+
+	return exitCode, err
+}
+
+func (f *FakeService) WaitForAllInstancesToExit(ctx context.Context, path string) error {
+	if !isValidPath(path) {
+		return taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	task, ok := f.tasks[key(path)]
+	f.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("error getting registered task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+
+	return f.wait(ctx, func() bool {
+		return len(task.instances) == 0
+	})
+}
+
+// Complete completes the oldest running instance of the registered task at path
+// with exitCode, and starts the next queued instance if there is one. An error is
+// returned if no instance of the task is running.
+func (f *FakeService) Complete(path string, exitCode int) error {
+	f.mu.Lock()
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("error getting registered task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+	if len(task.instances) == 0 || task.instances[0].queued {
+		f.mu.Unlock()
+		return fmt.Errorf("no instance of the registered task %s is running", path)
+	}
+	next := f.complete(task, task.instances[0], exitCode)
+	f.mu.Unlock()
+
+	f.handle(path, next)
+
+	return nil
+}
+
+// CreateFolder creates the folder at path and its parent folders. Creating a
+// folder that already exists is not an error. The security descriptor isn't
+// simulated, so sddl is ignored.
+func (f *FakeService) CreateFolder(path, sddl string) error {
+	if !isValidPath(path) {
+		return taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.createFolder(path)
+
+	return nil
+}
+
+// DeleteFolder deletes the folder at path. If deleteRecursively is false, it
+// returns false without deleting the folder if it contains tasks or folders.
+// Otherwise, the tasks and subfolders of the folder are deleted as well.
+func (f *FakeService) DeleteFolder(path string, deleteRecursively bool) (bool, error) {
+	if !isValidPath(path) {
+		return false, taskmaster.ErrInvalidPath
+	}
+	path = strings.TrimSuffix(path, `\`)
+	if path == "" {
+		return false, errors.New("error deleting folder: the root folder can't be deleted")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.folders[key(path)]; !ok {
+		return false, fmt.Errorf("error getting folder %s: %w", path, taskmaster.ErrFolderNotFound)
+	}
+
+	var tasks []*fakeTask
+	for _, task := range f.tasks {
+		if isInFolder(task.task.Path, path) {
+			tasks = append(tasks, task)
+		}
+	}
+	var folders []string
+	for folderKey, folder := range f.folders {
+		if folderKey != key(path) && isInFolder(folder, path) {
+			folders = append(folders, folderKey)
+		}
+	}
+	if !deleteRecursively && (len(tasks) > 0 || len(folders) > 0) {
+		return false, nil
+	}
+
+	for _, task := range tasks {
+		f.deleteTask(task)
+	}
+	for _, folderKey := range folders {
+		delete(f.folders, folderKey)
+	}
+	delete(f.folders, key(path))
+
+	return true, nil
+}
+
+// run starts an instance of the task at path, and returns it, or nil if no
+// instance was started because of the MultipleInstances policy of the task.
+func (f *FakeService) run(path string, args []string) (*fakeInstance, error) {
+	if !isValidPath(path) {
+		return nil, taskmaster.ErrInvalidPath
+	}
+
+	f.mu.Lock()
+	task, ok := f.tasks[key(path)]
+	if !ok {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("error getting registered task %s: %w", path, taskmaster.ErrTaskNotFound)
+	}
+	if !task.task.Enabled {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("error running registered task %s: cannot run a disabled task", path)
+	}
+	if !task.task.Definition.Settings.AllowDemandStart {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("error running registered task %s: %w", path, taskmaster.ErrAccessDenied)
+	}
+
+	instance := &fakeInstance{args: append([]string(nil), args...)}
+	if len(task.instances) > 0 {
+		switch task.task.Definition.Settings.MultipleInstances {
+		case taskmaster.TASK_INSTANCES_IGNORE_NEW:
+			f.mu.Unlock()
+			return nil, nil
+		case taskmaster.TASK_INSTANCES_QUEUE:
+			instance.queued = true
+		case taskmaster.TASK_INSTANCES_STOP_EXISTING:
+			f.stopInstances(task)
+		}
+	}
+
+	f.instances++
+	instance.guid = fmt.Sprintf("{00000000-0000-0000-0000-%012X}", f.instances)
+	task.instances = append(task.instances, instance)
+	if !instance.queued {
+		task.task.LastRunTime = f.now()
+	}
+	task.updateState()
+	f.notify()
+	f.mu.Unlock()
+
+	if !instance.queued {
+		f.handle(path, instance)
+	}
+
+	return instance, nil
+}
+
+// handle completes instance and the instances queued after it with RunHandler,
+// if it's set.
+func (f *FakeService) handle(path string, instance *fakeInstance) {
+	for f.RunHandler != nil && instance != nil {
+		exitCode := f.RunHandler(path, instance.args)
+
+		f.mu.Lock()
+		task, ok := f.tasks[key(path)]
+		if !ok || instance.done {
+			f.mu.Unlock()
+			return
+		}
+		instance = f.complete(task, instance, exitCode)
+		f.mu.Unlock()
+	}
+}
+
+// complete completes instance with exitCode, and returns the queued instance
+// that was started in its place, if any.
+func (f *FakeService) complete(task *fakeTask, instance *fakeInstance, exitCode int) *fakeInstance {
+	instance.done = true
+	instance.exitCode = exitCode
+	task.task.LastTaskResult = taskmaster.TaskResult(uint32(exitCode))
+	for i, running := range task.instances {
+		if running == instance {
+			task.instances = append(task.instances[:i], task.instances[i+1:]...)
+			break
+		}
+	}
+
+	var next *fakeInstance
+	if len(task.instances) > 0 && task.instances[0].queued {
+		next = task.instances[0]
+		next.queued = false
+		task.task.LastRunTime = f.now()
+	}
+	task.updateState()
+	f.notify()
+
+	return next
+}
+
+func (f *FakeService) stopInstances(task *fakeTask) {
+	for _, instance := range task.instances {
+		instance.done = true
+		instance.exitCode = int(taskmaster.SCHED_S_TASK_TERMINATED)
+	}
+	if len(task.instances) > 0 {
+		task.task.LastTaskResult = taskmaster.SCHED_S_TASK_TERMINATED
+	}
+	task.instances = nil
+	task.updateState()
+	f.notify()
+}
+
+func (f *FakeService) deleteTask(task *fakeTask) {
+	f.stopInstances(task)
+	delete(f.tasks, key(task.task.Path))
+}
+
+// wait blocks until done returns true or ctx is done. done is called with f.mu
+// held.
+func (f *FakeService) wait(ctx context.Context, done func() bool) error {
+	for {
+		f.mu.Lock()
+		finished := done()
+		changed := f.changed
+		f.mu.Unlock()
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up the goroutines that are waiting for instances to complete.
+func (f *FakeService) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *FakeService) createFolder(path string) {
+	var folderPath string
+	for _, component := range strings.Split(strings.Trim(path, `\`), `\`) {
+		if component == "" {
+			continue
+		}
+		folderPath += `\` + component
+		if _, ok := f.folders[key(folderPath)]; !ok {
+			f.folders[key(folderPath)] = folderPath
+		}
+	}
+}
+
+func (f *FakeService) sortedTasks() []*fakeTask {
+	tasks := make([]*fakeTask, 0, len(f.tasks))
+	for _, task := range f.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return key(tasks[i].task.Path) < key(tasks[j].task.Path)
+	})
+
+	return tasks
+}
+
+// registeredTask returns a copy of the registered task whose definition can be
+// modified by the caller.
+func (f *FakeService) registeredTask(task *fakeTask) taskmaster.RegisteredTask {
+	registeredTask := task.task
+	registeredTask.Definition = cloneDefinition(task.task.Definition)
+	if registeredTask.Enabled {
+		registeredTask.NextRunTime = nextRunTime(registeredTask.Definition, f.now())
+	}
+
+	return registeredTask
+}
+
+func (f *FakeService) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+
+	return time.Now()
+}
+
+func (t *fakeTask) runningTask(instance *fakeInstance) taskmaster.RunningTask {
+	state := taskmaster.TASK_STATE_RUNNING
+	if instance.queued {
+		state = taskmaster.TASK_STATE_QUEUED
+	}
+
+	return taskmaster.RunningTask{
+		InstanceGUID: instance.guid,
+		Name:         t.task.Name,
+		Path:         t.task.Path,
+		State:        state,
+	}
+}
+
+func (t *fakeTask) updateState() {
+	switch {
+	case len(t.instances) > 0 && !t.instances[0].queued:
+		t.task.State = taskmaster.TASK_STATE_RUNNING
+	case len(t.instances) > 0:
+		t.task.State = taskmaster.TASK_STATE_QUEUED
+	case !t.task.Enabled:
+		t.task.State = taskmaster.TASK_STATE_DISABLED
+	default:
+		t.task.State = taskmaster.TASK_STATE_READY
+	}
+}
+
+// nextRunTime returns the earliest time after now that a trigger of def fires,
+// or the zero time if none of them will.
+func nextRunTime(def taskmaster.Definition, now time.Time) time.Time {
+	var next time.Time
+	for _, trigger := range def.Triggers {
+		occurrences := trigger.NextOccurrences(now, 1)
+		if len(occurrences) > 0 && (next.IsZero() || occurrences[0].Before(next)) {
+			next = occurrences[0]
+		}
+	}
+
+	return next
+}
+
+// cloneDefinition copies the slices of def, so that the copy can be modified
+// without affecting def.
+func cloneDefinition(def taskmaster.Definition) taskmaster.Definition {
+	def.Actions = append([]taskmaster.Action(nil), def.Actions...)
+	def.Triggers = append([]taskmaster.Trigger(nil), def.Triggers...)
+	def.Principal.RequiredPrivileges = append([]string(nil), def.Principal.RequiredPrivileges...)
+	if def.Settings.MaintenanceSettings != nil {
+		maintenanceSettings := *def.Settings.MaintenanceSettings
+		def.Settings.MaintenanceSettings = &maintenanceSettings
+	}
+
+	return def
+}
+
+// filterTasks returns the tasks that an enumeration of TaskService with opts
+// would include, in the same order.
+func filterTasks(tasks taskmaster.RegisteredTaskCollection, opts []taskmaster.EnumOption) taskmaster.RegisteredTaskCollection {
+	config := newEnumConfig(opts)
+
+	filtered := make(taskmaster.RegisteredTaskCollection, 0, len(tasks))
+	for _, task := range tasks {
+		if config.Includes(task.Definition.Settings.Hidden, task.Enabled) {
+			filtered = append(filtered, task)
+		}
+	}
+
+	return filtered
+}
+
+func newEnumConfig(opts []taskmaster.EnumOption) enumopts.Config {
+	var config enumopts.Config
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return config
+}
+
+func key(path string) string {
+	return strings.ToLower(path)
+}
+
+func isValidPath(path string) bool {
+	return path != "" && path[0] == '\\'
+}
+
+func parentFolder(path string) string {
+	i := strings.LastIndex(path, `\`)
+	if i <= 0 {
+		return `\`
+	}
+
+	return path[:i]
+}
+
+// isInFolder returns true if path is inside folder or one of its subfolders.
+func isInFolder(path, folder string) bool {
+	folder = strings.TrimSuffix(folder, `\`) + `\`
+	return strings.HasPrefix(key(path), key(folder))
+}
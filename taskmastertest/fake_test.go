@@ -0,0 +1,340 @@
+package taskmastertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stratg5/taskmaster"
+)
+
+func newDefinition() taskmaster.Definition {
+	def := taskmaster.NewDefinition()
+	def.AddAction(taskmaster.ExecAction{Path: "cmd.exe", Args: "/c exit 0"})
+
+	return def
+}
+
+func TestFakeServiceRegistration(t *testing.T) {
+	service := NewFakeService()
+
+	task, created, err := service.CreateTask(`\Taskmaster\Backup`, newDefinition(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || task.Name != "Backup" || task.State != taskmaster.TASK_STATE_READY || task.LastTaskResult != taskmaster.SCHED_S_TASK_HAS_NOT_RUN {
+		t.Fatalf("unexpected task %+v", task)
+	}
+
+	def := newDefinition()
+	def.RegistrationInfo.Description = "updated"
+	if _, created, err = service.CreateTask(`\taskmaster\backup`, def, false); err != nil || created {
+		t.Fatalf("expected the existing task to be kept, got %t %v", created, err)
+	}
+	if _, created, err = service.CreateTask(`\Taskmaster\Backup`, def, true); err != nil || !created {
+		t.Fatalf("expected the task to be replaced, got %t %v", created, err)
+	}
+
+	task, err = service.GetRegisteredTask(`\TASKMASTER\BACKUP`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Definition.RegistrationInfo.Description != "updated" {
+		t.Fatal("expected the definition of the replaced task")
+	}
+
+	// the returned definitions must not share memory with the fake
+	task.Definition.Actions[0] = taskmaster.ExecAction{Path: "changed.exe"}
+	task, _ = service.GetRegisteredTask(`\Taskmaster\Backup`)
+	if task.Definition.Actions[0].(taskmaster.ExecAction).Path != "cmd.exe" {
+		t.Fatal("modifying a returned definition changed the registered task")
+	}
+
+	tasks, err := service.GetTasksInFolder(`\Taskmaster`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task in the created folder, got %d", len(tasks))
+	}
+	tasks, err = service.GetTasksInFolder(`\`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 0 {
+		t.Fatalf("expected no task directly in the root folder, got %d", len(tasks))
+	}
+	tasks, err = service.GetTasksInFolder(`\`, taskmaster.IncludeSubfolders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task in the subfolders of the root folder, got %d", len(tasks))
+	}
+
+	if _, err = service.GetRegisteredTask(`\Missing`); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+	if _, _, err = service.CreateTask("Backup", newDefinition(), false); !errors.Is(err, taskmaster.ErrInvalidPath) {
+		t.Fatalf("expected ErrInvalidPath, got %v", err)
+	}
+	if _, _, err = service.CreateTask(`\Invalid`, taskmaster.NewDefinition(), false); err == nil {
+		t.Fatal("expected an error for a definition without actions")
+	}
+	if _, err = service.UpdateTask(`\Missing`, newDefinition()); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+
+	if err = service.DeleteTask(`\Taskmaster\Backup`); err != nil {
+		t.Fatal(err)
+	}
+	if err = service.DeleteTask(`\Taskmaster\Backup`); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestFakeServiceEnumOptions(t *testing.T) {
+	service := NewFakeService()
+
+	hidden := newDefinition()
+	hidden.Settings.Hidden = true
+	disabled := newDefinition()
+	disabled.Settings.Enabled = false
+	for path, def := range map[string]taskmaster.Definition{`\B`: newDefinition(), `\A`: hidden, `\C`: disabled} {
+		if _, _, err := service.CreateTask(path, def, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		opts  []taskmaster.EnumOption
+		paths []string
+	}{
+		{nil, []string{`\A`, `\B`, `\C`}},
+		{[]taskmaster.EnumOption{taskmaster.ExcludeHidden()}, []string{`\B`, `\C`}},
+		{[]taskmaster.EnumOption{taskmaster.OnlyEnabled()}, []string{`\A`, `\B`}},
+		{[]taskmaster.EnumOption{taskmaster.OnlyDisabled()}, []string{`\C`}},
+	}
+
+	for _, test := range tests {
+		tasks, err := service.GetRegisteredTasks(test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != len(test.paths) {
+			t.Errorf("expected %d tasks, got %d", len(test.paths), len(tasks))
+			continue
+		}
+		for i, task := range tasks {
+			if task.Path != test.paths[i] {
+				t.Errorf("expected task %s, got %s", test.paths[i], task.Path)
+			}
+		}
+	}
+
+	task, _ := service.GetRegisteredTask(`\C`)
+	if task.State != taskmaster.TASK_STATE_DISABLED {
+		t.Fatalf("expected a disabled task, got %s", task.State)
+	}
+	if err := service.SetTaskEnabled(`\C`, true); err != nil {
+		t.Fatal(err)
+	}
+	task, _ = service.GetRegisteredTask(`\C`)
+	if !task.Enabled || !task.Definition.Settings.Enabled || task.State != taskmaster.TASK_STATE_READY {
+		t.Fatalf("expected an enabled task, got %+v", task)
+	}
+}
+
+func TestFakeServiceFindTasks(t *testing.T) {
+	service := NewFakeService()
+
+	script := taskmaster.NewDefinition()
+	script.AddAction(taskmaster.ExecAction{Path: `C:\Scripts\Clean.ps1`})
+	for _, path := range []string{`\Scripts\Clean`, `\Scripts\Nested\Clean`, `\Clean`} {
+		if _, _, err := service.CreateTask(path, script, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := service.CreateTask(`\Scripts\Other`, newDefinition(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := service.FindTasks(taskmaster.Query{Folder: `\Scripts`, ActionPathMatches: "*.ps1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Path != `\Scripts\Clean` || tasks[1].Path != `\Scripts\Nested\Clean` {
+		t.Fatalf("unexpected tasks %+v", tasks)
+	}
+
+	if _, err = service.FindTasks(taskmaster.Query{ActionPathMatches: "["}); err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+	if _, err = service.FindTasks(taskmaster.Query{Folder: `\Missing`}); !errors.Is(err, taskmaster.ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+}
+
+func TestFakeServiceRun(t *testing.T) {
+	service := NewFakeService()
+	now := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.UTC)
+	service.Now = func() time.Time { return now }
+
+	if _, _, err := service.CreateTask(`\Run`, newDefinition(), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RunTask(`\Run`, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	task, _ := service.GetRegisteredTask(`\Run`)
+	if task.State != taskmaster.TASK_STATE_RUNNING || !task.LastRunTime.Equal(now) {
+		t.Fatalf("expected a running task, got %+v", task)
+	}
+	runningTasks, _ := service.GetRunningTasks()
+	if len(runningTasks) != 1 || runningTasks[0].Path != `\Run` || runningTasks[0].InstanceGUID == "" {
+		t.Fatalf("unexpected running tasks %+v", runningTasks)
+	}
+
+	if err := service.Complete(`\Run`, 2); err != nil {
+		t.Fatal(err)
+	}
+	task, _ = service.GetRegisteredTask(`\Run`)
+	if task.State != taskmaster.TASK_STATE_READY || task.LastTaskResult != 2 {
+		t.Fatalf("expected a completed task, got %+v", task)
+	}
+	if err := service.Complete(`\Run`, 0); err == nil {
+		t.Fatal("expected an error completing a task that isn't running")
+	}
+
+	if err := service.RunTask(`\Run`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.StopTask(`\Run`); err != nil {
+		t.Fatal(err)
+	}
+	task, _ = service.GetRegisteredTask(`\Run`)
+	if task.State != taskmaster.TASK_STATE_READY || task.LastTaskResult != taskmaster.SCHED_S_TASK_TERMINATED {
+		t.Fatalf("expected a stopped task, got %+v", task)
+	}
+
+	if err := service.SetTaskEnabled(`\Run`, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RunTask(`\Run`, nil); err == nil {
+		t.Fatal("expected an error running a disabled task")
+	}
+}
+
+func TestFakeServiceMultipleInstances(t *testing.T) {
+	service := NewFakeService()
+
+	queue := newDefinition()
+	queue.Settings.MultipleInstances = taskmaster.TASK_INSTANCES_QUEUE
+	ignore := newDefinition()
+	ignore.Settings.MultipleInstances = taskmaster.TASK_INSTANCES_IGNORE_NEW
+	for path, def := range map[string]taskmaster.Definition{`\Queue`: queue, `\Ignore`: ignore} {
+		if _, _, err := service.CreateTask(path, def, false); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := service.RunTask(path, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	runningTasks, _ := service.GetRunningTasks()
+	if len(runningTasks) != 3 {
+		t.Fatalf("expected 3 instances, got %d", len(runningTasks))
+	}
+	if runningTasks[1].Path != `\Queue` || runningTasks[1].State != taskmaster.TASK_STATE_RUNNING || runningTasks[2].State != taskmaster.TASK_STATE_QUEUED {
+		t.Fatalf("expected a running and a queued instance, got %+v", runningTasks[1:])
+	}
+
+	if err := service.Complete(`\Queue`, 0); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := service.GetRegisteredTask(`\Queue`)
+	if task.State != taskmaster.TASK_STATE_RUNNING {
+		t.Fatalf("expected the queued instance to start, got %s", task.State)
+	}
+	if err := service.Complete(`\Queue`, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Complete(`\Queue`, 0); err == nil {
+		t.Fatal("expected only 2 instances of the queued task")
+	}
+}
+
+func TestFakeServiceRunAndWait(t *testing.T) {
+	service := NewFakeService()
+	service.RunHandler = func(path string, args []string) int {
+		return len(args)
+	}
+
+	if _, _, err := service.CreateTask(`\Wait`, newDefinition(), false); err != nil {
+		t.Fatal(err)
+	}
+	exitCode, err := service.RunAndWait(context.Background(), `\Wait`, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 2 {
+		t.Fatalf("expected exit code 2, got %d", exitCode)
+	}
+
+	service.RunHandler = nil
+	go func() {
+		for service.Complete(`\Wait`, 3) != nil {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if exitCode, err = service.RunAndWait(context.Background(), `\Wait`, nil); err != nil || exitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d %v", exitCode, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = service.RunAndWait(ctx, `\Wait`, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if err = service.DeleteTask(`\Wait`); err != nil {
+		t.Fatal(err)
+	}
+	if err = service.WaitForAllInstancesToExit(context.Background(), `\Wait`); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestFakeServiceFolders(t *testing.T) {
+	service := NewFakeService()
+
+	if err := service.CreateFolder(`\A\B`, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.CreateFolder(`\a\b`, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.CreateTask(`\A\B\Task`, newDefinition(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := service.DeleteFolder(`\A`, false)
+	if err != nil || deleted {
+		t.Fatalf("expected a folder that isn't empty to be kept, got %t %v", deleted, err)
+	}
+	if deleted, err = service.DeleteFolder(`\A`, true); err != nil || !deleted {
+		t.Fatalf("expected the folder to be deleted, got %t %v", deleted, err)
+	}
+	if _, err = service.GetRegisteredTask(`\A\B\Task`); !errors.Is(err, taskmaster.ErrTaskNotFound) {
+		t.Fatalf("expected the task to be deleted, got %v", err)
+	}
+	if _, err = service.GetTasksInFolder(`\A\B`); !errors.Is(err, taskmaster.ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+	if _, err = service.DeleteFolder(`\A`, false); !errors.Is(err, taskmaster.ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+}
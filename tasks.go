@@ -3,38 +3,125 @@
 package taskmaster
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
 
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
 )
 
-func (d *Definition) AddAction(action Action) {
-	d.Actions = append(d.Actions, action)
-}
+// XML returns the XML representation of the definition, in the same format that
+// the Task Scheduler service stores it in. A temporary connection to the local
+// Task Scheduler service is used to generate the XML.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskdefinition-get_xmltext
+func (d Definition) XML() (string, error) {
+	taskService, err := Connect()
+	if err != nil {
+		return "", err
+	}
+	defer taskService.Disconnect()
 
-func (d *Definition) AddTrigger(trigger Trigger) {
-	d.Triggers = append(d.Triggers, trigger)
+	return taskService.definitionXML(d)
 }
 
-// Refresh refreshes all of the local instance variables of the running task.
+// Refresh re-reads CurrentAction, EnginePID and State of the running task from
+// the Task Scheduler service, so that an instance can be polled without
+// enumerating the running tasks again. If the instance has completed, Refresh
+// doesn't return an error; instead Completed is set, CurrentAction and EnginePID
+// are cleared, and State is set to TASK_STATE_UNKNOWN, as the instance no longer
+// has a state; the state of the registered task at Path can be read instead.
+// Once an instance has completed, Refresh doesn't call the Task Scheduler service
+// anymore.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-irunningtask-refresh
-func (r RunningTask) Refresh() error {
+func (r *RunningTask) Refresh() error {
+	if r.Completed {
+		return nil
+	}
+
+	err := r.refresh()
+	if errors.Is(err, ErrRunningTaskCompleted) {
+		r.Completed = true
+		r.CurrentAction = ""
+		r.EnginePID = 0
+		r.State = TASK_STATE_UNKNOWN
+		return nil
+	}
+
+	return err
+}
+
+func (r *RunningTask) refresh() error {
 	_, err := oleutil.CallMethod(r.taskObj, "Refresh")
 	if err != nil {
-		return fmt.Errorf("error refreshing running task %s: %v", r.Path, getTaskSchedulerError(err))
+		return fmt.Errorf("error refreshing running task %s: %w", r.Path, getRunningTaskError(err, "Refresh", r.Path))
 	}
 
+	currentAction, err := oleutil.GetProperty(r.taskObj, "CurrentAction")
+	if err != nil {
+		return fmt.Errorf("error refreshing running task %s: %w", r.Path, getRunningTaskError(err, "CurrentAction", r.Path))
+	}
+	enginePID, err := oleutil.GetProperty(r.taskObj, "EnginePid")
+	if err != nil {
+		return fmt.Errorf("error refreshing running task %s: %w", r.Path, getRunningTaskError(err, "EnginePid", r.Path))
+	}
+	state, err := oleutil.GetProperty(r.taskObj, "State")
+	if err != nil {
+		return fmt.Errorf("error refreshing running task %s: %w", r.Path, getRunningTaskError(err, "State", r.Path))
+	}
+
+	r.CurrentAction = currentAction.ToString()
+	r.EnginePID = uint(enginePID.Val)
+	r.State = TaskState(state.Val)
+
 	return nil
 }
 
-// Stop kills and releases a running task.
+// defaultWaitPollInterval is how often Wait refreshes a running task if no
+// poll interval is given.
+const defaultWaitPollInterval = time.Second
+
+// Wait blocks until the running task has completed or ctx is cancelled, refreshing
+// the running task every pollInterval. If pollInterval is zero, the running task is
+// refreshed once a second. Wait returns nil once the running task has completed,
+// at which point the exit code can be read from the LastTaskResult field of the
+// registered task at Path. If ctx is cancelled first, ctx.Err() is returned.
+func (r *RunningTask) Wait(ctx context.Context, pollInterval time.Duration) error {
+	if pollInterval == 0 {
+		pollInterval = defaultWaitPollInterval
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Refresh(); err != nil {
+			return err
+		}
+		if r.Completed {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop kills a running task. Other running instances of the same registered task
+// are not affected. If the instance has already completed, an error wrapping
+// ErrRunningTaskCompleted is returned. The running task is released once it has
+// been stopped, so it can't be used afterwards; calling Release is still safe.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-irunningtask-stop
 func (r *RunningTask) Stop() error {
 	_, err := oleutil.CallMethod(r.taskObj, "Stop")
 	if err != nil {
-		return fmt.Errorf("error stopping running task %s: %v", r.Path, getTaskSchedulerError(err))
+		return fmt.Errorf("error stopping running task %s: %w", r.Path, getRunningTaskError(err, "Stop", r.Path))
 	}
 
 	r.Release()
@@ -51,15 +138,19 @@ func (r *RunningTask) Release() {
 	}
 }
 
-// Run starts an instance of a registered task. If the task was started successfully,
-// a pointer to a running task will be returned.
+// Run starts an instance of a registered task, passing args to its actions. If the
+// task was started successfully, the running instance of the task will be returned,
+// and must be released by the caller.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-run
 func (r *RegisteredTask) Run(args ...string) (RunningTask, error) {
 	return r.RunEx(args, TASK_RUN_NO_FLAGS, 0, "")
 }
 
-// RunEx starts an instance of a registered task. If the task was started successfully,
-// a pointer to a running task will be returned.
+// RunEx starts an instance of a registered task, passing args to its actions. flags
+// control how the task is started, sessionID is the terminal server session to run
+// the task in if TASK_RUN_USE_SESSION_ID is set, and user is the user to run the task
+// as if TASK_RUN_AS_SELF isn't set. If the task was started successfully, the running
+// instance of the task will be returned, and must be released by the caller.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-runex
 func (r *RegisteredTask) RunEx(args []string, flags TaskRunFlags, sessionID int, user string) (RunningTask, error) {
 	if !r.Enabled {
@@ -68,29 +159,118 @@ func (r *RegisteredTask) RunEx(args []string, flags TaskRunFlags, sessionID int,
 
 	runningTaskObj, err := oleutil.CallMethod(r.taskObj, "RunEx", args, int(flags), sessionID, user)
 	if err != nil {
-		return RunningTask{}, fmt.Errorf("error running registered task %s: %v", r.Path, getTaskSchedulerError(err))
+		return RunningTask{}, fmt.Errorf("error running registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "RunEx", r.Path))
+	}
+
+	runningTask, err := parseRunningTask(runningTaskObj.ToIDispatch())
+	if err != nil {
+		runningTaskObj.ToIDispatch().Release()
+		return RunningTask{}, err
+	}
+
+	return runningTask, nil
+}
+
+// RunAndWait runs the registered task with args, waits for the instance that was
+// started to complete, and returns the exit code of the instance. A non-zero exit
+// code doesn't cause RunAndWait to return an error; an error is only returned if the
+// task couldn't be run or waited on. If ctx is done before the instance has
+// completed, ctx.Err() is returned and the instance is left running.
+func (r *RegisteredTask) RunAndWait(ctx context.Context, args []string) (int, error) {
+	runningTask, err := r.Run(args...)
+	if err != nil && !errors.Is(err, ErrRunningTaskCompleted) {
+		return 0, err
+	} else if err == nil {
+		defer runningTask.Release()
+
+		if err = runningTask.Wait(ctx, 0); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = r.Refresh(); err != nil {
+		return 0, err
+	}
+
+	return int(r.LastTaskResult), nil
+}
+
+// waitBackoff is how often WaitForState and WaitForAllInstancesToExit poll the
+// Task Scheduler service: quickly at first, as tasks often change state soon,
+// then less often so that waiting on long-running tasks stays cheap.
+var waitBackoff = RetryPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// WaitForState blocks until the registered task is in state or ctx is done,
+// refreshing the task with Refresh, first every 100 milliseconds and then less
+// and less often, up to every 5 seconds. If ctx is done first, ctx.Err() is
+// returned.
+func (r *RegisteredTask) WaitForState(ctx context.Context, state TaskState) error {
+	for attempt := 1; ; attempt++ {
+		if err := r.Refresh(); err != nil {
+			return err
+		}
+		if r.State == state {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitBackoff.backoff(attempt)):
+		}
 	}
+}
+
+// WaitForAllInstancesToExit blocks until no instance of the registered task is
+// running or ctx is done, polling its instances as often as WaitForState polls
+// its state. This is useful to wait for a task to finish before replacing the
+// program that it runs. If ctx is done first, ctx.Err() is returned.
+func (r *RegisteredTask) WaitForAllInstancesToExit(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		instances, err := r.GetInstances()
+		if err != nil {
+			return err
+		}
+		running := len(instances) > 0
+		instances.Release()
+		if !running {
+			return nil
+		}
 
-	return parseRunningTask(runningTaskObj.ToIDispatch())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitBackoff.backoff(attempt)):
+		}
+	}
 }
 
 // GetInstances returns all of the currently running instances of a registered task.
+// Unlike TaskService.GetRunningTasks, only the instances of this task are enumerated.
+// IRegisteredTask::GetInstances has a flags parameter, but it is reserved and must be
+// 0, so GetInstances doesn't take one. Running instances that complete while they are
+// being enumerated are skipped.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-getinstances
 func (r *RegisteredTask) GetInstances() (RunningTaskCollection, error) {
 	runningTasks, err := oleutil.CallMethod(r.taskObj, "GetInstances", 0)
 	if err != nil {
-		return nil, fmt.Errorf("error getting instances of registered task %s: %v", r.Path, getTaskSchedulerError(err))
+		return nil, fmt.Errorf("error getting instances of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "GetInstances", r.Path))
 	}
 
 	runningTasksObj := runningTasks.ToIDispatch()
 	defer runningTasksObj.Release()
-	var parsedRunningTasks RunningTaskCollection
+	// return an empty collection rather than nil if the task isn't running
+	parsedRunningTasks := RunningTaskCollection{}
 
 	err = oleutil.ForEach(runningTasksObj, func(v *ole.VARIANT) error {
 		runningTaskObj := v.ToIDispatch()
 
 		parsedRunningTask, err := parseRunningTask(runningTaskObj)
 		if err != nil {
+			runningTaskObj.Release()
 			if errors.Is(err, ErrRunningTaskCompleted) {
 				return nil
 			}
@@ -102,6 +282,7 @@ func (r *RegisteredTask) GetInstances() (RunningTaskCollection, error) {
 		return nil
 	})
 	if err != nil {
+		parsedRunningTasks.Release()
 		return nil, err
 	}
 
@@ -109,18 +290,208 @@ func (r *RegisteredTask) GetInstances() (RunningTaskCollection, error) {
 }
 
 // Stop kills all running instances of the registered task that the current
-// user has access to. If all instances were killed, Stop returns true,
-// otherwise Stop returns false.
+// user has access to. Use RunningTask.Stop to kill a single instance.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-stop
 func (r *RegisteredTask) Stop() error {
 	_, err := oleutil.CallMethod(r.taskObj, "Stop", 0)
 	if err != nil {
-		return fmt.Errorf("error stopping registered task %s: %v", r.Path, getTaskSchedulerError(err))
+		return fmt.Errorf("error stopping registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "Stop", r.Path))
+	}
+
+	return nil
+}
+
+// Refresh re-reads the Enabled, State, MissedRuns, NextRunTime, LastRunTime and
+// LastTaskResult fields of the registered task from the Task Scheduler service.
+// LastTaskResult is the exit code of the last run of the task, or an HRESULT
+// if the Task Scheduler service failed to run the task. Only these properties
+// are read, so Refresh is cheap enough to call in monitoring loops instead of
+// getting the registered task again; the Definition field is left unchanged.
+func (r *RegisteredTask) Refresh() error {
+	enabled, err := oleutil.GetProperty(r.taskObj, "Enabled")
+	if err != nil {
+		return fmt.Errorf("error refreshing registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "Enabled", r.Path))
+	}
+	state, err := oleutil.GetProperty(r.taskObj, "State")
+	if err != nil {
+		return fmt.Errorf("error refreshing registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "State", r.Path))
+	}
+	missedRuns, err := oleutil.GetProperty(r.taskObj, "NumberOfMissedRuns")
+	if err != nil {
+		return fmt.Errorf("error refreshing registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "NumberOfMissedRuns", r.Path))
+	}
+	nextRunTime, err := oleutil.GetProperty(r.taskObj, "NextRunTime")
+	if err != nil {
+		return fmt.Errorf("error refreshing registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "NextRunTime", r.Path))
+	}
+	lastRunTime, err := oleutil.GetProperty(r.taskObj, "LastRunTime")
+	if err != nil {
+		return fmt.Errorf("error refreshing registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "LastRunTime", r.Path))
+	}
+	lastTaskResult, err := oleutil.GetProperty(r.taskObj, "LastTaskResult")
+	if err != nil {
+		return fmt.Errorf("error refreshing registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "LastTaskResult", r.Path))
 	}
 
+	r.Enabled = enabled.Value().(bool)
+	r.State = TaskState(state.Val)
+	r.MissedRuns = uint(missedRuns.Val)
+	r.NextRunTime = nextRunTime.Value().(time.Time)
+	r.LastRunTime = lastRunTime.Value().(time.Time)
+	r.LastTaskResult = TaskResult(lastTaskResult.Val)
+
+	return nil
+}
+
+// LoadDefinition reads the definition of the registered task from the Task
+// Scheduler service and stores it in the Definition field. It is meant for
+// tasks returned by TaskService.ListTasks, whose definitions aren't parsed.
+func (r *RegisteredTask) LoadDefinition() error {
+	def, err := parseRegisteredTaskDefinition(r.taskObj)
+	if err != nil {
+		return fmt.Errorf("error loading definition of registered task %s: %v", r.Path, err)
+	}
+	r.Definition = def
+
 	return nil
 }
 
+// GetState returns the current operational state of the registered task, and
+// updates the State field of the registered task with it.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-get_state
+func (r *RegisteredTask) GetState() (TaskState, error) {
+	state, err := oleutil.GetProperty(r.taskObj, "State")
+	if err != nil {
+		return TASK_STATE_UNKNOWN, fmt.Errorf("error getting state of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "State", r.Path))
+	}
+	r.State = TaskState(state.Val)
+
+	return r.State, nil
+}
+
+// GetMissedRuns returns the number of times the registered task has missed a
+// scheduled run, and updates the MissedRuns field of the registered task with it.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-get_numberofmissedruns
+func (r *RegisteredTask) GetMissedRuns() (uint, error) {
+	missedRuns, err := oleutil.GetProperty(r.taskObj, "NumberOfMissedRuns")
+	if err != nil {
+		return 0, fmt.Errorf("error getting missed runs of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "NumberOfMissedRuns", r.Path))
+	}
+	r.MissedRuns = uint(missedRuns.Val)
+
+	return r.MissedRuns, nil
+}
+
+// SetEnabled enables or disables the registered task. Unlike updating the task's
+// definition, this doesn't re-register the task, so stored credentials are preserved.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-put_enabled
+func (r *RegisteredTask) SetEnabled(enabled bool) error {
+	_, err := oleutil.PutProperty(r.taskObj, "Enabled", enabled)
+	if err != nil {
+		return fmt.Errorf("error setting enabled state of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "Enabled", r.Path))
+	}
+	r.Enabled = enabled
+	r.Definition.Settings.Enabled = enabled
+
+	return nil
+}
+
+// ExportXML returns the XML representation of the registered task, as stored by
+// the Task Scheduler service.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-get_xml
+func (r *RegisteredTask) ExportXML() (string, error) {
+	xml, err := oleutil.GetProperty(r.taskObj, "Xml")
+	if err != nil {
+		return "", fmt.Errorf("error getting XML of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "Xml", r.Path))
+	}
+
+	return xml.ToString(), nil
+}
+
+// GetSecurityDescriptor returns the security descriptor of the registered task in
+// the Security Descriptor Definition Language (SDDL). The info parameter specifies
+// which parts of the security descriptor are returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-getsecuritydescriptor
+func (r *RegisteredTask) GetSecurityDescriptor(info SecurityInformation) (string, error) {
+	sddl, err := oleutil.CallMethod(r.taskObj, "GetSecurityDescriptor", int(info))
+	if err != nil {
+		return "", fmt.Errorf("error getting security descriptor of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "GetSecurityDescriptor", r.Path))
+	}
+
+	return sddl.ToString(), nil
+}
+
+// SetSecurityDescriptor sets the security descriptor of the registered task to sddl,
+// which must be in the Security Descriptor Definition Language (SDDL). The only flag
+// that flags can contain is TASK_DONT_ADD_PRINCIPAL_ACE.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-setsecuritydescriptor
+func (r *RegisteredTask) SetSecurityDescriptor(sddl string, flags TaskCreationFlags) error {
+	_, err := oleutil.CallMethod(r.taskObj, "SetSecurityDescriptor", sddl, int(flags))
+	if err != nil {
+		return fmt.Errorf("error setting security descriptor of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "SetSecurityDescriptor", r.Path))
+	}
+
+	return nil
+}
+
+// maxRunTimes is the maximum number of run times GetRunTimes will request.
+const maxRunTimes = 1000
+
+// GetRunTimes returns the times that the registered task is scheduled to run
+// between start and end, in local time. At most 1000 run times are returned;
+// if the Task Scheduler service has more run times in the window, only the
+// earliest ones are returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-iregisteredtask-getruntimes
+func (r *RegisteredTask) GetRunTimes(start, end time.Time) ([]time.Time, error) {
+	task, err := r.taskObj.QueryInterface(iidIRegisteredTask)
+	if err != nil {
+		return nil, fmt.Errorf("error getting run times of registered task %s: %w", r.Path, getTaskSchedulerPathError(err, "GetRunTimes", r.Path))
+	}
+	defer task.Release()
+
+	startTime := timeToSystemTime(start)
+	endTime := timeToSystemTime(end)
+	count := uint32(maxRunTimes)
+	var runTimes *syscall.Systemtime
+
+	hr, _, _ := syscall.SyscallN(
+		getIRegisteredTaskVtbl(&task.IUnknown).GetRunTimes,
+		uintptr(unsafe.Pointer(task)),
+		uintptr(unsafe.Pointer(&startTime)),
+		uintptr(unsafe.Pointer(&endTime)),
+		uintptr(unsafe.Pointer(&count)),
+		uintptr(unsafe.Pointer(&runTimes)),
+	)
+	// S_FALSE and SCHED_S_* codes are successes, only fewer or no run times are returned
+	if int32(hr) < 0 {
+		return nil, fmt.Errorf("error getting run times of registered task %s: %w", r.Path, getTaskSchedulerError(ole.NewError(hr)))
+	}
+	if runTimes == nil {
+		return nil, nil
+	}
+	defer ole.CoTaskMemFree(uintptr(unsafe.Pointer(runTimes)))
+
+	times := make([]time.Time, 0, count)
+	for _, runTime := range unsafe.Slice(runTimes, count) {
+		times = append(times, systemTimeToTime(runTime))
+	}
+
+	return times, nil
+}
+
+// RawObject returns the IRegisteredTask COM object of the registered task, so
+// that the properties and methods that RegisteredTask doesn't wrap can be used
+// through go-ole. The object is owned by the registered task: it must not be
+// released by the caller, and is only valid until Release is called. RawObject
+// returns nil if the registered task has been released.
+func (r *RegisteredTask) RawObject() *ole.IDispatch {
+	if r.isReleased {
+		return nil
+	}
+
+	return r.taskObj
+}
+
 // Release frees the registered task COM object. Must be called before
 // program termination to avoid memory leaks.
 func (r *RegisteredTask) Release() {
@@ -130,9 +501,6 @@ func (r *RegisteredTask) Release() {
 	}
 }
 
-// RunningTaskCollection is a collection of running tasks.
-type RunningTaskCollection []RunningTask
-
 // Stop kills and frees all the running tasks COM objects in the
 // collection. If an error is encountered while stopping a running
 // task, Stop returns the error without attempting to stop any
@@ -155,9 +523,6 @@ func (r RunningTaskCollection) Release() {
 	}
 }
 
-// RegisteredTaskCollection is a collection of registered tasks.
-type RegisteredTaskCollection []RegisteredTask
-
 // Release frees all the registered task COM objects in the collection.
 // Must be called before program termination to avoid memory leaks.
 func (r RegisteredTaskCollection) Release() {
@@ -179,3 +544,164 @@ func (f *TaskFolder) Release() {
 		f.isReleased = true
 	}
 }
+
+func newTaskFolderHandle(taskService *TaskService, folderObj *ole.IDispatch) TaskFolderHandle {
+	return TaskFolderHandle{
+		folderObj:   folderObj,
+		taskService: taskService,
+		Name:        oleutil.MustGetProperty(folderObj, "Name").ToString(),
+		Path:        oleutil.MustGetProperty(folderObj, "Path").ToString(),
+	}
+}
+
+// taskPath returns the full path of the task or subfolder name in the folder.
+func (f *TaskFolderHandle) taskPath(name string) string {
+	if f.Path == `\` {
+		return `\` + name
+	}
+
+	return f.Path + `\` + name
+}
+
+// CreateTask creates a registered task named name in the folder. CreateTask returns
+// true if the task was successfully registered, and false if the overwrite parameter
+// is false and a task with the same name already exists in the folder.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-registertaskdefinition
+func (f *TaskFolderHandle) CreateTask(name string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
+	path := f.taskPath(name)
+	if err := validateDefinition(newTaskDef); err != nil {
+		return RegisteredTask{}, false, err
+	}
+
+	f.taskService.mu.Lock()
+	defer f.taskService.mu.Unlock()
+
+	existingTaskObj, err := f.taskService.callMethod(f.folderObj, "GetTask", name)
+	if err == nil {
+		if !overwrite {
+			existingTask, _, err := parseRegisteredTask(existingTaskObj.ToIDispatch())
+			if err != nil {
+				return RegisteredTask{}, false, fmt.Errorf("error parsing registered task %s: %v", path, err)
+			}
+
+			return existingTask, false, nil
+		}
+		existingTaskObj.ToIDispatch().Release()
+
+		_, err = f.taskService.callMethod(f.folderObj, "DeleteTask", name, 0)
+		if err != nil {
+			return RegisteredTask{}, false, fmt.Errorf("error deleting registered task %s: %w", path, getTaskSchedulerPathError(err, "DeleteTask", path))
+		}
+	} else if !isNotFoundError(err) {
+		return RegisteredTask{}, false, fmt.Errorf("error getting registered task %s: %w", path, getTaskSchedulerPathError(err, "GetTask", path))
+	}
+
+	newTaskDefObj, err := f.taskService.newDefinitionObj(newTaskDef)
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error creating registered task %s: %v", path, err)
+	}
+	defer newTaskDefObj.Release()
+
+	newTaskObj, err := f.taskService.callMethod(f.folderObj, "RegisterTaskDefinition", name, newTaskDefObj, int(TASK_CREATE), "", "", int(newTaskDef.Principal.LogonType), "")
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error registering task %s: %w", path, getTaskSchedulerPathError(err, "RegisterTaskDefinition", path))
+	}
+
+	newTask, _, err := parseRegisteredTask(newTaskObj.ToIDispatch())
+	if err != nil {
+		return RegisteredTask{}, false, fmt.Errorf("error parsing registered task %s: %v", path, err)
+	}
+
+	return newTask, true, nil
+}
+
+// DeleteTask deletes the registered task named name in the folder. If the task
+// doesn't exist, an error wrapping ErrTaskNotFound is returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-deletetask
+func (f *TaskFolderHandle) DeleteTask(name string) error {
+	path := f.taskPath(name)
+
+	f.taskService.mu.Lock()
+	defer f.taskService.mu.Unlock()
+
+	_, err := f.taskService.callMethod(f.folderObj, "DeleteTask", name, 0)
+	if err != nil {
+		if isNotFoundError(err) {
+			return fmt.Errorf("error deleting task %s: %w", path, ErrTaskNotFound)
+		}
+		return fmt.Errorf("error deleting task %s: %w", path, getTaskSchedulerPathError(err, "DeleteTask", path))
+	}
+
+	return nil
+}
+
+// CreateSubFolder creates a subfolder named name in the folder, and applies the
+// security descriptor sddl to it. If sddl is empty, the security descriptor of
+// the folder is inherited. If the subfolder already exists, an error wrapping
+// ErrAlreadyExists is returned.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-createfolder
+func (f *TaskFolderHandle) CreateSubFolder(name, sddl string) (TaskFolderHandle, error) {
+	path := f.taskPath(name)
+
+	f.taskService.mu.Lock()
+	defer f.taskService.mu.Unlock()
+
+	res, err := f.taskService.callMethod(f.folderObj, "CreateFolder", name, sddl)
+	if err != nil {
+		if isAlreadyExistsError(err) {
+			return TaskFolderHandle{}, fmt.Errorf("error creating folder %s: %w", path, ErrAlreadyExists)
+		}
+		return TaskFolderHandle{}, fmt.Errorf("error creating folder %s: %w", path, getTaskSchedulerPathError(err, "CreateFolder", path))
+	}
+
+	return newTaskFolderHandle(f.taskService, res.ToIDispatch()), nil
+}
+
+// GetTasks returns the registered tasks that are directly inside the folder,
+// including hidden tasks unless ExcludeHidden is passed.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-gettasks
+func (f *TaskFolderHandle) GetTasks(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	f.taskService.mu.RLock()
+	defer f.taskService.mu.RUnlock()
+
+	return f.taskService.parseTasksInFolder(f.folderObj, f.Path, newEnumConfig(opts))
+}
+
+// GetFolders returns handles to the subfolders that are directly inside the folder.
+// Each returned handle must be released.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nf-taskschd-itaskfolder-getfolders
+func (f *TaskFolderHandle) GetFolders() ([]TaskFolderHandle, error) {
+	f.taskService.mu.RLock()
+	defer f.taskService.mu.RUnlock()
+
+	res, err := f.taskService.callMethod(f.folderObj, "GetFolders", 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subfolders of folder %s: %w", f.Path, getTaskSchedulerPathError(err, "GetFolders", f.Path))
+	}
+	folderCollection := res.ToIDispatch()
+	defer folderCollection.Release()
+
+	var subFolders []TaskFolderHandle
+	err = f.taskService.forEach(folderCollection, func(v *ole.VARIANT) error {
+		subFolders = append(subFolders, newTaskFolderHandle(f.taskService, v.ToIDispatch()))
+
+		return nil
+	})
+	if err != nil {
+		for i := range subFolders {
+			subFolders[i].Release()
+		}
+		return nil, err
+	}
+
+	return subFolders, nil
+}
+
+// Release frees the task folder COM object. Must be called before
+// program termination to avoid memory leaks.
+func (f *TaskFolderHandle) Release() {
+	if !f.isReleased && f.folderObj != nil {
+		f.folderObj.Release()
+		f.isReleased = true
+	}
+}
@@ -3,6 +3,9 @@
 package taskmaster
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -47,6 +50,106 @@ func TestRefreshRunningTask(t *testing.T) {
 	runningTask.Release()
 }
 
+func TestRefreshCompletedRunningTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	runningTask, err := testTask.Run("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runningTask.Release()
+
+	time.Sleep(5 * time.Second)
+	err = runningTask.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !runningTask.Completed || runningTask.EnginePID != 0 || runningTask.State != TASK_STATE_UNKNOWN {
+		t.Fatalf("expected a completed running task, got %+v", runningTask)
+	}
+}
+
+func TestWaitRunningTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	runningTask, err := testTask.Run("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runningTask.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = runningTask.Wait(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a running task that will never complete in time should return the context's error
+	longRunningTask, err := testTask.Run("9001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer longRunningTask.Stop()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = longRunningTask.Wait(ctx, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWaitForState(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	runningTask, err := testTask.Run("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runningTask.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err = testTask.WaitForState(ctx, TASK_STATE_RUNNING); err != nil {
+		t.Fatal(err)
+	}
+	if err = taskService.WaitForAllInstancesToExit(ctx, testTask.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err = testTask.WaitForState(ctx, TASK_STATE_READY); err != nil {
+		t.Fatal(err)
+	}
+
+	longRunningTask, err := testTask.Run("9001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer longRunningTask.Stop()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = testTask.WaitForAllInstancesToExit(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
 func TestStopRunningTask(t *testing.T) {
 	taskService, err := Connect()
 	if err != nil {
@@ -99,6 +202,68 @@ func TestGetInstancesRegisteredTask(t *testing.T) {
 	instances.Release()
 }
 
+func TestGetInstancesNotRunning(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	instances, err := testTask.GetInstances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instances == nil {
+		t.Fatal("instances should be an empty collection, not nil")
+	}
+	if len(instances) != 0 {
+		t.Fatalf("should have 0 instances, got %d instead", len(instances))
+	}
+}
+
+func TestRefreshRegisteredTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	runningTask, err := testTask.Run("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runningTask.Stop()
+
+	state, err := testTask.GetState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != TASK_STATE_RUNNING {
+		t.Fatalf("expected registered task to be running, got %s", state)
+	}
+
+	lastRunTime := testTask.LastRunTime
+	if err = testTask.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if !testTask.LastRunTime.After(lastRunTime) {
+		t.Fatal("LastRunTime should have been updated")
+	}
+	if kind := testTask.LastResult().Kind(); kind != TASK_RESULT_RUNNING {
+		t.Fatalf("expected the last result of a running task to be %s, got %s", TASK_RESULT_RUNNING, kind)
+	}
+
+	missedRuns, err := testTask.GetMissedRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missedRuns != testTask.MissedRuns {
+		t.Fatalf("MissedRuns should have been updated to %d, got %d", missedRuns, testTask.MissedRuns)
+	}
+}
+
 func TestStopRegisteredTask(t *testing.T) {
 	taskService, err := Connect()
 	if err != nil {
@@ -119,3 +284,133 @@ func TestStopRegisteredTask(t *testing.T) {
 		t.Fatalf("error stopping tasks: %v", err)
 	}
 }
+
+func TestExportXML(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "cmd.exe",
+		Args: "/c timeout $(Arg0)",
+	})
+	def.RegistrationInfo.Description = "タスク\nmulti-line description"
+
+	defXML, err := def.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(defXML, "タスク") {
+		t.Error("definition XML doesn't contain the unicode description")
+	}
+
+	task, _, err := taskService.CreateTask("\\Taskmaster\\ExportXMLTask", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	taskXML, err := task.ExportXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(taskXML, "タスク") {
+		t.Error("registered task XML doesn't contain the unicode description")
+	}
+}
+
+func TestGetRunTimes(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taskService.Disconnect()
+
+	start := time.Now().Add(time.Hour)
+	def := taskService.NewTaskDefinition()
+	def.AddAction(ExecAction{
+		Path: "calc.exe",
+	})
+	def.AddTrigger(DailyTrigger{
+		DayInterval: EveryDay,
+		TaskTrigger: TaskTrigger{
+			Enabled:       true,
+			StartBoundary: start,
+		},
+	})
+	task, _, err := taskService.CreateTask("\\Taskmaster\\RunTimesTask", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Release()
+
+	runTimes, err := task.GetRunTimes(time.Now(), start.AddDate(0, 0, 4).Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runTimes) != 5 {
+		t.Fatalf("should have 5 run times, got %d instead", len(runTimes))
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	if err = testTask.SetEnabled(false); err != nil {
+		t.Fatal(err)
+	}
+	if err = testTask.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if testTask.Enabled {
+		t.Fatal("registered task should be disabled")
+	}
+
+	if err = taskService.SetTaskEnabled(testTask.Path, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = testTask.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if !testTask.Enabled {
+		t.Fatal("registered task should be enabled")
+	}
+}
+
+func TestRunAndWaitRegisteredTask(t *testing.T) {
+	taskService, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTask := createTestTask(taskService)
+	defer taskService.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exitCode, err := testTask.RunAndWait(ctx, []string{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d", exitCode)
+	}
+
+	// an instance that will never complete in time should return the context's error
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = testTask.RunAndWait(ctx, []string{"9001"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if err = testTask.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package taskmaster
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+// NewBootTrigger returns an enabled BootTrigger that fires delay after the
+// system boots. An optional repetition pattern can be passed, only the first one
+// is used. An error is returned if delay is negative or the repetition pattern
+// is invalid.
+func NewBootTrigger(delay period.Period, repetition ...RepetitionPattern) (BootTrigger, error) {
+	return validTrigger(BootTrigger{
+		TaskTrigger: newTaskTrigger(time.Time{}, repetition),
+		Delay:       delay,
+	})
+}
+
+// NewDailyTrigger returns an enabled DailyTrigger that first fires at start and
+// then every dayInterval days at the same time of day. An optional repetition
+// pattern can be passed, only the first one is used. An error is returned if
+// start is the zero time or dayInterval isn't between 1 and 365.
+func NewDailyTrigger(start time.Time, dayInterval DayInterval, repetition ...RepetitionPattern) (DailyTrigger, error) {
+	return validTrigger(DailyTrigger{
+		TaskTrigger: newTaskTrigger(start, repetition),
+		DayInterval: dayInterval,
+	})
+}
+
+// NewIdleTrigger returns an enabled IdleTrigger, which fires when the computer
+// becomes idle as set by the IdleSettings of the task.
+func NewIdleTrigger() IdleTrigger {
+	return IdleTrigger{
+		TaskTrigger: newTaskTrigger(time.Time{}, nil),
+	}
+}
+
+// NewLogonTrigger returns an enabled LogonTrigger that fires when userID logs
+// on, or when any user logs on if userID is empty.
+func NewLogonTrigger(userID string) LogonTrigger {
+	return LogonTrigger{
+		TaskTrigger: newTaskTrigger(time.Time{}, nil),
+		UserID:      userID,
+	}
+}
+
+// NewRegistrationTrigger returns an enabled RegistrationTrigger that fires delay
+// after the task is registered or updated. An error is returned if delay is
+// negative.
+func NewRegistrationTrigger(delay period.Period) (RegistrationTrigger, error) {
+	return validTrigger(RegistrationTrigger{
+		TaskTrigger: newTaskTrigger(time.Time{}, nil),
+		Delay:       delay,
+	})
+}
+
+// NewTimeTrigger returns an enabled TimeTrigger that fires once at start. An
+// optional repetition pattern can be passed, only the first one is used. An
+// error is returned if start is the zero time.
+func NewTimeTrigger(start time.Time, repetition ...RepetitionPattern) (TimeTrigger, error) {
+	return validTrigger(TimeTrigger{
+		TaskTrigger: newTaskTrigger(start, repetition),
+	})
+}
+
+// NewWeeklyTrigger returns an enabled WeeklyTrigger that fires on daysOfWeek
+// every weekInterval weeks, at the time of day of start. An optional repetition
+// pattern can be passed, only the first one is used. An error is returned if
+// start is the zero time, daysOfWeek is empty or weekInterval isn't between 1
+// and 52.
+func NewWeeklyTrigger(start time.Time, weekInterval WeekInterval, daysOfWeek DayOfWeek, repetition ...RepetitionPattern) (WeeklyTrigger, error) {
+	return validTrigger(WeeklyTrigger{
+		TaskTrigger:  newTaskTrigger(start, repetition),
+		DaysOfWeek:   daysOfWeek,
+		WeekInterval: weekInterval,
+	})
+}
+
+// NewMonthlyTrigger returns an enabled MonthlyTrigger that fires on daysOfMonth
+// of monthsOfYear, at the time of day of start. If daysOfMonth includes
+// LastDayOfMonth, the trigger also fires on the last day of each month, whatever
+// its number. An optional repetition pattern can be passed, only the first one
+// is used. An error is returned if start is the zero time, or daysOfMonth or
+// monthsOfYear is empty.
+func NewMonthlyTrigger(start time.Time, daysOfMonth DayOfMonth, monthsOfYear Month, repetition ...RepetitionPattern) (MonthlyTrigger, error) {
+	return validTrigger(MonthlyTrigger{
+		TaskTrigger:         newTaskTrigger(start, repetition),
+		DaysOfMonth:         daysOfMonth &^ LastDayOfMonth,
+		MonthsOfYear:        monthsOfYear,
+		RunOnLastDayOfMonth: daysOfMonth&LastDayOfMonth != 0,
+	})
+}
+
+// NewMonthlyDOWTrigger returns an enabled MonthlyDOWTrigger that fires on
+// daysOfWeek of weeksOfMonth of monthsOfYear, at the time of day of start, such
+// as on the second Tuesday of every month. If weeksOfMonth includes LastWeek,
+// the trigger also fires in the last week of each month. An optional repetition
+// pattern can be passed, only the first one is used. An error is returned if
+// start is the zero time, or weeksOfMonth, daysOfWeek or monthsOfYear is empty.
+func NewMonthlyDOWTrigger(start time.Time, weeksOfMonth Week, daysOfWeek DayOfWeek, monthsOfYear Month, repetition ...RepetitionPattern) (MonthlyDOWTrigger, error) {
+	return validTrigger(MonthlyDOWTrigger{
+		TaskTrigger:          newTaskTrigger(start, repetition),
+		DaysOfWeek:           daysOfWeek,
+		MonthsOfYear:         monthsOfYear,
+		RunOnLastWeekOfMonth: weeksOfMonth&LastWeek != 0,
+		WeeksOfMonth:         weeksOfMonth &^ LastWeek,
+	})
+}
+
+// NewSessionStateChangeTrigger returns an enabled SessionStateChangeTrigger that
+// fires delay after the session of userID changes state, such as when it is
+// locked or disconnected from Remote Desktop. If userID is empty, session state
+// changes of any user fire the trigger. An error is returned if stateChange
+// isn't a valid TaskSessionStateChangeType or delay is negative.
+func NewSessionStateChangeTrigger(stateChange TaskSessionStateChangeType, userID string, delay period.Period) (SessionStateChangeTrigger, error) {
+	return validTrigger(SessionStateChangeTrigger{
+		TaskTrigger: newTaskTrigger(time.Time{}, nil),
+		Delay:       delay,
+		StateChange: stateChange,
+		UserID:      userID,
+	})
+}
+
+// NewEventTrigger returns an enabled EventTrigger that fires when an event with
+// the ID eventID is logged to the event log logName, such as "System" or
+// "Microsoft-Windows-TaskScheduler/Operational", by the event source source. If
+// source is empty, events with the ID eventID from any source fire the trigger.
+// An error is returned if logName is empty or eventID isn't between 0 and 65535.
+func NewEventTrigger(logName, source string, eventID int) (EventTrigger, error) {
+	if logName == "" {
+		return EventTrigger{}, errors.New("invalid EventTrigger: logName is required")
+	}
+	if eventID < 0 || eventID > 0xFFFF {
+		return EventTrigger{}, fmt.Errorf("invalid EventTrigger: event ID %d is not between 0 and 65535", eventID)
+	}
+
+	return validTrigger(EventTrigger{
+		TaskTrigger:  newTaskTrigger(time.Time{}, nil),
+		Subscription: eventSubscription(logName, source, eventID),
+	})
+}
+
+// validTrigger returns trigger, or the zero value of its type and the error
+// registering it would fail with if it's invalid.
+func validTrigger[T Trigger](trigger T) (T, error) {
+	if err := validateTrigger(trigger); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return trigger, nil
+}
+
+// eventSubscription returns the subscription query of an EventTrigger that
+// matches the events with the ID eventID logged to logName by source.
+func eventSubscription(logName, source string, eventID int) string {
+	query := fmt.Sprintf("*[System[EventID=%d]]", eventID)
+	if source != "" {
+		query = fmt.Sprintf("*[System[Provider[@Name=%s] and EventID=%d]]", xpathLiteral(source), eventID)
+	}
+
+	return fmt.Sprintf(`<QueryList><Query Id="0" Path="%[1]s"><Select Path="%[1]s">%[2]s</Select></Query></QueryList>`, escapeXML(logName), escapeXML(query))
+}
+
+// xpathLiteral quotes s as an XPath string literal. XPath has no escape
+// sequences, so strings containing both kinds of quotes are built with concat.
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	} else if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+
+	parts := strings.Split(s, "'")
+	for i, part := range parts {
+		parts[i] = "'" + part + "'"
+	}
+
+	return "concat(" + strings.Join(parts, `, "'", `) + ")"
+}
+
+func escapeXML(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+
+	return buf.String()
+}
+
+func newTaskTrigger(start time.Time, repetition []RepetitionPattern) TaskTrigger {
+	trigger := TaskTrigger{
+		Enabled:       true,
+		StartBoundary: start,
+	}
+	if len(repetition) > 0 {
+		trigger.RepetitionPattern = repetition[0]
+	}
+
+	return trigger
+}
@@ -0,0 +1,162 @@
+package taskmaster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+func TestNewEventTrigger(t *testing.T) {
+	tests := []struct {
+		logName      string
+		source       string
+		eventID      int
+		subscription string
+	}{
+		{
+			"System", "", 12,
+			`<QueryList><Query Id="0" Path="System"><Select Path="System">*[System[EventID=12]]</Select></Query></QueryList>`,
+		},
+		{
+			"Application", "MyApp", 1000,
+			`<QueryList><Query Id="0" Path="Application"><Select Path="Application">*[System[Provider[@Name=&#39;MyApp&#39;] and EventID=1000]]</Select></Query></QueryList>`,
+		},
+		{
+			"Application", `It's "quoted"`, 1,
+			`<QueryList><Query Id="0" Path="Application"><Select Path="Application">*[System[Provider[@Name=concat(&#39;It&#39;, &#34;&#39;&#34;, &#39;s &#34;quoted&#34;&#39;)] and EventID=1]]</Select></Query></QueryList>`,
+		},
+	}
+
+	for _, test := range tests {
+		trigger, err := NewEventTrigger(test.logName, test.source, test.eventID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trigger.Subscription != test.subscription {
+			t.Errorf("NewEventTrigger(%q, %q, %d): expected subscription\n%s\ngot\n%s", test.logName, test.source, test.eventID, test.subscription, trigger.Subscription)
+		}
+		if !trigger.Enabled {
+			t.Errorf("NewEventTrigger(%q, %q, %d): trigger should be enabled", test.logName, test.source, test.eventID)
+		}
+
+		def := defaultDefinition()
+		def.AddAction(ExecAction{Path: "calc.exe"})
+		def.AddTrigger(trigger)
+		if err := validateDefinition(def); err != nil {
+			t.Errorf("NewEventTrigger(%q, %q, %d): %v", test.logName, test.source, test.eventID, err)
+		}
+	}
+}
+
+func TestNewMonthlyTriggers(t *testing.T) {
+	start := time.Now()
+
+	monthlyTrigger := mustTrigger(NewMonthlyTrigger(start, One|Fifteen|LastDayOfMonth, AllMonths))
+	if monthlyTrigger.DaysOfMonth != One|Fifteen || !monthlyTrigger.RunOnLastDayOfMonth {
+		t.Errorf("LastDayOfMonth should have been moved to RunOnLastDayOfMonth: %+v", monthlyTrigger)
+	}
+	lastDayTrigger := mustTrigger(NewMonthlyTrigger(start, LastDayOfMonth, February))
+
+	monthlyDOWTrigger := mustTrigger(NewMonthlyDOWTrigger(start, Second|LastWeek, Tuesday, January|December))
+	if monthlyDOWTrigger.WeeksOfMonth != Second || !monthlyDOWTrigger.RunOnLastWeekOfMonth {
+		t.Errorf("LastWeek should have been moved to RunOnLastWeekOfMonth: %+v", monthlyDOWTrigger)
+	}
+	lastWeekTrigger := mustTrigger(NewMonthlyDOWTrigger(start, LastWeek, Friday, AllMonths))
+
+	for _, trigger := range []Trigger{monthlyTrigger, lastDayTrigger, monthlyDOWTrigger, lastWeekTrigger} {
+		def := Definition{}
+		def.AddAction(ExecAction{Path: "calc.exe"})
+		def.AddTrigger(trigger)
+		if err := validateDefinition(def); err != nil {
+			t.Errorf("%+v: %v", trigger, err)
+		}
+	}
+}
+
+func TestNewSessionStateChangeTrigger(t *testing.T) {
+	trigger := mustTrigger(NewSessionStateChangeTrigger(TASK_REMOTE_DISCONNECT, "DOMAIN\\user", period.NewHMS(0, 5, 0)))
+	if !trigger.Enabled || trigger.StateChange != TASK_REMOTE_DISCONNECT || trigger.UserID != "DOMAIN\\user" {
+		t.Fatalf("SessionStateChangeTrigger wasn't created correctly: %+v", trigger)
+	}
+
+	def := defaultDefinition()
+	def.AddAction(ExecAction{Path: "calc.exe"})
+	def.AddTrigger(trigger)
+	if err := validateDefinition(def); err != nil {
+		t.Fatal(err)
+	}
+
+	trigger.StateChange = 5
+	def.Triggers[0] = trigger
+	if err := validateDefinition(def); err == nil {
+		t.Fatal("a SessionStateChangeTrigger with an invalid StateChange should not be valid")
+	}
+	if _, err := NewSessionStateChangeTrigger(5, "", period.Period{}); err == nil {
+		t.Fatal("NewSessionStateChangeTrigger should reject an invalid StateChange")
+	}
+}
+
+func TestNewTriggersValidate(t *testing.T) {
+	start := time.Now()
+	negative := period.NewHMS(0, -5, 0)
+
+	tests := []struct {
+		name    string
+		trigger func() (Trigger, error)
+	}{
+		{"BootTrigger with a negative delay", func() (Trigger, error) { return NewBootTrigger(negative) }},
+		{"BootTrigger with an interval longer than its duration", func() (Trigger, error) {
+			return NewBootTrigger(period.Period{}, RepetitionPattern{RepetitionInterval: period.NewHMS(2, 0, 0), RepetitionDuration: period.NewHMS(1, 0, 0)})
+		}},
+		{"DailyTrigger without a start", func() (Trigger, error) { return NewDailyTrigger(time.Time{}, EveryDay) }},
+		{"DailyTrigger without an interval", func() (Trigger, error) { return NewDailyTrigger(start, 0) }},
+		{"DailyTrigger with an interval over a year", func() (Trigger, error) { return NewDailyTrigger(start, 366) }},
+		{"EventTrigger without a log", func() (Trigger, error) { return NewEventTrigger("", "", 1) }},
+		{"EventTrigger with a negative event ID", func() (Trigger, error) { return NewEventTrigger("System", "", -1) }},
+		{"EventTrigger with an event ID over 65535", func() (Trigger, error) { return NewEventTrigger("System", "", 65536) }},
+		{"MonthlyTrigger without days", func() (Trigger, error) { return NewMonthlyTrigger(start, 0, AllMonths) }},
+		{"MonthlyTrigger without months", func() (Trigger, error) { return NewMonthlyTrigger(start, One, 0) }},
+		{"MonthlyDOWTrigger without weeks", func() (Trigger, error) { return NewMonthlyDOWTrigger(start, 0, Monday, AllMonths) }},
+		{"MonthlyDOWTrigger without days", func() (Trigger, error) { return NewMonthlyDOWTrigger(start, First, 0, AllMonths) }},
+		{"RegistrationTrigger with a negative delay", func() (Trigger, error) { return NewRegistrationTrigger(negative) }},
+		{"SessionStateChangeTrigger with a negative delay", func() (Trigger, error) {
+			return NewSessionStateChangeTrigger(TASK_SESSION_LOCK, "", negative)
+		}},
+		{"TimeTrigger without a start", func() (Trigger, error) { return NewTimeTrigger(time.Time{}) }},
+		{"WeeklyTrigger without days", func() (Trigger, error) { return NewWeeklyTrigger(start, EveryWeek, 0) }},
+		{"WeeklyTrigger without an interval", func() (Trigger, error) { return NewWeeklyTrigger(start, 0, Monday) }},
+		{"WeeklyTrigger with an interval over a year", func() (Trigger, error) { return NewWeeklyTrigger(start, 53, Monday) }},
+	}
+
+	for _, test := range tests {
+		if trigger, err := test.trigger(); err == nil {
+			t.Errorf("%s: expected an error, got %+v", test.name, trigger)
+		}
+	}
+
+	valid := []Trigger{
+		mustTrigger(NewBootTrigger(period.NewHMS(0, 1, 0))),
+		mustTrigger(NewDailyTrigger(start, 365)),
+		NewIdleTrigger(),
+		NewLogonTrigger(""),
+		mustTrigger(NewRegistrationTrigger(period.Period{})),
+		mustTrigger(NewTimeTrigger(start)),
+		mustTrigger(NewWeeklyTrigger(start, 52, Saturday|Sunday)),
+	}
+	for _, trigger := range valid {
+		if !trigger.GetEnabled() {
+			t.Errorf("%+v: trigger should be enabled", trigger)
+		}
+	}
+}
+
+// mustTrigger returns trigger, and panics if err isn't nil. It's meant to wrap
+// calls to the trigger constructors in tests.
+func mustTrigger[T Trigger](trigger T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return trigger
+}
@@ -1,12 +1,12 @@
-//go:build windows
-// +build windows
-
 package taskmaster
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -63,7 +63,7 @@ func (d DayOfWeek) String() string {
 }
 
 // DayInterval specifies if a task runs every day or every other day.
-type DayInterval uint8
+type DayInterval uint16
 
 const (
 	EveryDay      DayInterval = 1
@@ -263,6 +263,19 @@ func (w WeekInterval) String() string {
 	return fmt.Sprintf("Every %d weeks", w)
 }
 
+// SecurityInformation specifies which parts of a security descriptor are
+// retrieved or set.
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/security-information
+type SecurityInformation uint32
+
+const (
+	OWNER_SECURITY_INFORMATION SecurityInformation = 0x01 // the owner identifier of the object
+	GROUP_SECURITY_INFORMATION SecurityInformation = 0x02 // the primary group identifier of the object
+	DACL_SECURITY_INFORMATION  SecurityInformation = 0x04 // the discretionary access control list (DACL) of the object
+	SACL_SECURITY_INFORMATION  SecurityInformation = 0x08 // the system access control list (SACL) of the object
+	LABEL_SECURITY_INFORMATION SecurityInformation = 0x10 // the mandatory integrity label of the object
+)
+
 // TaskActionType specifies the type of a task action.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/ne-taskschd-task_action_type
 type TaskActionType uint
@@ -413,13 +426,64 @@ func (t TaskLogonType) String() string {
 type TaskRunFlags uint
 
 const (
-	TASK_RUN_NO_FLAGS           TaskRunFlags = iota // the task is run with all flags ignored
-	TASK_RUN_AS_SELF                                // the task is run as the user who is calling the Run method
-	TASK_RUN_IGNORE_CONSTRAINTS                     // the task is run regardless of constraints such as "do not run on batteries" or "run only if idle"
-	TASK_RUN_USE_SESSION_ID                         // the task is run using a terminal server session identifier
-	TASK_RUN_USER_SID                               // the task is run using a security identifier
+	TASK_RUN_NO_FLAGS           TaskRunFlags = 0x00 // the task is run with all flags ignored
+	TASK_RUN_AS_SELF            TaskRunFlags = 0x01 // the task is run as the user who is calling the Run method
+	TASK_RUN_IGNORE_CONSTRAINTS TaskRunFlags = 0x02 // the task is run regardless of constraints such as "do not run on batteries" or "run only if idle"
+	TASK_RUN_USE_SESSION_ID     TaskRunFlags = 0x04 // the task is run using a terminal server session identifier
+	TASK_RUN_USER_SID           TaskRunFlags = 0x08 // the task is run using a security identifier
 )
 
+// TaskProcessTokenSidType specifies the type of security identifier (SID) of the
+// process token that a task running as a service account is started with.
+// Unlike in the Task Scheduler API, the zero value is TASK_PROCESSTOKENSID_DEFAULT,
+// so that principals that don't set it keep the default token.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/ne-taskschd-task_processtokensid_type
+type TaskProcessTokenSidType uint
+
+const (
+	TASK_PROCESSTOKENSID_DEFAULT      TaskProcessTokenSidType = iota // the task is started with the token of the service account
+	TASK_PROCESSTOKENSID_NONE                                        // the task is started with a token that has no SID of its own
+	TASK_PROCESSTOKENSID_UNRESTRICTED                                // the task is started with a token that has an unrestricted task SID
+)
+
+// taskProcessTokenSidTypes are the values of TASK_PROCESSTOKENSID_TYPE, indexed
+// by their value in the Task Scheduler API.
+var taskProcessTokenSidTypes = []TaskProcessTokenSidType{TASK_PROCESSTOKENSID_NONE, TASK_PROCESSTOKENSID_UNRESTRICTED, TASK_PROCESSTOKENSID_DEFAULT}
+
+// apiValue returns the value of t in the Task Scheduler API.
+func (t TaskProcessTokenSidType) apiValue() uint {
+	for value, sidType := range taskProcessTokenSidTypes {
+		if sidType == t {
+			return uint(value)
+		}
+	}
+
+	return uint(t)
+}
+
+// processTokenSidTypeFromAPI returns the TaskProcessTokenSidType of a value of
+// the Task Scheduler API.
+func processTokenSidTypeFromAPI(value uint) TaskProcessTokenSidType {
+	if value < uint(len(taskProcessTokenSidTypes)) {
+		return taskProcessTokenSidTypes[value]
+	}
+
+	return TaskProcessTokenSidType(value)
+}
+
+func (t TaskProcessTokenSidType) String() string {
+	switch t {
+	case TASK_PROCESSTOKENSID_NONE:
+		return "None"
+	case TASK_PROCESSTOKENSID_UNRESTRICTED:
+		return "Unrestricted"
+	case TASK_PROCESSTOKENSID_DEFAULT:
+		return "Default"
+	default:
+		return ""
+	}
+}
+
 // TaskRunLevel specifies whether the task will be run with full permissions or not.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/ne-taskschd-task_runlevel_type
 type TaskRunLevel uint
@@ -552,6 +616,9 @@ func (t TaskTriggerType) String() string {
 	}
 }
 
+// TaskResult is the result of the last run of a registered task: the exit code
+// of its action, or an SCHED_S_* status or HRESULT set by the Task Scheduler
+// service. Use Kind and ExitCode to tell them apart.
 type TaskResult uint32
 
 const (
@@ -603,14 +670,158 @@ func (r TaskResult) String() string {
 	}
 }
 
+// TaskResultKind classifies a TaskResult.
+type TaskResultKind uint
+
+const (
+	TASK_RESULT_SUCCESS    TaskResultKind = iota // the action exited with code 0
+	TASK_RESULT_EXIT_CODE                        // the action exited with a non-zero exit code
+	TASK_RESULT_RUNNING                          // an instance of the task is still running
+	TASK_RESULT_NOT_RUN                          // the task has not been run yet
+	TASK_RESULT_TERMINATED                       // the last instance was stopped before it exited
+	TASK_RESULT_STATUS                           // another SCHED_S_* status set by the Task Scheduler service, such as SCHED_S_TASK_QUEUED
+	TASK_RESULT_ERROR                            // the Task Scheduler service failed to run the task with a SCHED_E_* error
+)
+
+func (k TaskResultKind) String() string {
+	switch k {
+	case TASK_RESULT_SUCCESS:
+		return "Success"
+	case TASK_RESULT_EXIT_CODE:
+		return "Exit code"
+	case TASK_RESULT_RUNNING:
+		return "Running"
+	case TASK_RESULT_NOT_RUN:
+		return "Not run"
+	case TASK_RESULT_TERMINATED:
+		return "Terminated"
+	case TASK_RESULT_STATUS:
+		return "Status"
+	case TASK_RESULT_ERROR:
+		return "Error"
+	default:
+		return ""
+	}
+}
+
+// Kind returns the kind of the result. Exit codes can't always be told apart
+// from the codes set by the Task Scheduler service, so results that match a
+// SCHED_S_* status or SCHED_E_* error are taken to be those rather than exit
+// codes.
+func (r TaskResult) Kind() TaskResultKind {
+	switch {
+	case r == SCHED_S_SUCCESS:
+		return TASK_RESULT_SUCCESS
+	case r == SCHED_S_TASK_RUNNING:
+		return TASK_RESULT_RUNNING
+	case r == SCHED_S_TASK_HAS_NOT_RUN:
+		return TASK_RESULT_NOT_RUN
+	case r == SCHED_S_TASK_TERMINATED:
+		return TASK_RESULT_TERMINATED
+	case r >= SCHED_S_TASK_READY && r <= SCHED_S_TASK_QUEUED:
+		return TASK_RESULT_STATUS
+	case r >= 0x80041300 && r <= 0x800413FF:
+		return TASK_RESULT_ERROR
+	default:
+		return TASK_RESULT_EXIT_CODE
+	}
+}
+
+// ExitCode returns the exit code of the action of the task, and true if the
+// result is an exit code, as opposed to a status or error set by the Task
+// Scheduler service.
+func (r TaskResult) ExitCode() (uint32, bool) {
+	switch r.Kind() {
+	case TASK_RESULT_SUCCESS, TASK_RESULT_EXIT_CODE:
+		return uint32(r), true
+	default:
+		return 0, false
+	}
+}
+
+// TaskService is a connection to a local or remote Task Scheduler service.
+// A TaskService is safe for concurrent use by multiple goroutines; copies of
+// a TaskService share the same underlying connection. Registered and running
+// tasks returned by a TaskService are not safe for concurrent use. Goroutines
+// that would otherwise contend on a shared TaskService can each use their own
+// connection created with Clone.
 type TaskService struct {
+	mu                    *sync.RWMutex // guards COM calls made on taskServiceObj and rootFolderObj
 	taskServiceObj        *ole.IDispatch
 	rootFolderObj         *ole.IDispatch
 	isInitialized         bool
+	apartmentThreaded     bool // COM was initialized in a single-threaded apartment, so the COM objects can only be used from its thread
 	isConnected           bool
 	connectedDomain       string
 	connectedComputerName string
 	connectedUser         string
+	highestVersion        TaskSchedulerVersion // the highest version of the Task Scheduler service that the connected computer supports
+	connectOptions        connectOptions       // the options passed to Connect, used by Reconnect
+	folders               *folderCache         // the task folder COM objects looked up by the operations in progress
+}
+
+// folderCache caches task folder COM objects by path while operations that use
+// the same folders repeatedly, such as registering a task or many tasks into one
+// folder, are in progress, so that the folders are only looked up once. Folders
+// aren't cached between operations, as other processes can delete them. It's safe
+// for concurrent use.
+type folderCache struct {
+	mu         sync.Mutex
+	folders    map[string]*ole.IDispatch
+	operations int // the number of operations in progress
+}
+
+// TaskSchedulerVersion is a version of the Task Scheduler service, which is also
+// the version of the task XML schema that it supports.
+// https://docs.microsoft.com/en-us/windows/win32/api/taskschd/nf-taskschd-itaskservice-get_highestversion
+type TaskSchedulerVersion struct {
+	Major uint
+	Minor uint
+}
+
+func (v TaskSchedulerVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Compatibility returns the highest compatibility level that tasks registered on
+// a Task Scheduler service of version v can use.
+func (v TaskSchedulerVersion) Compatibility() TaskCompatibility {
+	if v.Major < 1 || (v.Major == 1 && v.Minor < 2) {
+		return TASK_COMPATIBILITY_V1
+	} else if v.Major > 1 || v.Minor >= 6 {
+		return TASK_COMPATIBILITY_V2_4
+	}
+
+	return TASK_COMPATIBILITY_V2 + TaskCompatibility(v.Minor-2)
+}
+
+// WindowsVersion returns the earliest version of Windows that has a Task
+// Scheduler service of version v.
+func (v TaskSchedulerVersion) WindowsVersion() string {
+	switch v.Compatibility() {
+	case TASK_COMPATIBILITY_V1:
+		return "Windows XP or Windows Server 2003"
+	case TASK_COMPATIBILITY_V2:
+		return "Windows Vista or Windows Server 2008"
+	case TASK_COMPATIBILITY_V2_1:
+		return "Windows 7 or Windows Server 2008 R2"
+	case TASK_COMPATIBILITY_V2_2:
+		return "Windows 8 or Windows Server 2012"
+	case TASK_COMPATIBILITY_V2_3:
+		return "Windows 8.1 or Windows Server 2012 R2"
+	default:
+		return "Windows 10 or Windows Server 2016"
+	}
+}
+
+type connectOptions struct {
+	serverName   string
+	domain       string
+	username     string
+	password     string
+	proxyBlanket *proxyBlanket
+	retryPolicy  RetryPolicy
+	logger       *slog.Logger
 }
 
 type TaskFolder struct {
@@ -621,6 +832,182 @@ type TaskFolder struct {
 	RegisteredTasks RegisteredTaskCollection
 }
 
+// TaskFilter selects which registered tasks are returned when enumerating tasks.
+// Fields that are empty match all tasks.
+type TaskFilter struct {
+	NamePattern string      // a filepath.Match pattern that the name of a task must match
+	PathPattern string      // a filepath.Match pattern that the path of a task must match
+	States      []TaskState // the states that a task must be in one of
+}
+
+// Query selects registered tasks by their principal, privileges and settings,
+// which is useful to audit the scheduled tasks of a computer. Fields that are
+// empty match all tasks, and a task must match every field that is set.
+type Query struct {
+	Folder            string // the folder to search, including its subfolders; the root folder if empty
+	RunAsSystem       bool   // only tasks that run as the SYSTEM account
+	HighestRunLevel   bool   // only tasks that run with the highest privileges
+	HiddenOnly        bool   // only hidden tasks
+	Privilege         string // only tasks whose principal requires this privilege, such as SeDebugPrivilege
+	UserID            string // only tasks that run as this user or group, with or without its domain
+	ActionPathMatches string // a pattern that the path of an ExecAction of the task must match; see Query.Matches
+}
+
+// ChangeType specifies how a part of a definition differs in a Change.
+type ChangeType uint
+
+const (
+	CHANGE_MODIFIED ChangeType = iota // the value was changed
+	CHANGE_ADDED                      // a trigger or action was added
+	CHANGE_REMOVED                    // a trigger or action was removed
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case CHANGE_MODIFIED:
+		return "Modified"
+	case CHANGE_ADDED:
+		return "Added"
+	case CHANGE_REMOVED:
+		return "Removed"
+	default:
+		return ""
+	}
+}
+
+// Change describes a difference between two definitions found by DiffDefinitions.
+type Change struct {
+	Type ChangeType
+	Path string // the field that differs, such as "Settings.Priority" or "Triggers[1].StartBoundary"
+	Old  string // the formatted old value. Empty if Type is CHANGE_ADDED
+	New  string // the formatted new value. Empty if Type is CHANGE_REMOVED
+}
+
+// ImportConflictPolicy specifies what TaskService.ImportFolder does when a task
+// in the archive already exists.
+type ImportConflictPolicy uint
+
+const (
+	IMPORT_CONFLICT_SKIP      ImportConflictPolicy = iota // the existing task is kept and the archived task isn't imported
+	IMPORT_CONFLICT_OVERWRITE                             // the existing task is replaced by the archived task
+	IMPORT_CONFLICT_RENAME                                // the archived task is imported under a new name, such as "Task (2)"
+)
+
+func (i ImportConflictPolicy) String() string {
+	switch i {
+	case IMPORT_CONFLICT_SKIP:
+		return "Skip"
+	case IMPORT_CONFLICT_OVERWRITE:
+		return "Overwrite"
+	case IMPORT_CONFLICT_RENAME:
+		return "Rename"
+	default:
+		return ""
+	}
+}
+
+// FolderArchive is a portable copy of a task folder and all of its subfolders
+// and tasks, created by TaskService.ExportFolder and recreated by
+// TaskService.ImportFolder. It can be stored with encoding/json.
+type FolderArchive struct {
+	Path    string           // the path of the exported folder
+	Folders []ArchivedFolder // the exported folder and its subfolders, parents before their children
+	Tasks   []ArchivedTask
+}
+
+// ArchivedFolder is a task folder stored in a FolderArchive.
+type ArchivedFolder struct {
+	Path string // the path of the folder relative to the exported folder. Empty for the exported folder itself
+	SDDL string // the security descriptor of the folder
+}
+
+// ArchivedTask is a registered task stored in a FolderArchive.
+type ArchivedTask struct {
+	Path      string        // the path of the task relative to the exported folder
+	XML       string        // the XML representation of the task
+	LogonType TaskLogonType // the logon type of the principal of the task
+	SDDL      string        // the security descriptor of the task
+}
+
+// ImportFolderOptions changes how TaskService.ImportFolder recreates a FolderArchive.
+type ImportFolderOptions struct {
+	Path            string               // the folder the archive is recreated in. Defaults to the path of the exported folder
+	Conflict        ImportConflictPolicy // what to do when a task already exists
+	RestoreSecurity bool                 // whether the security descriptors of folders and tasks are restored
+}
+
+// CopyTaskOptions changes how a task is registered by CopyTask.
+type CopyTaskOptions struct {
+	Principal   *Principal        // if not nil, replaces the principal of the task
+	Username    string            // the user the task is registered for. If empty, the principal of the task is used
+	Password    string            // the password of Username, if needed by the logon type of the task
+	WorkingDirs map[string]string // maps working directories of exec actions to the directories they are replaced with
+	Overwrite   bool              // whether a task that already exists at the destination path is replaced
+}
+
+// EnsureTaskOptions changes how TaskService.EnsureTask registers a task.
+type EnsureTaskOptions struct {
+	Username  string        // the user the task is registered for. If empty, the principal of the definition is used
+	Password  string        // the password of Username, if needed by LogonType
+	LogonType TaskLogonType // the logon type the task is registered with. If zero, the logon type of the principal of the definition is used
+}
+
+// TaskSpec describes a task to register with TaskService.CreateTasks.
+type TaskSpec struct {
+	Path       string // the path to where the task will be stored
+	Definition Definition
+	Username   string        // the user the task is registered for. If empty, the principal of Definition is used
+	Password   string        // the password of Username, if needed by LogonType
+	LogonType  TaskLogonType // the logon type the task is registered with. If zero, the logon type of the principal of Definition is used
+	Overwrite  bool          // whether a task that already exists at Path is replaced
+}
+
+// CreateTaskResult is the result of registering a TaskSpec with TaskService.CreateTasks.
+type CreateTaskResult struct {
+	Path    string
+	Task    RegisteredTask // the registered task, or the existing task if it wasn't replaced
+	Created bool           // whether the task was registered
+	Err     error          // the error that prevented the task from being registered, if any
+}
+
+// BulkTaskResult is the outcome of a bulk operation for one registered task.
+type BulkTaskResult struct {
+	Path    string
+	Changed bool  // whether the task was modified; false if it was already in the desired state or the operation failed
+	Err     error // the error that prevented the task from being modified, if any
+}
+
+// BulkResult is the outcome of a bulk operation, such as TaskService.SetFolderEnabled,
+// for every registered task it applied to.
+type BulkResult struct {
+	Tasks []BulkTaskResult // in the order the tasks were enumerated
+}
+
+// Err returns an error combining the errors of every task that failed, or nil
+// if the operation succeeded for every task.
+func (r BulkResult) Err() error {
+	var errs []error
+	for _, task := range r.Tasks {
+		if task.Err != nil {
+			errs = append(errs, task.Err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// TaskFolderHandle is a task folder that keeps a reference to its COM object, so
+// tasks and subfolders can be managed relative to it without looking the folder
+// up again. It must be released once it's no longer needed.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-itaskfolder
+type TaskFolderHandle struct {
+	folderObj   *ole.IDispatch
+	taskService *TaskService
+	isReleased  bool
+	Name        string
+	Path        string
+}
+
 // RunningTask is a task that is currently running.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-irunningtask
 type RunningTask struct {
@@ -632,6 +1019,62 @@ type RunningTask struct {
 	Name          string    // the name of the task
 	Path          string    // the path to where the task is stored
 	State         TaskState // an identifier for the state of the running task
+	Completed     bool      // whether Refresh found that the instance has completed
+}
+
+// RunningTaskCollection is a collection of running tasks.
+type RunningTaskCollection []RunningTask
+
+// StateChangeEvent describes a task instance starting or stopping.
+type StateChangeEvent struct {
+	Path         string     // the path to where the task is stored
+	InstanceGUID string     // the GUID identifier of the task instance that changed state
+	State        TaskState  // the new state of the task
+	Result       TaskResult // the result of the last run of the task. Only meaningful once the instance has stopped
+}
+
+// WatchEventType specifies what happened to a task reported by TaskService.Watch.
+type WatchEventType uint
+
+const (
+	WATCH_TASK_REGISTERED WatchEventType = iota // a task was registered
+	WATCH_TASK_DELETED                          // a registered task was deleted
+	WATCH_TASK_STARTED                          // an instance of a task started
+	WATCH_TASK_COMPLETED                        // an instance of a task completed
+	WATCH_TASK_MISSED                           // a task missed one or more scheduled runs
+)
+
+func (w WatchEventType) String() string {
+	switch w {
+	case WATCH_TASK_REGISTERED:
+		return "Registered"
+	case WATCH_TASK_DELETED:
+		return "Deleted"
+	case WATCH_TASK_STARTED:
+		return "Started"
+	case WATCH_TASK_COMPLETED:
+		return "Completed"
+	case WATCH_TASK_MISSED:
+		return "Missed"
+	default:
+		return ""
+	}
+}
+
+// WatchOptions configures TaskService.Watch.
+type WatchOptions struct {
+	Folder        string        // the folder whose tasks are watched, including its subfolders. Defaults to the root folder
+	IncludeHidden bool          // whether hidden tasks are watched
+	PollInterval  time.Duration // how often the Task Scheduler service is polled. Defaults to one second
+}
+
+// WatchEvent describes a change to a task reported by TaskService.Watch.
+type WatchEvent struct {
+	Type         WatchEventType
+	Path         string     // the path to where the task is stored
+	InstanceGUID string     // the GUID identifier of the task instance. Only set for started and completed events
+	Result       TaskResult // the result of the last run of the task. Only set for completed events
+	MissedRuns   uint       // the number of scheduled runs the task has missed. Only set for missed events
 }
 
 // RegisteredTask is a task that is registered in the Task Scheduler database.
@@ -648,8 +1091,18 @@ type RegisteredTask struct {
 	NextRunTime    time.Time  // the time when the registered task is next scheduled to run
 	LastRunTime    time.Time  // the time the registered task was last run
 	LastTaskResult TaskResult // the results that were returned the last time the registered task was run
+	RawXML         string     // the XML representation of the registered task. Only set by GetRegisteredTasksWithXML
 }
 
+// LastResult returns LastTaskResult, the result of the last run of the
+// registered task, which can be decoded with its Kind and ExitCode methods.
+func (r *RegisteredTask) LastResult() TaskResult {
+	return r.LastTaskResult
+}
+
+// RegisteredTaskCollection is a collection of registered tasks.
+type RegisteredTaskCollection []RegisteredTask
+
 // Definition defines all the components of a task, such as the task settings, triggers, actions, and registration information
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-itaskdefinition
 type Definition struct {
@@ -661,6 +1114,22 @@ type Definition struct {
 	Settings         TaskSettings
 	Triggers         []Trigger
 	XMLText          string // the XML-formatted definition of the task
+
+	// AfterFill, if not nil, is called with the ITaskDefinition COM object once
+	// it has been filled from the definition, before the task is registered or
+	// validated, or its XML is generated. It can set the properties that
+	// Definition doesn't model yet through go-ole. An error it returns is
+	// returned by the TaskService method. AfterFill isn't part of the JSON and XML
+	// representations of the definition, and is ignored by Equal and Diff.
+	AfterFill func(definitionObj *ole.IDispatch) error `json:"-"`
+}
+
+func (d *Definition) AddAction(action Action) {
+	d.Actions = append(d.Actions, action)
+}
+
+func (d *Definition) AddTrigger(trigger Trigger) {
+	d.Triggers = append(d.Triggers, trigger)
 }
 
 type Action interface {
@@ -689,6 +1158,35 @@ type ComHandlerAction struct {
 	Data    string
 }
 
+// EmailAction is an action that sends an email. It is deprecated since Windows 8 and
+// Windows Server 2012, so the Task Scheduler service may refuse to register tasks
+// with it, but it is parsed so that existing tasks that have one can be enumerated.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-iemailaction
+type EmailAction struct {
+	ID           string
+	Server       string            // the name of the SMTP server used to send the email
+	Subject      string            // the subject of the email
+	To           string            // the email addresses of the recipients of the email
+	Cc           string            // the email addresses that are carbon copied on the email
+	Bcc          string            // the email addresses that are blind carbon copied on the email
+	ReplyTo      string            // the email address to reply to
+	From         string            // the email address of the sender
+	HeaderFields map[string]string // the header fields that are included in the email
+	Body         string            // the body of the email
+	Attachments  []string          // the paths of the files attached to the email
+}
+
+// ShowMessageAction is an action that shows a message box. It is deprecated since
+// Windows 8 and Windows Server 2012, so the Task Scheduler service may refuse to
+// register tasks with it, but it is parsed so that existing tasks that have one can
+// be enumerated.
+// https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-ishowmessageaction
+type ShowMessageAction struct {
+	ID          string
+	Title       string // the title of the message box
+	MessageBody string // the message shown in the message box
+}
+
 // Principal provides security credentials that define the security context for the tasks that are associated with it.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-iprincipal
 type Principal struct {
@@ -698,6 +1196,9 @@ type Principal struct {
 	LogonType TaskLogonType // the security logon method that is required to run the tasks
 	RunLevel  TaskRunLevel  // the identifier that is used to specify the privilege level that is required to run the tasks
 	UserID    string        // the user identifier that is required to run the tasks
+	// the following fields only apply to tasks that use TASK_LOGON_SERVICE_ACCOUNT, and require Windows 7 or later
+	ProcessTokenSidType TaskProcessTokenSidType // the type of SID of the process token the task is started with
+	RequiredPrivileges  []string                // the privileges, such as SeBackupPrivilege, that the process token is restricted to. If empty, the token has all the privileges of the account
 }
 
 // RegistrationInfo provides the administrative information that can be used to describe the task
@@ -716,26 +1217,29 @@ type RegistrationInfo struct {
 // TaskSettings provides the settings that the Task Scheduler service uses to perform the task
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-itasksettings
 type TaskSettings struct {
-	AllowDemandStart       bool              // indicates that the task can be started by using either the Run command or the Context menu
-	AllowHardTerminate     bool              // indicates that the task may be terminated by the Task Scheduler service using TerminateProcess
-	Compatibility          TaskCompatibility // indicates which version of Task Scheduler a task is compatible with
-	DeleteExpiredTaskAfter string            // the amount of time that the Task Scheduler will wait before deleting the task after it expires
-	DontStartOnBatteries   bool              // indicates that the task will not be started if the computer is running on batteries
-	Enabled                bool              // indicates that the task is enabled
-	TimeLimit              period.Period     // the amount of time that is allowed to complete the task
-	Hidden                 bool              // indicates that the task will not be visible in the UI
+	AllowDemandStart                bool              // indicates that the task can be started by using either the Run command or the Context menu
+	AllowHardTerminate              bool              // indicates that the task may be terminated by the Task Scheduler service using TerminateProcess
+	Compatibility                   TaskCompatibility // indicates which version of Task Scheduler a task is compatible with
+	DeleteExpiredTaskAfter          period.Period     // the amount of time that the Task Scheduler will wait before deleting the task after it expires. If zero, the task is never deleted
+	DisallowStartOnRemoteAppSession bool              // indicates that the task will not be started if it is triggered to run in a Remote Applications Integrated Locally (RAIL) session. Requires Windows 7 or later
+	DontStartOnBatteries            bool              // indicates that the task will not be started if the computer is running on batteries
+	Enabled                         bool              // indicates that the task is enabled
+	TimeLimit                       period.Period     // the amount of time that is allowed to complete the task
+	Hidden                          bool              // indicates that the task will not be visible in the UI
 	IdleSettings
 	*MaintenanceSettings
 	MultipleInstances TaskInstancesPolicy // defines how the Task Scheduler deals with multiple instances of the task
 	NetworkSettings
-	Priority                  uint          // the priority level of the task, ranging from 0 - 10, where 0 is the highest priority, and 10 is the lowest. Only applies to ComHandler, Email, and MessageBox actions
-	RestartCount              uint          // the number of times that the Task Scheduler will attempt to restart the task
-	RestartInterval           period.Period // specifies how long the Task Scheduler will attempt to restart the task
-	RunOnlyIfIdle             bool          // indicates that the Task Scheduler will run the task only if the computer is in an idle condition
-	RunOnlyIfNetworkAvailable bool          // indicates that the Task Scheduler will run the task only when a network is available
-	StartWhenAvailable        bool          // indicates that the Task Scheduler can start the task at any time after its scheduled time has passed
-	StopIfGoingOnBatteries    bool          // indicates that the task will be stopped if the computer is going onto batteries
-	WakeToRun                 bool          // indicates that the Task Scheduler will wake the computer when it is time to run the task, and keep the computer awake until the task is completed
+	Priority                   uint          // the priority level of the task, ranging from 0 - 10, where 0 is the highest priority, and 10 is the lowest. Only applies to ComHandler, Email, and MessageBox actions
+	RestartCount               uint          // the number of times that the Task Scheduler will attempt to restart the task
+	RestartInterval            period.Period // the amount of time between attempts to restart the task. Must be between one minute and 31 days
+	RunOnlyIfIdle              bool          // indicates that the Task Scheduler will run the task only if the computer is in an idle condition
+	RunOnlyIfNetworkAvailable  bool          // indicates that the Task Scheduler will run the task only when a network is available
+	StartWhenAvailable         bool          // indicates that the Task Scheduler can start the task at any time after its scheduled time has passed
+	StopIfGoingOnBatteries     bool          // indicates that the task will be stopped if the computer is going onto batteries
+	UseUnifiedSchedulingEngine bool          // indicates that the Unified Scheduling Engine will be used to run the task. Requires Windows 7 or later
+	Volatile                   bool          // indicates that the task is disabled every time Windows starts. Requires Windows 8 or later
+	WakeToRun                  bool          // indicates that the Task Scheduler will wake the computer when it is time to run the task, and keep the computer awake until the task is completed
 }
 
 // IdleSettings specifies how the Task Scheduler performs tasks when the computer is in an idle condition.
@@ -756,6 +1260,8 @@ type MaintenanceSettings struct {
 }
 
 // NetworkSettings provides the settings that the Task Scheduler service uses to obtain a network profile.
+// When RunOnlyIfNetworkAvailable is set, the task only runs when this network profile is available;
+// if neither field is set, any network will do.
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-inetworksettings
 type NetworkSettings struct {
 	ID   string // a GUID value that identifies a network profile
@@ -771,7 +1277,9 @@ type Trigger interface {
 	GetRepetitionInterval() period.Period
 	GetStartBoundary() time.Time
 	GetStopAtDurationEnd() bool
+	GetSynchronizeAcrossTimeZones() bool
 	GetType() TaskTriggerType
+	NextOccurrences(from time.Time, n int) []time.Time
 }
 
 // TaskTrigger provides the common properties that are inherited by all trigger objects.
@@ -783,6 +1291,13 @@ type TaskTrigger struct {
 	ID                 string        // the identifier for the trigger
 	RepetitionPattern
 	StartBoundary time.Time // the date and time when the trigger is activated
+	// SynchronizeAcrossTimeZones makes StartBoundary and EndBoundary be registered
+	// with their UTC offset, so the trigger fires at the same instant whatever the
+	// time zone of the computer that runs the task. Otherwise they're registered as
+	// local times, and the trigger fires at their time of day in the time zone of
+	// the computer. It's set when a trigger whose boundaries have a UTC offset is
+	// read, so updating the task doesn't shift its schedule.
+	SynchronizeAcrossTimeZones bool
 }
 
 // RepetitionPattern defines how often the task is run and how long the repetition pattern is repeated after the task is started.
@@ -838,7 +1353,7 @@ type MonthlyDOWTrigger struct {
 	DaysOfWeek           DayOfWeek     // the days of the week during which the task runs
 	MonthsOfYear         Month         // the months of the year during which the task runs
 	RandomDelay          period.Period // a delay time that is randomly added to the start time of the trigger
-	RunOnLastWeekOfMonth bool          // indicates that the task runs on the last week of the month
+	RunOnLastWeekOfMonth bool          // indicates that the task runs on the last week of the month, in addition to WeeksOfMonth
 	WeeksOfMonth         Week          // the weeks of the month during which the task runs
 }
 
@@ -847,10 +1362,21 @@ type MonthlyDOWTrigger struct {
 // https://docs.microsoft.com/en-us/windows/desktop/api/taskschd/nn-taskschd-imonthlytrigger
 type MonthlyTrigger struct {
 	TaskTrigger
-	DaysOfMonth          DayOfMonth    // the days of the month during which the task runs
-	MonthsOfYear         Month         // the months of the year during which the task runs
-	RandomDelay          period.Period // a delay time that is randomly added to the start time of the trigger
-	RunOnLastWeekOfMonth bool          // indicates that the task runs on the last week of the month
+	DaysOfMonth         DayOfMonth    // the days of the month during which the task runs
+	MonthsOfYear        Month         // the months of the year during which the task runs
+	RandomDelay         period.Period // a delay time that is randomly added to the start time of the trigger
+	RunOnLastDayOfMonth bool          // indicates that the task runs on the last day of the month, in addition to DaysOfMonth
+
+	// Deprecated: use RunOnLastDayOfMonth, which has the same meaning. The task
+	// runs on the last day of the month if either is true, and parsed triggers
+	// only set RunOnLastDayOfMonth.
+	RunOnLastWeekOfMonth bool
+}
+
+// runsOnLastDayOfMonth returns true if the trigger runs on the last day of the
+// month, including because the deprecated RunOnLastWeekOfMonth is true.
+func (t MonthlyTrigger) runsOnLastDayOfMonth() bool {
+	return t.RunOnLastDayOfMonth || t.RunOnLastWeekOfMonth
 }
 
 // RegistrationTrigger triggers the task when the task is registered.
@@ -866,7 +1392,7 @@ type SessionStateChangeTrigger struct {
 	TaskTrigger
 	Delay       period.Period              // indicates how long of a delay takes place before a task is started after a Terminal Server session state change is detected
 	StateChange TaskSessionStateChangeType // the kind of Terminal Server session change that would trigger a task launch
-	UserId      string                     // the user for the Terminal Server session. When a session state change is detected for this user, a task is started
+	UserID      string                     // the user for the Terminal Server session. When a session state change is detected for this user, a task is started. If left empty, the trigger will fire for any user
 }
 
 // TimeTrigger triggers the task at a specific time of day. StartBoundary determines when the trigger fires.
@@ -905,6 +1431,13 @@ func (t TaskService) GetConnectedUser() string {
 	return t.connectedUser
 }
 
+// GetHighestVersion returns the highest version of the Task Scheduler service
+// that the connected computer supports, which can be used to find out which
+// features are available before registering tasks that use them.
+func (t TaskService) GetHighestVersion() TaskSchedulerVersion {
+	return t.highestVersion
+}
+
 func (e ExecAction) GetID() string {
 	return e.ID
 }
@@ -921,6 +1454,22 @@ func (ComHandlerAction) GetType() TaskActionType {
 	return TASK_ACTION_COM_HANDLER
 }
 
+func (e EmailAction) GetID() string {
+	return e.ID
+}
+
+func (EmailAction) GetType() TaskActionType {
+	return TASK_ACTION_SEND_EMAIL
+}
+
+func (s ShowMessageAction) GetID() string {
+	return s.ID
+}
+
+func (ShowMessageAction) GetType() TaskActionType {
+	return TASK_ACTION_SHOW_MESSAGE
+}
+
 func (t TaskTrigger) GetRepetitionDuration() period.Period {
 	return t.RepetitionDuration
 }
@@ -953,6 +1502,10 @@ func (t TaskTrigger) GetStopAtDurationEnd() bool {
 	return t.StopAtDurationEnd
 }
 
+func (t TaskTrigger) GetSynchronizeAcrossTimeZones() bool {
+	return t.SynchronizeAcrossTimeZones
+}
+
 func (BootTrigger) GetType() TaskTriggerType {
 	return TASK_TRIGGER_BOOT
 }
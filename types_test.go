@@ -0,0 +1,56 @@
+package taskmaster
+
+import "testing"
+
+func TestTaskResultKind(t *testing.T) {
+	tests := []struct {
+		result   TaskResult
+		kind     TaskResultKind
+		exitCode uint32
+		isExit   bool
+	}{
+		{SCHED_S_SUCCESS, TASK_RESULT_SUCCESS, 0, true},
+		{1, TASK_RESULT_EXIT_CODE, 1, true},
+		{0xFFFFFFFF, TASK_RESULT_EXIT_CODE, 0xFFFFFFFF, true},
+		{SCHED_S_TASK_RUNNING, TASK_RESULT_RUNNING, 0, false},
+		{SCHED_S_TASK_HAS_NOT_RUN, TASK_RESULT_NOT_RUN, 0, false},
+		{SCHED_S_TASK_TERMINATED, TASK_RESULT_TERMINATED, 0, false},
+		{SCHED_S_TASK_QUEUED, TASK_RESULT_STATUS, 0, false},
+		{0x8004130F, TASK_RESULT_ERROR, 0, false}, // SCHED_E_ACCOUNT_INFORMATION_NOT_SET
+	}
+
+	for _, test := range tests {
+		if kind := test.result.Kind(); kind != test.kind {
+			t.Errorf("%#x: expected kind %s, got %s", uint32(test.result), test.kind, kind)
+		}
+		exitCode, isExit := test.result.ExitCode()
+		if exitCode != test.exitCode || isExit != test.isExit {
+			t.Errorf("%#x: expected exit code %d %t, got %d %t", uint32(test.result), test.exitCode, test.isExit, exitCode, isExit)
+		}
+	}
+
+	task := RegisteredTask{LastTaskResult: SCHED_S_TASK_HAS_NOT_RUN}
+	if task.LastResult().Kind() != TASK_RESULT_NOT_RUN {
+		t.Fatal("LastResult should return LastTaskResult")
+	}
+}
+
+func TestTaskProcessTokenSidTypeAPIValue(t *testing.T) {
+	var principal Principal
+	if principal.ProcessTokenSidType != TASK_PROCESSTOKENSID_DEFAULT {
+		t.Fatal("the zero value should be TASK_PROCESSTOKENSID_DEFAULT")
+	}
+
+	for value, sidType := range map[uint]TaskProcessTokenSidType{
+		0: TASK_PROCESSTOKENSID_NONE,
+		1: TASK_PROCESSTOKENSID_UNRESTRICTED,
+		2: TASK_PROCESSTOKENSID_DEFAULT,
+	} {
+		if sidType.apiValue() != value {
+			t.Errorf("%s: expected API value %d, got %d", sidType, value, sidType.apiValue())
+		}
+		if processTokenSidTypeFromAPI(value) != sidType {
+			t.Errorf("%d: expected %s, got %s", value, sidType, processTokenSidTypeFromAPI(value))
+		}
+	}
+}
@@ -0,0 +1,413 @@
+//go:build !windows
+// +build !windows
+
+package taskmaster
+
+import (
+	"context"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// The Task Scheduler service is only available on Windows. On other platforms
+// the package still compiles so that cross-platform programs can import it, but
+// every function that would call the Task Scheduler service returns
+// ErrUnsupportedPlatform. Functions that only work on definitions, such as
+// Definition.MarshalXML or Definition.Diff, are available on every platform.
+
+func Connect(opts ...ConnectOption) (TaskService, error) {
+	return TaskService{}, ErrUnsupportedPlatform
+}
+
+func ConnectWithOptions(serverName, domain, username, password string) (TaskService, error) {
+	return TaskService{}, ErrUnsupportedPlatform
+}
+
+func ConnectWithOptionsCtx(ctx context.Context, serverName, domain, username, password string) (TaskService, error) {
+	return TaskService{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) Connected() bool {
+	return false
+}
+
+func (t *TaskService) IsHealthy() bool {
+	return false
+}
+
+func (t *TaskService) Retry(fn func() error) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) Reconnect() error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) Clone() (TaskService, error) {
+	return TaskService{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) Disconnect() {}
+
+func (t *TaskService) GetRunningTasks() (RunningTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasks(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksOptions(includeHidden bool) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksWithXML(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksMatching(pattern string, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksCtx(ctx context.Context, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksWithErrors(opts ...EnumOption) (RegisteredTaskCollection, []TaskParseError, error) {
+	return nil, nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) ListTasks(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) ForEachRegisteredTask(fn func(RegisteredTask) error, opts ...EnumOption) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksParallel(workers int, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksInFolder(path string, recursive, includeHidden bool) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTasksFiltered(path string, recursive, includeHidden bool, filter TaskFilter) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) FindTasks(query Query) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTask(path string) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetRegisteredTaskWithXML(path string) (RegisteredTask, string, error) {
+	return RegisteredTask{}, "", ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetTasksInFolder(path string, opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetTasksInFolderOptions(path string, includeHidden bool) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) OpenTaskFolder(path string) (TaskFolderHandle, error) {
+	return TaskFolderHandle{}, ErrUnsupportedPlatform
+}
+
+func (t TaskService) GetTaskFolders() (TaskFolder, error) {
+	return TaskFolder{}, ErrUnsupportedPlatform
+}
+
+func (t TaskService) GetTaskFolder(path string) (TaskFolder, error) {
+	return TaskFolder{}, ErrUnsupportedPlatform
+}
+
+func (t TaskService) GetTaskFolderOptions(path string, includeHidden bool) (TaskFolder, error) {
+	return TaskFolder{}, ErrUnsupportedPlatform
+}
+
+func (t TaskService) NewTaskDefinition() Definition {
+	return defaultDefinition()
+}
+
+func (t *TaskService) CreateTask(path string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) CreateTaskCtx(ctx context.Context, path string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) CreateTaskEx(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) CreateTaskWithSDDL(path string, newTaskDef Definition, username, password string, logonType TaskLogonType, sddl string, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) EnsureTask(path string, def Definition, opts EnsureTaskOptions) (bool, error) {
+	return false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) CreateTaskFromXML(path, xml string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) RegisterTaskFromXML(path, xml string, logonType TaskLogonType, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) CreateTasks(specs []TaskSpec) []CreateTaskResult {
+	results := make([]CreateTaskResult, len(specs))
+	for i, spec := range specs {
+		results[i] = CreateTaskResult{Path: spec.Path, Err: ErrUnsupportedPlatform}
+	}
+
+	return results
+}
+
+func (t *TaskService) MoveTask(oldPath, newPath string) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) MoveTaskEx(oldPath, newPath string, overwrite bool) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) RenameTask(path, newName string) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func CopyTask(src *TaskService, srcPath string, dst *TaskService, dstPath string, opts CopyTaskOptions) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) SetTaskCredentials(path, username, password string, logonType TaskLogonType) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) WaitForAllInstancesToExit(ctx context.Context, path string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) SetTaskEnabled(path string, enabled bool) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) RunAndWait(ctx context.Context, path string, args []string) (int, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) RunTask(path string, args []string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) StopTask(path string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) UpdateTask(path string, newTaskDef Definition) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) UpdateTaskEx(path string, newTaskDef Definition, username, password string, logonType TaskLogonType) (RegisteredTask, error) {
+	return RegisteredTask{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) ValidateTaskDefinition(def Definition) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) PreviewTask(def Definition) (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func (t *TaskService) CreateFolder(path, sddl string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) GetFolderSecurityDescriptor(path string, info SecurityInformation) (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func (t *TaskService) SetFolderSecurityDescriptor(path, sddl string, flags TaskCreationFlags) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) DeleteFolder(path string, deleteRecursively bool) (bool, error) {
+	return false, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) DeleteEmptyFolders(rootPath string) ([]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) DeleteTask(path string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (t *TaskService) DeleteTasks(paths []string) []error {
+	errs := make([]error, len(paths))
+	for i := range errs {
+		errs[i] = ErrUnsupportedPlatform
+	}
+
+	return errs
+}
+
+func (t *TaskService) SetFolderEnabled(path string, enabled, recursive bool) (BulkResult, error) {
+	return BulkResult{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) DeleteTasksMatching(pattern string) ([]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) ExportFolder(path string) (FolderArchive, error) {
+	return FolderArchive{}, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) ImportFolder(archive FolderArchive, opts ImportFolderOptions) ([]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) WatchStateChanges(ctx context.Context) (<-chan StateChangeEvent, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (t *TaskService) Watch(ctx context.Context, opts WatchOptions) (<-chan WatchEvent, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (d Definition) XML() (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func (r *RunningTask) Refresh() error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RunningTask) Wait(ctx context.Context, pollInterval time.Duration) error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RunningTask) Stop() error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RunningTask) Release() {}
+
+func (r *RegisteredTask) Run(args ...string) (RunningTask, error) {
+	return RunningTask{}, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) RunEx(args []string, flags TaskRunFlags, sessionID int, user string) (RunningTask, error) {
+	return RunningTask{}, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) RunAndWait(ctx context.Context, args []string) (int, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) GetInstances() (RunningTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) Stop() error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) Refresh() error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) WaitForState(ctx context.Context, state TaskState) error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) WaitForAllInstancesToExit(ctx context.Context) error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) LoadDefinition() error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) GetState() (TaskState, error) {
+	return TASK_STATE_UNKNOWN, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) GetMissedRuns() (uint, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) SetEnabled(enabled bool) error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) ExportXML() (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) GetSecurityDescriptor(info SecurityInformation) (string, error) {
+	return "", ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) SetSecurityDescriptor(sddl string, flags TaskCreationFlags) error {
+	return ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) GetRunTimes(start, end time.Time) ([]time.Time, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (r *RegisteredTask) RawObject() *ole.IDispatch {
+	return nil
+}
+
+func (r *RegisteredTask) Release() {}
+
+func (r RunningTaskCollection) Stop() error {
+	return ErrUnsupportedPlatform
+}
+
+func (r RunningTaskCollection) Release() {}
+
+func (r RegisteredTaskCollection) Release() {}
+
+func (f *TaskFolder) Release() {}
+
+func (f *TaskFolderHandle) CreateTask(name string, newTaskDef Definition, overwrite bool) (RegisteredTask, bool, error) {
+	return RegisteredTask{}, false, ErrUnsupportedPlatform
+}
+
+func (f *TaskFolderHandle) DeleteTask(name string) error {
+	return ErrUnsupportedPlatform
+}
+
+func (f *TaskFolderHandle) CreateSubFolder(name, sddl string) (TaskFolderHandle, error) {
+	return TaskFolderHandle{}, ErrUnsupportedPlatform
+}
+
+func (f *TaskFolderHandle) GetTasks(opts ...EnumOption) (RegisteredTaskCollection, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (f *TaskFolderHandle) GetFolders() ([]TaskFolderHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (f *TaskFolderHandle) Release() {}
+
+func queryTaskEvents(query string) ([]TaskEvent, error) {
+	return nil, ErrUnsupportedPlatform
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package taskmaster
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUnsupportedPlatform(t *testing.T) {
+	taskService, err := Connect()
+	if !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+	defer taskService.Disconnect()
+
+	if _, err = taskService.GetRegisteredTask(`\Taskmaster\TestTask`); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+
+	var task RegisteredTask
+	if _, err = task.History(time.Time{}); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+}
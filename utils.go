@@ -1,5 +1,3 @@
-// +build windows
-
 package taskmaster
 
 import (
@@ -40,7 +38,7 @@ func TaskDateToTime(s string) (time.Time, error) {
 	var t time.Time
 	var err error
 
-	if strings.Count(s, "-") == 3 || strings.Contains(s, "+") {
+	if s[len(s)-1] != 'Z' && taskDateHasTimeZone(s) {
 		t, err = time.Parse(taskDateFormatWTimeZone, s)
 	} else if s[len(s)-1] == 'Z' {
 		t, err = time.Parse(taskDateFormatUTC, s)
@@ -54,6 +52,39 @@ func TaskDateToTime(s string) (time.Time, error) {
 	return t, nil
 }
 
+// taskDateHasTimeZone returns true if the task date s has a UTC offset or is in
+// UTC, rather than being a local time.
+func taskDateHasTimeZone(s string) bool {
+	return strings.Count(s, "-") == 3 || strings.Contains(s, "+") || strings.HasSuffix(s, "Z")
+}
+
+// boundaryToTaskDate formats the start or end boundary of a trigger. If
+// synchronized is true, the boundary is written with the UTC offset of t, or in
+// UTC if t is, so the trigger fires at the same instant in every time zone.
+// Otherwise it's written as a local time like TimeToTaskDate does, and fires at
+// its time of day in the time zone of the computer that runs the task.
+func boundaryToTaskDate(t time.Time, synchronized bool) string {
+	if !synchronized || t == (time.Time{}) {
+		return TimeToTaskDate(t)
+	}
+	if t.Location() == time.UTC {
+		return t.Format(taskDateFormatUTC)
+	}
+
+	return t.Format(taskDateFormatWTimeZone)
+}
+
+// boundariesHaveTimeZone returns true if the start boundary of a trigger, or
+// its end boundary if it has no start boundary, has a UTC offset, which is how
+// the Task Scheduler service stores triggers that synchronize across time zones.
+func boundariesHaveTimeZone(startBoundary, endBoundary string) bool {
+	if startBoundary != "" {
+		return taskDateHasTimeZone(startBoundary)
+	}
+
+	return taskDateHasTimeZone(endBoundary)
+}
+
 func StringToPeriod(s string) (period.Period, error) {
 	if s == "" {
 		return period.Period{}, nil
@@ -70,3 +101,24 @@ func PeriodToString(p period.Period) string {
 
 	return s
 }
+
+// trimUsernameDomain removes the domain from a username in the form of
+// DOMAIN\username. Usernames without a domain are returned unchanged.
+func trimUsernameDomain(username string) string {
+	if i := strings.LastIndex(username, `\`); i != -1 {
+		return username[i+1:]
+	}
+
+	return username
+}
+
+// splitTaskPath splits the path of a task into the path of its folder and its name.
+func splitTaskPath(path string) (string, string) {
+	nameIndex := strings.LastIndex(path, `\`)
+	folderPath := path[:nameIndex]
+	if folderPath == "" {
+		folderPath = `\`
+	}
+
+	return folderPath, path[nameIndex+1:]
+}
@@ -1,15 +1,26 @@
-//go:build windows
-// +build windows
-
 package taskmaster
 
 import (
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"time"
+
+	ole "github.com/go-ole/go-ole"
 )
 
 var defaultTime = time.Time{}
 
+// Validate returns the error that registering the definition would fail with
+// because of a problem that can be detected without the Task Scheduler service,
+// such as a missing action or invalid settings. TaskService.ValidateTaskDefinition
+// also has the Task Scheduler service check the definition.
+func (d Definition) Validate() error {
+	return validateDefinition(d)
+}
+
 func validateDefinition(def Definition) error {
 	var err error
 
@@ -22,21 +33,112 @@ func validateDefinition(def Definition) error {
 	if err = validateTriggers(def.Triggers); err != nil {
 		return err
 	}
+	if err = validateSettings(def.Settings); err != nil {
+		return err
+	}
 
 	if def.Principal.UserID != "" && def.Principal.GroupID != "" {
 		return ErrInvalidPrincipal
 	}
+	if err = validateCompatibility(def); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateCompatibility checks that the features def uses are supported by its
+// compatibility level, as the Task Scheduler service only returns a generic error
+// when they aren't.
+func validateCompatibility(def Definition) error {
+	compatibility := def.Settings.Compatibility
+	requires := func(feature string, required TaskCompatibility) error {
+		if compatibility < required {
+			return fmt.Errorf("%w: %s requires compatibility level %s or later, but Compatibility is %s", ErrIncompatibleDefinition, feature, required, compatibility)
+		}
+		return nil
+	}
+
+	var err error
+	if len(def.Actions) > 1 {
+		if err = requires("more than one action", TASK_COMPATIBILITY_V2); err != nil {
+			return err
+		}
+	}
+	for _, action := range def.Actions {
+		if action.GetType() != TASK_ACTION_EXEC {
+			if err = requires(fmt.Sprintf("a %s action", action.GetType()), TASK_COMPATIBILITY_V2); err != nil {
+				return err
+			}
+		}
+	}
+	for _, trigger := range def.Triggers {
+		switch trigger.(type) {
+		case EventTrigger, RegistrationTrigger, SessionStateChangeTrigger:
+			if err = requires(fmt.Sprintf("a %s trigger", trigger.GetType()), TASK_COMPATIBILITY_V2); err != nil {
+				return err
+			}
+		}
+	}
+
+	if def.Principal.GroupID != "" {
+		if err = requires("a principal with a GroupID", TASK_COMPATIBILITY_V2); err != nil {
+			return err
+		}
+	}
+	if def.Principal.RunLevel == TASK_RUNLEVEL_HIGHEST {
+		if err = requires("TASK_RUNLEVEL_HIGHEST", TASK_COMPATIBILITY_V2); err != nil {
+			return err
+		}
+	}
+	if def.Principal.LogonType == TASK_LOGON_SERVICE_ACCOUNT {
+		if def.Principal.ProcessTokenSidType != TASK_PROCESSTOKENSID_DEFAULT {
+			if err = requires("Principal.ProcessTokenSidType", TASK_COMPATIBILITY_V2_1); err != nil {
+				return err
+			}
+		}
+		if len(def.Principal.RequiredPrivileges) > 0 {
+			if err = requires("Principal.RequiredPrivileges", TASK_COMPATIBILITY_V2_1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if def.Settings.DisallowStartOnRemoteAppSession {
+		if err = requires("Settings.DisallowStartOnRemoteAppSession", TASK_COMPATIBILITY_V2_1); err != nil {
+			return err
+		}
+	}
+	if def.Settings.UseUnifiedSchedulingEngine {
+		if err = requires("Settings.UseUnifiedSchedulingEngine", TASK_COMPATIBILITY_V2_1); err != nil {
+			return err
+		}
+	}
+	if def.Settings.MaintenanceSettings != nil {
+		if err = requires("Settings.MaintenanceSettings", TASK_COMPATIBILITY_V2_2); err != nil {
+			return err
+		}
+	}
+	if def.Settings.Volatile {
+		if err = requires("Settings.Volatile", TASK_COMPATIBILITY_V2_2); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 func validateActions(actions []Action) error {
 	for _, action := range actions {
-		switch action.GetType() {
-		case TASK_ACTION_EXEC:
-			return nil
-		case TASK_ACTION_COM_HANDLER:
-			return nil
+		switch a := action.(type) {
+		case ExecAction:
+		case ComHandlerAction:
+			// the Task Scheduler service requires the CLSID in registry format, ie {xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx}
+			if len(a.ClassID) != 38 || ole.NewGUID(a.ClassID) == nil {
+				return fmt.Errorf("invalid ComHandlerAction: ClassID %q is not a valid GUID", a.ClassID)
+			}
+		case EmailAction:
+		case ShowMessageAction:
 		default:
 			return errors.New("invalid task action type")
 		}
@@ -47,82 +149,174 @@ func validateActions(actions []Action) error {
 
 func validateTriggers(triggers []Trigger) error {
 	for _, trigger := range triggers {
-		switch t := trigger.(type) {
-		case BootTrigger:
-			return nil
-		case DailyTrigger:
-			if t.GetStartBoundary() == defaultTime {
-				return errors.New("invalid DailyTrigger: StartBoundary is required")
-			} else if t.DayInterval > EveryOtherDay {
-				return errors.New("invalid DailyTrigger: invalid DayInterval")
-			}
+		if err := validateTrigger(trigger); err != nil {
+			return err
+		}
+	}
 
-			return nil
-		case EventTrigger:
-			if t.Subscription == "" {
-				return errors.New("invalid EventTrigger: Subscription is required")
-			}
+	return nil
+}
 
-			return nil
-		case IdleTrigger:
-			return nil
-		case LogonTrigger:
-			return nil
-		case MonthlyDOWTrigger:
-			if t.GetStartBoundary() == defaultTime {
-				return errors.New("invalid MonthlyDOWTrigger: StartBoundary is required")
-			} else if t.DaysOfWeek == 0 {
-				return errors.New("invalid MonthlyDOWTrigger: DaysOfWeek is required")
-			} else if t.DaysOfWeek > AllDays {
-				return errors.New("invalid MonthlyDOWTrigger: invalid DaysOfWeek")
-			} else if t.MonthsOfYear == 0 {
-				return errors.New("invalid MonthlyDOWTrigger: MonthsOfYear is required")
-			} else if t.MonthsOfYear > AllMonths {
-				return errors.New("invalid MonthlyDOWTrigger: invalid MonthsOfYear")
-			} else if t.WeeksOfMonth == 0 {
-				return errors.New("invalid MonthlyDOWTrigger: WeeksOfMonth is required")
-			} else if t.WeeksOfMonth > AllWeeks {
-				return errors.New("invalid MonthlyDOWTrigger: invalid WeeksOfMonth")
-			}
+// validateTrigger checks the fields of trigger that the Task Scheduler service
+// would otherwise reject with E_INVALIDARG when the task is registered.
+func validateTrigger(trigger Trigger) error {
+	if err := validateRepetitionPattern(trigger); err != nil {
+		return err
+	}
+	if trigger.GetEndBoundary() != defaultTime && trigger.GetEndBoundary().Before(trigger.GetStartBoundary()) {
+		return ErrEndBoundaryBeforeStart
+	}
 
-			return nil
-		case MonthlyTrigger:
-			if t.GetStartBoundary() == defaultTime {
-				return errors.New("invalid MonthlyTrigger: StartBoundary is required")
-			} else if t.DaysOfMonth == 0 {
-				return errors.New("invalid MonthlyTrigger: DaysOfMonth is required")
-			} else if t.DaysOfMonth > AllDaysOfMonth {
-				return errors.New("invalid MonthlyTrigger: invalid DaysOfMonth")
-			} else if t.MonthsOfYear == 0 {
-				return errors.New("invalid MonthlyTrigger: MonthsOfYear is required")
-			} else if t.MonthsOfYear > AllMonths {
-				return errors.New("invalid MonthlyTrigger: invalid MonthsOfYear")
-			}
+	switch t := trigger.(type) {
+	case BootTrigger:
+		if t.Delay.IsNegative() {
+			return errors.New("invalid BootTrigger: Delay is negative")
+		}
+	case DailyTrigger:
+		if t.GetStartBoundary() == defaultTime {
+			return errors.New("invalid DailyTrigger: StartBoundary is required")
+		} else if t.DayInterval == 0 {
+			return errors.New("invalid DailyTrigger: DayInterval is required")
+		} else if t.DayInterval > 365 {
+			return errors.New("invalid DailyTrigger: invalid DayInterval")
+		}
+	case EventTrigger:
+		if t.Subscription == "" {
+			return errors.New("invalid EventTrigger: Subscription is required")
+		} else if err := validateXML(t.Subscription); err != nil {
+			return fmt.Errorf("invalid EventTrigger: Subscription is not valid XML: %v", err)
+		} else if t.Delay.IsNegative() {
+			return errors.New("invalid EventTrigger: Delay is negative")
+		}
+	case IdleTrigger:
+	case LogonTrigger:
+		if t.Delay.IsNegative() {
+			return errors.New("invalid LogonTrigger: Delay is negative")
+		}
+	case MonthlyDOWTrigger:
+		if t.GetStartBoundary() == defaultTime {
+			return errors.New("invalid MonthlyDOWTrigger: StartBoundary is required")
+		} else if t.DaysOfWeek == 0 {
+			return errors.New("invalid MonthlyDOWTrigger: DaysOfWeek is required")
+		} else if t.DaysOfWeek > AllDays {
+			return errors.New("invalid MonthlyDOWTrigger: invalid DaysOfWeek")
+		} else if t.MonthsOfYear == 0 {
+			return errors.New("invalid MonthlyDOWTrigger: MonthsOfYear is required")
+		} else if t.MonthsOfYear > AllMonths {
+			return errors.New("invalid MonthlyDOWTrigger: invalid MonthsOfYear")
+		} else if t.WeeksOfMonth == 0 && !t.RunOnLastWeekOfMonth {
+			return errors.New("invalid MonthlyDOWTrigger: WeeksOfMonth or RunOnLastWeekOfMonth is required")
+		} else if t.WeeksOfMonth > AllWeeks {
+			return errors.New("invalid MonthlyDOWTrigger: invalid WeeksOfMonth")
+		}
+	case MonthlyTrigger:
+		if t.GetStartBoundary() == defaultTime {
+			return errors.New("invalid MonthlyTrigger: StartBoundary is required")
+		} else if t.DaysOfMonth == 0 && !t.runsOnLastDayOfMonth() {
+			return errors.New("invalid MonthlyTrigger: DaysOfMonth or RunOnLastDayOfMonth is required")
+		} else if t.DaysOfMonth > AllDaysOfMonth {
+			return errors.New("invalid MonthlyTrigger: invalid DaysOfMonth")
+		} else if t.MonthsOfYear == 0 {
+			return errors.New("invalid MonthlyTrigger: MonthsOfYear is required")
+		} else if t.MonthsOfYear > AllMonths {
+			return errors.New("invalid MonthlyTrigger: invalid MonthsOfYear")
+		}
+	case RegistrationTrigger:
+		if t.Delay.IsNegative() {
+			return errors.New("invalid RegistrationTrigger: Delay is negative")
+		}
+	case SessionStateChangeTrigger:
+		switch t.StateChange {
+		case TASK_CONSOLE_CONNECT, TASK_CONSOLE_DISCONNECT, TASK_REMOTE_CONNECT, TASK_REMOTE_DISCONNECT, TASK_SESSION_LOCK, TASK_SESSION_UNLOCK:
+		default:
+			return errors.New("invalid SessionStateChangeTrigger: invalid StateChange")
+		}
+		if t.Delay.IsNegative() {
+			return errors.New("invalid SessionStateChangeTrigger: Delay is negative")
+		}
+	case TimeTrigger:
+		if t.GetStartBoundary() == defaultTime {
+			return errors.New("invalid TimeTrigger: StartBoundary is required")
+		}
+	case WeeklyTrigger:
+		if t.GetStartBoundary() == defaultTime {
+			return errors.New("invalid WeeklyTrigger: StartBoundary is required")
+		} else if t.DaysOfWeek == 0 {
+			return errors.New("invalid WeeklyTrigger: DaysOfWeek is required")
+		} else if t.DaysOfWeek > AllDays {
+			return errors.New("invalid WeeklyTrigger: invalid DaysOfWeek")
+		} else if t.WeekInterval == 0 {
+			return errors.New("invalid WeeklyTrigger: WeekInterval is required")
+		} else if t.WeekInterval > 52 {
+			return errors.New("invalid WeeklyTrigger: invalid WeekInterval")
+		}
+	default:
+		return errors.New("invalid task trigger type")
+	}
 
-			return nil
-		case RegistrationTrigger:
-			return nil
-		case SessionStateChangeTrigger:
-			return nil
-		case TimeTrigger:
-			return nil
-		case WeeklyTrigger:
-			if t.GetStartBoundary() == defaultTime {
-				return errors.New("invalid WeeklyTrigger: StartBoundary is required")
-			} else if t.DaysOfWeek == 0 {
-				return errors.New("invalid WeeklyTrigger: DaysOfWeek is required")
-			} else if t.DaysOfWeek > AllDays {
-				return errors.New("invalid WeeklyTrigger: invalid DaysOfWeek")
-			} else if t.WeekInterval == 0 {
-				return errors.New("invalid WeeklyTrigger: WeekInterval is required")
-			} else if t.WeekInterval > EveryOtherWeek {
-				return errors.New("invalid WeeklyTrigger: invalid WeekInterval")
-			}
+	return nil
+}
 
-			return nil
-		default:
-			return errors.New("invalid task trigger type")
+func validateSettings(settings TaskSettings) error {
+	if !settings.IdleSettings.WaitTimeout.IsZero() &&
+		settings.IdleSettings.WaitTimeout.DurationApprox() < settings.IdleSettings.IdleDuration.DurationApprox() {
+		return ErrIdleWaitTimeoutTooShort
+	}
+	if settings.Priority > 10 {
+		return ErrInvalidPriority
+	}
+	if settings.MultipleInstances > TASK_INSTANCES_STOP_EXISTING {
+		return ErrInvalidInstancesPolicy
+	}
+	if !settings.RestartInterval.IsZero() {
+		restartInterval := settings.RestartInterval.DurationApprox()
+		if restartInterval < time.Minute || restartInterval > 31*24*time.Hour {
+			return errors.New("invalid task settings: RestartInterval must be between one minute and 31 days")
 		}
 	}
+	if settings.NetworkSettings.ID != "" && (len(settings.NetworkSettings.ID) != 38 || ole.NewGUID(settings.NetworkSettings.ID) == nil) {
+		return fmt.Errorf("invalid task settings: NetworkSettings.ID %q is not a valid GUID", settings.NetworkSettings.ID)
+	}
+
+	return nil
+}
+
+func validateRepetitionPattern(trigger Trigger) error {
+	duration := trigger.GetRepetitionDuration()
+	interval := trigger.GetRepetitionInterval()
+
+	if duration.IsZero() {
+		// the pattern is repeated indefinitely
+		return nil
+	}
+	if interval.IsZero() {
+		return errors.New("invalid repetition pattern: RepetitionInterval is required if RepetitionDuration is specified")
+	}
+	if interval.DurationApprox() > duration.DurationApprox() {
+		return errors.New("invalid repetition pattern: RepetitionInterval is longer than RepetitionDuration")
+	}
+
+	return nil
+}
+
+// validateXML returns an error if s isn't well-formed XML with a root element.
+func validateXML(s string) error {
+	decoder := xml.NewDecoder(strings.NewReader(s))
+	hasRoot := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			hasRoot = true
+		}
+	}
+	if !hasRoot {
+		return errors.New("no root element")
+	}
+
 	return nil
 }
@@ -0,0 +1,62 @@
+package taskmaster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rickb777/date/period"
+)
+
+func TestValidateCompatibility(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(def *Definition)
+		compatibility TaskCompatibility
+		valid         bool
+	}{
+		{"ExecAction", func(def *Definition) {}, TASK_COMPATIBILITY_V1, true},
+		{"two actions", func(def *Definition) { def.AddAction(ExecAction{Path: "calc.exe"}) }, TASK_COMPATIBILITY_V1, false},
+		{"ComHandlerAction", func(def *Definition) {
+			def.Actions[0] = ComHandlerAction{ClassID: "{F0001111-0000-0000-0000-0000FEEDACDC}"}
+		}, TASK_COMPATIBILITY_V1, false},
+		{"EventTrigger", func(def *Definition) { def.AddTrigger(mustTrigger(NewEventTrigger("System", "", 1))) }, TASK_COMPATIBILITY_V1, false},
+		{"EventTrigger", func(def *Definition) { def.AddTrigger(mustTrigger(NewEventTrigger("System", "", 1))) }, TASK_COMPATIBILITY_V2, true},
+		{"GroupID", func(def *Definition) { def.Principal.GroupID = "Users" }, TASK_COMPATIBILITY_V1, false},
+		{"RequiredPrivileges", func(def *Definition) {
+			def.Principal.LogonType = TASK_LOGON_SERVICE_ACCOUNT
+			def.Principal.RequiredPrivileges = []string{"SeBackupPrivilege"}
+		}, TASK_COMPATIBILITY_V2, false},
+		{"RequiredPrivileges", func(def *Definition) {
+			def.Principal.LogonType = TASK_LOGON_SERVICE_ACCOUNT
+			def.Principal.RequiredPrivileges = []string{"SeBackupPrivilege"}
+		}, TASK_COMPATIBILITY_V2_1, true},
+		{"UseUnifiedSchedulingEngine", func(def *Definition) { def.Settings.UseUnifiedSchedulingEngine = true }, TASK_COMPATIBILITY_V2, false},
+		{"MaintenanceSettings", func(def *Definition) {
+			def.Settings.MaintenanceSettings = &MaintenanceSettings{Period: period.NewYMD(0, 0, 1)}
+		}, TASK_COMPATIBILITY_V2_1, false},
+		{"MaintenanceSettings", func(def *Definition) {
+			def.Settings.MaintenanceSettings = &MaintenanceSettings{Period: period.NewYMD(0, 0, 1)}
+		}, TASK_COMPATIBILITY_V2_2, true},
+		{"Volatile", func(def *Definition) { def.Settings.Volatile = true }, TASK_COMPATIBILITY_V2_1, false},
+		{"SYSTEM principal", func(def *Definition) {
+			def.Principal = Principal{LogonType: TASK_LOGON_SERVICE_ACCOUNT, UserID: "SYSTEM"}
+		}, TASK_COMPATIBILITY_V2, true},
+		{"ProcessTokenSidType", func(def *Definition) {
+			def.Principal = Principal{LogonType: TASK_LOGON_SERVICE_ACCOUNT, UserID: "SYSTEM", ProcessTokenSidType: TASK_PROCESSTOKENSID_NONE}
+		}, TASK_COMPATIBILITY_V2, false},
+	}
+
+	for _, test := range tests {
+		def := defaultDefinition()
+		def.AddAction(ExecAction{Path: "cmd.exe"})
+		test.modify(&def)
+		def.Settings.Compatibility = test.compatibility
+
+		err := validateDefinition(def)
+		if test.valid && err != nil {
+			t.Errorf("%s at %s: unexpected error: %v", test.name, test.compatibility, err)
+		} else if !test.valid && !errors.Is(err, ErrIncompatibleDefinition) {
+			t.Errorf("%s at %s: expected ErrIncompatibleDefinition, got %v", test.name, test.compatibility, err)
+		}
+	}
+}
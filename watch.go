@@ -0,0 +1,304 @@
+//go:build windows
+// +build windows
+
+package taskmaster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// watchPollInterval is how often WatchStateChanges polls the Task Scheduler
+// service for running tasks.
+const watchPollInterval = time.Second
+
+// errWatchDisconnected is returned by the snapshots of watchers once the
+// TaskService has been disconnected, which stops them.
+var errWatchDisconnected = errors.New("the TaskService was disconnected")
+
+// WatchStateChanges polls the Task Scheduler service for running tasks and sends
+// an event on the returned channel whenever a task instance starts or stops. When
+// an instance stops, the event carries the state and last result of the registered
+// task, so a failed run can be detected by checking for a non-zero Result. Tasks
+// that are already running when WatchStateChanges is called will not generate a
+// start event. The channel is closed once ctx is cancelled or the TaskService is
+// disconnected.
+func (t *TaskService) WatchStateChanges(ctx context.Context) (<-chan StateChangeEvent, error) {
+	running, err := t.runningTasksSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return pollChanges(ctx, watchPollInterval, running, t.runningTasksSnapshot, t.stateChangeEvents), nil
+}
+
+// runningTasksSnapshot returns the paths of all currently running task instances,
+// keyed by instance GUID, or errWatchDisconnected if the TaskService has been
+// disconnected.
+func (t *TaskService) runningTasksSnapshot() (map[string]string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.isConnected {
+		return nil, errWatchDisconnected
+	}
+
+	return t.runningTaskPaths()
+}
+
+// watchedTask returns the registered task at path, or errWatchDisconnected if
+// the TaskService has been disconnected since the last snapshot.
+func (t *TaskService) watchedTask(path string) (RegisteredTask, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.isConnected {
+		return RegisteredTask{}, errWatchDisconnected
+	}
+
+	return t.getRegisteredTask(path)
+}
+
+// stateChangeEvents returns the events describing the task instances that
+// started or stopped between two sets of running task instances.
+func (t *TaskService) stateChangeEvents(prev, current map[string]string) []StateChangeEvent {
+	var events []StateChangeEvent
+	for guid, path := range current {
+		if _, ok := prev[guid]; !ok {
+			events = append(events, StateChangeEvent{
+				Path:         path,
+				InstanceGUID: guid,
+				State:        TASK_STATE_RUNNING,
+			})
+		}
+	}
+
+	for guid, path := range prev {
+		if _, ok := current[guid]; ok {
+			continue
+		}
+
+		event := StateChangeEvent{
+			Path:         path,
+			InstanceGUID: guid,
+			State:        TASK_STATE_UNKNOWN,
+		}
+		task, err := t.watchedTask(path)
+		if err == nil {
+			event.State = task.State
+			event.Result = task.LastTaskResult
+			task.Release()
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// Watch polls the Task Scheduler service and sends an event on the returned channel
+// whenever a task in opts.Folder or its subfolders is registered or deleted, an
+// instance of one starts or completes, or one misses a scheduled run. Changes are
+// detected by comparing the results of consecutive polls, so tasks that are
+// registered and deleted, or instances that start and complete, between two polls
+// are not reported. The channel is closed once ctx is cancelled or the TaskService
+// is disconnected.
+func (t *TaskService) Watch(ctx context.Context, opts WatchOptions) (<-chan WatchEvent, error) {
+	if opts.Folder == "" {
+		opts.Folder = "\\"
+	}
+	if opts.Folder[0] != '\\' {
+		return nil, ErrInvalidPath
+	}
+	if opts.Folder != "\\" {
+		opts.Folder = strings.TrimSuffix(opts.Folder, "\\")
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = watchPollInterval
+	}
+
+	prev, err := t.watchSnapshot(opts)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := func() (watchSnapshot, error) {
+		return t.watchSnapshot(opts)
+	}
+
+	return pollChanges(ctx, opts.PollInterval, prev, snapshot, t.watchEvents), nil
+}
+
+// pollChanges takes a snapshot every interval, and sends the events that changes
+// returns for each pair of consecutive snapshots, starting with prev, on the
+// returned channel. Snapshots that fail are skipped, as the service may be
+// temporarily unreachable, except with errWatchDisconnected. The channel is
+// closed once ctx is cancelled or a snapshot returns errWatchDisconnected.
+func pollChanges[S, E any](ctx context.Context, interval time.Duration, prev S, snapshot func() (S, error), changes func(prev, current S) []E) <-chan E {
+	events := make(chan E)
+	go func() {
+		defer close(events)
+
+		uninitialize, err := initializeThread()
+		if err != nil {
+			return
+		}
+		defer uninitialize()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := snapshot()
+			if errors.Is(err, errWatchDisconnected) {
+				return
+			} else if err != nil {
+				// try again next tick
+				continue
+			}
+
+			for _, event := range changes(prev, current) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			prev = current
+		}
+	}()
+
+	return events
+}
+
+// watchSnapshot is the state of the watched tasks at the time of a poll.
+type watchSnapshot struct {
+	missedRuns map[string]uint   // the number of missed runs of registered tasks, keyed by path
+	running    map[string]string // the paths of running task instances, keyed by instance GUID
+}
+
+func (t *TaskService) watchSnapshot(opts WatchOptions) (watchSnapshot, error) {
+	snapshot := watchSnapshot{
+		missedRuns: make(map[string]uint),
+	}
+
+	// the whole snapshot is taken under the lock, so that Disconnect can't
+	// release the COM objects while it's in progress
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.isConnected {
+		return watchSnapshot{}, errWatchDisconnected
+	}
+	folderObj, err := t.getFolderObj(opts.Folder)
+	if err != nil {
+		return watchSnapshot{}, err
+	}
+	err = t.walkRegisteredTasks(folderObj, enumFlags(opts.IncludeHidden), func(task *ole.IDispatch) error {
+		defer task.Release()
+
+		path, err := oleutil.GetProperty(task, "Path")
+		if err != nil {
+			return fmt.Errorf("error getting path of registered task: %w", getTaskSchedulerError(err))
+		}
+		missedRuns, err := oleutil.GetProperty(task, "NumberOfMissedRuns")
+		if err != nil {
+			return fmt.Errorf("error getting missed runs of registered task %s: %w", path.ToString(), getTaskSchedulerPathError(err, "NumberOfMissedRuns", path.ToString()))
+		}
+		snapshot.missedRuns[path.ToString()] = uint(missedRuns.Val)
+
+		return nil
+	})
+	folderObj.Release()
+	if err != nil {
+		return watchSnapshot{}, err
+	}
+
+	running, err := t.runningTaskPaths()
+	if err != nil {
+		return watchSnapshot{}, err
+	}
+	snapshot.running = make(map[string]string, len(running))
+	for guid, path := range running {
+		if opts.Folder == "\\" || strings.HasPrefix(strings.ToLower(path), strings.ToLower(opts.Folder)+"\\") {
+			snapshot.running[guid] = path
+		}
+	}
+
+	return snapshot, nil
+}
+
+// watchEvents returns the events describing the changes between two snapshots,
+// ordered by type and then by path.
+func (t *TaskService) watchEvents(prev, current watchSnapshot) []WatchEvent {
+	var events []WatchEvent
+
+	for path, missedRuns := range current.missedRuns {
+		prevMissedRuns, ok := prev.missedRuns[path]
+		if !ok {
+			events = append(events, WatchEvent{Type: WATCH_TASK_REGISTERED, Path: path})
+		} else if missedRuns > prevMissedRuns {
+			events = append(events, WatchEvent{Type: WATCH_TASK_MISSED, Path: path, MissedRuns: missedRuns})
+		}
+	}
+	for path := range prev.missedRuns {
+		if _, ok := current.missedRuns[path]; !ok {
+			events = append(events, WatchEvent{Type: WATCH_TASK_DELETED, Path: path})
+		}
+	}
+	for guid, path := range current.running {
+		if _, ok := prev.running[guid]; !ok {
+			events = append(events, WatchEvent{Type: WATCH_TASK_STARTED, Path: path, InstanceGUID: guid})
+		}
+	}
+	for guid, path := range prev.running {
+		if _, ok := current.running[guid]; ok {
+			continue
+		}
+
+		event := WatchEvent{Type: WATCH_TASK_COMPLETED, Path: path, InstanceGUID: guid}
+		if task, err := t.watchedTask(path); err == nil {
+			event.Result = task.LastTaskResult
+			task.Release()
+		}
+		events = append(events, event)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Type != events[j].Type {
+			return events[i].Type < events[j].Type
+		}
+		return events[i].Path < events[j].Path
+	})
+
+	return events
+}
+
+// runningTaskPaths returns the paths of all currently running task instances,
+// keyed by instance GUID. t.mu must be held.
+func (t *TaskService) runningTaskPaths() (map[string]string, error) {
+	runningTasks, err := t.getRunningTasks()
+	if err != nil {
+		return nil, fmt.Errorf("error getting running tasks: %v", err)
+	}
+	defer runningTasks.Release()
+
+	paths := make(map[string]string, len(runningTasks))
+	for _, runningTask := range runningTasks {
+		paths[runningTask.InstanceGUID] = runningTask.Path
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,1067 @@
+package taskmaster
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/rickb777/date/period"
+)
+
+// A Definition can be encoded to and decoded from the Task Scheduler XML schema
+// without a connection to the Task Scheduler service. The types below mirror the
+// elements of the schema; fields of a Definition that don't have an equivalent
+// element, such as XMLText, are ignored.
+// https://docs.microsoft.com/en-us/windows/win32/taskschd/task-scheduler-schema
+
+const taskXMLNamespace = "http://schemas.microsoft.com/windows/2004/02/mit/task"
+
+var compatibilityVersions = map[TaskCompatibility]string{
+	TASK_COMPATIBILITY_AT:   "1.0",
+	TASK_COMPATIBILITY_V1:   "1.1",
+	TASK_COMPATIBILITY_V2:   "1.2",
+	TASK_COMPATIBILITY_V2_1: "1.3",
+	TASK_COMPATIBILITY_V2_2: "1.4",
+	TASK_COMPATIBILITY_V2_3: "1.5",
+	TASK_COMPATIBILITY_V2_4: "1.6",
+}
+
+var logonTypeNames = map[TaskLogonType]string{
+	TASK_LOGON_PASSWORD:                      "Password",
+	TASK_LOGON_S4U:                           "S4U",
+	TASK_LOGON_INTERACTIVE_TOKEN:             "InteractiveToken",
+	TASK_LOGON_INTERACTIVE_TOKEN_OR_PASSWORD: "InteractiveTokenOrPassword",
+}
+
+var runLevelNames = map[TaskRunLevel]string{
+	TASK_RUNLEVEL_LUA:     "LeastPrivilege",
+	TASK_RUNLEVEL_HIGHEST: "HighestAvailable",
+}
+
+var processTokenSidTypeNames = map[TaskProcessTokenSidType]string{
+	TASK_PROCESSTOKENSID_NONE:         "None",
+	TASK_PROCESSTOKENSID_UNRESTRICTED: "Unrestricted",
+	TASK_PROCESSTOKENSID_DEFAULT:      "Default",
+}
+
+var instancesPolicyNames = map[TaskInstancesPolicy]string{
+	TASK_INSTANCES_PARALLEL:      "Parallel",
+	TASK_INSTANCES_QUEUE:         "Queue",
+	TASK_INSTANCES_IGNORE_NEW:    "IgnoreNew",
+	TASK_INSTANCES_STOP_EXISTING: "StopExisting",
+}
+
+var sessionStateChangeNames = map[TaskSessionStateChangeType]string{
+	TASK_CONSOLE_CONNECT:    "ConsoleConnect",
+	TASK_CONSOLE_DISCONNECT: "ConsoleDisconnect",
+	TASK_REMOTE_CONNECT:     "RemoteConnect",
+	TASK_REMOTE_DISCONNECT:  "RemoteDisconnect",
+	TASK_SESSION_LOCK:       "SessionLock",
+	TASK_SESSION_UNLOCK:     "SessionUnlock",
+}
+
+var (
+	dayOfWeekNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	monthNames     = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+)
+
+type taskXML struct {
+	XMLName          xml.Name            `xml:"Task"`
+	Version          string              `xml:"version,attr"`
+	RegistrationInfo registrationInfoXML `xml:"RegistrationInfo"`
+	Triggers         triggersXML         `xml:"Triggers"`
+	Principals       principalsXML       `xml:"Principals"`
+	Settings         settingsXML         `xml:"Settings"`
+	Data             string              `xml:"Data,omitempty"`
+	Actions          actionsXML          `xml:"Actions"`
+}
+
+type registrationInfoXML struct {
+	Date               string `xml:"Date,omitempty"`
+	Author             string `xml:"Author,omitempty"`
+	Description        string `xml:"Description,omitempty"`
+	Documentation      string `xml:"Documentation,omitempty"`
+	SecurityDescriptor string `xml:"SecurityDescriptor,omitempty"`
+	Source             string `xml:"Source,omitempty"`
+	URI                string `xml:"URI,omitempty"`
+	Version            string `xml:"Version,omitempty"`
+}
+
+type triggersXML struct {
+	Triggers []triggerXML `xml:",any"`
+}
+
+type triggerXML struct {
+	XMLName            xml.Name
+	ID                 string                 `xml:"id,attr,omitempty"`
+	Repetition         *repetitionXML         `xml:"Repetition"`
+	StartBoundary      string                 `xml:"StartBoundary,omitempty"`
+	EndBoundary        string                 `xml:"EndBoundary,omitempty"`
+	ExecutionTimeLimit string                 `xml:"ExecutionTimeLimit,omitempty"`
+	Enabled            *bool                  `xml:"Enabled"`
+	Delay              string                 `xml:"Delay,omitempty"`
+	RandomDelay        string                 `xml:"RandomDelay,omitempty"`
+	UserID             string                 `xml:"UserId,omitempty"`
+	StateChange        string                 `xml:"StateChange,omitempty"`
+	Subscription       string                 `xml:"Subscription,omitempty"`
+	ValueQueries       *valueQueriesXML       `xml:"ValueQueries"`
+	ScheduleByDay      *scheduleByDayXML      `xml:"ScheduleByDay"`
+	ScheduleByWeek     *scheduleByWeekXML     `xml:"ScheduleByWeek"`
+	ScheduleByMonth    *scheduleByMonthXML    `xml:"ScheduleByMonth"`
+	ScheduleByMonthDOW *scheduleByMonthDOWXML `xml:"ScheduleByMonthDayOfWeek"`
+}
+
+type repetitionXML struct {
+	Interval          string `xml:"Interval"`
+	Duration          string `xml:"Duration,omitempty"`
+	StopAtDurationEnd bool   `xml:"StopAtDurationEnd"`
+}
+
+type valueQueriesXML struct {
+	Values []valueQueryXML `xml:"Value"`
+}
+
+type valueQueryXML struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type scheduleByDayXML struct {
+	DaysInterval DayInterval `xml:"DaysInterval"`
+}
+
+type scheduleByWeekXML struct {
+	DaysOfWeek    daysOfWeekXML `xml:"DaysOfWeek"`
+	WeeksInterval WeekInterval  `xml:"WeeksInterval"`
+}
+
+type scheduleByMonthXML struct {
+	DaysOfMonth daysOfMonthXML `xml:"DaysOfMonth"`
+	Months      monthsXML      `xml:"Months"`
+}
+
+type scheduleByMonthDOWXML struct {
+	Weeks      weeksXML      `xml:"Weeks"`
+	DaysOfWeek daysOfWeekXML `xml:"DaysOfWeek"`
+	Months     monthsXML     `xml:"Months"`
+}
+
+type principalsXML struct {
+	Principal principalXML `xml:"Principal"`
+}
+
+type principalXML struct {
+	ID          string `xml:"id,attr,omitempty"`
+	UserID      string `xml:"UserId,omitempty"`
+	GroupID     string `xml:"GroupId,omitempty"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+	LogonType   string `xml:"LogonType,omitempty"`
+	RunLevel    string `xml:"RunLevel,omitempty"`
+	// these elements are omitted unless they are set, as older versions of Windows
+	// don't accept them
+	ProcessTokenSidType string                 `xml:"ProcessTokenSidType,omitempty"`
+	RequiredPrivileges  *requiredPrivilegesXML `xml:"RequiredPrivileges"`
+}
+
+type requiredPrivilegesXML struct {
+	Privileges []string `xml:"Privilege"`
+}
+
+type settingsXML struct {
+	DisallowStartIfOnBatteries *bool                   `xml:"DisallowStartIfOnBatteries"`
+	StopIfGoingOnBatteries     *bool                   `xml:"StopIfGoingOnBatteries"`
+	MultipleInstancesPolicy    string                  `xml:"MultipleInstancesPolicy,omitempty"`
+	AllowHardTerminate         *bool                   `xml:"AllowHardTerminate"`
+	StartWhenAvailable         *bool                   `xml:"StartWhenAvailable"`
+	RunOnlyIfNetworkAvailable  *bool                   `xml:"RunOnlyIfNetworkAvailable"`
+	NetworkSettings            *networkSettingsXML     `xml:"NetworkSettings"`
+	IdleSettings               *idleSettingsXML        `xml:"IdleSettings"`
+	AllowStartOnDemand         *bool                   `xml:"AllowStartOnDemand"`
+	Enabled                    *bool                   `xml:"Enabled"`
+	Hidden                     *bool                   `xml:"Hidden"`
+	RunOnlyIfIdle              *bool                   `xml:"RunOnlyIfIdle"`
+	WakeToRun                  *bool                   `xml:"WakeToRun"`
+	ExecutionTimeLimit         string                  `xml:"ExecutionTimeLimit,omitempty"`
+	DeleteExpiredTaskAfter     string                  `xml:"DeleteExpiredTaskAfter,omitempty"`
+	Priority                   *uint                   `xml:"Priority"`
+	RestartOnFailure           *restartOnFailureXML    `xml:"RestartOnFailure"`
+	MaintenanceSettings        *maintenanceSettingsXML `xml:"MaintenanceSettings"`
+	// these elements are omitted unless they are set, as older versions of Windows
+	// don't accept them
+	UseUnifiedSchedulingEngine      bool `xml:"UseUnifiedSchedulingEngine,omitempty"`
+	DisallowStartOnRemoteAppSession bool `xml:"DisallowStartOnRemoteAppSession,omitempty"`
+	Volatile                        bool `xml:"Volatile,omitempty"`
+}
+
+type networkSettingsXML struct {
+	Name string `xml:"Name,omitempty"`
+	ID   string `xml:"Id,omitempty"`
+}
+
+type idleSettingsXML struct {
+	Duration      string `xml:"Duration,omitempty"`
+	WaitTimeout   string `xml:"WaitTimeout,omitempty"`
+	StopOnIdleEnd *bool  `xml:"StopOnIdleEnd"`
+	RestartOnIdle *bool  `xml:"RestartOnIdle"`
+}
+
+type restartOnFailureXML struct {
+	Interval string `xml:"Interval"`
+	Count    uint   `xml:"Count"`
+}
+
+type maintenanceSettingsXML struct {
+	Period    string `xml:"Period"`
+	Deadline  string `xml:"Deadline,omitempty"`
+	Exclusive bool   `xml:"Exclusive"`
+}
+
+type actionsXML struct {
+	Context string      `xml:"Context,attr,omitempty"`
+	Actions []actionXML `xml:",any"`
+}
+
+type actionXML struct {
+	XMLName          xml.Name
+	ID               string           `xml:"id,attr,omitempty"`
+	Command          string           `xml:"Command,omitempty"`
+	Arguments        string           `xml:"Arguments,omitempty"`
+	WorkingDirectory string           `xml:"WorkingDirectory,omitempty"`
+	ClassID          string           `xml:"ClassId,omitempty"`
+	Data             string           `xml:"Data,omitempty"`
+	Server           string           `xml:"Server,omitempty"`
+	Subject          string           `xml:"Subject,omitempty"`
+	To               string           `xml:"To,omitempty"`
+	Cc               string           `xml:"Cc,omitempty"`
+	Bcc              string           `xml:"Bcc,omitempty"`
+	ReplyTo          string           `xml:"ReplyTo,omitempty"`
+	From             string           `xml:"From,omitempty"`
+	HeaderFields     *headerFieldsXML `xml:"HeaderFields"`
+	Title            string           `xml:"Title,omitempty"`
+	Body             string           `xml:"Body,omitempty"`
+	Attachments      *attachmentsXML  `xml:"Attachments"`
+}
+
+type headerFieldsXML struct {
+	Fields []headerFieldXML `xml:"HeaderField"`
+}
+
+type headerFieldXML struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+type attachmentsXML struct {
+	Files []string `xml:"File"`
+}
+
+// MarshalXML encodes the definition as a Task element of the Task Scheduler XML
+// schema, so xml.Marshal can be used to get the XML representation of a definition
+// without a connection to the Task Scheduler service.
+func (d Definition) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	task, err := newTaskXML(d)
+	if err != nil {
+		return err
+	}
+
+	start = xml.StartElement{
+		Name: xml.Name{Space: taskXMLNamespace, Local: "Task"},
+	}
+	return e.EncodeElement(task, start)
+}
+
+// UnmarshalXML decodes a definition from a Task element of the Task Scheduler
+// XML schema, such as one returned by RegisteredTask.ExportXML. Elements that are
+// omitted are set to the defaults of the Task Scheduler service.
+func (d *Definition) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var task taskXML
+	if err := dec.DecodeElement(&task, &start); err != nil {
+		return err
+	}
+
+	def, err := task.toDefinition()
+	if err != nil {
+		return err
+	}
+	*d = def
+
+	return nil
+}
+
+func newTaskXML(d Definition) (taskXML, error) {
+	version, ok := compatibilityVersions[d.Settings.Compatibility]
+	if !ok {
+		return taskXML{}, fmt.Errorf("error encoding definition: invalid Compatibility %d", d.Settings.Compatibility)
+	}
+
+	task := taskXML{
+		Version: version,
+		RegistrationInfo: registrationInfoXML{
+			Date:               TimeToTaskDate(d.RegistrationInfo.Date),
+			Author:             d.RegistrationInfo.Author,
+			Description:        d.RegistrationInfo.Description,
+			Documentation:      d.RegistrationInfo.Documentation,
+			SecurityDescriptor: d.RegistrationInfo.SecurityDescriptor,
+			Source:             d.RegistrationInfo.Source,
+			URI:                d.RegistrationInfo.URI,
+			Version:            d.RegistrationInfo.Version,
+		},
+		Principals: principalsXML{
+			Principal: principalXML{
+				ID:          d.Principal.ID,
+				UserID:      d.Principal.UserID,
+				GroupID:     d.Principal.GroupID,
+				DisplayName: d.Principal.Name,
+				LogonType:   logonTypeNames[d.Principal.LogonType],
+				RunLevel:    runLevelNames[d.Principal.RunLevel],
+			},
+		},
+		Settings: newSettingsXML(d.Settings),
+		Data:     d.Data,
+		Actions: actionsXML{
+			Context: d.Context,
+		},
+	}
+	if d.Principal.ProcessTokenSidType != TASK_PROCESSTOKENSID_DEFAULT {
+		task.Principals.Principal.ProcessTokenSidType = processTokenSidTypeNames[d.Principal.ProcessTokenSidType]
+	}
+	if len(d.Principal.RequiredPrivileges) > 0 {
+		task.Principals.Principal.RequiredPrivileges = &requiredPrivilegesXML{Privileges: d.Principal.RequiredPrivileges}
+	}
+
+	for _, trigger := range d.Triggers {
+		triggerXML, err := newTriggerXML(trigger)
+		if err != nil {
+			return taskXML{}, err
+		}
+		task.Triggers.Triggers = append(task.Triggers.Triggers, triggerXML)
+	}
+
+	for _, action := range d.Actions {
+		switch a := action.(type) {
+		case ExecAction:
+			task.Actions.Actions = append(task.Actions.Actions, actionXML{
+				XMLName:          xml.Name{Local: "Exec"},
+				ID:               a.ID,
+				Command:          a.Path,
+				Arguments:        a.Args,
+				WorkingDirectory: a.WorkingDir,
+			})
+		case ComHandlerAction:
+			task.Actions.Actions = append(task.Actions.Actions, actionXML{
+				XMLName: xml.Name{Local: "ComHandler"},
+				ID:      a.ID,
+				ClassID: a.ClassID,
+				Data:    a.Data,
+			})
+		case EmailAction:
+			emailXML := actionXML{
+				XMLName: xml.Name{Local: "SendEmail"},
+				ID:      a.ID,
+				Server:  a.Server,
+				Subject: a.Subject,
+				To:      a.To,
+				Cc:      a.Cc,
+				Bcc:     a.Bcc,
+				ReplyTo: a.ReplyTo,
+				From:    a.From,
+				Body:    a.Body,
+			}
+			if len(a.HeaderFields) > 0 {
+				emailXML.HeaderFields = &headerFieldsXML{}
+				names := make([]string, 0, len(a.HeaderFields))
+				for name := range a.HeaderFields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					emailXML.HeaderFields.Fields = append(emailXML.HeaderFields.Fields, headerFieldXML{Name: name, Value: a.HeaderFields[name]})
+				}
+			}
+			if len(a.Attachments) > 0 {
+				emailXML.Attachments = &attachmentsXML{Files: a.Attachments}
+			}
+			task.Actions.Actions = append(task.Actions.Actions, emailXML)
+		case ShowMessageAction:
+			task.Actions.Actions = append(task.Actions.Actions, actionXML{
+				XMLName: xml.Name{Local: "ShowMessage"},
+				ID:      a.ID,
+				Title:   a.Title,
+				Body:    a.MessageBody,
+			})
+		default:
+			return taskXML{}, fmt.Errorf("error encoding action: unsupported action type %s", action.GetType())
+		}
+	}
+
+	return task, nil
+}
+
+func newSettingsXML(s TaskSettings) settingsXML {
+	settings := settingsXML{
+		DisallowStartIfOnBatteries: &s.DontStartOnBatteries,
+		StopIfGoingOnBatteries:     &s.StopIfGoingOnBatteries,
+		MultipleInstancesPolicy:    instancesPolicyNames[s.MultipleInstances],
+		AllowHardTerminate:         &s.AllowHardTerminate,
+		StartWhenAvailable:         &s.StartWhenAvailable,
+		RunOnlyIfNetworkAvailable:  &s.RunOnlyIfNetworkAvailable,
+		IdleSettings: &idleSettingsXML{
+			Duration:      PeriodToString(s.IdleSettings.IdleDuration),
+			WaitTimeout:   PeriodToString(s.IdleSettings.WaitTimeout),
+			StopOnIdleEnd: &s.IdleSettings.StopOnIdleEnd,
+			RestartOnIdle: &s.IdleSettings.RestartOnIdle,
+		},
+		AllowStartOnDemand:     &s.AllowDemandStart,
+		Enabled:                &s.Enabled,
+		Hidden:                 &s.Hidden,
+		RunOnlyIfIdle:          &s.RunOnlyIfIdle,
+		WakeToRun:              &s.WakeToRun,
+		ExecutionTimeLimit:     periodOrZero(s.TimeLimit),
+		DeleteExpiredTaskAfter: PeriodToString(s.DeleteExpiredTaskAfter),
+		Priority:               &s.Priority,
+
+		UseUnifiedSchedulingEngine:      s.UseUnifiedSchedulingEngine,
+		DisallowStartOnRemoteAppSession: s.DisallowStartOnRemoteAppSession,
+		Volatile:                        s.Volatile,
+	}
+	if s.NetworkSettings != (NetworkSettings{}) {
+		settings.NetworkSettings = &networkSettingsXML{
+			Name: s.NetworkSettings.Name,
+			ID:   s.NetworkSettings.ID,
+		}
+	}
+	if s.RestartCount > 0 {
+		settings.RestartOnFailure = &restartOnFailureXML{
+			Interval: PeriodToString(s.RestartInterval),
+			Count:    s.RestartCount,
+		}
+	}
+	if s.MaintenanceSettings != nil {
+		settings.MaintenanceSettings = &maintenanceSettingsXML{
+			Period:    PeriodToString(s.MaintenanceSettings.Period),
+			Deadline:  PeriodToString(s.MaintenanceSettings.Deadline),
+			Exclusive: s.MaintenanceSettings.Exclusive,
+		}
+	}
+
+	return settings
+}
+
+// periodOrZero is like PeriodToString, but returns "PT0S" for a zero period, which
+// the Task Scheduler service interprets as an unlimited amount of time.
+func periodOrZero(p period.Period) string {
+	if p.IsZero() {
+		return "PT0S"
+	}
+
+	return p.String()
+}
+
+func newTriggerXML(trigger Trigger) (triggerXML, error) {
+	enabled := trigger.GetEnabled()
+	t := triggerXML{
+		ID:                 trigger.GetID(),
+		StartBoundary:      boundaryToTaskDate(trigger.GetStartBoundary(), trigger.GetSynchronizeAcrossTimeZones()),
+		EndBoundary:        boundaryToTaskDate(trigger.GetEndBoundary(), trigger.GetSynchronizeAcrossTimeZones()),
+		ExecutionTimeLimit: PeriodToString(trigger.GetExecutionTimeLimit()),
+		Enabled:            &enabled,
+	}
+	if !trigger.GetRepetitionInterval().IsZero() {
+		t.Repetition = &repetitionXML{
+			Interval:          PeriodToString(trigger.GetRepetitionInterval()),
+			Duration:          PeriodToString(trigger.GetRepetitionDuration()),
+			StopAtDurationEnd: trigger.GetStopAtDurationEnd(),
+		}
+	}
+
+	switch tt := trigger.(type) {
+	case BootTrigger:
+		t.XMLName.Local = "BootTrigger"
+		t.Delay = PeriodToString(tt.Delay)
+	case DailyTrigger:
+		t.XMLName.Local = "CalendarTrigger"
+		t.RandomDelay = PeriodToString(tt.RandomDelay)
+		t.ScheduleByDay = &scheduleByDayXML{
+			DaysInterval: tt.DayInterval,
+		}
+	case EventTrigger:
+		t.XMLName.Local = "EventTrigger"
+		t.Delay = PeriodToString(tt.Delay)
+		t.Subscription = tt.Subscription
+		if len(tt.ValueQueries) > 0 {
+			t.ValueQueries = &valueQueriesXML{}
+			names := make([]string, 0, len(tt.ValueQueries))
+			for name := range tt.ValueQueries {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				t.ValueQueries.Values = append(t.ValueQueries.Values, valueQueryXML{Name: name, Value: tt.ValueQueries[name]})
+			}
+		}
+	case IdleTrigger:
+		t.XMLName.Local = "IdleTrigger"
+	case LogonTrigger:
+		t.XMLName.Local = "LogonTrigger"
+		t.Delay = PeriodToString(tt.Delay)
+		t.UserID = tt.UserID
+	case MonthlyDOWTrigger:
+		t.XMLName.Local = "CalendarTrigger"
+		t.RandomDelay = PeriodToString(tt.RandomDelay)
+		weeks := tt.WeeksOfMonth
+		if tt.RunOnLastWeekOfMonth {
+			weeks |= LastWeek
+		}
+		t.ScheduleByMonthDOW = &scheduleByMonthDOWXML{
+			Weeks:      weeksXML(weeks),
+			DaysOfWeek: daysOfWeekXML(tt.DaysOfWeek),
+			Months:     monthsXML(tt.MonthsOfYear),
+		}
+	case MonthlyTrigger:
+		t.XMLName.Local = "CalendarTrigger"
+		t.RandomDelay = PeriodToString(tt.RandomDelay)
+		days := tt.DaysOfMonth
+		if tt.runsOnLastDayOfMonth() {
+			days |= LastDayOfMonth
+		}
+		t.ScheduleByMonth = &scheduleByMonthXML{
+			DaysOfMonth: daysOfMonthXML(days),
+			Months:      monthsXML(tt.MonthsOfYear),
+		}
+	case RegistrationTrigger:
+		t.XMLName.Local = "RegistrationTrigger"
+		t.Delay = PeriodToString(tt.Delay)
+	case SessionStateChangeTrigger:
+		t.XMLName.Local = "SessionStateChangeTrigger"
+		t.Delay = PeriodToString(tt.Delay)
+		t.UserID = tt.UserID
+		t.StateChange = sessionStateChangeNames[tt.StateChange]
+	case TimeTrigger:
+		t.XMLName.Local = "TimeTrigger"
+		t.RandomDelay = PeriodToString(tt.RandomDelay)
+	case WeeklyTrigger:
+		t.XMLName.Local = "CalendarTrigger"
+		t.RandomDelay = PeriodToString(tt.RandomDelay)
+		t.ScheduleByWeek = &scheduleByWeekXML{
+			DaysOfWeek:    daysOfWeekXML(tt.DaysOfWeek),
+			WeeksInterval: tt.WeekInterval,
+		}
+	default:
+		return triggerXML{}, fmt.Errorf("error encoding trigger: unsupported trigger type %s", trigger.GetType())
+	}
+
+	return t, nil
+}
+
+func (task taskXML) toDefinition() (Definition, error) {
+	var err error
+	var def Definition
+
+	compatibilityFound := false
+	for compatibility, version := range compatibilityVersions {
+		if version == task.Version {
+			def.Settings.Compatibility = compatibility
+			compatibilityFound = true
+		}
+	}
+	if !compatibilityFound {
+		return Definition{}, fmt.Errorf("error decoding definition: unsupported task version %q", task.Version)
+	}
+
+	def.RegistrationInfo = RegistrationInfo{
+		Author:             task.RegistrationInfo.Author,
+		Description:        task.RegistrationInfo.Description,
+		Documentation:      task.RegistrationInfo.Documentation,
+		SecurityDescriptor: task.RegistrationInfo.SecurityDescriptor,
+		Source:             task.RegistrationInfo.Source,
+		URI:                task.RegistrationInfo.URI,
+		Version:            task.RegistrationInfo.Version,
+	}
+	if def.RegistrationInfo.Date, err = TaskDateToTime(task.RegistrationInfo.Date); err != nil {
+		return Definition{}, fmt.Errorf("error decoding registration date: %v", err)
+	}
+
+	principal := task.Principals.Principal
+	def.Principal = Principal{
+		Name:                principal.DisplayName,
+		GroupID:             principal.GroupID,
+		ID:                  principal.ID,
+		UserID:              principal.UserID,
+		ProcessTokenSidType: TASK_PROCESSTOKENSID_DEFAULT,
+	}
+	if principal.ProcessTokenSidType != "" {
+		if def.Principal.ProcessTokenSidType, err = lookupXMLName(processTokenSidTypeNames, principal.ProcessTokenSidType, "ProcessTokenSidType"); err != nil {
+			return Definition{}, err
+		}
+	}
+	if principal.RequiredPrivileges != nil {
+		def.Principal.RequiredPrivileges = principal.RequiredPrivileges.Privileges
+	}
+	if principal.LogonType != "" {
+		if def.Principal.LogonType, err = lookupXMLName(logonTypeNames, principal.LogonType, "LogonType"); err != nil {
+			return Definition{}, err
+		}
+	} else if principal.GroupID != "" {
+		def.Principal.LogonType = TASK_LOGON_GROUP
+	}
+	if principal.RunLevel != "" {
+		if def.Principal.RunLevel, err = lookupXMLName(runLevelNames, principal.RunLevel, "RunLevel"); err != nil {
+			return Definition{}, err
+		}
+	}
+
+	compatibility := def.Settings.Compatibility
+	if def.Settings, err = task.Settings.toTaskSettings(); err != nil {
+		return Definition{}, err
+	}
+	def.Settings.Compatibility = compatibility
+
+	def.Data = task.Data
+	def.Context = task.Actions.Context
+
+	for _, t := range task.Triggers.Triggers {
+		trigger, err := t.toTrigger()
+		if err != nil {
+			return Definition{}, err
+		}
+		def.Triggers = append(def.Triggers, trigger)
+	}
+
+	for _, a := range task.Actions.Actions {
+		switch a.XMLName.Local {
+		case "Exec":
+			def.Actions = append(def.Actions, ExecAction{
+				ID:         a.ID,
+				Path:       a.Command,
+				Args:       a.Arguments,
+				WorkingDir: a.WorkingDirectory,
+			})
+		case "ComHandler":
+			def.Actions = append(def.Actions, ComHandlerAction{
+				ID:      a.ID,
+				ClassID: a.ClassID,
+				Data:    a.Data,
+			})
+		case "SendEmail":
+			emailAction := EmailAction{
+				ID:      a.ID,
+				Server:  a.Server,
+				Subject: a.Subject,
+				To:      a.To,
+				Cc:      a.Cc,
+				Bcc:     a.Bcc,
+				ReplyTo: a.ReplyTo,
+				From:    a.From,
+				Body:    a.Body,
+			}
+			if a.HeaderFields != nil {
+				emailAction.HeaderFields = make(map[string]string, len(a.HeaderFields.Fields))
+				for _, field := range a.HeaderFields.Fields {
+					emailAction.HeaderFields[field.Name] = field.Value
+				}
+			}
+			if a.Attachments != nil {
+				emailAction.Attachments = a.Attachments.Files
+			}
+			def.Actions = append(def.Actions, emailAction)
+		case "ShowMessage":
+			def.Actions = append(def.Actions, ShowMessageAction{
+				ID:          a.ID,
+				Title:       a.Title,
+				MessageBody: a.Body,
+			})
+		default:
+			return Definition{}, fmt.Errorf("error decoding action: unsupported action type %q", a.XMLName.Local)
+		}
+	}
+
+	return def, nil
+}
+
+func (s settingsXML) toTaskSettings() (TaskSettings, error) {
+	var err error
+
+	// elements that are omitted have the defaults of the Task Scheduler schema
+	settings := TaskSettings{
+		AllowDemandStart:          boolOrDefault(s.AllowStartOnDemand, true),
+		AllowHardTerminate:        boolOrDefault(s.AllowHardTerminate, true),
+		DontStartOnBatteries:      boolOrDefault(s.DisallowStartIfOnBatteries, true),
+		Enabled:                   boolOrDefault(s.Enabled, true),
+		Hidden:                    boolOrDefault(s.Hidden, false),
+		MultipleInstances:         TASK_INSTANCES_IGNORE_NEW,
+		Priority:                  7,
+		RunOnlyIfIdle:             boolOrDefault(s.RunOnlyIfIdle, false),
+		RunOnlyIfNetworkAvailable: boolOrDefault(s.RunOnlyIfNetworkAvailable, false),
+		StartWhenAvailable:        boolOrDefault(s.StartWhenAvailable, false),
+		StopIfGoingOnBatteries:    boolOrDefault(s.StopIfGoingOnBatteries, true),
+		WakeToRun:                 boolOrDefault(s.WakeToRun, false),
+		TimeLimit:                 period.NewHMS(72, 0, 0),
+
+		DisallowStartOnRemoteAppSession: s.DisallowStartOnRemoteAppSession,
+		UseUnifiedSchedulingEngine:      s.UseUnifiedSchedulingEngine,
+		Volatile:                        s.Volatile,
+		IdleSettings: IdleSettings{
+			IdleDuration:  period.NewHMS(0, 10, 0),
+			StopOnIdleEnd: true,
+			WaitTimeout:   period.NewHMS(1, 0, 0),
+		},
+	}
+
+	if s.MultipleInstancesPolicy != "" {
+		if settings.MultipleInstances, err = lookupXMLName(instancesPolicyNames, s.MultipleInstancesPolicy, "MultipleInstancesPolicy"); err != nil {
+			return TaskSettings{}, err
+		}
+	}
+	if s.Priority != nil {
+		settings.Priority = *s.Priority
+	}
+	if s.ExecutionTimeLimit != "" {
+		if settings.TimeLimit, err = parseXMLPeriod(s.ExecutionTimeLimit, "ExecutionTimeLimit"); err != nil {
+			return TaskSettings{}, err
+		}
+	}
+	if settings.DeleteExpiredTaskAfter, err = parseXMLPeriod(s.DeleteExpiredTaskAfter, "DeleteExpiredTaskAfter"); err != nil {
+		return TaskSettings{}, err
+	}
+	if s.NetworkSettings != nil {
+		settings.NetworkSettings = NetworkSettings{
+			ID:   s.NetworkSettings.ID,
+			Name: s.NetworkSettings.Name,
+		}
+	}
+	if s.IdleSettings != nil {
+		if s.IdleSettings.Duration != "" {
+			if settings.IdleSettings.IdleDuration, err = parseXMLPeriod(s.IdleSettings.Duration, "IdleSettings.Duration"); err != nil {
+				return TaskSettings{}, err
+			}
+		}
+		if s.IdleSettings.WaitTimeout != "" {
+			if settings.IdleSettings.WaitTimeout, err = parseXMLPeriod(s.IdleSettings.WaitTimeout, "IdleSettings.WaitTimeout"); err != nil {
+				return TaskSettings{}, err
+			}
+		}
+		settings.IdleSettings.StopOnIdleEnd = boolOrDefault(s.IdleSettings.StopOnIdleEnd, true)
+		settings.IdleSettings.RestartOnIdle = boolOrDefault(s.IdleSettings.RestartOnIdle, false)
+	}
+	if s.RestartOnFailure != nil {
+		settings.RestartCount = s.RestartOnFailure.Count
+		if settings.RestartInterval, err = parseXMLPeriod(s.RestartOnFailure.Interval, "RestartOnFailure.Interval"); err != nil {
+			return TaskSettings{}, err
+		}
+	}
+	if s.MaintenanceSettings != nil {
+		settings.MaintenanceSettings = &MaintenanceSettings{
+			Exclusive: s.MaintenanceSettings.Exclusive,
+		}
+		if settings.MaintenanceSettings.Period, err = parseXMLPeriod(s.MaintenanceSettings.Period, "MaintenanceSettings.Period"); err != nil {
+			return TaskSettings{}, err
+		}
+		if settings.MaintenanceSettings.Deadline, err = parseXMLPeriod(s.MaintenanceSettings.Deadline, "MaintenanceSettings.Deadline"); err != nil {
+			return TaskSettings{}, err
+		}
+	}
+
+	return settings, nil
+}
+
+func (t triggerXML) toTrigger() (Trigger, error) {
+	var err error
+
+	taskTrigger := TaskTrigger{
+		Enabled:                    boolOrDefault(t.Enabled, true),
+		ID:                         t.ID,
+		SynchronizeAcrossTimeZones: boundariesHaveTimeZone(t.StartBoundary, t.EndBoundary),
+	}
+	if taskTrigger.StartBoundary, err = TaskDateToTime(t.StartBoundary); err != nil {
+		return nil, fmt.Errorf("error decoding %s: invalid StartBoundary: %v", t.XMLName.Local, err)
+	}
+	if taskTrigger.EndBoundary, err = TaskDateToTime(t.EndBoundary); err != nil {
+		return nil, fmt.Errorf("error decoding %s: invalid EndBoundary: %v", t.XMLName.Local, err)
+	}
+	if taskTrigger.ExecutionTimeLimit, err = parseXMLPeriod(t.ExecutionTimeLimit, "ExecutionTimeLimit"); err != nil {
+		return nil, err
+	}
+	if t.Repetition != nil {
+		taskTrigger.StopAtDurationEnd = t.Repetition.StopAtDurationEnd
+		if taskTrigger.RepetitionInterval, err = parseXMLPeriod(t.Repetition.Interval, "Repetition.Interval"); err != nil {
+			return nil, err
+		}
+		if taskTrigger.RepetitionDuration, err = parseXMLPeriod(t.Repetition.Duration, "Repetition.Duration"); err != nil {
+			return nil, err
+		}
+	}
+
+	delay, err := parseXMLPeriod(t.Delay, "Delay")
+	if err != nil {
+		return nil, err
+	}
+	randomDelay, err := parseXMLPeriod(t.RandomDelay, "RandomDelay")
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.XMLName.Local {
+	case "BootTrigger":
+		return BootTrigger{TaskTrigger: taskTrigger, Delay: delay}, nil
+	case "CalendarTrigger":
+		switch {
+		case t.ScheduleByDay != nil:
+			return DailyTrigger{
+				TaskTrigger: taskTrigger,
+				DayInterval: t.ScheduleByDay.DaysInterval,
+				RandomDelay: randomDelay,
+			}, nil
+		case t.ScheduleByWeek != nil:
+			return WeeklyTrigger{
+				TaskTrigger:  taskTrigger,
+				DaysOfWeek:   DayOfWeek(t.ScheduleByWeek.DaysOfWeek),
+				RandomDelay:  randomDelay,
+				WeekInterval: t.ScheduleByWeek.WeeksInterval,
+			}, nil
+		case t.ScheduleByMonth != nil:
+			days := DayOfMonth(t.ScheduleByMonth.DaysOfMonth)
+			return MonthlyTrigger{
+				TaskTrigger:         taskTrigger,
+				DaysOfMonth:         days &^ LastDayOfMonth,
+				MonthsOfYear:        Month(t.ScheduleByMonth.Months),
+				RandomDelay:         randomDelay,
+				RunOnLastDayOfMonth: days&LastDayOfMonth != 0,
+			}, nil
+		case t.ScheduleByMonthDOW != nil:
+			weeks := Week(t.ScheduleByMonthDOW.Weeks)
+			return MonthlyDOWTrigger{
+				TaskTrigger:          taskTrigger,
+				DaysOfWeek:           DayOfWeek(t.ScheduleByMonthDOW.DaysOfWeek),
+				MonthsOfYear:         Month(t.ScheduleByMonthDOW.Months),
+				RandomDelay:          randomDelay,
+				RunOnLastWeekOfMonth: weeks&LastWeek != 0,
+				WeeksOfMonth:         weeks &^ LastWeek,
+			}, nil
+		default:
+			return nil, fmt.Errorf("error decoding CalendarTrigger: unsupported schedule")
+		}
+	case "EventTrigger":
+		trigger := EventTrigger{
+			TaskTrigger:  taskTrigger,
+			Delay:        delay,
+			Subscription: t.Subscription,
+		}
+		if t.ValueQueries != nil {
+			trigger.ValueQueries = make(map[string]string, len(t.ValueQueries.Values))
+			for _, value := range t.ValueQueries.Values {
+				trigger.ValueQueries[value.Name] = value.Value
+			}
+		}
+		return trigger, nil
+	case "IdleTrigger":
+		return IdleTrigger{TaskTrigger: taskTrigger}, nil
+	case "LogonTrigger":
+		return LogonTrigger{TaskTrigger: taskTrigger, Delay: delay, UserID: t.UserID}, nil
+	case "RegistrationTrigger":
+		return RegistrationTrigger{TaskTrigger: taskTrigger, Delay: delay}, nil
+	case "SessionStateChangeTrigger":
+		stateChange, err := lookupXMLName(sessionStateChangeNames, t.StateChange, "StateChange")
+		if err != nil {
+			return nil, err
+		}
+		return SessionStateChangeTrigger{
+			TaskTrigger: taskTrigger,
+			Delay:       delay,
+			StateChange: stateChange,
+			UserID:      t.UserID,
+		}, nil
+	case "TimeTrigger":
+		return TimeTrigger{TaskTrigger: taskTrigger, RandomDelay: randomDelay}, nil
+	default:
+		return nil, fmt.Errorf("error decoding trigger: unsupported trigger type %q", t.XMLName.Local)
+	}
+}
+
+func lookupXMLName[T comparable](names map[T]string, name, element string) (T, error) {
+	for value, valueName := range names {
+		if valueName == name {
+			return value, nil
+		}
+	}
+
+	var zero T
+	return zero, fmt.Errorf("error decoding definition: invalid %s %q", element, name)
+}
+
+func parseXMLPeriod(s, element string) (period.Period, error) {
+	p, err := StringToPeriod(s)
+	if err != nil {
+		return period.Period{}, fmt.Errorf("error decoding definition: invalid %s %q: %v", element, s, err)
+	}
+
+	return p, nil
+}
+
+func boolOrDefault(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+
+	return *b
+}
+
+// The days, weeks and months of calendar triggers are bit fields, which the
+// Task Scheduler schema represents as a list of elements.
+
+type daysOfWeekXML DayOfWeek
+
+func (d daysOfWeekXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalFlagElements(e, start, uint32(d), dayOfWeekNames)
+}
+
+func (d *daysOfWeekXML) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	flags, err := unmarshalFlagElements(dec, dayOfWeekNames)
+	*d = daysOfWeekXML(flags)
+	return err
+}
+
+type monthsXML Month
+
+func (m monthsXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalFlagElements(e, start, uint32(m), monthNames)
+}
+
+func (m *monthsXML) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	flags, err := unmarshalFlagElements(dec, monthNames)
+	*m = monthsXML(flags)
+	return err
+}
+
+type weeksXML Week
+
+var weekValues = []string{"1", "2", "3", "4", "Last"}
+
+func (w weeksXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalFlagValues(e, start, "Week", uint32(w), weekValues)
+}
+
+func (w *weeksXML) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	flags, err := unmarshalFlagValues(dec, weekValues)
+	*w = weeksXML(flags)
+	return err
+}
+
+type daysOfMonthXML DayOfMonth
+
+var dayOfMonthValues = func() []string {
+	values := make([]string, 32)
+	for i := 0; i < 31; i++ {
+		values[i] = strconv.Itoa(i + 1)
+	}
+	values[31] = "Last"
+
+	return values
+}()
+
+func (d daysOfMonthXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalFlagValues(e, start, "Day", uint32(d), dayOfMonthValues)
+}
+
+func (d *daysOfMonthXML) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	flags, err := unmarshalFlagValues(dec, dayOfMonthValues)
+	*d = daysOfMonthXML(flags)
+	return err
+}
+
+// marshalFlagElements encodes flags as an element containing an empty element
+// named names[i] for every bit i that is set.
+func marshalFlagElements(e *xml.Encoder, start xml.StartElement, flags uint32, names []string) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i, name := range names {
+		if flags&(1<<uint(i)) != 0 {
+			elem := xml.StartElement{Name: xml.Name{Local: name}}
+			if err := e.EncodeToken(elem); err != nil {
+				return err
+			}
+			if err := e.EncodeToken(elem.End()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func unmarshalFlagElements(dec *xml.Decoder, names []string) (uint32, error) {
+	var flags uint32
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+
+		switch elem := token.(type) {
+		case xml.StartElement:
+			found := false
+			for i, name := range names {
+				if elem.Name.Local == name {
+					flags |= 1 << uint(i)
+					found = true
+				}
+			}
+			if !found {
+				return 0, fmt.Errorf("error decoding definition: unexpected element %s", elem.Name.Local)
+			}
+			if err := dec.Skip(); err != nil {
+				return 0, err
+			}
+		case xml.EndElement:
+			return flags, nil
+		}
+	}
+}
+
+// marshalFlagValues encodes flags as an element containing an element named
+// elemName with the value values[i] for every bit i that is set.
+func marshalFlagValues(e *xml.Encoder, start xml.StartElement, elemName string, flags uint32, values []string) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i, value := range values {
+		if flags&(1<<uint(i)) != 0 {
+			if err := e.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: elemName}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func unmarshalFlagValues(dec *xml.Decoder, values []string) (uint32, error) {
+	var flags uint32
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+
+		switch elem := token.(type) {
+		case xml.StartElement:
+			var value string
+			if err := dec.DecodeElement(&value, &elem); err != nil {
+				return 0, err
+			}
+			found := false
+			for i, v := range values {
+				if value == v {
+					flags |= 1 << uint(i)
+					found = true
+				}
+			}
+			if !found {
+				return 0, fmt.Errorf("error decoding definition: invalid %s %q", elem.Name.Local, value)
+			}
+		case xml.EndElement:
+			return flags, nil
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package taskmaster
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/date/period"
+)
+
+func TestDefinitionXML(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 8, 0, 0, 0, time.UTC)
+
+	def := Definition{
+		Context: "Author",
+		Principal: Principal{
+			ID:                  "Author",
+			LogonType:           TASK_LOGON_INTERACTIVE_TOKEN,
+			RunLevel:            TASK_RUNLEVEL_HIGHEST,
+			ProcessTokenSidType: TASK_PROCESSTOKENSID_UNRESTRICTED,
+			RequiredPrivileges:  []string{"SeBackupPrivilege", "SeRestorePrivilege"},
+		},
+		RegistrationInfo: RegistrationInfo{
+			Author: "taskmaster",
+			Date:   start,
+		},
+		Settings: TaskSettings{
+			AllowDemandStart:   true,
+			AllowHardTerminate: true,
+			Compatibility:      TASK_COMPATIBILITY_V2_1,
+			Enabled:            true,
+			IdleSettings: IdleSettings{
+				IdleDuration:  period.NewHMS(0, 10, 0),
+				StopOnIdleEnd: true,
+				WaitTimeout:   period.NewHMS(1, 0, 0),
+			},
+			MultipleInstances: TASK_INSTANCES_QUEUE,
+			Priority:          7,
+			RestartCount:      3,
+			RestartInterval:   period.NewHMS(0, 5, 0),
+			TimeLimit:         period.NewHMS(72, 0, 0),
+			MaintenanceSettings: &MaintenanceSettings{
+				Period: period.NewYMD(0, 0, 1),
+			},
+			DisallowStartOnRemoteAppSession: true,
+			UseUnifiedSchedulingEngine:      true,
+			Volatile:                        true,
+		},
+	}
+	def.AddAction(ExecAction{
+		ID:   "exec",
+		Path: "cmd.exe",
+		Args: "/c timeout $(Arg0)",
+	})
+	def.AddAction(ComHandlerAction{
+		ClassID: "{F0001111-0000-0000-0000-0000FEEDACDC}",
+	})
+	def.AddAction(EmailAction{
+		Server:       "smtp.example.com",
+		Subject:      "Task ran",
+		To:           "admin@example.com",
+		From:         "tasks@example.com",
+		HeaderFields: map[string]string{"X-Priority": "1", "X-Mailer": "Taskmaster"},
+		Body:         "The task ran.",
+		Attachments:  []string{`C:\logs\task.log`},
+	})
+	def.AddAction(ShowMessageAction{
+		Title:       "Task ran",
+		MessageBody: "The task ran.",
+	})
+	def.AddTrigger(mustTrigger(NewBootTrigger(period.Period{})))
+	def.AddTrigger(mustTrigger(NewDailyTrigger(start, EveryDay, RepetitionPattern{
+		RepetitionDuration: period.NewHMS(1, 0, 0),
+		RepetitionInterval: period.NewHMS(0, 5, 0),
+	})))
+	def.AddTrigger(mustTrigger(NewWeeklyTrigger(start, EveryOtherWeek, Monday|Friday)))
+	def.AddTrigger(MonthlyTrigger{
+		TaskTrigger:         TaskTrigger{Enabled: true, StartBoundary: start},
+		DaysOfMonth:         1 | 1<<14,
+		MonthsOfYear:        January | July,
+		RunOnLastDayOfMonth: true,
+	})
+	def.AddTrigger(MonthlyDOWTrigger{
+		TaskTrigger:          TaskTrigger{Enabled: true, StartBoundary: start},
+		DaysOfWeek:           Sunday,
+		MonthsOfYear:         AllMonths,
+		RunOnLastWeekOfMonth: true,
+		WeeksOfMonth:         First,
+	})
+	def.AddTrigger(EventTrigger{
+		TaskTrigger:  TaskTrigger{Enabled: true},
+		Subscription: "<QueryList></QueryList>",
+		ValueQueries: map[string]string{"id": "Event/System/EventID"},
+	})
+	def.AddTrigger(SessionStateChangeTrigger{
+		TaskTrigger: TaskTrigger{Enabled: true},
+		StateChange: TASK_SESSION_LOCK,
+	})
+
+	data, err := xml.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `<Task xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task" version="1.3">`) {
+		t.Fatalf("unexpected root element: %s", data)
+	}
+
+	var decodedDef Definition
+	if err = xml.Unmarshal(data, &decodedDef); err != nil {
+		t.Fatal(err)
+	}
+	// the decoded triggers are normalized, so compare them separately
+	if !reflect.DeepEqual(def.Triggers, decodedDef.Triggers) {
+		t.Fatalf("triggers weren't decoded correctly:\n%+v\n%+v\n%s", def.Triggers, decodedDef.Triggers, data)
+	}
+	if !reflect.DeepEqual(def, decodedDef) {
+		t.Fatalf("definition wasn't decoded correctly:\n%+v\n%+v\n%s", def, decodedDef, data)
+	}
+
+	// omitted elements get the defaults of the Task Scheduler service
+	minimal := `<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <Actions Context="Author">
+    <Exec>
+      <Command>notepad.exe</Command>
+    </Exec>
+  </Actions>
+</Task>`
+	if err = xml.Unmarshal([]byte(minimal), &decodedDef); err != nil {
+		t.Fatal(err)
+	}
+	if decodedDef.Settings.Compatibility != TASK_COMPATIBILITY_V2 || !decodedDef.Settings.Enabled ||
+		decodedDef.Settings.Priority != 7 || decodedDef.Settings.MultipleInstances != TASK_INSTANCES_IGNORE_NEW {
+		t.Fatalf("defaults weren't set correctly: %+v", decodedDef.Settings)
+	}
+	if len(decodedDef.Actions) != 1 || decodedDef.Actions[0].(ExecAction).Path != "notepad.exe" {
+		t.Fatalf("actions weren't decoded correctly: %+v", decodedDef.Actions)
+	}
+
+	if err = xml.Unmarshal([]byte(`<Task version="1.2"><Triggers><HourlyTrigger/></Triggers></Task>`), &decodedDef); err == nil {
+		t.Fatal("decoding an unknown trigger type should fail")
+	}
+	if _, err = xml.Marshal(Definition{Triggers: []Trigger{CustomTrigger{}}}); err == nil {
+		t.Fatal("encoding a custom trigger should fail")
+	}
+}
+
+func TestTriggerBoundaryTimeZones(t *testing.T) {
+	taskXML := `<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <Triggers>
+    <TimeTrigger>
+      <StartBoundary>2021-01-01T03:00:00+02:00</StartBoundary>
+      <EndBoundary>2021-01-02T03:00:00+02:00</EndBoundary>
+    </TimeTrigger>
+    <TimeTrigger>
+      <StartBoundary>2021-01-01T03:00:00</StartBoundary>
+    </TimeTrigger>
+    <TimeTrigger>
+      <StartBoundary>2021-01-01T03:00:00Z</StartBoundary>
+    </TimeTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>notepad.exe</Command>
+    </Exec>
+  </Actions>
+</Task>`
+
+	var def Definition
+	if err := xml.Unmarshal([]byte(taskXML), &def); err != nil {
+		t.Fatal(err)
+	}
+	for i, synchronized := range []bool{true, false, true} {
+		if def.Triggers[i].GetSynchronizeAcrossTimeZones() != synchronized {
+			t.Errorf("trigger %d: expected SynchronizeAcrossTimeZones to be %t", i, synchronized)
+		}
+	}
+
+	// the boundaries must be encoded as they were decoded
+	data, err := xml.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, boundary := range []string{
+		"<StartBoundary>2021-01-01T03:00:00+02:00</StartBoundary>",
+		"<EndBoundary>2021-01-02T03:00:00+02:00</EndBoundary>",
+		"<StartBoundary>2021-01-01T03:00:00</StartBoundary>",
+		"<StartBoundary>2021-01-01T03:00:00Z</StartBoundary>",
+	} {
+		if !strings.Contains(string(data), boundary) {
+			t.Errorf("expected %s in the encoded XML:\n%s", boundary, data)
+		}
+	}
+
+	// a local time is written with its UTC offset if the trigger synchronizes
+	// across time zones
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	trigger := mustTrigger(NewTimeTrigger(time.Date(2021, time.January, 1, 3, 0, 0, 0, zone)))
+	if boundary := boundaryToTaskDate(trigger.StartBoundary, trigger.SynchronizeAcrossTimeZones); boundary != "2021-01-01T03:00:00" {
+		t.Fatalf("expected a local time, got %s", boundary)
+	}
+	trigger.SynchronizeAcrossTimeZones = true
+	if boundary := boundaryToTaskDate(trigger.StartBoundary, trigger.SynchronizeAcrossTimeZones); boundary != "2021-01-01T03:00:00-05:00" {
+		t.Fatalf("expected a time with a UTC offset, got %s", boundary)
+	}
+}
+
+func TestTriggerNegativeUTCOffsetRoundTrip(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	start := time.Date(2021, time.January, 1, 3, 0, 0, 0, zone)
+	trigger := mustTrigger(NewTimeTrigger(start))
+	trigger.EndBoundary = start.AddDate(0, 1, 0)
+	trigger.SynchronizeAcrossTimeZones = true
+
+	def := defaultDefinition()
+	def.AddAction(ExecAction{Path: "cmd.exe"})
+	def.AddTrigger(trigger)
+	data, err := xml.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decodedDef Definition
+	if err = xml.Unmarshal(data, &decodedDef); err != nil {
+		t.Fatal(err)
+	}
+	decoded := decodedDef.Triggers[0]
+	if !decoded.GetSynchronizeAcrossTimeZones() {
+		t.Error("expected the decoded trigger to synchronize across time zones")
+	}
+	for _, boundary := range [][2]time.Time{{decoded.GetStartBoundary(), trigger.StartBoundary}, {decoded.GetEndBoundary(), trigger.EndBoundary}} {
+		if _, offset := boundary[0].Zone(); !boundary[0].Equal(boundary[1]) || offset != -5*60*60 {
+			t.Errorf("expected boundary %s, got %s", boundary[1], boundary[0])
+		}
+	}
+}